@@ -2,26 +2,56 @@ package main
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"strconv"
+	"sync"
 	"time"
 )
 
+// Binding calls give up on their queries after callTimeout, or exportTimeout for those
+// that copy or export the whole database. On exit, the app waits up to shutdownTimeout
+// for cancelled calls to return before closing the database.
+const (
+	callTimeout     = 30 * time.Second
+	exportTimeout   = 10 * time.Minute
+	shutdownTimeout = 5 * time.Second
+)
+
 // Product represents a product in our system
 type Product struct {
-	ID          string  `json:"id"`
-	Name        string  `json:"name"`
-	Price       float64 `json:"price"`
-	Description string  `json:"description"`
-	Status      string  `json:"status"`
+	ID           string           `json:"id"`
+	Name         string           `json:"name"`
+	Price        float64          `json:"price"`
+	Description  string           `json:"description"`
+	Status       string           `json:"status"`
+	SKU          string           `json:"sku"`
+	Barcode      string           `json:"barcode"`
+	Category     string           `json:"category"`
+	Tags         []string         `json:"tags"`
+	Image        string           `json:"image"`
+	ImageURL     string           `json:"imageUrl"`
+	ThumbnailURL string           `json:"thumbnailUrl"`
+	Variants     []ProductVariant `json:"variants"`
 }
 
 // OrderItem represents a product in an order with quantity
 type OrderItem struct {
+	ID          string  `json:"id,omitempty"`
 	ProductID   string  `json:"productId"`
+	VariantID   string  `json:"variantId"`
 	ProductName string  `json:"productName"`
 	Price       float64 `json:"price"`
 	Quantity    int     `json:"quantity"`
+	// Description, SKU and ListPrice are copied from the product or variant when the line
+	// is created. A Price other than ListPrice needs a reason code in OverrideReason.
+	Description    string  `json:"description"`
+	SKU            string  `json:"sku"`
+	ListPrice      float64 `json:"listPrice"`
+	OverrideReason string  `json:"overrideReason"`
+	// Shipped and Remaining are derived from the order's shipments, Returned from its returns
+	Shipped   int `json:"shipped"`
+	Remaining int `json:"remaining"`
+	Returned  int `json:"returned"`
 }
 
 // Order represents a customer order
@@ -33,6 +63,14 @@ type Order struct {
 	Items       []OrderItem `json:"items"`
 	Total       float64     `json:"total"`
 	Status      string      `json:"status"`
+	// Refunded is the sum of the refunds given on the order's returns. RefundsPaidOut is
+	// the part of it handed back to the customer; the rest came off what they still owed.
+	Refunded       float64 `json:"refunded"`
+	RefundsPaidOut float64 `json:"refundsPaidOut"`
+	// Paid, BalanceDue and PaymentStatus are derived from the order's payments and refunds
+	Paid          float64 `json:"paid"`
+	BalanceDue    float64 `json:"balanceDue"`
+	PaymentStatus string  `json:"paymentStatus"`
 }
 
 // StockItem represents an item in the inventory
@@ -41,39 +79,185 @@ type StockItem struct {
 	Name        string  `json:"name"`
 	Description string  `json:"description"`
 	Quantity    float64 `json:"quantity"`
+	SKU         string  `json:"sku"`
+	Barcode     string  `json:"barcode"`
+	// Quantity is the total over all locations; Locations lists where it is held
+	Locations []StockLevel `json:"locations"`
+	// Reserved is what open orders still have to ship, and Available what is left
+	// for new orders. Both are worked out when the item is read.
+	Reserved  float64 `json:"reserved"`
+	Available float64 `json:"available"`
 }
 
 // App struct
 type App struct {
 	ctx context.Context
-	db  *Database
+
+	// repos hold the products, orders and stock. db is the SQLite database behind the
+	// remaining features, such as accounts and categories; it is nil in tests that run
+	// the App on in-memory repositories, and while the app is in recovery mode.
+	// Recovery replaces both, so they are read through stores.
+	dbMu   sync.RWMutex
+	repos  Repositories
+	db     *Database
+	images *ImageStore
+	log    *slog.Logger
+
+	// files are the database file and its backups. In recovery mode, dbErr is why the
+	// database could not be opened and dbLog the logger to open it with.
+	files           databaseFiles
+	dbErr           error
+	dbLog           *slog.Logger
+	recoveryMessage string
+	recoverMu       sync.Mutex
+
+	// logPath is the log file read back by GetRecentLogs
+	logPath string
+
+	// background lives from startup to shutdown; stopBackground ends it, and with it
+	// the event forwarding, the clock and the queries of binding calls still running.
+	// calls counts those binding calls, so shutdown can wait for them.
+	background     context.Context
+	stopBackground context.CancelFunc
+	calls          sync.WaitGroup
+
+	// user is the account logged in to the desktop window
+	userMu sync.RWMutex
+	user   User
 }
 
-// NewApp creates a new App application struct
-func NewApp() *App {
-	// Initialize the database
-	db, err := NewDatabase()
-	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+// NewApp creates a new App application struct around its stores. db may be nil when
+// repos are not backed by SQLite, which leaves the features without a repository
+// unavailable. A nil logger logs to the default logger.
+func NewApp(repos Repositories, db *Database, images *ImageStore, logger *slog.Logger, logPath string) *App {
+	files := defaultDatabaseFiles()
+	if db != nil {
+		files.path = db.path
 	}
 
 	return &App{
-		db: db,
+		repos:   repos,
+		db:      db,
+		images:  images,
+		log:     logger,
+		logPath: logPath,
+		files:   files,
+	}
+}
+
+// logger returns the logger of the App, or the default logger for an App built without one
+func (a *App) logger() *slog.Logger {
+	if a.log == nil {
+		return slog.Default()
+	}
+	return a.log
+}
+
+// call starts a binding call. The returned logger and database tag every message with
+// the binding name and a correlation ID, so the messages of one call can be found together.
+// Queries run with the returned context give up after callTimeout, or when the app shuts
+// down; done must be called when the binding returns.
+func (a *App) call(binding string) (ctx context.Context, logger *slog.Logger, db *Database, done func()) {
+	return a.callWithTimeout(binding, callTimeout)
+}
+
+// callWithTimeout starts a binding call like call, whose queries may run for timeout
+func (a *App) callWithTimeout(binding string, timeout time.Duration) (context.Context, *slog.Logger, *Database, func()) {
+	ctx, done := a.callContext(timeout)
+	logger, tags := a.begin(binding)
+	_, db := a.stores()
+	return ctx, logger, db.with(tags...), done
+}
+
+// callRepositories starts a binding call that works on products, orders or stock,
+// tagging the messages of the returned logger and repositories like call does
+func (a *App) callRepositories(binding string) (context.Context, *slog.Logger, Repositories, func()) {
+	ctx, done := a.callContext(callTimeout)
+	logger, tags := a.begin(binding)
+	repos, _ := a.stores()
+	return ctx, logger, repos.with(tags...), done
+}
+
+// callContext returns the context of a binding call, which ends after timeout or when
+// the app shuts down, and the function that releases it and reports the call finished
+func (a *App) callContext(timeout time.Duration) (context.Context, func()) {
+	a.dbMu.RLock()
+	parent := a.background
+	a.dbMu.RUnlock()
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	a.calls.Add(1)
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	return ctx, func() {
+		cancel()
+		a.calls.Done()
 	}
 }
 
+// stores returns the App's current repositories and database
+func (a *App) stores() (Repositories, *Database) {
+	a.dbMu.RLock()
+	defer a.dbMu.RUnlock()
+	return a.repos, a.db
+}
+
+// begin returns the tags identifying a new binding call and a logger that adds them
+func (a *App) begin(binding string) (*slog.Logger, []any) {
+	tags := []any{"call", binding, "call_id", newCorrelationID()}
+	logger := a.logger().With(tags...)
+	logger.Debug("Binding called")
+	return logger, tags
+}
+
 // startup is called when the app starts. The context is saved
 // so we can call the runtime methods
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
+
+	// Push data changes and the clock to the frontend instead of having it poll, and
+	// generate scheduled orders while the app is open
+	background, cancel := context.WithCancel(ctx)
+	a.stopBackground = cancel
+
+	a.dbMu.Lock()
+	a.background = background
+	db := a.db
+	a.dbMu.Unlock()
+
+	go a.runClock(background)
+	go a.runScheduler(background)
+
+	// In recovery mode there is no database yet, and useDatabase does this once one
+	// has been opened
+	if db != nil {
+		a.databaseOpened(background, db)
+	}
 }
 
-// shutdown is called when the app is closing
+// shutdown is called when the app is closing. It cancels the queries of binding calls
+// still running and gives them shutdownTimeout to return before closing the database.
 func (a *App) shutdown(ctx context.Context) {
+	if a.stopBackground != nil {
+		a.stopBackground()
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		a.calls.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(shutdownTimeout):
+		a.logger().Warn("Binding calls still running at shutdown", "waited", shutdownTimeout)
+	}
+
 	// Close the database connection
-	if a.db != nil {
-		if err := a.db.Close(); err != nil {
-			log.Printf("Error closing database: %v", err)
+	if _, db := a.stores(); db != nil {
+		if err := db.Close(); err != nil {
+			a.logger().Error("Error closing database", "err", err)
 		}
 	}
 }
@@ -87,13 +271,16 @@ func (a *App) GetCurrentTime() string {
 
 // DatabaseStatus returns the status of the database connection
 func (a *App) DatabaseStatus() string {
-	if a.db == nil {
+	if repos, _ := a.stores(); repos.Products == nil {
 		return "Database not initialized"
 	}
+	ctx, logger, repos, done := a.callRepositories("DatabaseStatus")
+	defer done()
 
 	// Test the database connection by getting the count of products
-	products, err := a.db.GetProducts()
+	products, err := repos.Products.GetProducts(ctx)
 	if err != nil {
+		logger.Error("Error checking database status", "err", err)
 		return "Database error: " + err.Error()
 	}
 
@@ -102,9 +289,12 @@ func (a *App) DatabaseStatus() string {
 
 // GetProducts returns all products
 func (a *App) GetProducts() []Product {
-	products, err := a.db.GetProducts()
+	ctx, logger, repos, done := a.callRepositories("GetProducts")
+	defer done()
+
+	products, err := repos.Products.GetProducts(ctx)
 	if err != nil {
-		log.Printf("Error getting products: %v", err)
+		logger.Error("Error getting products", "err", err)
 		return []Product{}
 	}
 	return products
@@ -112,9 +302,17 @@ func (a *App) GetProducts() []Product {
 
 // AddProduct adds a new product
 func (a *App) AddProduct(product Product) bool {
-	_, err := a.db.AddProduct(product)
+	ctx, logger, repos, done := a.callRepositories("AddProduct")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error adding product", "err", err)
+		return false
+	}
+
+	_, err := repos.Products.AddProduct(ctx, product)
 	if err != nil {
-		log.Printf("Error adding product: %v", err)
+		logger.Error("Error adding product", "err", err)
 		return false
 	}
 	return true
@@ -122,9 +320,17 @@ func (a *App) AddProduct(product Product) bool {
 
 // UpdateProduct updates an existing product
 func (a *App) UpdateProduct(updatedProduct Product) bool {
-	err := a.db.UpdateProduct(updatedProduct)
+	ctx, logger, repos, done := a.callRepositories("UpdateProduct")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error updating product", "err", err)
+		return false
+	}
+
+	err := repos.Products.UpdateProduct(ctx, updatedProduct)
 	if err != nil {
-		log.Printf("Error updating product: %v", err)
+		logger.Error("Error updating product", "err", err)
 		return false
 	}
 	return true
@@ -132,21 +338,42 @@ func (a *App) UpdateProduct(updatedProduct Product) bool {
 
 // DeleteProduct removes a product by ID
 func (a *App) DeleteProduct(id string) bool {
-	log.Printf("DeleteProduct called with ID: %s", id)
-	err := a.db.DeleteProduct(id)
+	ctx, logger, repos, done := a.callRepositories("DeleteProduct")
+	defer done()
+
+	if err := a.authorize(RoleAdmin); err != nil {
+		logger.Error("Error deleting product", "err", err)
+		return false
+	}
+
+	logger.Info("Deleting product", "id", id)
+	image, err := repos.Products.GetProductImage(ctx, id)
 	if err != nil {
-		log.Printf("Error deleting product: %v", err)
+		logger.Error("Error getting product image", "err", err)
+	}
+
+	err = repos.Products.DeleteProduct(ctx, id)
+	if err != nil {
+		logger.Error("Error deleting product", "err", err)
 		return false
 	}
-	log.Printf("Product with ID %s deleted successfully", id)
+	logger.Info("Product deleted", "id", id)
+
+	// Remove the image files once no other product shares them
+	if image != "" {
+		a.releaseImage(ctx, logger, repos.Products, image)
+	}
 	return true
 }
 
 // GetOrders returns all orders
 func (a *App) GetOrders() []Order {
-	orders, err := a.db.GetOrders()
+	ctx, logger, repos, done := a.callRepositories("GetOrders")
+	defer done()
+
+	orders, err := repos.Orders.GetOrders(ctx)
 	if err != nil {
-		log.Printf("Error getting orders: %v", err)
+		logger.Error("Error getting orders", "err", err)
 		return []Order{}
 	}
 	return orders
@@ -154,9 +381,12 @@ func (a *App) GetOrders() []Order {
 
 // GetProductByID returns a product by its ID
 func (a *App) GetProductByID(id string) *Product {
-	products, err := a.db.GetProducts()
+	ctx, logger, repos, done := a.callRepositories("GetProductByID")
+	defer done()
+
+	products, err := repos.Products.GetProducts(ctx)
 	if err != nil {
-		log.Printf("Error getting products: %v", err)
+		logger.Error("Error getting products", "err", err)
 		return nil
 	}
 
@@ -168,39 +398,63 @@ func (a *App) GetProductByID(id string) *Product {
 	return nil
 }
 
+// validateNewOrder checks the fields required to create an order
+func validateNewOrder(name string, items []OrderItem) error {
+	if len(items) == 0 {
+		return invalidf("cannot create an order with no items")
+	}
+
+	if name == "" {
+		return invalidf("cannot create an order without a name")
+	}
+
+	return nil
+}
+
 // CreateOrder creates a new order with the given items
 func (a *App) CreateOrder(order struct {
 	Name        string      `json:"name"`
 	Description string      `json:"description"`
 	Items       []OrderItem `json:"items"`
 }) bool {
-	if len(order.Items) == 0 {
-		log.Println("Cannot create an order with no items")
+	ctx, logger, repos, done := a.callRepositories("CreateOrder")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error creating order", "err", err)
 		return false
 	}
 
-	if order.Name == "" {
-		log.Println("Cannot create an order without a name")
+	if err := validateNewOrder(order.Name, order.Items); err != nil {
+		logger.Warn("Rejected order", "err", err)
 		return false
 	}
 
-	log.Printf("Creating order with name: %s, description: %s, items count: %d", order.Name, order.Description, len(order.Items))
+	logger.Info("Creating order", "items", len(order.Items))
 
-	orderID, err := a.db.CreateOrder(order.Name, order.Description, order.Items)
+	orderID, err := repos.Orders.CreateOrder(ctx, order.Name, order.Description, order.Items)
 	if err != nil {
-		log.Printf("Error creating order: %v", err)
+		logger.Error("Error creating order", "err", err)
 		return false
 	}
 
-	log.Printf("Order created successfully with ID: %s", orderID)
+	logger.Info("Order created", "id", orderID)
 	return true
 }
 
 // UpdateOrderStatus updates the status of an order
 func (a *App) UpdateOrderStatus(orderID string, status string) bool {
-	err := a.db.UpdateOrderStatus(orderID, status)
+	ctx, logger, repos, done := a.callRepositories("UpdateOrderStatus")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error updating order status", "err", err)
+		return false
+	}
+
+	err := repos.Orders.UpdateOrderStatus(ctx, orderID, status)
 	if err != nil {
-		log.Printf("Error updating order status: %v", err)
+		logger.Error("Error updating order status", "err", err)
 		return false
 	}
 	return true
@@ -208,21 +462,32 @@ func (a *App) UpdateOrderStatus(orderID string, status string) bool {
 
 // DeleteOrder removes an order by ID
 func (a *App) DeleteOrder(id string) bool {
-	log.Printf("DeleteOrder called with ID: %s", id)
-	err := a.db.DeleteOrder(id)
+	ctx, logger, repos, done := a.callRepositories("DeleteOrder")
+	defer done()
+
+	if err := a.authorize(RoleAdmin); err != nil {
+		logger.Error("Error deleting order", "err", err)
+		return false
+	}
+
+	logger.Info("Deleting order", "id", id)
+	err := repos.Orders.DeleteOrder(ctx, id)
 	if err != nil {
-		log.Printf("Error deleting order: %v", err)
+		logger.Error("Error deleting order", "err", err)
 		return false
 	}
-	log.Printf("Order with ID %s deleted successfully", id)
+	logger.Info("Order deleted", "id", id)
 	return true
 }
 
 // GetStockItems retrieves all stock items from the database
 func (a *App) GetStockItems() []StockItem {
-	items, err := a.db.GetStockItems()
+	ctx, logger, repos, done := a.callRepositories("GetStockItems")
+	defer done()
+
+	items, err := repos.Stock.GetStockItems(ctx)
 	if err != nil {
-		log.Printf("Error getting stock items: %v", err)
+		logger.Error("Error getting stock items", "err", err)
 		return []StockItem{}
 	}
 	return items
@@ -230,9 +495,17 @@ func (a *App) GetStockItems() []StockItem {
 
 // AddStockItem adds a new stock item to the database
 func (a *App) AddStockItem(item StockItem) StockItem {
-	savedItem, err := a.db.AddStockItem(item)
+	ctx, logger, repos, done := a.callRepositories("AddStockItem")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error adding stock item", "err", err)
+		return StockItem{}
+	}
+
+	savedItem, err := repos.Stock.AddStockItem(ctx, item)
 	if err != nil {
-		log.Printf("Error adding stock item: %v", err)
+		logger.Error("Error adding stock item", "err", err)
 		return StockItem{}
 	}
 	return savedItem
@@ -240,9 +513,17 @@ func (a *App) AddStockItem(item StockItem) StockItem {
 
 // UpdateStockItem updates an existing stock item
 func (a *App) UpdateStockItem(item StockItem) bool {
-	success, err := a.db.UpdateStockItem(item)
+	ctx, logger, repos, done := a.callRepositories("UpdateStockItem")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error updating stock item", "err", err)
+		return false
+	}
+
+	success, err := repos.Stock.UpdateStockItem(ctx, item)
 	if err != nil {
-		log.Printf("Error updating stock item: %v", err)
+		logger.Error("Error updating stock item", "err", err)
 		return false
 	}
 	return success
@@ -250,11 +531,19 @@ func (a *App) UpdateStockItem(item StockItem) bool {
 
 // DeleteStockItem removes a stock item by ID
 func (a *App) DeleteStockItem(id string) bool {
-	success, err := a.db.DeleteStockItem(id)
+	ctx, logger, repos, done := a.callRepositories("DeleteStockItem")
+	defer done()
+
+	if err := a.authorize(RoleAdmin); err != nil {
+		logger.Error("Error deleting stock item", "err", err)
+		return false
+	}
+
+	success, err := repos.Stock.DeleteStockItem(ctx, id)
 	if err != nil {
-		log.Printf("Error deleting stock item: %v", err)
+		logger.Error("Error deleting stock item", "err", err)
 		return false
 	}
-	log.Printf("Stock item with ID %s deleted successfully", id)
+	logger.Info("Stock item deleted", "id", id)
 	return success
 }
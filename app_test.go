@@ -0,0 +1,1342 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// Users the tests log in to the App as
+var (
+	testAdmin    = User{ID: "admin-id", Username: "admin", Role: RoleAdmin}
+	testStaff    = User{ID: "staff-id", Username: "staff", Role: RoleStaff}
+	testReadOnly = User{ID: "viewer-id", Username: "viewer", Role: RoleReadOnly}
+)
+
+// newMemoryApp creates an App over in-memory repositories, logged in as user
+func newMemoryApp(t *testing.T, user User) (*App, *memoryStore) {
+	t.Helper()
+
+	store := newMemoryStore()
+	app := NewApp(store.Repositories(), nil, NewImageStore(t.TempDir()), nil, "")
+	app.setCurrentUser(user)
+	return app, store
+}
+
+// newSQLiteApp creates an App over a fresh SQLite database, logged in as user, for
+// the bindings that have no repository interface
+func newSQLiteApp(t *testing.T, user User) (*App, *Database) {
+	t.Helper()
+
+	db := openTestDatabase(t)
+	app := newTestApp(t, db)
+	app.setCurrentUser(user)
+	return app, db
+}
+
+// newTestApp creates an App over db, or without a database if it is nil, with its
+// images and log in a temporary directory
+func newTestApp(t *testing.T, db *Database) *App {
+	t.Helper()
+
+	repos := Repositories{}
+	if db != nil {
+		repos = db.Repositories()
+	}
+	dir := t.TempDir()
+	return NewApp(repos, db, NewImageStore(filepath.Join(dir, "images")), nil, filepath.Join(dir, "app.log"))
+}
+
+// testPNG returns the bytes of a small PNG image with the given width
+func testPNG(t *testing.T, width int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, 4))); err != nil {
+		t.Fatalf("failed to encode image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestAppGetCurrentTime(t *testing.T) {
+	app, _ := newMemoryApp(t, User{})
+
+	if _, err := time.Parse(time.RFC1123, app.GetCurrentTime()); err != nil {
+		t.Fatalf("expected an RFC 1123 time: %v", err)
+	}
+}
+
+func TestAppDatabaseStatus(t *testing.T) {
+	if status := NewApp(Repositories{}, nil, nil, nil, "").DatabaseStatus(); status != "Database not initialized" {
+		t.Fatalf("unexpected status without repositories: %q", status)
+	}
+
+	app, store := newMemoryApp(t, testReadOnly)
+	store.AddProduct(context.Background(), Product{Name: "Basil"})
+	if status := app.DatabaseStatus(); status != "Database connected, product count: 1" {
+		t.Fatalf("unexpected status: %q", status)
+	}
+
+	store.fail(errors.New("disk I/O error"))
+	if status := app.DatabaseStatus(); status != "Database error: disk I/O error" {
+		t.Fatalf("unexpected status for a failing store: %q", status)
+	}
+}
+
+func TestAppProducts(t *testing.T) {
+	app, store := newMemoryApp(t, testStaff)
+
+	if products := app.GetProducts(); products == nil || len(products) != 0 {
+		t.Fatalf("expected an empty product list, got %#v", products)
+	}
+
+	if !app.AddProduct(Product{ID: "basil", Name: "Basil", Price: 3.5, Status: "In Stock"}) {
+		t.Fatal("AddProduct failed")
+	}
+	if app.AddProduct(Product{ID: "basil", Name: "Basil again"}) {
+		t.Fatal("expected adding a duplicate ID to fail")
+	}
+
+	if !app.UpdateProduct(Product{ID: "basil", Name: "Sweet Basil", Price: 4, Status: "In Stock"}) {
+		t.Fatal("UpdateProduct failed")
+	}
+	if app.UpdateProduct(Product{ID: "missing", Name: "Nothing"}) {
+		t.Fatal("expected updating a missing product to fail")
+	}
+
+	product := app.GetProductByID("basil")
+	if product == nil || product.Name != "Sweet Basil" || product.Price != 4 {
+		t.Fatalf("unexpected product %+v", product)
+	}
+	if app.GetProductByID("missing") != nil {
+		t.Fatal("expected no product for an unknown ID")
+	}
+
+	// Deleting needs an admin
+	if app.DeleteProduct("basil") {
+		t.Fatal("staff should not be able to delete products")
+	}
+	app.setCurrentUser(testAdmin)
+	if !app.DeleteProduct("basil") {
+		t.Fatal("DeleteProduct failed")
+	}
+	if app.DeleteProduct("basil") {
+		t.Fatal("expected deleting a missing product to fail")
+	}
+	if products, _ := store.GetProducts(context.Background()); len(products) != 0 {
+		t.Fatalf("expected no products left, got %+v", products)
+	}
+}
+
+func TestImageStoreRejectsHugeImages(t *testing.T) {
+	store := NewImageStore(t.TempDir())
+
+	// A PNG header describing 100000x100000 pixels is enough to be refused, without
+	// the gigabytes its pixels would take
+	header := make([]byte, 17)
+	copy(header, "IHDR")
+	binary.BigEndian.PutUint32(header[4:], 100000)
+	binary.BigEndian.PutUint32(header[8:], 100000)
+	header[12], header[13] = 8, 6 // 8-bit RGBA
+	var data bytes.Buffer
+	data.WriteString("\x89PNG\r\n\x1a\n")
+	binary.Write(&data, binary.BigEndian, uint32(len(header)-4))
+	data.Write(header)
+	binary.Write(&data, binary.BigEndian, crc32.ChecksumIEEE(header))
+
+	if _, err := store.Save(data.Bytes()); err == nil || !strings.Contains(err.Error(), "100000x100000") {
+		t.Fatalf("expected the image to be refused for its size, got %v", err)
+	}
+	if names, _ := store.List(); len(names) != 0 {
+		t.Fatalf("expected nothing to be stored, got %v", names)
+	}
+	if _, err := store.Save(testPNG(t, 8)); err != nil {
+		t.Fatalf("failed to save a small image: %v", err)
+	}
+}
+
+func TestAppDeleteProductReleasesImage(t *testing.T) {
+	app, store := newMemoryApp(t, testAdmin)
+
+	name, err := app.images.Save(testPNG(t, 8))
+	if err != nil {
+		t.Fatalf("failed to save image: %v", err)
+	}
+	store.AddProduct(context.Background(), Product{ID: "a", Name: "Fern", Image: name})
+	store.AddProduct(context.Background(), Product{ID: "b", Name: "Fern, large", Image: name})
+
+	// The image stays while another product shows it
+	if !app.DeleteProduct("a") {
+		t.Fatal("DeleteProduct failed")
+	}
+	if _, err := os.Stat(app.images.imagePath(name)); err != nil {
+		t.Fatalf("shared image was removed: %v", err)
+	}
+
+	if !app.DeleteProduct("b") {
+		t.Fatal("DeleteProduct failed")
+	}
+	if _, err := os.Stat(app.images.imagePath(name)); !os.IsNotExist(err) {
+		t.Fatalf("expected the unused image to be removed, got %v", err)
+	}
+}
+
+func TestAppOrders(t *testing.T) {
+	app, store := newMemoryApp(t, testStaff)
+	items := []OrderItem{
+		{ProductID: "1", ProductName: "Tomato Plant", Price: 5, Quantity: 3},
+		{ProductID: "2", ProductName: "Compost", Price: 12.5, Quantity: 1},
+	}
+
+	type newOrder = struct {
+		Name        string      `json:"name"`
+		Description string      `json:"description"`
+		Items       []OrderItem `json:"items"`
+	}
+	if app.CreateOrder(newOrder{Name: "Dana"}) {
+		t.Fatal("expected an order without items to be rejected")
+	}
+	if app.CreateOrder(newOrder{Items: items}) {
+		t.Fatal("expected an order without a name to be rejected")
+	}
+	if !app.CreateOrder(newOrder{Name: "Dana", Description: "Pickup Friday", Items: items}) {
+		t.Fatal("CreateOrder failed")
+	}
+
+	orders := app.GetOrders()
+	if len(orders) != 1 {
+		t.Fatalf("expected one order, got %+v", orders)
+	}
+	order := orders[0]
+	if order.Name != "Dana" || order.Total != 27.5 || order.Status != "Pending" || len(order.Items) != 2 {
+		t.Fatalf("unexpected order %+v", order)
+	}
+
+	if !app.UpdateOrderStatus(order.ID, "Completed") {
+		t.Fatal("UpdateOrderStatus failed")
+	}
+	if app.UpdateOrderStatus("missing", "Completed") {
+		t.Fatal("expected updating a missing order to fail")
+	}
+	if orders, _ := store.GetOrders(context.Background()); orders[0].Status != "Completed" {
+		t.Fatalf("expected status Completed, got %s", orders[0].Status)
+	}
+
+	if app.DeleteOrder(order.ID) {
+		t.Fatal("staff should not be able to delete orders")
+	}
+	app.setCurrentUser(testAdmin)
+	if !app.DeleteOrder(order.ID) {
+		t.Fatal("DeleteOrder failed")
+	}
+	if len(app.GetOrders()) != 0 {
+		t.Fatal("expected the order to be deleted")
+	}
+}
+
+func TestAppUpdateOrder(t *testing.T) {
+	app, store := newMemoryApp(t, testStaff)
+	ctx := context.Background()
+	id, _ := store.CreateOrder(ctx, "Dana", "", []OrderItem{
+		{ProductID: "1", ProductName: "Tomato Plant", Price: 5, Quantity: 3},
+		{ProductID: "2", ProductName: "Compost", Price: 12.5, Quantity: 1},
+	})
+
+	edit := NewOrder{Name: "Dana Levi", Items: []OrderItem{
+		{ProductID: "1", ProductName: "Tomato Plant", Price: 5, Quantity: 4},
+		{ProductID: "3", ProductName: "Basil", Price: 3.5, Quantity: 2},
+	}}
+	if !app.UpdateOrder(id, edit) {
+		t.Fatal("UpdateOrder failed")
+	}
+	if app.UpdateOrder(id, NewOrder{Name: "Dana"}) {
+		t.Fatal("expected removing every item to be rejected")
+	}
+	if app.UpdateOrder("missing", edit) {
+		t.Fatal("expected updating a missing order to fail")
+	}
+
+	order := app.GetOrders()[0]
+	if order.Name != "Dana Levi" || order.Total != 27 || len(order.Items) != 2 {
+		t.Fatalf("unexpected order %+v", order)
+	}
+
+	history := app.GetOrderHistory(id)
+	var changes []string
+	for _, change := range history {
+		if change.ChangedBy != testStaff.Username {
+			t.Fatalf("expected changes by %s, got %+v", testStaff.Username, change)
+		}
+		changes = append(changes, change.Change)
+	}
+	want := []string{
+		`Name changed from "Dana" to "Dana Levi"`,
+		"Tomato Plant quantity changed from 3 to 4",
+		"Added 2 × Basil",
+		"Removed 1 × Compost",
+	}
+	if strings.Join(changes, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected history %q", changes)
+	}
+
+	// Shipped orders are final
+	store.UpdateOrderStatus(ctx, id, "Shipped")
+	if app.UpdateOrder(id, NewOrder{Name: "Late change", Items: edit.Items}) {
+		t.Fatal("expected a shipped order to be read-only")
+	}
+
+	app.setCurrentUser(testReadOnly)
+	store.UpdateOrderStatus(ctx, id, "Pending")
+	if app.UpdateOrder(id, NewOrder{Name: "Viewer", Items: edit.Items}) {
+		t.Fatal("read-only users should not be able to edit orders")
+	}
+}
+
+func TestAppShipments(t *testing.T) {
+	app, db := newSQLiteApp(t, testStaff)
+	ctx := context.Background()
+	id, err := db.CreateOrder(ctx, "Dana", "", []OrderItem{
+		{ProductID: addTestProduct(t, db, "Tomato Plant", 5), Quantity: 3},
+		{ProductID: addTestProduct(t, db, "Compost", 12.5), Quantity: 2},
+		{ProductID: addTestProduct(t, db, "Basil", 3.5), Quantity: 4},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, _ := db.GetOrder(ctx, id)
+	tomato, compost, basil := order.Items[0], order.Items[1], order.Items[2]
+	if tomato.ID == "" || tomato.Remaining != 3 {
+		t.Fatalf("expected items with IDs and nothing shipped, got %+v", tomato)
+	}
+
+	if app.SplitOrder(id, OrderSplit{Items: []ShipmentLine{
+		{OrderItemID: tomato.ID, Quantity: 3}, {OrderItemID: compost.ID, Quantity: 2}, {OrderItemID: basil.ID, Quantity: 4},
+	}}) != "" {
+		t.Fatal("expected a split that empties the order to be rejected")
+	}
+
+	if !app.ShipOrder(id, NewShipment{Items: []ShipmentLine{{OrderItemID: tomato.ID, Quantity: 2}}, Note: "Courier"}) {
+		t.Fatal("ShipOrder failed")
+	}
+	if app.ShipOrder(id, NewShipment{Items: []ShipmentLine{{OrderItemID: tomato.ID, Quantity: 2}}}) {
+		t.Fatal("expected shipping more than is left to be rejected")
+	}
+	if app.UpdateOrder(id, NewOrder{Name: "Dana", Items: order.Items}) {
+		t.Fatal("expected a partially shipped order to be read-only")
+	}
+
+	order, _ = db.GetOrder(ctx, id)
+	if order.Status != "Partially Shipped" || order.Items[0].Shipped != 2 || order.Items[0].Remaining != 1 {
+		t.Fatalf("unexpected order after a partial shipment %+v", order)
+	}
+	if shipments := app.GetShipments(id); len(shipments) != 1 || shipments[0].ShippedBy != testStaff.Username ||
+		len(shipments[0].Items) != 1 || shipments[0].Items[0].ProductName != "Tomato Plant" {
+		t.Fatalf("unexpected shipments %+v", shipments)
+	}
+
+	// The last tomato and the basil move to an order of their own
+	newID := app.SplitOrder(id, OrderSplit{Items: []ShipmentLine{
+		{OrderItemID: tomato.ID, Quantity: 1},
+		{OrderItemID: basil.ID, Quantity: 4},
+	}})
+	if newID == "" {
+		t.Fatal("SplitOrder failed")
+	}
+	split, _ := db.GetOrder(ctx, newID)
+	if split.Status != "Pending" || len(split.Items) != 2 || split.Total != 19 {
+		t.Fatalf("unexpected split order %+v", split)
+	}
+	order, _ = db.GetOrder(ctx, id)
+	if len(order.Items) != 2 || order.Items[0].Quantity != 2 || order.Total != 35 {
+		t.Fatalf("unexpected order after the split %+v", order)
+	}
+	if app.SplitOrder(id, OrderSplit{Items: []ShipmentLine{{OrderItemID: basil.ID, Quantity: 1}}}) != "" {
+		t.Fatal("expected an item that moved away to be rejected")
+	}
+
+	// Shipping the rest completes the order
+	if !app.ShipOrder(id, NewShipment{Items: []ShipmentLine{{OrderItemID: compost.ID, Quantity: 2}}}) {
+		t.Fatal("ShipOrder failed")
+	}
+	if order, _ = db.GetOrder(ctx, id); order.Status != "Shipped" {
+		t.Fatalf("expected the order to be Shipped, got %s", order.Status)
+	}
+	if app.SplitOrder(id, OrderSplit{Items: []ShipmentLine{{OrderItemID: compost.ID, Quantity: 1}}}) != "" {
+		t.Fatal("expected a shipped order to refuse a split")
+	}
+
+	app.setCurrentUser(testAdmin)
+	if !app.DeleteOrder(id) {
+		t.Fatal("expected an order with shipments to be deletable")
+	}
+}
+
+func TestAppReturns(t *testing.T) {
+	app, db := newSQLiteApp(t, testStaff)
+	ctx := context.Background()
+	shelf, err := db.AddStockItem(ctx, StockItem{Name: "Tomato Plant", Quantity: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := db.CreateOrder(ctx, "Dana", "", []OrderItem{
+		{ProductID: addTestProduct(t, db, "Tomato Plant", 5), Quantity: 4},
+		{ProductID: addTestProduct(t, db, "Compost", 12.5), Quantity: 2},
+	})
+	order, _ := db.GetOrder(ctx, id)
+	tomato, compost := order.Items[0], order.Items[1]
+
+	// Only shipped items can come back
+	tomatoes := []ShipmentLine{{OrderItemID: tomato.ID, Quantity: 3}}
+	if app.ReturnItems(id, NewReturn{Items: []ReturnLine{{OrderItemID: tomato.ID, Quantity: 1, Condition: "Resellable"}}, Reason: "Too many"}) {
+		t.Fatal("expected returning an unshipped item to be rejected")
+	}
+	if _, err := db.CreateShipment(ctx, id, NewShipment{Items: tomatoes}, "staff"); err != nil {
+		t.Fatal(err)
+	}
+
+	ret := NewReturn{
+		Items:  []ReturnLine{{OrderItemID: tomato.ID, Quantity: 2, Condition: "Resellable", RestockItemID: shelf.ID}},
+		Reason: "Changed mind",
+		Refund: 10,
+	}
+	if !app.ReturnItems(id, ret) {
+		t.Fatal("ReturnItems failed")
+	}
+	if item, _ := db.GetStockItem(ctx, shelf.ID); item.Quantity != 12 {
+		t.Fatalf("expected the returned plants back in stock, got %g", item.Quantity)
+	}
+
+	rejected := []NewReturn{
+		{Items: []ReturnLine{{OrderItemID: tomato.ID, Quantity: 2, Condition: "Resellable"}}, Reason: "Too many"},
+		{Items: []ReturnLine{{OrderItemID: tomato.ID, Quantity: 1, Condition: "Damaged", RestockItemID: shelf.ID}}, Reason: "Broken"},
+		{Items: []ReturnLine{{OrderItemID: tomato.ID, Quantity: 1, Condition: "Lost"}}, Reason: "Unknown"},
+		{Items: []ReturnLine{{OrderItemID: tomato.ID, Quantity: 1, Condition: "Opened"}}},
+		{Items: []ReturnLine{{OrderItemID: tomato.ID, Quantity: 1, Condition: "Opened"}}, Reason: "Refund too high", Refund: 36},
+		{Items: []ReturnLine{{OrderItemID: compost.ID, Quantity: 1, Condition: "Opened"}}, Reason: "Not shipped"},
+	}
+	for _, r := range rejected {
+		if app.ReturnItems(id, r) {
+			t.Fatalf("expected return %+v to be rejected", r)
+		}
+	}
+
+	order, _ = db.GetOrder(ctx, id)
+	if order.Refunded != 10 || order.Items[0].Returned != 2 {
+		t.Fatalf("unexpected order after the return %+v", order)
+	}
+	if returns := app.GetReturns(id); len(returns) != 1 || returns[0].Items[0].RestockItemID != shelf.ID || returns[0].ReturnedBy != "staff" {
+		t.Fatalf("unexpected returns %+v", returns)
+	}
+
+	summary := app.GetSalesSummary("", "")
+	if summary.GrossSales != 45 || summary.Refunds != 10 || summary.NetSales != 35 || summary.ReturnedUnits != 2 {
+		t.Fatalf("unexpected sales summary %+v", summary)
+	}
+	if summary := app.GetSalesSummary("2000-01-01", "2000-12-31"); summary.OrderCount != 0 || summary.ReturnCount != 0 {
+		t.Fatalf("expected nothing in 2000, got %+v", summary)
+	}
+
+	app.setCurrentUser(testAdmin)
+	if !app.DeleteOrder(id) {
+		t.Fatal("expected an order with returns to be deletable")
+	}
+}
+
+func TestAppPayments(t *testing.T) {
+	app, db := newSQLiteApp(t, testStaff)
+	ctx := context.Background()
+	items := []OrderItem{{ProductID: addTestProduct(t, db, "Fern", 25), Quantity: 2}}
+	id, _ := db.CreateOrder(ctx, "Dana", "", items)
+	paidID, _ := db.CreateOrder(ctx, "Noa", "", items)
+	cancelledID, _ := db.CreateOrder(ctx, "Yael", "", items)
+	db.UpdateOrderStatus(ctx, cancelledID, "Cancelled")
+
+	if order, _ := db.GetOrder(ctx, id); order.PaymentStatus != PaymentUnpaid || order.BalanceDue != 50 {
+		t.Fatalf("expected a new order to be unpaid, got %+v", order)
+	}
+
+	if !app.AddPayment(id, NewPayment{Method: "Cash", Amount: 20, Deposit: true}) {
+		t.Fatal("AddPayment failed")
+	}
+	if !app.AddPayment(paidID, NewPayment{Method: "Transfer", Amount: 50, PaidAt: "2026-03-01", Reference: "TX-1"}) {
+		t.Fatal("AddPayment failed")
+	}
+
+	rejected := []NewPayment{
+		{Method: "Cheque", Amount: 5},
+		{Method: "Card", Amount: 0},
+		{Method: "Card", Amount: 30.01},
+		{Method: "Card", Amount: 5, PaidAt: "yesterday"},
+	}
+	for _, payment := range rejected {
+		if app.AddPayment(id, payment) {
+			t.Fatalf("expected payment %+v to be rejected", payment)
+		}
+	}
+	if app.AddPayment(cancelledID, NewPayment{Method: "Cash", Amount: 5}) {
+		t.Fatal("expected paying a cancelled order to be rejected")
+	}
+
+	order, _ := db.GetOrder(ctx, id)
+	if order.PaymentStatus != PaymentPartiallyPaid || order.Paid != 20 || order.BalanceDue != 30 {
+		t.Fatalf("unexpected order after a deposit %+v", order)
+	}
+	if app.UpdateOrder(id, NewOrder{Name: "Dana", Items: []OrderItem{{ProductID: "1", ProductName: "Fern", Price: 5, Quantity: 2}}}) {
+		t.Fatal("expected an edit below what has been paid to be rejected")
+	}
+
+	outstanding := app.GetOutstandingOrders()
+	if len(outstanding) != 1 || outstanding[0].ID != id {
+		t.Fatalf("expected only the partly paid order to be outstanding, got %+v", outstanding)
+	}
+
+	payments := app.GetPayments(paidID)
+	if len(payments) != 1 || payments[0].PaidAt != "2026-03-01 00:00:00" || payments[0].RecordedBy != "staff" {
+		t.Fatalf("unexpected payments %+v", payments)
+	}
+	if app.DeletePayment(payments[0].ID) {
+		t.Fatal("staff should not be able to delete payments")
+	}
+	app.setCurrentUser(testAdmin)
+	if !app.DeletePayment(payments[0].ID) {
+		t.Fatal("DeletePayment failed")
+	}
+	if len(app.GetOutstandingOrders()) != 2 {
+		t.Fatal("expected the order to be outstanding again after its payment was deleted")
+	}
+	if !app.DeleteOrder(id) {
+		t.Fatal("expected an order with payments to be deletable")
+	}
+}
+
+func TestAppOrderTemplates(t *testing.T) {
+	app, db := newSQLiteApp(t, testStaff)
+	ctx := context.Background()
+	now := time.Now()
+	today := now.Format("2006-01-02")
+	soilID := addTestProduct(t, db, "Potting Soil 50L", 40)
+	items := []OrderItem{
+		{ProductID: soilID, Quantity: 10},
+		{ProductID: addTestProduct(t, db, "Lavender Seedling", 12), Quantity: 24},
+	}
+
+	if app.SaveOrderTemplate(OrderTemplate{Name: "Green Yards", Items: items, Frequency: "daily", StartDate: today}) != "" {
+		t.Fatal("expected an unknown frequency to be rejected")
+	}
+	if app.SaveOrderTemplate(OrderTemplate{Name: "Green Yards", Items: items, Frequency: FrequencyMonthly}) != "" {
+		t.Fatal("expected a schedule without a start date to be rejected")
+	}
+
+	manualID := app.SaveOrderTemplate(OrderTemplate{Name: "Oak Gardens", Items: items[:1]})
+	monthlyID := app.SaveOrderTemplate(OrderTemplate{Name: "Green Yards", Description: "Monthly delivery", Items: items,
+		Frequency: FrequencyMonthly, StartDate: today})
+	weeklyID := app.SaveOrderTemplate(OrderTemplate{Name: "City Parks", Items: items[1:],
+		Frequency: FrequencyWeekly, StartDate: now.AddDate(0, 0, 3).Format("2006-01-02")})
+	if manualID == "" || monthlyID == "" || weeklyID == "" {
+		t.Fatal("SaveOrderTemplate failed")
+	}
+
+	templates := app.GetOrderTemplates()
+	if len(templates) != 3 || templates[1].Name != "Green Yards" || templates[1].Total != 688 || templates[1].NextRun != today {
+		t.Fatalf("unexpected templates %+v", templates)
+	}
+
+	preview := app.PreviewScheduledOrders(14)
+	if len(preview) != 3 || preview[0].TemplateID != monthlyID || preview[0].Date != today || preview[1].TemplateID != weeklyID {
+		t.Fatalf("unexpected preview %+v", preview)
+	}
+
+	if app.SaveOrderTemplate(OrderTemplate{Name: "Unknown", Items: []OrderItem{{ProductID: "no-such-product", Quantity: 1}}}) != "" {
+		t.Fatal("expected a template with an unknown product to be rejected")
+	}
+
+	// Orders are priced when they are created, not when the template was saved
+	if err := db.UpdateProduct(ctx, Product{ID: soilID, Name: "Potting Soil 50L", Price: 45, Status: "In Stock"}); err != nil {
+		t.Fatal(err)
+	}
+	orderID := app.CreateOrderFromTemplate(manualID)
+	if order, err := db.GetOrder(ctx, orderID); err != nil || order.Status != "Pending" || order.Total != 450 {
+		t.Fatalf("unexpected order from template %+v: %v", order, err)
+	}
+
+	created, err := db.GenerateDueOrders(ctx, now)
+	if err != nil || len(created) != 1 {
+		t.Fatalf("expected the monthly order to be generated, got %v: %v", created, err)
+	}
+	order, _ := db.GetOrder(ctx, created[0])
+	if order.Name != "Green Yards" || order.Description != "Monthly delivery" || len(order.Items) != 2 || order.Status != "Pending" {
+		t.Fatalf("unexpected generated order %+v", order)
+	}
+	history := app.GetOrderHistory(created[0])
+	if len(history) != 1 || history[0].Change != `Generated from template "Green Yards" for `+today {
+		t.Fatalf("unexpected history of a generated order %+v", history)
+	}
+	if again, _ := db.GenerateDueOrders(ctx, now); len(again) != 0 {
+		t.Fatalf("expected no orders to be generated twice, got %v", again)
+	}
+
+	// Orders missed while the app was closed are all generated
+	created, err = db.GenerateDueOrders(ctx, now.AddDate(0, 0, 16))
+	if err != nil || len(created) != 2 {
+		t.Fatalf("expected two weekly orders to catch up, got %v: %v", created, err)
+	}
+
+	if !app.DeleteOrderTemplate(monthlyID) {
+		t.Fatal("DeleteOrderTemplate failed")
+	}
+	if len(app.GetOrderTemplates()) != 2 {
+		t.Fatal("expected the template to be deleted")
+	}
+	if _, err := db.GetOrder(ctx, order.ID); err != nil {
+		t.Fatalf("expected generated orders to outlive their template: %v", err)
+	}
+}
+
+func TestAppQuotes(t *testing.T) {
+	app, db := newSQLiteApp(t, testStaff)
+	ctx := context.Background()
+	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	oliveID := addTestProduct(t, db, "Olive Tree", 350)
+	items := []OrderItem{
+		{ProductID: oliveID, Quantity: 2},
+		{ProductID: addTestProduct(t, db, "Planting", 120), Price: 100, Quantity: 1, OverrideReason: "Discount"},
+	}
+
+	if app.CreateQuote(NewQuote{Name: "Dana", Items: items, ExpiresOn: "next week"}) != "" {
+		t.Fatal("expected an invalid expiry date to be rejected")
+	}
+	id := app.CreateQuote(NewQuote{Name: "Dana", Description: "Front garden", Items: items})
+	expiredID := app.CreateQuote(NewQuote{Name: "Noa", Items: items[:1], ExpiresOn: yesterday})
+	if id == "" || expiredID == "" {
+		t.Fatal("CreateQuote failed")
+	}
+
+	quotes := app.GetQuotes()
+	if len(quotes) != 2 || quotes[0].ID != expiredID || quotes[0].Status != QuoteExpired {
+		t.Fatalf("expected the newest quote first and expired, got %+v", quotes)
+	}
+	if quotes[1].Status != QuoteDraft || quotes[1].Total != 800 || quotes[1].ExpiresOn == "" || quotes[1].CreatedBy != "staff" {
+		t.Fatalf("unexpected draft quote %+v", quotes[1])
+	}
+
+	if app.ConvertQuoteToOrder(expiredID) != "" {
+		t.Fatal("expected an expired quote not to convert")
+	}
+	if app.UpdateQuoteStatus(id, QuoteAccepted) {
+		t.Fatal("expected a quote to be accepted only by converting it")
+	}
+	if !app.UpdateQuoteStatus(id, QuoteSent) {
+		t.Fatal("UpdateQuoteStatus failed")
+	}
+
+	// Quoted prices are kept even when the product's price has changed since
+	if err := db.UpdateProduct(ctx, Product{ID: oliveID, Name: "Olive Tree", Price: 380, Status: "In Stock"}); err != nil {
+		t.Fatal(err)
+	}
+	orderID := app.ConvertQuoteToOrder(id)
+	order, err := db.GetOrder(ctx, orderID)
+	if err != nil || order.Name != "Dana" || order.Total != 800 || order.Items[0].Price != 350 || order.Status != "Pending" {
+		t.Fatalf("unexpected order from quote %+v: %v", order, err)
+	}
+	if olive, planting := order.Items[0], order.Items[1]; olive.ListPrice != 380 || olive.OverrideReason != "Quote" ||
+		planting.Price != 100 || planting.OverrideReason != "Discount" {
+		t.Fatalf("expected the quoted prices to be recorded as overrides, got %+v", order.Items)
+	}
+	if history := app.GetOrderHistory(orderID); len(history) != 1 || history[0].Change != "Created from quote #"+id {
+		t.Fatalf("unexpected order history %+v", history)
+	}
+
+	quote, _ := db.GetQuote(ctx, id)
+	if quote.Status != QuoteAccepted || quote.OrderID != orderID {
+		t.Fatalf("expected the quote to be accepted, got %+v", quote)
+	}
+	if app.ConvertQuoteToOrder(id) != "" {
+		t.Fatal("expected a quote to convert only once")
+	}
+	if app.UpdateQuote(id, NewQuote{Name: "Dana", Items: items[:1]}) || app.DeleteQuote(id) {
+		t.Fatal("expected an accepted quote to be read-only")
+	}
+
+	// Editing an expired quote extends it as a new draft
+	if !app.UpdateQuote(expiredID, NewQuote{Name: "Noa", Items: items[:1]}) {
+		t.Fatal("UpdateQuote failed")
+	}
+	if quote, _ := db.GetQuote(ctx, expiredID); quote.Status != QuoteDraft {
+		t.Fatalf("expected the edited quote to be a draft, got %+v", quote)
+	}
+
+	// Quote PDFs embed a font with Hebrew letters and draw them in display order
+	quote.Name = "דנה כהן"
+	pdf, err := renderQuote(quote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, bold, _ := newDocumentFonts()
+	if !bytes.HasPrefix(pdf, []byte("%PDF")) || !bytes.Contains(pdf, []byte(bold.text("Quote #"+id))) {
+		t.Fatal("expected a PDF document of the quote")
+	}
+	if !bytes.Contains(pdf, []byte("/FontFile2")) || !bytes.Contains(pdf, []byte(bold.text("דנה כהן"))) {
+		t.Fatal("expected the Hebrew name to be drawn with an embedded font")
+	}
+	long := Quote{ID: "9", Items: make([]OrderItem, 100)}
+	if pdf, err := renderQuote(long); err != nil || bytes.Count(pdf, []byte("/Type /Page ")) != 3 {
+		t.Fatalf("expected 100 lines to take 3 pages, got %d: %v", bytes.Count(pdf, []byte("/Type /Page ")), err)
+	}
+
+	if !app.DeleteQuote(expiredID) || len(app.GetQuotes()) != 1 {
+		t.Fatal("expected the quote to be deleted")
+	}
+}
+
+func TestVisualOrder(t *testing.T) {
+	for logical, visual := range map[string]string{
+		"Fern":                 "Fern",
+		"שתיל":                 "ליתש",
+		"עציץ 30 ס״מ":          "מ״ס 30 ץיצע",
+		"Fern (שרך)":           "Fern (ךרש)",
+		"שרך (Fern)":           "(Fern) ךרש",
+		"מחיר ₪1,250.50 לפריט": "טירפל ₪1,250.50 ריחמ",
+	} {
+		if got := visualOrder(logical); got != visual {
+			t.Errorf("visualOrder(%q) = %q, expected %q", logical, got, visual)
+		}
+	}
+}
+
+func TestSubsetTrueType(t *testing.T) {
+	regular, _, err := newDocumentFonts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept := regular.glyph('ש')
+	subset, err := subsetTrueType(dejaVuSans, []sfnt.GlyphIndex{kept})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subset) >= len(dejaVuSans)/10 {
+		t.Fatalf("expected the subset to be much smaller than the font, got %d of %d bytes", len(subset), len(dejaVuSans))
+	}
+
+	// The subset is still a valid font with the kept glyph's outline and no others
+	font, err := sfnt.Parse(subset)
+	if err != nil {
+		t.Fatalf("subset does not parse: %v", err)
+	}
+	var buf sfnt.Buffer
+	if segments, err := font.LoadGlyph(&buf, kept, fixed.I(12), nil); err != nil || len(segments) == 0 {
+		t.Fatalf("expected the kept glyph to have an outline: %v", err)
+	}
+	if segments, err := font.LoadGlyph(&buf, regular.glyph('A'), fixed.I(12), nil); err != nil || len(segments) != 0 {
+		t.Fatalf("expected other glyphs to be empty, got %d segments: %v", len(segments), err)
+	}
+}
+
+func TestScheduledDate(t *testing.T) {
+	start := time.Date(2026, time.January, 31, 0, 0, 0, 0, time.Local)
+	for n, want := range []string{"2026-01-31", "2026-02-28", "2026-03-31", "2026-04-30"} {
+		if got := scheduledDate(start, FrequencyMonthly, n).Format("2006-01-02"); got != want {
+			t.Errorf("monthly occurrence %d: got %s, want %s", n, got, want)
+		}
+	}
+	if got := scheduledDate(start, FrequencyWeekly, 2).Format("2006-01-02"); got != "2026-02-14" {
+		t.Errorf("weekly occurrence 2: got %s", got)
+	}
+}
+
+func TestAppStock(t *testing.T) {
+	app, _ := newMemoryApp(t, testStaff)
+
+	if items := app.GetStockItems(); items == nil || len(items) != 0 {
+		t.Fatalf("expected an empty stock list, got %#v", items)
+	}
+
+	item := app.AddStockItem(StockItem{Name: "Compost 40L", Quantity: 10})
+	if item.ID == "" {
+		t.Fatal("expected AddStockItem to return the item with an ID")
+	}
+
+	item.Quantity = 7
+	if !app.UpdateStockItem(item) {
+		t.Fatal("UpdateStockItem failed")
+	}
+	if app.UpdateStockItem(StockItem{ID: "missing", Name: "Nothing"}) {
+		t.Fatal("expected updating a missing item to report false")
+	}
+	if items := app.GetStockItems(); len(items) != 1 || items[0].Quantity != 7 {
+		t.Fatalf("unexpected stock %+v", items)
+	}
+
+	if app.DeleteStockItem(item.ID) {
+		t.Fatal("staff should not be able to delete stock items")
+	}
+	app.setCurrentUser(testAdmin)
+	if !app.DeleteStockItem(item.ID) {
+		t.Fatal("DeleteStockItem failed")
+	}
+	if app.DeleteStockItem(item.ID) {
+		t.Fatal("expected deleting a missing item to report false")
+	}
+}
+
+func TestAppLocations(t *testing.T) {
+	app, db := newSQLiteApp(t, testStaff)
+	ctx := context.Background()
+
+	greenhouse := app.SaveLocation(Location{Name: "Greenhouse"})
+	shop := app.SaveLocation(Location{Name: "Shop floor"})
+	if greenhouse.ID == "" || shop.ID == "" {
+		t.Fatal("SaveLocation failed")
+	}
+	if app.SaveLocation(Location{Name: "greenhouse"}).ID != "" {
+		t.Fatal("expected a duplicate location name to be rejected")
+	}
+
+	// Opening stock is counted at the default location
+	item := app.AddStockItem(StockItem{Name: "Basil", Quantity: 10})
+	if !app.TransferStock(StockTransfer{StockItemID: item.ID, FromLocationID: DefaultLocationID, ToLocationID: greenhouse.ID, Quantity: 6}) {
+		t.Fatal("TransferStock failed")
+	}
+	if app.TransferStock(StockTransfer{StockItemID: item.ID, FromLocationID: DefaultLocationID, ToLocationID: shop.ID, Quantity: 5}) {
+		t.Fatal("expected moving more than a location holds to be rejected")
+	}
+	if !app.AdjustStock(item.ID, StockAdjustment{LocationID: shop.ID, Delta: 2, Note: "Found on a shelf"}) {
+		t.Fatal("AdjustStock failed")
+	}
+
+	items := app.GetStockItems()
+	if len(items) != 1 || items[0].Quantity != 12 || len(items[0].Locations) != 3 {
+		t.Fatalf("unexpected stock %+v", items)
+	}
+	held := map[string]float64{}
+	for _, level := range items[0].Locations {
+		held[level.LocationID] = level.Quantity
+	}
+	if held[DefaultLocationID] != 4 || held[greenhouse.ID] != 6 || held[shop.ID] != 2 {
+		t.Fatalf("unexpected quantities per location %v", held)
+	}
+
+	// Orders are fulfilled from the chosen location
+	orderID, _ := db.CreateOrder(ctx, "Dana", "", []OrderItem{{ProductID: addTestProduct(t, db, "Basil", 3.5), Quantity: 8}})
+	order, _ := db.GetOrder(ctx, orderID)
+	line := ShipmentLine{OrderItemID: order.Items[0].ID, Quantity: 7, StockItemID: item.ID}
+	if app.ShipOrder(orderID, NewShipment{Items: []ShipmentLine{line}, LocationID: greenhouse.ID}) {
+		t.Fatal("expected shipping more than the greenhouse holds to be rejected")
+	}
+	line.Quantity = 5
+	if !app.ShipOrder(orderID, NewShipment{Items: []ShipmentLine{line}, LocationID: greenhouse.ID}) {
+		t.Fatal("ShipOrder failed")
+	}
+	if shipments := app.GetShipments(orderID); len(shipments) != 1 || shipments[0].Location != "Greenhouse" {
+		t.Fatalf("unexpected shipments %+v", shipments)
+	}
+	if stock, _ := db.GetStockItem(ctx, item.ID); stock.Quantity != 7 {
+		t.Fatalf("expected the shipped plants to leave stock, got %+v", stock)
+	}
+
+	// Returned items go back to the location they are put in
+	ret := NewReturn{Items: []ReturnLine{{OrderItemID: line.OrderItemID, Quantity: 1, Condition: "Resellable", RestockItemID: item.ID}},
+		Reason: "Wilted", LocationID: shop.ID}
+	if !app.ReturnItems(orderID, ret) {
+		t.Fatal("ReturnItems failed")
+	}
+
+	movements := app.GetStockMovements(item.ID)
+	if len(movements) != 5 || movements[0].Kind != MovementReturn || movements[0].ToLocation != "Shop floor" ||
+		movements[1].Kind != MovementShipment || movements[1].FromLocationID != greenhouse.ID || movements[1].Reference != "Order #"+orderID {
+		t.Fatalf("unexpected movements %+v", movements)
+	}
+
+	app.setCurrentUser(testAdmin)
+	if app.DeleteLocation(shop.ID) || app.DeleteLocation(DefaultLocationID) {
+		t.Fatal("expected locations holding stock and the default location to be kept")
+	}
+	if !app.TransferStock(StockTransfer{StockItemID: item.ID, FromLocationID: shop.ID, ToLocationID: greenhouse.ID, Quantity: 3}) {
+		t.Fatal("TransferStock failed")
+	}
+	if !app.DeleteLocation(shop.ID) {
+		t.Fatal("DeleteLocation failed")
+	}
+	if locations := app.GetLocations(); len(locations) != 2 || locations[0].Name != "Greenhouse" || locations[0].Units != 4 {
+		t.Fatalf("unexpected locations %+v", locations)
+	}
+}
+
+func TestAppPermissions(t *testing.T) {
+	for _, user := range []User{{}, testReadOnly} {
+		app, store := newMemoryApp(t, user)
+		store.AddProduct(context.Background(), Product{ID: "p", Name: "Rose"})
+		orderID, _ := store.CreateOrder(context.Background(), "Dana", "", []OrderItem{{ProductID: "p", Price: 1, Quantity: 1}})
+		item, _ := store.AddStockItem(context.Background(), StockItem{Name: "Pots"})
+
+		denied := map[string]bool{
+			"AddProduct":        app.AddProduct(Product{Name: "Tulip"}),
+			"UpdateProduct":     app.UpdateProduct(Product{ID: "p", Name: "Red Rose"}),
+			"DeleteProduct":     app.DeleteProduct("p"),
+			"UpdateOrderStatus": app.UpdateOrderStatus(orderID, "Completed"),
+			"DeleteOrder":       app.DeleteOrder(orderID),
+			"AddStockItem":      app.AddStockItem(StockItem{Name: "Soil"}).ID != "",
+			"UpdateStockItem":   app.UpdateStockItem(StockItem{ID: item.ID, Name: "Big pots"}),
+			"DeleteStockItem":   app.DeleteStockItem(item.ID),
+			"CreateOrder": app.CreateOrder(struct {
+				Name        string      `json:"name"`
+				Description string      `json:"description"`
+				Items       []OrderItem `json:"items"`
+			}{Name: "Lee", Items: []OrderItem{{ProductID: "p", Price: 1, Quantity: 1}}}),
+		}
+		for binding, succeeded := range denied {
+			if succeeded {
+				t.Errorf("%s succeeded for user %q", binding, user.Role)
+			}
+		}
+
+		// Reading is allowed to everyone, and nothing was changed
+		products := app.GetProducts()
+		if len(products) != 1 || products[0].Name != "Rose" {
+			t.Errorf("products changed for user %q: %+v", user.Role, products)
+		}
+		if orders := app.GetOrders(); len(orders) != 1 || orders[0].Status != "Pending" {
+			t.Errorf("orders changed for user %q: %+v", user.Role, orders)
+		}
+		if items := app.GetStockItems(); len(items) != 1 || items[0].Name != "Pots" {
+			t.Errorf("stock changed for user %q: %+v", user.Role, items)
+		}
+	}
+}
+
+func TestAppRepositoryErrors(t *testing.T) {
+	app, store := newMemoryApp(t, testAdmin)
+	store.AddProduct(context.Background(), Product{ID: "p", Name: "Rose"})
+	store.fail(errors.New("database is locked"))
+
+	if products := app.GetProducts(); products == nil || len(products) != 0 {
+		t.Errorf("GetProducts: expected an empty list, got %#v", products)
+	}
+	if orders := app.GetOrders(); orders == nil || len(orders) != 0 {
+		t.Errorf("GetOrders: expected an empty list, got %#v", orders)
+	}
+	if items := app.GetStockItems(); items == nil || len(items) != 0 {
+		t.Errorf("GetStockItems: expected an empty list, got %#v", items)
+	}
+	if app.GetProductByID("p") != nil {
+		t.Error("GetProductByID: expected nil")
+	}
+	if app.AddProduct(Product{Name: "Tulip"}) || app.UpdateProduct(Product{ID: "p"}) || app.DeleteProduct("p") {
+		t.Error("product changes should fail")
+	}
+	if app.UpdateOrderStatus("1", "Completed") || app.DeleteOrder("1") {
+		t.Error("order changes should fail")
+	}
+	if app.AddStockItem(StockItem{Name: "Soil"}).ID != "" || app.UpdateStockItem(StockItem{ID: "s"}) || app.DeleteStockItem("s") {
+		t.Error("stock changes should fail")
+	}
+}
+
+func TestAppAuthentication(t *testing.T) {
+	app, _ := newSQLiteApp(t, User{})
+
+	if !app.NeedsSetup() {
+		t.Fatal("a fresh database should need setup")
+	}
+	if user := app.CreateInitialAdmin("admin", "short"); user.ID != "" {
+		t.Fatal("expected a short password to be rejected")
+	}
+	admin := app.CreateInitialAdmin("admin", "admin-password")
+	if admin.ID == "" || admin.Role != RoleAdmin {
+		t.Fatalf("unexpected initial admin %+v", admin)
+	}
+	if app.NeedsSetup() {
+		t.Fatal("setup should be done once an admin exists")
+	}
+	if app.GetCurrentUser().ID != admin.ID {
+		t.Fatal("the initial admin should be logged in")
+	}
+	if user := app.CreateInitialAdmin("second", "admin-password"); user.ID != "" {
+		t.Fatal("expected a second initial admin to be rejected")
+	}
+
+	if !app.Logout() || app.GetCurrentUser().ID != "" {
+		t.Fatal("Logout should clear the current user")
+	}
+	if user := app.Login("admin", "wrong-password"); user.ID != "" || app.GetCurrentUser().ID != "" {
+		t.Fatal("expected a wrong password to be rejected")
+	}
+	if user := app.Login("admin", "admin-password"); user.ID != admin.ID {
+		t.Fatalf("Login failed, got %+v", user)
+	}
+
+	if app.ChangePassword("wrong-password", "new-password") {
+		t.Fatal("expected ChangePassword to check the old password")
+	}
+	if !app.ChangePassword("admin-password", "new-password") {
+		t.Fatal("ChangePassword failed")
+	}
+	app.Logout()
+	if app.ChangePassword("new-password", "another-password") {
+		t.Fatal("expected ChangePassword to need a login")
+	}
+	if user := app.Login("admin", "new-password"); user.ID == "" {
+		t.Fatal("expected the new password to work")
+	}
+}
+
+func TestAppUserManagement(t *testing.T) {
+	app, _ := newSQLiteApp(t, User{})
+	admin := app.CreateInitialAdmin("admin", "admin-password")
+
+	staff := app.AddUser("lee", "lee-password", RoleStaff)
+	if staff.ID == "" || staff.Role != RoleStaff {
+		t.Fatalf("unexpected user %+v", staff)
+	}
+	if user := app.AddUser("lee", "lee-password", RoleStaff); user.ID != "" {
+		t.Fatal("expected a duplicate username to be rejected")
+	}
+	if user := app.AddUser("kim", "kim-password", "owner"); user.ID != "" {
+		t.Fatal("expected an unknown role to be rejected")
+	}
+	if users := app.GetUsers(); len(users) != 2 {
+		t.Fatalf("expected two users, got %+v", users)
+	}
+
+	if !app.UpdateUser(staff.ID, RoleReadOnly, "") {
+		t.Fatal("UpdateUser failed")
+	}
+	if app.DeleteUser(admin.ID) {
+		t.Fatal("expected deleting the logged-in account to fail")
+	}
+
+	// Only admins manage accounts
+	app.Logout()
+	if user := app.Login("lee", "lee-password"); user.Role != RoleReadOnly {
+		t.Fatalf("expected the changed role on login, got %+v", user)
+	}
+	if users := app.GetUsers(); len(users) != 0 {
+		t.Fatal("read-only users should not list accounts")
+	}
+	if app.AddUser("kim", "kim-password", RoleStaff).ID != "" || app.UpdateUser(staff.ID, RoleAdmin, "") || app.DeleteUser(admin.ID) {
+		t.Fatal("read-only users should not change accounts")
+	}
+
+	app.Logout()
+	app.Login("admin", "admin-password")
+	if !app.DeleteUser(staff.ID) {
+		t.Fatal("DeleteUser failed")
+	}
+	if users := app.GetUsers(); len(users) != 1 {
+		t.Fatalf("expected one user left, got %+v", users)
+	}
+}
+
+func TestAppCategories(t *testing.T) {
+	app, db := newSQLiteApp(t, testStaff)
+
+	if app.AddCategory(Category{}).ID != "" {
+		t.Fatal("expected a category without a name to be rejected")
+	}
+	plants := app.AddCategory(Category{Name: "Plants"})
+	herbs := app.AddCategory(Category{Name: "Herbs", ParentID: plants.ID})
+	if plants.ID == "" || herbs.ID == "" {
+		t.Fatal("AddCategory failed")
+	}
+	if categories := app.GetCategories(); len(categories) != 2 {
+		t.Fatalf("expected two categories, got %+v", categories)
+	}
+	if app.UpdateCategory(Category{ID: plants.ID, Name: "Plants", ParentID: herbs.ID}) {
+		t.Fatal("expected moving a category below its own child to fail")
+	}
+	if !app.UpdateCategory(Category{ID: herbs.ID, Name: "Kitchen Herbs", ParentID: plants.ID}) {
+		t.Fatal("UpdateCategory failed")
+	}
+
+	if _, err := db.AddProduct(context.Background(), Product{ID: "mint", Name: "Mint", Price: 4, Status: "In Stock", Category: herbs.ID, Tags: []string{"edible", "perennial"}}); err != nil {
+		t.Fatal(err)
+	}
+	if tags := app.GetTags(); strings.Join(tags, ",") != "edible,perennial" {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+	if products := app.QueryProducts(ProductQuery{CategoryID: plants.ID, IncludeSubcategories: true}); len(products) != 1 || products[0].ID != "mint" {
+		t.Fatalf("expected mint under plants, got %+v", products)
+	}
+	if products := app.QueryProducts(ProductQuery{Tags: []string{"edible"}}); len(products) != 1 {
+		t.Fatalf("expected one edible product, got %+v", products)
+	}
+	if groups := app.GetProductsByCategory(); len(groups) == 0 {
+		t.Fatal("expected products grouped by category")
+	}
+
+	if _, err := db.CreateOrder(context.Background(), "Dana", "", []OrderItem{{ProductID: "mint", ProductName: "Mint", Price: 4, Quantity: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	today := time.Now().Format("2006-01-02")
+	var herbSales *CategorySales
+	for _, sales := range app.GetCategorySales(today, today) {
+		if sales.CategoryID == herbs.ID {
+			herbSales = &sales
+		}
+	}
+	if herbSales == nil || herbSales.QuantitySold != 2 || herbSales.Revenue != 8 {
+		t.Fatalf("unexpected herb sales %+v", herbSales)
+	}
+
+	if app.DeleteCategory(herbs.ID) {
+		t.Fatal("staff should not be able to delete categories")
+	}
+	app.setCurrentUser(testAdmin)
+	if !app.DeleteCategory(herbs.ID) {
+		t.Fatal("DeleteCategory failed")
+	}
+}
+
+func TestAppVariantsAndBarcodes(t *testing.T) {
+	app, db := newSQLiteApp(t, testStaff)
+	if _, err := db.AddProduct(context.Background(), Product{ID: "fern", Name: "Fern", Price: 9, Status: "In Stock", Barcode: "4006381333931"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if reason := app.ValidateBarcode("4006381333931"); reason != "" {
+		t.Fatalf("expected a valid EAN-13, got %q", reason)
+	}
+	if reason := app.ValidateBarcode("4006381333932"); reason == "" {
+		t.Fatal("expected a wrong check digit to be reported")
+	}
+
+	variant := app.AddProductVariant(ProductVariant{ProductID: "fern", Size: "Large", Price: 14, SKU: "FERN-L"})
+	if variant.ID == "" {
+		t.Fatal("AddProductVariant failed")
+	}
+	if app.AddProductVariant(ProductVariant{ProductID: "fern", Size: "Small", SKU: "FERN-L"}).ID != "" {
+		t.Fatal("expected a duplicate SKU to be rejected")
+	}
+
+	variant.Price = 15
+	if !app.UpdateProductVariant(variant) {
+		t.Fatal("UpdateProductVariant failed")
+	}
+	if variants := app.GetProductVariants("fern"); len(variants) != 1 || variants[0].Price != 15 {
+		t.Fatalf("unexpected variants %+v", variants)
+	}
+
+	if lookup := app.LookupByBarcode("4006381333931"); !lookup.Found || lookup.Kind != "product" || lookup.Product.ID != "fern" {
+		t.Fatalf("unexpected product lookup %+v", lookup)
+	}
+	if lookup := app.LookupByBarcode("FERN-L"); !lookup.Found || lookup.Kind != "variant" {
+		t.Fatalf("unexpected variant lookup %+v", lookup)
+	}
+	if _, err := db.AddProduct(context.Background(), Product{ID: "moss", Name: "Moss", Price: 4, Status: "In Stock"}); err != nil {
+		t.Fatal(err)
+	}
+	if labels := productLabels(Product{ID: "moss", Name: "Moss"}); labels[0].Code != "moss" {
+		t.Fatalf("expected a product without codes to be labelled with its ID, got %+v", labels)
+	}
+	if lookup := app.LookupByBarcode("moss"); !lookup.Found || lookup.Kind != "product" || lookup.Product.ID != "moss" {
+		t.Fatalf("expected a label printed with the product ID to scan, got %+v", lookup)
+	}
+	if lookup := app.LookupByBarcode("0000000000000"); lookup.Found {
+		t.Fatalf("expected no match, got %+v", lookup)
+	}
+
+	if app.DeleteProductVariant(variant.ID) {
+		t.Fatal("staff should not be able to delete variants")
+	}
+	app.setCurrentUser(testAdmin)
+	if !app.DeleteProductVariant(variant.ID) {
+		t.Fatal("DeleteProductVariant failed")
+	}
+	if variants := app.GetProductVariants("fern"); len(variants) != 0 {
+		t.Fatalf("expected no variants left, got %+v", variants)
+	}
+}
+
+func TestAppPrices(t *testing.T) {
+	app, db := newSQLiteApp(t, testStaff)
+	if _, err := db.AddProduct(context.Background(), Product{ID: "rose", Name: "Rose", Price: 10, Status: "In Stock"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	nextWeek := time.Now().AddDate(0, 0, 7).Format("2006-01-02")
+
+	sale := app.SchedulePriceChange("rose", 8, tomorrow, nextWeek, "Spring sale")
+	if sale.ID == "" {
+		t.Fatal("SchedulePriceChange failed")
+	}
+	if app.SchedulePriceChange("rose", -1, tomorrow, "", "").ID != "" {
+		t.Fatal("expected a negative price to be rejected")
+	}
+	if history := app.GetPriceHistory("rose"); len(history) < 2 {
+		t.Fatalf("expected the base price and the sale in the history, got %+v", history)
+	}
+
+	if price := app.GetPriceAt("rose", tomorrow+" 12:00"); price != 8 {
+		t.Fatalf("expected the sale price tomorrow, got %v", price)
+	}
+	if price := app.GetPriceAt("rose", "not a date"); price != -1 {
+		t.Fatalf("expected -1 for an invalid date, got %v", price)
+	}
+
+	if !app.CancelScheduledPrice(sale.ID) {
+		t.Fatal("CancelScheduledPrice failed")
+	}
+	if app.CancelScheduledPrice(sale.ID) {
+		t.Fatal("expected cancelling twice to fail")
+	}
+	if price := app.GetPriceAt("rose", tomorrow+" 12:00"); price != 10 {
+		t.Fatalf("expected the base price after cancelling, got %v", price)
+	}
+}
+
+func TestAppProductImages(t *testing.T) {
+	app, db := newSQLiteApp(t, testStaff)
+	if _, err := db.AddProduct(context.Background(), Product{ID: "lily", Name: "Lily", Price: 6, Status: "In Stock"}); err != nil {
+		t.Fatal(err)
+	}
+
+	notAnImage := filepath.Join(t.TempDir(), "notes.txt")
+	os.WriteFile(notAnImage, []byte("not an image"), 0644)
+	if app.AttachProductImage("lily", notAnImage) {
+		t.Fatal("expected a non-image file to be rejected")
+	}
+
+	imagePath := filepath.Join(t.TempDir(), "lily.png")
+	if err := os.WriteFile(imagePath, testPNG(t, 16), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !app.AttachProductImage("lily", imagePath) {
+		t.Fatal("AttachProductImage failed")
+	}
+	name, err := db.GetProductImage(context.Background(), "lily")
+	if err != nil || name == "" {
+		t.Fatalf("expected the image to be linked, got %q, %v", name, err)
+	}
+
+	if !app.RemoveProductImage("lily") {
+		t.Fatal("RemoveProductImage failed")
+	}
+	if _, err := os.Stat(app.images.imagePath(name)); !os.IsNotExist(err) {
+		t.Fatalf("expected the unused image to be removed, got %v", err)
+	}
+
+	// Read-only users are refused before any file dialog opens
+	app.setCurrentUser(testReadOnly)
+	if app.SelectProductImage("lily") || app.AttachProductImage("lily", imagePath) || app.RemoveProductImage("lily") {
+		t.Fatal("read-only users should not change product images")
+	}
+}
+
+func TestAppBarcodeLabels(t *testing.T) {
+	app, db := newSQLiteApp(t, testStaff)
+	if _, err := db.AddProduct(context.Background(), Product{ID: "fern", Name: "Fern", Price: 9, Status: "In Stock", Barcode: "4006381333931"}); err != nil {
+		t.Fatal(err)
+	}
+
+	pdf, err := app.buildProductLabelSheet(context.Background(), db, []string{"fern"})
+	if err != nil {
+		t.Fatalf("failed to build label sheet: %v", err)
+	}
+	if !bytes.HasPrefix(pdf, []byte("%PDF")) {
+		t.Fatal("expected a PDF document")
+	}
+
+	// Unknown products fail before the save dialog opens
+	if app.GenerateBarcodeLabels([]string{"missing"}) {
+		t.Fatal("expected labels for a missing product to fail")
+	}
+}
+
+func TestAppDiagnostics(t *testing.T) {
+	app, _ := newSQLiteApp(t, testAdmin)
+
+	diagnostics := app.GetDiagnostics()
+	if diagnostics.SchemaVersion != schemaVersion || diagnostics.Integrity != "ok" || diagnostics.LogPath != app.logPath {
+		t.Fatalf("unexpected diagnostics %+v", diagnostics)
+	}
+
+	log := `{"time":"2026-01-02T10:00:00Z","level":"INFO","msg":"Starting application","subsystem":"app"}
+{"time":"2026-01-02T10:00:01Z","level":"ERROR","msg":"Error getting products","subsystem":"app","err":"disk full"}
+`
+	if err := os.WriteFile(app.logPath, []byte(log), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if entries := app.GetRecentLogs("", 0); len(entries) != 2 {
+		t.Fatalf("expected both entries, got %+v", entries)
+	}
+	entries := app.GetRecentLogs("error", 10)
+	if len(entries) != 1 || entries[0].Attrs["err"] != "disk full" {
+		t.Fatalf("expected the error entry, got %+v", entries)
+	}
+	if entries := app.GetRecentLogs("loud", 10); len(entries) != 0 {
+		t.Fatal("expected an unknown level to return nothing")
+	}
+
+	// Diagnostics are for admins only
+	app.setCurrentUser(testStaff)
+	if app.GetDiagnostics().Version != "" || len(app.GetRecentLogs("", 0)) != 0 || app.ExportSupportBundle() {
+		t.Fatal("staff should not see diagnostics")
+	}
+}
+
+func TestAppCallDeadline(t *testing.T) {
+	app, _ := newMemoryApp(t, testAdmin)
+
+	ctx, _, _, done := app.call("Test")
+	defer done()
+	deadline, ok := ctx.Deadline()
+	if !ok || time.Until(deadline) > callTimeout {
+		t.Fatalf("expected a deadline within %s, got %v", callTimeout, deadline)
+	}
+
+	ctx, _, _, exportDone := app.callWithTimeout("Export", exportTimeout)
+	defer exportDone()
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) <= callTimeout {
+		t.Fatalf("expected a longer deadline for exports, got %v", deadline)
+	}
+}
+
+func TestAppShutdownCancelsCalls(t *testing.T) {
+	app, db := newSQLiteApp(t, testAdmin)
+	app.background, app.stopBackground = context.WithCancel(context.Background())
+
+	// A query that never finishes on its own stands in for a long export
+	started := make(chan struct{})
+	result := make(chan error, 1)
+	go func() {
+		ctx, _, db, done := app.call("Export")
+		defer done()
+		close(started)
+		var count int
+		result <- db.read.QueryRowContext(ctx,
+			"WITH RECURSIVE n(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM n) SELECT COUNT(*) FROM n",
+		).Scan(&count)
+	}()
+	<-started
+	time.Sleep(100 * time.Millisecond) // let the query start running
+
+	begin := time.Now()
+	app.shutdown(context.Background())
+	if elapsed := time.Since(begin); elapsed >= shutdownTimeout {
+		t.Fatalf("shutdown waited %s for the call instead of cancelling it", elapsed)
+	}
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected the query to be cancelled, got %v", err)
+		}
+	default:
+		t.Fatal("expected the call to have returned before shutdown finished")
+	}
+	if _, err := db.GetProducts(context.Background()); err == nil {
+		t.Fatal("expected the database to be closed")
+	}
+}
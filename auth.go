@@ -0,0 +1,627 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// User roles, from most to least privileged
+const (
+	RoleAdmin    = "admin"
+	RoleStaff    = "staff"
+	RoleReadOnly = "read-only"
+)
+
+// roleRank orders the roles so a role is allowed everything a lower-ranked role is
+var roleRank = map[string]int{
+	RoleReadOnly: 1,
+	RoleStaff:    2,
+	RoleAdmin:    3,
+}
+
+// sessionDuration is how long a login stays valid
+const sessionDuration = 24 * time.Hour
+
+// minPasswordLength is the shortest password accepted for an account
+const minPasswordLength = 8
+
+// ErrNotAuthenticated is returned when an action requires a logged-in user
+var ErrNotAuthenticated = errors.New("not logged in")
+
+// ErrInvalidCredentials is returned when a username and password do not match an account
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// PermissionError is returned when the user's role does not allow an action
+type PermissionError struct {
+	Username string
+	Role     string
+	Required string
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("user %s with role %s is not allowed to do this, %s role required", e.Username, e.Role, e.Required)
+}
+
+// User is an account that can log in to the app or the API
+type User struct {
+	ID        string `json:"id"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// initializeUsers creates the users and sessions tables
+func (db *Database) initializeUsers() error {
+	_, err := db.db.Exec(`CREATE TABLE IF NOT EXISTS users (
+		id TEXT PRIMARY KEY,
+		username TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		password_hash TEXT NOT NULL,
+		role TEXT NOT NULL,
+		created_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create users table: %v", err)
+	}
+
+	// Only a hash of each session token is stored, so a copy of the database
+	// cannot be used to take over a session
+	_, err = db.db.Exec(`CREATE TABLE IF NOT EXISTS sessions (
+		token_hash TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		expires_at TEXT NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create sessions table: %v", err)
+	}
+
+	return nil
+}
+
+// requireRole checks that a user is logged in and has at least the required role
+func requireRole(user User, required string) error {
+	if user.ID == "" {
+		return ErrNotAuthenticated
+	}
+	if roleRank[user.Role] < roleRank[required] {
+		return &PermissionError{Username: user.Username, Role: user.Role, Required: required}
+	}
+	return nil
+}
+
+// validateUser checks the username, role and (when set) password of an account
+func validateUser(username string, password string, role string, passwordRequired bool) error {
+	if strings.TrimSpace(username) == "" {
+		return invalidf("username is required")
+	}
+	if _, ok := roleRank[role]; !ok {
+		return invalidf("invalid role %q, expected %s, %s or %s", role, RoleAdmin, RoleStaff, RoleReadOnly)
+	}
+	if (passwordRequired || password != "") && len(password) < minPasswordLength {
+		return invalidf("password must be at least %d characters", minPasswordLength)
+	}
+	return nil
+}
+
+// hashPassword returns the bcrypt hash of a password
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %v", err)
+	}
+	return string(hash), nil
+}
+
+// dummyPasswordHash returns a hash that no password is checked against for real
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte(uuid.New().String()), bcrypt.DefaultCost)
+	return hash
+})
+
+// hashToken returns the form of a session token stored in the database
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// insertUser adds an account within the given transaction
+func insertUser(ctx context.Context, tx *sql.Tx, username string, password string, role string) (User, error) {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return User{}, err
+	}
+
+	user := User{
+		ID:        uuid.New().String(),
+		Username:  strings.TrimSpace(username),
+		Role:      role,
+		CreatedAt: time.Now().Format(priceTimeLayout),
+	}
+
+	var taken int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE username = ?", user.Username).Scan(&taken); err != nil {
+		return User{}, fmt.Errorf("failed to check username: %v", err)
+	}
+	if taken > 0 {
+		return User{}, invalidf("username %s is already taken", user.Username)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO users (id, username, password_hash, role, created_at) VALUES (?, ?, ?, ?, ?)",
+		user.ID, user.Username, hash, user.Role, user.CreatedAt,
+	)
+	if err != nil {
+		return User{}, fmt.Errorf("failed to insert user: %v", err)
+	}
+	return user, nil
+}
+
+// CountUsers returns the number of accounts
+func (db *Database) CountUsers(ctx context.Context) (int, error) {
+	var count int
+	if err := db.read.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count users: %v", err)
+	}
+	return count, nil
+}
+
+// AddUser creates an account with the given role
+func (db *Database) AddUser(ctx context.Context, username string, password string, role string) (User, error) {
+	if err := validateUser(username, password, role, true); err != nil {
+		return User{}, err
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return User{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	user, err := insertUser(ctx, tx, username, password, role)
+	if err != nil {
+		return User{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return User{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return user, nil
+}
+
+// AddFirstAdmin creates the initial admin account. It fails once any account exists,
+// so it cannot be used to take over an installation that has been set up.
+func (db *Database) AddFirstAdmin(ctx context.Context, username string, password string) (User, error) {
+	if err := validateUser(username, password, RoleAdmin, true); err != nil {
+		return User{}, err
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return User{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var count int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		return User{}, fmt.Errorf("failed to count users: %v", err)
+	}
+	if count > 0 {
+		return User{}, invalidf("setup has already been completed")
+	}
+
+	user, err := insertUser(ctx, tx, username, password, RoleAdmin)
+	if err != nil {
+		return User{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return User{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return user, nil
+}
+
+// GetUsers retrieves all accounts, ordered by username
+func (db *Database) GetUsers(ctx context.Context) ([]User, error) {
+	rows, err := db.read.QueryContext(ctx, "SELECT id, username, role, created_at FROM users ORDER BY username")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %v", err)
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.Username, &user.Role, &user.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %v", err)
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating users: %v", err)
+	}
+
+	return users, nil
+}
+
+// GetUser retrieves a single account
+func (db *Database) GetUser(ctx context.Context, id string) (User, error) {
+	var user User
+	err := db.read.QueryRowContext(ctx, "SELECT id, username, role, created_at FROM users WHERE id = ?", id).
+		Scan(&user.ID, &user.Username, &user.Role, &user.CreatedAt)
+	if err == sql.ErrNoRows {
+		return User{}, &NotFoundError{Kind: "user", ID: id}
+	}
+	if err != nil {
+		return User{}, fmt.Errorf("failed to query user: %v", err)
+	}
+	return user, nil
+}
+
+// checkLastAdmin fails if the change would leave no admin account
+func checkLastAdmin(ctx context.Context, tx *sql.Tx, id string) error {
+	var admins int
+	err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE role = ? AND id != ?", RoleAdmin, id).Scan(&admins)
+	if err != nil {
+		return fmt.Errorf("failed to count admins: %v", err)
+	}
+	if admins == 0 {
+		return invalidf("at least one admin account is required")
+	}
+	return nil
+}
+
+// UpdateUser changes the role of an account and, when password is not empty, its password.
+// Changing the password logs the account out everywhere.
+func (db *Database) UpdateUser(ctx context.Context, id string, role string, password string) error {
+	user, err := db.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := validateUser(user.Username, password, role, false); err != nil {
+		return err
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if user.Role == RoleAdmin && role != RoleAdmin {
+		if err := checkLastAdmin(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET role = ? WHERE id = ?", role, id); err != nil {
+		return fmt.Errorf("failed to update user: %v", err)
+	}
+
+	if password != "" {
+		hash, err := hashPassword(password)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE users SET password_hash = ? WHERE id = ?", hash, id); err != nil {
+			return fmt.Errorf("failed to update password: %v", err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM sessions WHERE user_id = ?", id); err != nil {
+			return fmt.Errorf("failed to delete sessions: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// DeleteUser removes an account and its sessions
+func (db *Database) DeleteUser(ctx context.Context, id string) error {
+	user, err := db.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if user.Role == RoleAdmin {
+		if err := checkLastAdmin(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM sessions WHERE user_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete sessions: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM users WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete user: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// Authenticate checks a username and password and returns the matching account
+func (db *Database) Authenticate(ctx context.Context, username string, password string) (User, error) {
+	var user User
+	var hash string
+	err := db.read.QueryRowContext(ctx,
+		"SELECT id, username, role, created_at, password_hash FROM users WHERE username = ?",
+		strings.TrimSpace(username),
+	).Scan(&user.ID, &user.Username, &user.Role, &user.CreatedAt, &hash)
+	if err == sql.ErrNoRows {
+		// Compare against a throwaway hash so unknown usernames take as long as wrong passwords
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return User{}, fmt.Errorf("failed to query user: %v", err)
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return User{}, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// ChangePassword replaces the password of an account after checking the current one
+func (db *Database) ChangePassword(ctx context.Context, id string, oldPassword string, newPassword string) error {
+	user, err := db.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if _, err := db.Authenticate(ctx, user.Username, oldPassword); err != nil {
+		return err
+	}
+	return db.UpdateUser(ctx, id, user.Role, newPassword)
+}
+
+// CreateSession starts a session for an account and returns its token and expiry time
+func (db *Database) CreateSession(ctx context.Context, userID string) (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("failed to generate session token: %v", err)
+	}
+	token := hex.EncodeToString(buf)
+
+	now := time.Now()
+	expiresAt := now.Add(sessionDuration).Format(priceTimeLayout)
+
+	// Expired sessions are cleaned up whenever a new one starts
+	if _, err := db.db.ExecContext(ctx, "DELETE FROM sessions WHERE expires_at <= ?", now.Format(priceTimeLayout)); err != nil {
+		return "", "", fmt.Errorf("failed to delete expired sessions: %v", err)
+	}
+
+	_, err := db.db.ExecContext(ctx,
+		"INSERT INTO sessions (token_hash, user_id, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		hashToken(token), userID, now.Format(priceTimeLayout), expiresAt,
+	)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to insert session: %v", err)
+	}
+	return token, expiresAt, nil
+}
+
+// GetSessionUser returns the account a session token belongs to
+func (db *Database) GetSessionUser(ctx context.Context, token string) (User, error) {
+	var user User
+	err := db.read.QueryRowContext(ctx, `
+		SELECT u.id, u.username, u.role, u.created_at
+		FROM sessions s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.token_hash = ? AND s.expires_at > ?
+	`, hashToken(token), time.Now().Format(priceTimeLayout)).Scan(&user.ID, &user.Username, &user.Role, &user.CreatedAt)
+	if err == sql.ErrNoRows {
+		return User{}, ErrNotAuthenticated
+	}
+	if err != nil {
+		return User{}, fmt.Errorf("failed to query session: %v", err)
+	}
+	return user, nil
+}
+
+// DeleteSession ends a session
+func (db *Database) DeleteSession(ctx context.Context, token string) error {
+	if _, err := db.db.ExecContext(ctx, "DELETE FROM sessions WHERE token_hash = ?", hashToken(token)); err != nil {
+		return fmt.Errorf("failed to delete session: %v", err)
+	}
+	return nil
+}
+
+// authorize checks that the user logged in to the desktop app has at least the required role
+func (a *App) authorize(required string) error {
+	a.userMu.RLock()
+	defer a.userMu.RUnlock()
+	return requireRole(a.user, required)
+}
+
+// currentUser returns the user logged in to the desktop app
+func (a *App) currentUser() User {
+	a.userMu.RLock()
+	defer a.userMu.RUnlock()
+	return a.user
+}
+
+// setCurrentUser changes the user logged in to the desktop app
+func (a *App) setCurrentUser(user User) {
+	a.userMu.Lock()
+	defer a.userMu.Unlock()
+	a.user = user
+}
+
+// NeedsSetup reports whether no accounts exist yet and the initial admin must be created
+func (a *App) NeedsSetup() bool {
+	ctx, logger, db, done := a.call("NeedsSetup")
+	defer done()
+
+	count, err := db.CountUsers(ctx)
+	if err != nil {
+		logger.Error("Error counting users", "err", err)
+		return false
+	}
+	return count == 0
+}
+
+// CreateInitialAdmin creates the first admin account and logs it in
+func (a *App) CreateInitialAdmin(username string, password string) User {
+	ctx, logger, db, done := a.call("CreateInitialAdmin")
+	defer done()
+
+	user, err := db.AddFirstAdmin(ctx, username, password)
+	if err != nil {
+		logger.Error("Error creating initial admin", "err", err)
+		return User{}
+	}
+	logger.Info("Initial admin created", "username", user.Username)
+	a.setCurrentUser(user)
+	return user
+}
+
+// Login checks the credentials and makes the account the current user.
+// An empty user is returned if they do not match.
+func (a *App) Login(username string, password string) User {
+	ctx, logger, db, done := a.call("Login")
+	defer done()
+
+	user, err := db.Authenticate(ctx, username, password)
+	if err != nil {
+		logger.Warn("Failed login", "username", username, "err", err)
+		return User{}
+	}
+	logger.Info("User logged in", "username", user.Username)
+	a.setCurrentUser(user)
+	return user
+}
+
+// Logout ends the current user's session
+func (a *App) Logout() bool {
+	logger, _ := a.begin("Logout")
+
+	if user := a.currentUser(); user.ID != "" {
+		logger.Info("User logged out", "username", user.Username)
+	}
+	a.setCurrentUser(User{})
+	return true
+}
+
+// GetCurrentUser returns the logged-in user, or an empty user if nobody is logged in
+func (a *App) GetCurrentUser() User {
+	return a.currentUser()
+}
+
+// ChangePassword changes the current user's password
+func (a *App) ChangePassword(oldPassword string, newPassword string) bool {
+	ctx, logger, db, done := a.call("ChangePassword")
+	defer done()
+
+	user := a.currentUser()
+	if user.ID == "" {
+		logger.Error("Error changing password", "err", ErrNotAuthenticated)
+		return false
+	}
+	if err := db.ChangePassword(ctx, user.ID, oldPassword, newPassword); err != nil {
+		logger.Error("Error changing password", "err", err)
+		return false
+	}
+	return true
+}
+
+// GetUsers returns all accounts
+func (a *App) GetUsers() []User {
+	ctx, logger, db, done := a.call("GetUsers")
+	defer done()
+
+	if err := a.authorize(RoleAdmin); err != nil {
+		logger.Error("Error getting users", "err", err)
+		return []User{}
+	}
+
+	users, err := db.GetUsers(ctx)
+	if err != nil {
+		logger.Error("Error getting users", "err", err)
+		return []User{}
+	}
+	return users
+}
+
+// AddUser creates an account
+func (a *App) AddUser(username string, password string, role string) User {
+	ctx, logger, db, done := a.call("AddUser")
+	defer done()
+
+	if err := a.authorize(RoleAdmin); err != nil {
+		logger.Error("Error adding user", "err", err)
+		return User{}
+	}
+
+	user, err := db.AddUser(ctx, username, password, role)
+	if err != nil {
+		logger.Error("Error adding user", "err", err)
+		return User{}
+	}
+	return user
+}
+
+// UpdateUser changes the role of an account and, when password is not empty, its password
+func (a *App) UpdateUser(id string, role string, password string) bool {
+	ctx, logger, db, done := a.call("UpdateUser")
+	defer done()
+
+	if err := a.authorize(RoleAdmin); err != nil {
+		logger.Error("Error updating user", "err", err)
+		return false
+	}
+
+	if err := db.UpdateUser(ctx, id, role, password); err != nil {
+		logger.Error("Error updating user", "err", err)
+		return false
+	}
+
+	// Keep the current user's role in step if admins edit their own account
+	if current := a.currentUser(); current.ID == id {
+		current.Role = role
+		a.setCurrentUser(current)
+	}
+	return true
+}
+
+// DeleteUser removes an account
+func (a *App) DeleteUser(id string) bool {
+	ctx, logger, db, done := a.call("DeleteUser")
+	defer done()
+
+	if err := a.authorize(RoleAdmin); err != nil {
+		logger.Error("Error deleting user", "err", err)
+		return false
+	}
+
+	if a.currentUser().ID == id {
+		logger.Error("Error deleting user: cannot delete the account you are logged in with")
+		return false
+	}
+	if err := db.DeleteUser(ctx, id); err != nil {
+		logger.Error("Error deleting user", "err", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// BarcodeLookup is the result of resolving a scanned barcode or SKU.
+// Exactly one of Product or StockItem is set when Found is true; Variant is
+// set as well when the code belongs to a specific product variant.
+type BarcodeLookup struct {
+	Found     bool            `json:"found"`
+	Code      string          `json:"code"`
+	Kind      string          `json:"kind"` // "product", "variant" or "stock"
+	Product   *Product        `json:"product"`
+	Variant   *ProductVariant `json:"variant"`
+	StockItem *StockItem      `json:"stockItem"`
+}
+
+// initializeBarcodes adds SKU and barcode columns with their uniqueness constraints
+func (db *Database) initializeBarcodes() error {
+	columns := []struct {
+		table  string
+		column string
+	}{
+		{"products", "sku"},
+		{"products", "barcode"},
+		{"product_variants", "barcode"},
+		{"stock_items", "sku"},
+		{"stock_items", "barcode"},
+	}
+
+	for _, c := range columns {
+		exists, err := db.columnExists(c.table, c.column)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			_, err = db.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s TEXT", c.table, c.column))
+			if err != nil {
+				return fmt.Errorf("failed to add %s column to %s table: %v", c.column, c.table, err)
+			}
+		}
+
+		// Empty identifiers are stored as NULL, so only real values have to be unique
+		_, err = db.db.Exec(fmt.Sprintf(
+			"CREATE UNIQUE INDEX IF NOT EXISTS idx_%s_%s ON %s(%s) WHERE %s IS NOT NULL",
+			c.table, c.column, c.table, c.column, c.column,
+		))
+		if err != nil {
+			return fmt.Errorf("failed to create %s index on %s table: %v", c.column, c.table, err)
+		}
+	}
+
+	return nil
+}
+
+// normalizeBarcode strips the whitespace and control characters that keyboard-wedge
+// scanners send around a code
+func normalizeBarcode(code string) string {
+	return strings.TrimFunc(code, func(r rune) bool {
+		return r <= ' ' || r == 0x7f
+	})
+}
+
+// gtinCheckDigit computes the GS1 check digit for the given digits (without check digit)
+func gtinCheckDigit(digits string) int {
+	sum := 0
+	// Weights alternate 3, 1, 3, ... starting from the rightmost digit
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-1-i)%2 == 0 {
+			sum += d * 3
+		} else {
+			sum += d
+		}
+	}
+	return (10 - sum%10) % 10
+}
+
+// ValidateBarcode checks that a code is a well-formed EAN-8, UPC-A, EAN-13 or
+// GTIN-14 barcode with a correct check digit
+func ValidateBarcode(code string) error {
+	switch len(code) {
+	case 8, 12, 13, 14:
+	default:
+		return invalidf("barcode %q must have 8 (EAN-8), 12 (UPC-A), 13 (EAN-13) or 14 (GTIN-14) digits", code)
+	}
+
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return invalidf("barcode %q must contain digits only", code)
+		}
+	}
+
+	expected := gtinCheckDigit(code[:len(code)-1])
+	if actual := int(code[len(code)-1] - '0'); actual != expected {
+		return invalidf("barcode %q has an invalid check digit: expected %d, got %d", code, expected, actual)
+	}
+
+	return nil
+}
+
+// validateIdentifiers checks the format of a SKU and barcode and that neither is used
+// by a different product, variant or stock item
+func (db *Database) validateIdentifiers(ctx context.Context, ownerID string, sku string, barcode string) error {
+	if sku != "" && strings.TrimSpace(sku) != sku {
+		return invalidf("SKU %q must not start or end with whitespace", sku)
+	}
+
+	if barcode != "" {
+		if err := ValidateBarcode(barcode); err != nil {
+			return err
+		}
+	}
+
+	// SKUs and barcodes share one namespace so a scan always resolves to a single item
+	for _, value := range []string{sku, barcode} {
+		if value == "" {
+			continue
+		}
+
+		var count int
+		err := db.read.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM (
+				SELECT id FROM products WHERE (sku = ?1 COLLATE NOCASE OR barcode = ?1) AND id != ?2
+				UNION ALL
+				SELECT id FROM product_variants WHERE (sku = ?1 COLLATE NOCASE OR barcode = ?1) AND id != ?2
+				UNION ALL
+				SELECT id FROM stock_items WHERE (sku = ?1 COLLATE NOCASE OR barcode = ?1) AND id != ?2
+			)
+		`, value, ownerID).Scan(&count)
+		if err != nil {
+			return fmt.Errorf("failed to check identifier uniqueness: %v", err)
+		}
+		if count > 0 {
+			return invalidf("SKU or barcode %q is already in use", value)
+		}
+	}
+
+	return nil
+}
+
+// LookupByBarcode resolves a scanned code against product, variant and stock item
+// barcodes first, SKUs second and product IDs last, since labels of products without
+// a barcode or SKU carry the product's ID
+func (db *Database) LookupByBarcode(ctx context.Context, code string) (BarcodeLookup, error) {
+	code = normalizeBarcode(code)
+	result := BarcodeLookup{Code: code}
+	if code == "" {
+		return result, nil
+	}
+
+	for _, column := range []string{"barcode", "sku"} {
+		var productID string
+		err := db.read.QueryRowContext(ctx, "SELECT id FROM products WHERE "+column+" = ? COLLATE NOCASE", code).Scan(&productID)
+		if err != nil && err != sql.ErrNoRows {
+			return result, fmt.Errorf("failed to look up product: %v", err)
+		}
+		if err == nil {
+			product, err := db.getProduct(ctx, productID)
+			if err != nil {
+				return result, err
+			}
+			result.Found, result.Kind, result.Product = true, "product", product
+			return result, nil
+		}
+
+		var variantID, variantProductID string
+		err = db.read.QueryRowContext(ctx, "SELECT id, product_id FROM product_variants WHERE "+column+" = ? COLLATE NOCASE", code).
+			Scan(&variantID, &variantProductID)
+		if err != nil && err != sql.ErrNoRows {
+			return result, fmt.Errorf("failed to look up product variant: %v", err)
+		}
+		if err == nil {
+			product, err := db.getProduct(ctx, variantProductID)
+			if err != nil {
+				return result, err
+			}
+			for i := range product.Variants {
+				if product.Variants[i].ID == variantID {
+					result.Variant = &product.Variants[i]
+				}
+			}
+			result.Found, result.Kind, result.Product = true, "variant", product
+			return result, nil
+		}
+
+		var item StockItem
+		var description, sku, barcode sql.NullString
+		err = db.read.QueryRowContext(ctx,
+			"SELECT id, name, description, quantity, sku, barcode FROM stock_items WHERE "+column+" = ? COLLATE NOCASE", code,
+		).Scan(&item.ID, &item.Name, &description, &item.Quantity, &sku, &barcode)
+		if err != nil && err != sql.ErrNoRows {
+			return result, fmt.Errorf("failed to look up stock item: %v", err)
+		}
+		if err == nil {
+			item.Description, item.SKU, item.Barcode = description.String, sku.String, barcode.String
+			result.Found, result.Kind, result.StockItem = true, "stock", &item
+			return result, nil
+		}
+	}
+
+	product, err := db.getProduct(ctx, code)
+	var notFound *NotFoundError
+	if errors.As(err, &notFound) {
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	result.Found, result.Kind, result.Product = true, "product", product
+	return result, nil
+}
+
+// getProduct retrieves a single product with its variants and tags
+func (db *Database) getProduct(ctx context.Context, id string) (*Product, error) {
+	products, err := db.queryProducts(ctx, " WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(products) == 0 {
+		return nil, &NotFoundError{Kind: "product", ID: id}
+	}
+	return &products[0], nil
+}
+
+// ValidateBarcode reports whether a barcode is well-formed, returning an empty string
+// when it is valid and the reason otherwise
+func (a *App) ValidateBarcode(code string) string {
+	if err := ValidateBarcode(normalizeBarcode(code)); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// LookupByBarcode resolves a scanned barcode or typed SKU to a product, variant or stock item
+func (a *App) LookupByBarcode(code string) BarcodeLookup {
+	ctx, logger, db, done := a.call("LookupByBarcode")
+	defer done()
+
+	result, err := db.LookupByBarcode(ctx, code)
+	if err != nil {
+		logger.Error("Error looking up barcode", "code", code, "err", err)
+		return BarcodeLookup{Code: code}
+	}
+	return result
+}
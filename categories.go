@@ -0,0 +1,572 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Category represents a product category, optionally nested under a parent category
+type Category struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	ParentID string `json:"parentId"`
+	Path     string `json:"path"`
+}
+
+// ProductQuery describes the filters accepted by QueryProducts.
+// Empty fields are ignored, so the zero value matches every product.
+type ProductQuery struct {
+	CategoryID           string   `json:"categoryId"`
+	IncludeSubcategories bool     `json:"includeSubcategories"`
+	Uncategorized        bool     `json:"uncategorized"`
+	Tags                 []string `json:"tags"`
+	Search               string   `json:"search"`
+}
+
+// CategoryProducts groups products under the category they belong to
+type CategoryProducts struct {
+	Category Category  `json:"category"`
+	Products []Product `json:"products"`
+}
+
+// CategorySales summarizes sales of the products in a category
+type CategorySales struct {
+	CategoryID   string  `json:"categoryId"`
+	CategoryName string  `json:"categoryName"`
+	ProductCount int     `json:"productCount"`
+	OrderCount   int     `json:"orderCount"`
+	QuantitySold int     `json:"quantitySold"`
+	Revenue      float64 `json:"revenue"`
+}
+
+// uncategorizedName is used for products that are not assigned to any category
+const uncategorizedName = "Uncategorized"
+
+// initializeCategories creates the categories and product_tags tables
+func (db *Database) initializeCategories() error {
+	_, err := db.db.Exec(`CREATE TABLE IF NOT EXISTS categories (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		parent_id TEXT,
+		FOREIGN KEY (parent_id) REFERENCES categories(id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create categories table: %v", err)
+	}
+
+	_, err = db.db.Exec(`CREATE TABLE IF NOT EXISTS product_tags (
+		product_id TEXT NOT NULL,
+		tag TEXT NOT NULL,
+		PRIMARY KEY (product_id, tag),
+		FOREIGN KEY (product_id) REFERENCES products(id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create product_tags table: %v", err)
+	}
+
+	_, err = db.db.Exec("CREATE INDEX IF NOT EXISTS idx_product_tags_tag ON product_tags(tag)")
+	if err != nil {
+		return fmt.Errorf("failed to create product_tags index: %v", err)
+	}
+
+	return nil
+}
+
+// normalizeTags trims, lowercases and de-duplicates free-form tags
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// setProductTags replaces the tags of a product within the given transaction
+func setProductTags(ctx context.Context, tx *sql.Tx, productID string, tags []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM product_tags WHERE product_id = ?", productID); err != nil {
+		return fmt.Errorf("failed to clear product tags: %v", err)
+	}
+
+	for _, tag := range normalizeTags(tags) {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO product_tags (product_id, tag) VALUES (?, ?)", productID, tag); err != nil {
+			return fmt.Errorf("failed to insert product tag: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// getTagsByProduct retrieves the tags of the products matching a WHERE clause on
+// products, grouped by product ID
+func (db *Database) getTagsByProduct(ctx context.Context, where string, args ...interface{}) (map[string][]string, error) {
+	rows, err := db.read.QueryContext(ctx,
+		"SELECT product_id, tag FROM product_tags WHERE product_id IN (SELECT id FROM products"+where+") ORDER BY tag",
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query product tags: %v", err)
+	}
+	defer rows.Close()
+
+	byProduct := make(map[string][]string)
+	for rows.Next() {
+		var productID, tag string
+		if err := rows.Scan(&productID, &tag); err != nil {
+			return nil, fmt.Errorf("failed to scan product tag: %v", err)
+		}
+		byProduct[productID] = append(byProduct[productID], tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating product tags: %v", err)
+	}
+
+	return byProduct, nil
+}
+
+// productConditions builds the WHERE clause and arguments for a product query
+func productConditions(query ProductQuery) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if query.Uncategorized {
+		conditions = append(conditions, "(category IS NULL OR category = '')")
+	} else if query.CategoryID != "" {
+		if query.IncludeSubcategories {
+			conditions = append(conditions, `category IN (
+				WITH RECURSIVE subtree(id) AS (
+					SELECT ?
+					UNION
+					SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id
+				)
+				SELECT id FROM subtree
+			)`)
+		} else {
+			conditions = append(conditions, "category = ?")
+		}
+		args = append(args, query.CategoryID)
+	}
+
+	for _, tag := range normalizeTags(query.Tags) {
+		conditions = append(conditions, "id IN (SELECT product_id FROM product_tags WHERE tag = ?)")
+		args = append(args, tag)
+	}
+
+	if search := strings.TrimSpace(query.Search); search != "" {
+		conditions = append(conditions, "(name LIKE ? OR description LIKE ?)")
+		pattern := "%" + search + "%"
+		args = append(args, pattern, pattern)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// GetCategories retrieves all categories with their full path, ordered by path
+func (db *Database) GetCategories(ctx context.Context) ([]Category, error) {
+	rows, err := db.read.QueryContext(ctx, "SELECT id, name, parent_id FROM categories")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query categories: %v", err)
+	}
+	defer rows.Close()
+
+	byID := make(map[string]*Category)
+	var categories []*Category
+	for rows.Next() {
+		var category Category
+		var parentID sql.NullString
+		if err := rows.Scan(&category.ID, &category.Name, &parentID); err != nil {
+			return nil, fmt.Errorf("failed to scan category: %v", err)
+		}
+		category.ParentID = parentID.String
+		byID[category.ID] = &category
+		categories = append(categories, &category)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating categories: %v", err)
+	}
+
+	result := make([]Category, 0, len(categories))
+	for _, category := range categories {
+		category.Path = categoryPath(byID, category)
+		result = append(result, *category)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+
+	return result, nil
+}
+
+// categoryPath builds the "Parent / Child" path of a category, guarding against cycles
+func categoryPath(byID map[string]*Category, category *Category) string {
+	names := []string{category.Name}
+	visited := map[string]bool{category.ID: true}
+	for parentID := category.ParentID; parentID != ""; {
+		parent, ok := byID[parentID]
+		if !ok || visited[parentID] {
+			break
+		}
+		visited[parentID] = true
+		names = append([]string{parent.Name}, names...)
+		parentID = parent.ParentID
+	}
+	return strings.Join(names, " / ")
+}
+
+// AddCategory adds a new category to the database
+func (db *Database) AddCategory(ctx context.Context, category Category) (Category, error) {
+	if strings.TrimSpace(category.Name) == "" {
+		return Category{}, invalidf("category name is required")
+	}
+
+	// Generate a UUID if not provided
+	if category.ID == "" {
+		category.ID = uuid.New().String()
+	}
+
+	_, err := db.db.ExecContext(ctx,
+		"INSERT INTO categories (id, name, parent_id) VALUES (?, ?, ?)",
+		category.ID, category.Name, nullIfEmpty(category.ParentID),
+	)
+	if err != nil {
+		return Category{}, fmt.Errorf("failed to insert category: %v", err)
+	}
+
+	return category, nil
+}
+
+// UpdateCategory renames or moves an existing category
+func (db *Database) UpdateCategory(ctx context.Context, category Category) error {
+	if category.ParentID == category.ID {
+		return invalidf("category cannot be its own parent")
+	}
+
+	// Refuse to move a category below one of its own descendants
+	if category.ParentID != "" {
+		var isDescendant int
+		err := db.read.QueryRowContext(ctx, `
+			WITH RECURSIVE subtree(id) AS (
+				SELECT ?
+				UNION
+				SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id
+			)
+			SELECT COUNT(*) FROM subtree WHERE id = ?
+		`, category.ID, category.ParentID).Scan(&isDescendant)
+		if err != nil {
+			return fmt.Errorf("failed to check category hierarchy: %v", err)
+		}
+		if isDescendant > 0 {
+			return invalidf("category cannot be moved below its own subcategory")
+		}
+	}
+
+	result, err := db.db.ExecContext(ctx,
+		"UPDATE categories SET name = ?, parent_id = ? WHERE id = ?",
+		category.Name, nullIfEmpty(category.ParentID), category.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update category: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return &NotFoundError{Kind: "category", ID: category.ID}
+	}
+
+	return nil
+}
+
+// DeleteCategory removes a category. Its subcategories move up to its parent
+// and its products become uncategorized.
+func (db *Database) DeleteCategory(ctx context.Context, id string) (err error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var parentID sql.NullString
+	err = tx.QueryRowContext(ctx, "SELECT parent_id FROM categories WHERE id = ?", id).Scan(&parentID)
+	if err == sql.ErrNoRows {
+		return &NotFoundError{Kind: "category", ID: id}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query category: %v", err)
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE categories SET parent_id = ? WHERE parent_id = ?", parentID, id); err != nil {
+		return fmt.Errorf("failed to re-parent subcategories: %v", err)
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE products SET category = NULL WHERE category = ?", id); err != nil {
+		return fmt.Errorf("failed to uncategorize products: %v", err)
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM categories WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete category: %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// GetTags retrieves every distinct tag in use, alphabetically
+func (db *Database) GetTags(ctx context.Context) ([]string, error) {
+	rows, err := db.read.QueryContext(ctx, "SELECT DISTINCT tag FROM product_tags ORDER BY tag")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %v", err)
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %v", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tags: %v", err)
+	}
+
+	return tags, nil
+}
+
+// GetProductsByCategory retrieves all products grouped by category, in category path order.
+// Products without a category are collected in a trailing "Uncategorized" group.
+func (db *Database) GetProductsByCategory(ctx context.Context) ([]CategoryProducts, error) {
+	categories, err := db.GetCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	products, err := db.GetProducts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byCategory := make(map[string][]Product)
+	for _, product := range products {
+		byCategory[product.Category] = append(byCategory[product.Category], product)
+	}
+
+	groups := []CategoryProducts{}
+	for _, category := range categories {
+		if items, ok := byCategory[category.ID]; ok {
+			groups = append(groups, CategoryProducts{Category: category, Products: items})
+			delete(byCategory, category.ID)
+		}
+	}
+
+	// Anything left references no category or a category that no longer exists
+	var uncategorized []Product
+	for _, product := range products {
+		if _, ok := byCategory[product.Category]; ok {
+			uncategorized = append(uncategorized, product)
+		}
+	}
+	if len(uncategorized) > 0 {
+		groups = append(groups, CategoryProducts{
+			Category: Category{Name: uncategorizedName, Path: uncategorizedName},
+			Products: uncategorized,
+		})
+	}
+
+	return groups, nil
+}
+
+// GetCategorySales aggregates order lines per product category.
+// Cancelled orders are excluded; empty dates leave the range open.
+func (db *Database) GetCategorySales(ctx context.Context, fromDate string, toDate string) ([]CategorySales, error) {
+	query := `
+		SELECT COALESCE(c.id, ''), COALESCE(c.name, ?),
+			COUNT(DISTINCT oi.product_id), COUNT(DISTINCT o.id),
+			COALESCE(SUM(oi.quantity), 0), COALESCE(SUM(oi.price * oi.quantity), 0)
+		FROM order_items oi
+		JOIN orders o ON o.id = oi.order_id
+		LEFT JOIN products p ON p.id = oi.product_id
+		LEFT JOIN categories c ON c.id = p.category
+		WHERE o.status != 'Cancelled'`
+	args := []interface{}{uncategorizedName}
+
+	if fromDate != "" {
+		query += " AND o.date >= ?"
+		args = append(args, fromDate)
+	}
+	if toDate != "" {
+		// Dates are stored with a time part, so include the whole final day
+		query += " AND o.date < date(?, '+1 day')"
+		args = append(args, toDate)
+	}
+	query += " GROUP BY c.id ORDER BY 6 DESC"
+
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query category sales: %v", err)
+	}
+	defer rows.Close()
+
+	sales := []CategorySales{}
+	for rows.Next() {
+		var entry CategorySales
+		if err := rows.Scan(&entry.CategoryID, &entry.CategoryName, &entry.ProductCount,
+			&entry.OrderCount, &entry.QuantitySold, &entry.Revenue); err != nil {
+			return nil, fmt.Errorf("failed to scan category sales: %v", err)
+		}
+		sales = append(sales, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating category sales: %v", err)
+	}
+
+	return sales, nil
+}
+
+// GetCategories returns all categories
+func (a *App) GetCategories() []Category {
+	ctx, logger, db, done := a.call("GetCategories")
+	defer done()
+
+	categories, err := db.GetCategories(ctx)
+	if err != nil {
+		logger.Error("Error getting categories", "err", err)
+		return []Category{}
+	}
+	return categories
+}
+
+// AddCategory adds a new category
+func (a *App) AddCategory(category Category) Category {
+	ctx, logger, db, done := a.call("AddCategory")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error adding category", "err", err)
+		return Category{}
+	}
+
+	savedCategory, err := db.AddCategory(ctx, category)
+	if err != nil {
+		logger.Error("Error adding category", "err", err)
+		return Category{}
+	}
+	return savedCategory
+}
+
+// UpdateCategory updates an existing category
+func (a *App) UpdateCategory(category Category) bool {
+	ctx, logger, db, done := a.call("UpdateCategory")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error updating category", "err", err)
+		return false
+	}
+
+	err := db.UpdateCategory(ctx, category)
+	if err != nil {
+		logger.Error("Error updating category", "err", err)
+		return false
+	}
+	return true
+}
+
+// DeleteCategory removes a category by ID
+func (a *App) DeleteCategory(id string) bool {
+	ctx, logger, db, done := a.call("DeleteCategory")
+	defer done()
+
+	if err := a.authorize(RoleAdmin); err != nil {
+		logger.Error("Error deleting category", "err", err)
+		return false
+	}
+
+	err := db.DeleteCategory(ctx, id)
+	if err != nil {
+		logger.Error("Error deleting category", "err", err)
+		return false
+	}
+	return true
+}
+
+// GetTags returns all tags currently assigned to products
+func (a *App) GetTags() []string {
+	ctx, logger, db, done := a.call("GetTags")
+	defer done()
+
+	tags, err := db.GetTags(ctx)
+	if err != nil {
+		logger.Error("Error getting tags", "err", err)
+		return []string{}
+	}
+	return tags
+}
+
+// QueryProducts returns the products matching the given filters
+func (a *App) QueryProducts(query ProductQuery) []Product {
+	ctx, logger, db, done := a.call("QueryProducts")
+	defer done()
+
+	products, err := db.QueryProducts(ctx, query)
+	if err != nil {
+		logger.Error("Error querying products", "err", err)
+		return []Product{}
+	}
+	return products
+}
+
+// GetProductsByCategory returns all products grouped by category
+func (a *App) GetProductsByCategory() []CategoryProducts {
+	ctx, logger, db, done := a.call("GetProductsByCategory")
+	defer done()
+
+	groups, err := db.GetProductsByCategory(ctx)
+	if err != nil {
+		logger.Error("Error grouping products by category", "err", err)
+		return []CategoryProducts{}
+	}
+	return groups
+}
+
+// GetCategorySales returns sales totals per category between two dates (YYYY-MM-DD, inclusive)
+func (a *App) GetCategorySales(fromDate string, toDate string) []CategorySales {
+	ctx, logger, db, done := a.call("GetCategorySales")
+	defer done()
+
+	sales, err := db.GetCategorySales(ctx, fromDate, toDate)
+	if err != nil {
+		logger.Error("Error getting category sales", "err", err)
+		return []CategorySales{}
+	}
+	return sales
+}
@@ -0,0 +1,712 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Exit codes returned by command-line subcommands
+const (
+	exitOK       = 0
+	exitFailure  = 1 // the command failed
+	exitUsage    = 2 // bad arguments or invalid input
+	exitNotFound = 3 // a record named on the command line does not exist
+)
+
+const cliUsage = `usage: wails-app <command> [flags]
+
+Commands:
+  products list [--category ID [--subcategories]] [--uncategorized] [--tag TAG]... [--search TEXT]
+  products lookup CODE              find a product, variant or stock item by barcode, SKU or product ID
+  orders list [--status STATUS]
+  orders create --from FILE         create an order from JSON ("-" reads stdin)
+  orders status ID STATUS           change the status of an order
+  stock list
+  stock adjust ID|CODE DELTA        add (or with a negative DELTA remove) stock
+  stock adjust --set ID|CODE QTY    set the quantity of a stock item
+    [--location ID]                 at a location (default: main)
+  stock receive ID|CODE QTY --lot LOT [--expires YYYY-MM-DD] [--location ID]
+                                    receive a lot of a stock item
+  stock expiring [--days N]         list lots that expire within N days (default: 30)
+  backup DEST                       write a copy of the database to DEST
+  export csv products|orders|stock [--out FILE]
+  serve [--addr HOST:PORT] [--db PATH] [--cors-origin ORIGIN]
+
+Common flags:
+  --db PATH          database to use (defaults to the desktop app's database)
+  --format FORMAT    output format: table (default) or json
+  --verbose          show log messages on stderr
+
+Commands act directly on the database file, with the same access as the desktop app's admin.`
+
+// cli is one invocation of a command-line subcommand
+type cli struct {
+	ctx     context.Context // cancelled by Ctrl-C, so a long export stops cleanly
+	stdout  io.Writer
+	stderr  io.Writer
+	dbPath  string
+	format  string
+	verbose bool
+}
+
+// cliTable is tabular output: a header row and data rows
+type cliTable struct {
+	headers []string
+	rows    [][]string
+}
+
+// runCommand runs a subcommand from the process command line and returns its exit code
+func runCommand(args []string) int {
+	if len(args) > 0 && args[0] == "serve" {
+		return runServe(args[1:])
+	}
+
+	return runCLI(args, os.Stdout, os.Stderr)
+}
+
+// runCLI runs a subcommand writing to the given streams and returns its exit code
+func runCLI(args []string, stdout io.Writer, stderr io.Writer) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	c := &cli{ctx: ctx, stdout: stdout, stderr: stderr}
+	if len(args) == 0 {
+		return c.usageError("no command given")
+	}
+
+	command, args := args[0], args[1:]
+	switch command {
+	case "help", "-h", "--help":
+		fmt.Fprintln(stdout, cliUsage)
+		return exitOK
+	case "products":
+		return c.runGroup(command, args, map[string]func([]string) int{
+			"list":   c.productsList,
+			"lookup": c.productsLookup,
+		})
+	case "orders":
+		return c.runGroup(command, args, map[string]func([]string) int{
+			"list":   c.ordersList,
+			"create": c.ordersCreate,
+			"status": c.ordersStatus,
+		})
+	case "stock":
+		return c.runGroup(command, args, map[string]func([]string) int{
+			"list":     c.stockList,
+			"adjust":   c.stockAdjust,
+			"receive":  c.stockReceive,
+			"expiring": c.stockExpiring,
+		})
+	case "backup":
+		return c.backup(args)
+	case "export":
+		return c.runGroup(command, args, map[string]func([]string) int{
+			"csv": c.exportCSV,
+		})
+	default:
+		return c.usageError("unknown command %q", command)
+	}
+}
+
+// runGroup dispatches the second word of a command such as "products list"
+func (c *cli) runGroup(group string, args []string, commands map[string]func([]string) int) int {
+	if len(args) == 0 {
+		return c.usageError("%s needs a subcommand", group)
+	}
+	run, ok := commands[args[0]]
+	if !ok {
+		return c.usageError("unknown command %q", group+" "+args[0])
+	}
+	return run(args[1:])
+}
+
+// usageError reports bad arguments and returns exitUsage
+func (c *cli) usageError(format string, args ...interface{}) int {
+	fmt.Fprintf(c.stderr, "error: "+format+"\n\n", args...)
+	fmt.Fprintln(c.stderr, cliUsage)
+	return exitUsage
+}
+
+// fail reports an error and returns the matching exit code
+func (c *cli) fail(err error) int {
+	fmt.Fprintf(c.stderr, "error: %v\n", err)
+
+	var notFound *NotFoundError
+	var invalid *ValidationError
+	switch {
+	case errors.As(err, &notFound):
+		return exitNotFound
+	case errors.As(err, &invalid):
+		return exitUsage
+	default:
+		return exitFailure
+	}
+}
+
+// flags returns a flag set with the flags shared by every subcommand
+func (c *cli) flags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.StringVar(&c.dbPath, "db", "", "database to use")
+	flags.StringVar(&c.format, "format", "table", "output format: table or json")
+	flags.BoolVar(&c.verbose, "verbose", false, "show log messages")
+	return flags
+}
+
+// parse parses the flags of a subcommand and checks its positional argument count
+func (c *cli) parse(flags *flag.FlagSet, args []string, positional int) ([]string, bool) {
+	// Allow flags after positional arguments, e.g. "stock adjust SOIL -3 --db x",
+	// where negative numbers are arguments rather than flags
+	var rest []string
+	for len(args) > 0 {
+		if _, err := strconv.ParseFloat(args[0], 64); err == nil {
+			rest = append(rest, args[0])
+			args = args[1:]
+			continue
+		}
+		if err := flags.Parse(args); err != nil {
+			return nil, false
+		}
+		args = flags.Args()
+		if len(args) > 0 {
+			rest = append(rest, args[0])
+			args = args[1:]
+		}
+	}
+
+	if c.format != "table" && c.format != "json" {
+		c.usageError("unknown format %q, expected table or json", c.format)
+		return nil, false
+	}
+	if len(rest) != positional {
+		c.usageError("%s expects %d argument(s), got %d", flags.Name(), positional, len(rest))
+		return nil, false
+	}
+	return rest, true
+}
+
+// open opens the database chosen with --db, or the desktop app's database
+func (c *cli) open() (*Database, error) {
+	logger := c.logger()
+
+	if c.dbPath != "" {
+		if _, err := os.Stat(c.dbPath); err != nil {
+			return nil, fmt.Errorf("cannot open database %s: %v", c.dbPath, err)
+		}
+		return OpenDatabase(c.dbPath, logger)
+	}
+	return NewDatabase(logger)
+}
+
+// logger returns the logger of the database, writing to stderr: warnings and errors
+// only, or everything with --verbose
+func (c *cli) logger() *slog.Logger {
+	config := defaultLogConfig()
+	config.Level = "warn"
+	if c.verbose {
+		config.Level = "debug"
+	}
+	return NewLogging(c.stderr, config).Logger("db")
+}
+
+// print writes a result as JSON or as a table, depending on --format
+func (c *cli) print(value interface{}, table cliTable) int {
+	if c.format == "json" {
+		encoder := json.NewEncoder(c.stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(value); err != nil {
+			return c.fail(err)
+		}
+		return exitOK
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(table.headers, "\t"))
+	for _, row := range table.rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	if err := w.Flush(); err != nil {
+		return c.fail(err)
+	}
+	return exitOK
+}
+
+// withDatabase opens the database, runs fn and closes the database again
+func (c *cli) withDatabase(fn func(db *Database) int) int {
+	db, err := c.open()
+	if err != nil {
+		return c.fail(err)
+	}
+	defer db.Close()
+	return fn(db)
+}
+
+// formatAmount formats a price or quantity for table output
+func formatAmount(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// productsTable renders products as a table
+func productsTable(products []Product) cliTable {
+	table := cliTable{headers: []string{"ID", "NAME", "SKU", "BARCODE", "PRICE", "STATUS", "TAGS"}}
+	for _, p := range products {
+		table.rows = append(table.rows, []string{
+			p.ID, p.Name, p.SKU, p.Barcode, fmt.Sprintf("%.2f", p.Price), p.Status, strings.Join(p.Tags, ","),
+		})
+	}
+	return table
+}
+
+// ordersTable renders orders as a table
+func ordersTable(orders []Order) cliTable {
+	table := cliTable{headers: []string{"ID", "DATE", "NAME", "ITEMS", "TOTAL", "STATUS"}}
+	for _, o := range orders {
+		table.rows = append(table.rows, []string{
+			o.ID, o.Date, o.Name, strconv.Itoa(len(o.Items)), fmt.Sprintf("%.2f", o.Total), o.Status,
+		})
+	}
+	return table
+}
+
+// stockTable renders stock items as a table
+func stockTable(items []StockItem) cliTable {
+	table := cliTable{headers: []string{"ID", "NAME", "SKU", "BARCODE", "QUANTITY", "RESERVED", "AVAILABLE"}}
+	for _, item := range items {
+		table.rows = append(table.rows, []string{
+			item.ID, item.Name, item.SKU, item.Barcode,
+			formatAmount(item.Quantity), formatAmount(item.Reserved), formatAmount(item.Available),
+		})
+	}
+	return table
+}
+
+// lotsTable renders lots as a table
+func lotsTable(lots []Lot) cliTable {
+	table := cliTable{headers: []string{"ITEM", "LOT", "EXPIRES", "QUANTITY", "LOCATIONS"}}
+	for _, lot := range lots {
+		locations := make([]string, len(lot.Locations))
+		for i, level := range lot.Locations {
+			locations[i] = level.LocationName + " " + formatAmount(level.Quantity)
+		}
+		table.rows = append(table.rows, []string{
+			lot.StockItemName, lot.LotNumber, lot.ExpiresOn, formatAmount(lot.Quantity), strings.Join(locations, ", "),
+		})
+	}
+	return table
+}
+
+// stringList is a flag that can be repeated
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+// productsList runs "products list"
+func (c *cli) productsList(args []string) int {
+	var query ProductQuery
+	var tags stringList
+	flags := c.flags("products list")
+	flags.StringVar(&query.CategoryID, "category", "", "only products in this category")
+	flags.BoolVar(&query.IncludeSubcategories, "subcategories", false, "include subcategories of --category")
+	flags.BoolVar(&query.Uncategorized, "uncategorized", false, "only products without a category")
+	flags.Var(&tags, "tag", "only products with this tag (repeatable)")
+	flags.StringVar(&query.Search, "search", "", "match name and description")
+	if _, ok := c.parse(flags, args, 0); !ok {
+		return exitUsage
+	}
+	query.Tags = tags
+
+	return c.withDatabase(func(db *Database) int {
+		products, err := db.QueryProducts(c.ctx, query)
+		if err != nil {
+			return c.fail(err)
+		}
+		if products == nil {
+			products = []Product{}
+		}
+		return c.print(products, productsTable(products))
+	})
+}
+
+// productsLookup runs "products lookup CODE"
+func (c *cli) productsLookup(args []string) int {
+	flags := c.flags("products lookup")
+	rest, ok := c.parse(flags, args, 1)
+	if !ok {
+		return exitUsage
+	}
+
+	return c.withDatabase(func(db *Database) int {
+		result, err := db.LookupByBarcode(c.ctx, rest[0])
+		if err != nil {
+			return c.fail(err)
+		}
+		if !result.Found {
+			return c.fail(&NotFoundError{Kind: "product, variant or stock item", ID: rest[0]})
+		}
+
+		table := cliTable{headers: []string{"KIND", "ID", "NAME", "SKU", "BARCODE", "PRICE/QTY"}}
+		switch {
+		case result.StockItem != nil:
+			item := result.StockItem
+			table.rows = append(table.rows, []string{result.Kind, item.ID, item.Name, item.SKU, item.Barcode, formatAmount(item.Quantity)})
+		case result.Variant != nil:
+			v := result.Variant
+			name := strings.TrimSpace(result.Product.Name + " " + strings.Join([]string{v.Size, v.Color}, " "))
+			table.rows = append(table.rows, []string{result.Kind, v.ID, name, v.SKU, v.Barcode, fmt.Sprintf("%.2f", v.Price)})
+		case result.Product != nil:
+			p := result.Product
+			table.rows = append(table.rows, []string{result.Kind, p.ID, p.Name, p.SKU, p.Barcode, fmt.Sprintf("%.2f", p.Price)})
+		}
+		return c.print(result, table)
+	})
+}
+
+// ordersList runs "orders list"
+func (c *cli) ordersList(args []string) int {
+	flags := c.flags("orders list")
+	status := flags.String("status", "", "only orders with this status")
+	if _, ok := c.parse(flags, args, 0); !ok {
+		return exitUsage
+	}
+
+	return c.withDatabase(func(db *Database) int {
+		orders, err := db.GetOrders(c.ctx)
+		if err != nil {
+			return c.fail(err)
+		}
+
+		filtered := []Order{}
+		for _, order := range orders {
+			if *status == "" || strings.EqualFold(order.Status, *status) {
+				filtered = append(filtered, order)
+			}
+		}
+		return c.print(filtered, ordersTable(filtered))
+	})
+}
+
+// ordersCreate runs "orders create --from FILE"
+func (c *cli) ordersCreate(args []string) int {
+	flags := c.flags("orders create")
+	from := flags.String("from", "", `JSON file with the order ("-" for stdin)`)
+	if _, ok := c.parse(flags, args, 0); !ok {
+		return exitUsage
+	}
+	if *from == "" {
+		return c.usageError("orders create needs --from")
+	}
+
+	var data []byte
+	var err error
+	if *from == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(*from)
+	}
+	if err != nil {
+		return c.fail(err)
+	}
+
+	var order NewOrder
+	if err := json.Unmarshal(data, &order); err != nil {
+		return c.fail(invalidf("invalid order JSON in %s: %v", *from, err))
+	}
+	if err := validateNewOrder(order.Name, order.Items); err != nil {
+		return c.fail(err)
+	}
+
+	return c.withDatabase(func(db *Database) int {
+		id, err := db.CreateOrder(c.ctx, order.Name, order.Description, order.Items)
+		if err != nil {
+			return c.fail(err)
+		}
+		created, err := db.GetOrder(c.ctx, id)
+		if err != nil {
+			return c.fail(err)
+		}
+		return c.print(created, ordersTable([]Order{*created}))
+	})
+}
+
+// ordersStatus runs "orders status ID STATUS"
+func (c *cli) ordersStatus(args []string) int {
+	flags := c.flags("orders status")
+	rest, ok := c.parse(flags, args, 2)
+	if !ok {
+		return exitUsage
+	}
+
+	return c.withDatabase(func(db *Database) int {
+		if err := db.UpdateOrderStatus(c.ctx, rest[0], rest[1]); err != nil {
+			return c.fail(err)
+		}
+		order, err := db.GetOrder(c.ctx, rest[0])
+		if err != nil {
+			return c.fail(err)
+		}
+		return c.print(order, ordersTable([]Order{*order}))
+	})
+}
+
+// stockList runs "stock list"
+func (c *cli) stockList(args []string) int {
+	flags := c.flags("stock list")
+	if _, ok := c.parse(flags, args, 0); !ok {
+		return exitUsage
+	}
+
+	return c.withDatabase(func(db *Database) int {
+		items, err := db.GetStockItems(c.ctx)
+		if err != nil {
+			return c.fail(err)
+		}
+		if items == nil {
+			items = []StockItem{}
+		}
+		return c.print(items, stockTable(items))
+	})
+}
+
+// stockAdjust runs "stock adjust ID|CODE DELTA"
+func (c *cli) stockAdjust(args []string) int {
+	flags := c.flags("stock adjust")
+	set := flags.Bool("set", false, "set the quantity instead of adjusting it")
+	location := flags.String("location", DefaultLocationID, "ID of the location to adjust the quantity at")
+	rest, ok := c.parse(flags, args, 2)
+	if !ok {
+		return exitUsage
+	}
+
+	amount, err := strconv.ParseFloat(rest[1], 64)
+	if err != nil {
+		return c.usageError("invalid quantity %q", rest[1])
+	}
+
+	return c.withDatabase(func(db *Database) int {
+		item, err := resolveStockItem(c.ctx, db, rest[0])
+		if err != nil {
+			return c.fail(err)
+		}
+
+		if *set {
+			if amount < 0 {
+				return c.fail(invalidf("quantity cannot be negative"))
+			}
+			for _, level := range item.Locations {
+				if level.LocationID == *location {
+					amount -= level.Quantity
+				}
+			}
+		}
+		if amount == 0 {
+			return c.print(item, stockTable([]StockItem{item}))
+		}
+
+		item, err = db.AdjustStockQuantity(c.ctx, item.ID, StockAdjustment{LocationID: *location, Delta: amount}, "")
+		if err != nil {
+			return c.fail(err)
+		}
+		return c.print(item, stockTable([]StockItem{item}))
+	})
+}
+
+// stockReceive runs "stock receive ID|CODE QTY --lot LOT"
+func (c *cli) stockReceive(args []string) int {
+	var receipt StockReceipt
+	flags := c.flags("stock receive")
+	flags.StringVar(&receipt.LotNumber, "lot", "", "lot number of the received stock")
+	flags.StringVar(&receipt.ExpiresOn, "expires", "", "last day the lot can be sold (YYYY-MM-DD)")
+	flags.StringVar(&receipt.LocationID, "location", DefaultLocationID, "ID of the location receiving the stock")
+	rest, ok := c.parse(flags, args, 2)
+	if !ok {
+		return exitUsage
+	}
+	if receipt.LotNumber == "" {
+		return c.usageError("stock receive needs --lot")
+	}
+
+	quantity, err := strconv.ParseFloat(rest[1], 64)
+	if err != nil {
+		return c.usageError("invalid quantity %q", rest[1])
+	}
+	receipt.Quantity = quantity
+
+	return c.withDatabase(func(db *Database) int {
+		item, err := resolveStockItem(c.ctx, db, rest[0])
+		if err != nil {
+			return c.fail(err)
+		}
+		item, err = db.ReceiveStock(c.ctx, item.ID, receipt, "")
+		if err != nil {
+			return c.fail(err)
+		}
+		return c.print(item, stockTable([]StockItem{item}))
+	})
+}
+
+// stockExpiring runs "stock expiring"
+func (c *cli) stockExpiring(args []string) int {
+	flags := c.flags("stock expiring")
+	days := flags.Int("days", expiringLotDays, "how many days ahead to look")
+	if _, ok := c.parse(flags, args, 0); !ok {
+		return exitUsage
+	}
+
+	return c.withDatabase(func(db *Database) int {
+		lots, err := db.GetExpiringLots(c.ctx, *days)
+		if err != nil {
+			return c.fail(err)
+		}
+		return c.print(lots, lotsTable(lots))
+	})
+}
+
+// resolveStockItem finds a stock item by ID, SKU or barcode
+func resolveStockItem(ctx context.Context, db *Database, ref string) (StockItem, error) {
+	item, err := db.GetStockItem(ctx, ref)
+	var notFound *NotFoundError
+	if !errors.As(err, &notFound) {
+		return item, err
+	}
+
+	result, lookupErr := db.LookupByBarcode(ctx, ref)
+	if lookupErr != nil {
+		return StockItem{}, lookupErr
+	}
+	if result.StockItem == nil {
+		return StockItem{}, err
+	}
+	return *result.StockItem, nil
+}
+
+// backup runs "backup DEST"
+func (c *cli) backup(args []string) int {
+	flags := c.flags("backup")
+	rest, ok := c.parse(flags, args, 1)
+	if !ok {
+		return exitUsage
+	}
+
+	return c.withDatabase(func(db *Database) int {
+		if err := db.Backup(c.ctx, rest[0]); err != nil {
+			return c.fail(err)
+		}
+		info, err := os.Stat(rest[0])
+		if err != nil {
+			return c.fail(err)
+		}
+
+		result := map[string]interface{}{"path": rest[0], "bytes": info.Size()}
+		return c.print(result, cliTable{
+			headers: []string{"BACKUP", "BYTES"},
+			rows:    [][]string{{rest[0], strconv.FormatInt(info.Size(), 10)}},
+		})
+	})
+}
+
+// exportCSV runs "export csv products|orders|stock"
+func (c *cli) exportCSV(args []string) int {
+	flags := c.flags("export csv")
+	out := flags.String("out", "", "file to write (defaults to stdout)")
+	rest, ok := c.parse(flags, args, 1)
+	if !ok {
+		return exitUsage
+	}
+
+	var rows func(ctx context.Context, db *Database) ([][]string, error)
+	switch rest[0] {
+	case "products":
+		rows = productCSVRows
+	case "orders":
+		rows = orderCSVRows
+	case "stock":
+		rows = stockCSVRows
+	default:
+		return c.usageError("cannot export %q, expected products, orders or stock", rest[0])
+	}
+
+	return c.withDatabase(func(db *Database) int {
+		records, err := rows(c.ctx, db)
+		if err != nil {
+			return c.fail(err)
+		}
+
+		w := c.stdout
+		if *out != "" {
+			f, err := os.Create(*out)
+			if err != nil {
+				return c.fail(err)
+			}
+			defer f.Close()
+			w = f
+		}
+
+		writer := csv.NewWriter(w)
+		if err := writer.WriteAll(records); err != nil {
+			return c.fail(fmt.Errorf("failed to write CSV: %v", err))
+		}
+		return exitOK
+	})
+}
+
+// productCSVRows returns every product as CSV records, header first
+func productCSVRows(ctx context.Context, db *Database) ([][]string, error) {
+	products, err := db.GetProducts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	records := [][]string{{"id", "name", "sku", "barcode", "category", "tags", "price", "status", "description"}}
+	for _, p := range products {
+		records = append(records, []string{
+			p.ID, p.Name, p.SKU, p.Barcode, p.Category, strings.Join(p.Tags, ";"),
+			formatAmount(p.Price), p.Status, p.Description,
+		})
+	}
+	return records, nil
+}
+
+// orderCSVRows returns one CSV record per order line, header first
+func orderCSVRows(ctx context.Context, db *Database) ([][]string, error) {
+	orders, err := db.GetOrders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	records := [][]string{{"order_id", "date", "name", "status", "product_id", "variant_id", "product_name", "quantity", "price", "line_total"}}
+	for _, o := range orders {
+		for _, item := range o.Items {
+			records = append(records, []string{
+				o.ID, o.Date, o.Name, o.Status, item.ProductID, item.VariantID, item.ProductName,
+				strconv.Itoa(item.Quantity), formatAmount(item.Price), formatAmount(item.Price * float64(item.Quantity)),
+			})
+		}
+	}
+	return records, nil
+}
+
+// stockCSVRows returns every stock item as CSV records, header first
+func stockCSVRows(ctx context.Context, db *Database) ([][]string, error) {
+	items, err := db.GetStockItems(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	records := [][]string{{"id", "name", "sku", "barcode", "quantity", "description"}}
+	for _, item := range items {
+		records = append(records, []string{item.ID, item.Name, item.SKU, item.Barcode, formatAmount(item.Quantity), item.Description})
+	}
+	return records, nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cliResult is the outcome of one CLI invocation
+type cliResult struct {
+	code   int
+	stdout string
+	stderr string
+}
+
+// newTestDatabase creates a database file in a temporary directory and returns its path
+func newTestDatabase(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "cli.sqlite")
+	openTestDatabaseAt(t, path).Close()
+	return path
+}
+
+// runTestCLI runs a subcommand against the database at dbPath
+func runTestCLI(t *testing.T, dbPath string, args ...string) cliResult {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	args = append(args, "--db", dbPath)
+	code := runCLI(args, &stdout, &stderr)
+	return cliResult{code: code, stdout: stdout.String(), stderr: stderr.String()}
+}
+
+// decodeCLI decodes the JSON output of a successful command
+func decodeCLI(t *testing.T, result cliResult, out interface{}) {
+	t.Helper()
+
+	if result.code != exitOK {
+		t.Fatalf("command failed with exit code %d: %s", result.code, result.stderr)
+	}
+	if err := json.Unmarshal([]byte(result.stdout), out); err != nil {
+		t.Fatalf("failed to decode output %q: %v", result.stdout, err)
+	}
+}
+
+func TestCLIProducts(t *testing.T) {
+	dbPath := newTestDatabase(t)
+
+	var products []Product
+	decodeCLI(t, runTestCLI(t, dbPath, "products", "list", "--format", "json"), &products)
+	if len(products) == 0 {
+		t.Fatal("expected the sample products")
+	}
+
+	table := runTestCLI(t, dbPath, "products", "list", "--search", products[0].Name)
+	if table.code != exitOK || !strings.HasPrefix(table.stdout, "ID") || !strings.Contains(table.stdout, products[0].Name) {
+		t.Fatalf("unexpected table output (exit %d): %q", table.code, table.stdout)
+	}
+
+	if result := runTestCLI(t, dbPath, "products", "lookup", "NO-SUCH-CODE"); result.code != exitNotFound {
+		t.Fatalf("lookup of unknown code: got exit code %d", result.code)
+	}
+}
+
+func TestCLIOrders(t *testing.T) {
+	dbPath := newTestDatabase(t)
+
+	orderFile := filepath.Join(t.TempDir(), "order.json")
+	data, _ := json.Marshal(NewOrder{
+		Name:  "Nightly restock",
+		Items: []OrderItem{{ProductID: "1", Quantity: 3}},
+	})
+	if err := os.WriteFile(orderFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var order Order
+	decodeCLI(t, runTestCLI(t, dbPath, "orders", "create", "--from", orderFile, "--format", "json"), &order)
+	if order.ID == "" || order.Total != 17.97 || order.Items[0].ProductName != "Tomato Plant" {
+		t.Fatalf("unexpected order: %+v", order)
+	}
+
+	decodeCLI(t, runTestCLI(t, dbPath, "orders", "status", order.ID, "Completed", "--format", "json"), &order)
+	if order.Status != "Completed" {
+		t.Fatalf("expected status Completed, got %s", order.Status)
+	}
+
+	var orders []Order
+	decodeCLI(t, runTestCLI(t, dbPath, "orders", "list", "--status", "completed", "--format", "json"), &orders)
+	if len(orders) != 1 {
+		t.Fatalf("expected 1 completed order, got %d", len(orders))
+	}
+
+	if result := runTestCLI(t, dbPath, "orders", "status", "missing", "Completed"); result.code != exitNotFound {
+		t.Fatalf("status of missing order: got exit code %d", result.code)
+	}
+
+	emptyFile := filepath.Join(t.TempDir(), "empty.json")
+	os.WriteFile(emptyFile, []byte(`{"name": "Nobody", "items": []}`), 0644)
+	if result := runTestCLI(t, dbPath, "orders", "create", "--from", emptyFile); result.code != exitUsage {
+		t.Fatalf("order without items: got exit code %d", result.code)
+	}
+}
+
+func TestCLIStockAdjust(t *testing.T) {
+	dbPath := newTestDatabase(t)
+
+	db := openTestDatabaseAt(t, dbPath)
+	item, err := db.AddStockItem(context.Background(), StockItem{Name: "Compost", Quantity: 10, SKU: "COMPOST-40L"})
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var adjusted StockItem
+	decodeCLI(t, runTestCLI(t, dbPath, "stock", "adjust", "compost-40l", "-4", "--format", "json"), &adjusted)
+	if adjusted.ID != item.ID || adjusted.Quantity != 6 {
+		t.Fatalf("expected quantity 6 after removing 4, got %+v", adjusted)
+	}
+
+	decodeCLI(t, runTestCLI(t, dbPath, "stock", "adjust", "--set", item.ID, "25", "--format", "json"), &adjusted)
+	if adjusted.Quantity != 25 {
+		t.Fatalf("expected quantity 25 after set, got %v", adjusted.Quantity)
+	}
+
+	if result := runTestCLI(t, dbPath, "stock", "adjust", item.ID, "-30"); result.code != exitUsage {
+		t.Fatalf("removing more than in stock: got exit code %d", result.code)
+	}
+	if result := runTestCLI(t, dbPath, "stock", "adjust", "missing", "1"); result.code != exitNotFound {
+		t.Fatalf("adjusting missing item: got exit code %d", result.code)
+	}
+	if result := runTestCLI(t, dbPath, "stock", "adjust", item.ID, "lots"); result.code != exitUsage {
+		t.Fatalf("invalid quantity: got exit code %d", result.code)
+	}
+}
+
+func TestCLIStockLots(t *testing.T) {
+	dbPath := newTestDatabase(t)
+
+	db := openTestDatabaseAt(t, dbPath)
+	item, err := db.AddStockItem(context.Background(), StockItem{Name: "Tomato seeds", SKU: "SEED-TOM"})
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expires := time.Now().AddDate(0, 0, 5).Format(scheduleDateLayout)
+	var received StockItem
+	decodeCLI(t, runTestCLI(t, dbPath, "stock", "receive", "seed-tom", "40", "--lot", "T-24", "--expires", expires, "--format", "json"), &received)
+	if received.ID != item.ID || received.Quantity != 40 {
+		t.Fatalf("expected quantity 40 after receiving, got %+v", received)
+	}
+	if result := runTestCLI(t, dbPath, "stock", "receive", item.ID, "10"); result.code != exitUsage {
+		t.Fatalf("receiving without a lot: got exit code %d", result.code)
+	}
+
+	var lots []Lot
+	decodeCLI(t, runTestCLI(t, dbPath, "stock", "expiring", "--days", "7", "--format", "json"), &lots)
+	if len(lots) != 1 || lots[0].LotNumber != "T-24" || lots[0].Quantity != 40 {
+		t.Fatalf("unexpected expiring lots %+v", lots)
+	}
+}
+
+func TestCLIBackup(t *testing.T) {
+	dbPath := newTestDatabase(t)
+	dest := filepath.Join(t.TempDir(), "backup.sqlite")
+
+	if result := runTestCLI(t, dbPath, "backup", dest); result.code != exitOK {
+		t.Fatalf("backup failed with exit code %d: %s", result.code, result.stderr)
+	}
+
+	var products []Product
+	decodeCLI(t, runTestCLI(t, dest, "products", "list", "--format", "json"), &products)
+	if len(products) == 0 {
+		t.Fatal("expected the backup to contain the sample products")
+	}
+
+	if result := runTestCLI(t, dbPath, "backup", dest); result.code != exitUsage {
+		t.Fatalf("backup over an existing file: got exit code %d", result.code)
+	}
+}
+
+func TestCLIExportCSV(t *testing.T) {
+	dbPath := newTestDatabase(t)
+
+	result := runTestCLI(t, dbPath, "export", "csv", "products")
+	if result.code != exitOK {
+		t.Fatalf("export failed with exit code %d: %s", result.code, result.stderr)
+	}
+	records, err := csv.NewReader(strings.NewReader(result.stdout)).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(records) < 2 || records[0][0] != "id" || records[0][1] != "name" {
+		t.Fatalf("unexpected CSV: %v", records)
+	}
+
+	out := filepath.Join(t.TempDir(), "stock.csv")
+	if result := runTestCLI(t, dbPath, "export", "csv", "stock", "--out", out); result.code != exitOK {
+		t.Fatalf("export to file failed with exit code %d: %s", result.code, result.stderr)
+	}
+	if _, err := os.Stat(out); err != nil {
+		t.Fatalf("expected export file: %v", err)
+	}
+
+	if result := runTestCLI(t, dbPath, "export", "csv", "customers"); result.code != exitUsage {
+		t.Fatalf("unknown export: got exit code %d", result.code)
+	}
+}
+
+func TestCLIUsageErrors(t *testing.T) {
+	dbPath := newTestDatabase(t)
+
+	tests := [][]string{
+		{"frobnicate"},
+		{"products"},
+		{"products", "delete"},
+		{"products", "list", "--format", "xml"},
+		{"products", "lookup"},
+		{"orders", "create"},
+	}
+	for _, args := range tests {
+		if result := runTestCLI(t, dbPath, args...); result.code != exitUsage {
+			t.Errorf("%v: got exit code %d, want %d", args, result.code, exitUsage)
+		}
+	}
+
+	if result := runTestCLI(t, filepath.Join(t.TempDir(), "missing.sqlite"), "products", "list"); result.code != exitFailure {
+		t.Errorf("missing database: got exit code %d, want %d", result.code, exitFailure)
+	}
+}
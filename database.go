@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -14,15 +16,63 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// schemaVersion is recorded in the database file's user_version once the migrations in
+// initialize have run. Bump it when adding a migration.
+const schemaVersion = 17
+
+// busyTimeout is how long a connection waits for another one to release its lock before
+// failing with "database is locked"
+const busyTimeout = 5 * time.Second
+
+// maxReadConnections limits the read pool. Writes all go through a single connection.
+const maxReadConnections = 4
+
 // Database represents our SQLite database connection
 type Database struct {
-	db *sql.DB
+	db     *sql.DB // the single writer connection
+	read   *sql.DB // read-only pool, which WAL lets run alongside the writer
+	path   string
+	log    *slog.Logger
+	events *EventBus
+}
+
+// NotFoundError reports that no record of the given kind exists with the given ID
+type NotFoundError struct {
+	Kind string
+	ID   string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("no %s found with ID: %s", e.Kind, e.ID)
+}
+
+// ValidationError reports input that was rejected before reaching the database
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// invalidf formats a ValidationError
+func invalidf(format string, args ...interface{}) error {
+	return &ValidationError{Message: fmt.Sprintf(format, args...)}
 }
 
 // NewDatabase initializes and returns a new Database instance
-func NewDatabase() (*Database, error) {
+func NewDatabase(logger *slog.Logger) (*Database, error) {
 	// Get the database file path in a cross-platform way
-	dbPath := getDBPath()
+	return OpenDatabase(getDBPath(), logger)
+}
+
+// OpenDatabase opens (creating if needed) the SQLite database at the given path.
+// A nil logger logs to the default logger.
+func OpenDatabase(dbPath string, logger *slog.Logger) (*Database, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.Debug("Opening database", "path", dbPath)
 
 	// Ensure the directory exists
 	dbDir := filepath.Dir(dbPath)
@@ -30,32 +80,70 @@ func NewDatabase() (*Database, error) {
 		return nil, fmt.Errorf("failed to create database directory: %v", err)
 	}
 
-	// Open the database connection
-	db, err := sql.Open("sqlite3", dbPath)
+	// Open the writer. WAL journaling is recorded in the file, so it is set here once
+	// the file exists, before any reader opens it. Transactions take the write lock when
+	// they begin, so two writers queue on the busy timeout instead of deadlocking.
+	// Foreign keys are enforced on every write from the start.
+	db, err := openConnection(dbPath, "_journal_mode=WAL&_synchronous=NORMAL&_txlock=immediate&_foreign_keys=1", 1)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %v", err)
+		return nil, err
 	}
 
-	// Test the connection
-	if err := db.Ping(); err != nil {
+	read, err := openConnection(dbPath, "_query_only=1", maxReadConnections)
+	if err != nil {
 		db.Close()
-		return nil, fmt.Errorf("failed to connect to database: %v", err)
+		return nil, err
 	}
 
 	// Create the database instance
-	database := &Database{db: db}
+	database := &Database{db: db, read: read, path: dbPath, log: logger, events: NewEventBus()}
+
+	// Refuse a damaged file up front instead of failing on whichever query first reaches the damage
+	if err := database.quickCheck(); err != nil {
+		database.Close()
+		return nil, err
+	}
 
 	// Initialize the database tables
 	if err := database.initialize(); err != nil {
-		db.Close()
+		database.Close()
 		return nil, err
 	}
 
 	return database, nil
 }
 
-// getDBPath returns the platform-specific SQLite database file path
-func getDBPath() string {
+// openConnection opens a pool of at most maxConns connections to the database at path,
+// each waiting busyTimeout for locks and configured with the given DSN parameters
+func openConnection(path string, params string, maxConns int) (*sql.DB, error) {
+	dsn := fmt.Sprintf("%s?_busy_timeout=%d&%s", path, busyTimeout.Milliseconds(), params)
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	db.SetMaxOpenConns(maxConns)
+	db.SetMaxIdleConns(maxConns)
+
+	// Test the connection
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %v", err)
+	}
+	return db, nil
+}
+
+// with returns a copy of the database handle whose log messages carry the given attributes
+func (db *Database) with(args ...any) *Database {
+	if db == nil {
+		return nil
+	}
+	scoped := *db
+	scoped.log = db.log.With(args...)
+	return &scoped
+}
+
+// getAppDataDir returns the platform-specific application data folder
+func getAppDataDir() string {
 	var appDataDir string
 
 	switch runtime.GOOS {
@@ -74,14 +162,14 @@ func getDBPath() string {
 		appDataDir = filepath.Join(os.Getenv("HOME"), ".local", "share")
 	}
 
-	// Create application-specific folder
-	dbDir := filepath.Join(appDataDir, "GardenProductManager")
-	dbPath := filepath.Join(dbDir, "garden_db.sqlite")
-
-	// Print the path for debugging
-	fmt.Printf("Database path: %s\n", dbPath)
+	// Application-specific folder
+	return filepath.Join(appDataDir, "GardenProductManager")
+}
 
-	return dbPath
+// getDBPath returns the platform-specific SQLite database file path, or the file
+// the user opened instead from the recovery screen
+func getDBPath() string {
+	return loadDatabasePath(getDatabaseSettingsPath(), filepath.Join(getAppDataDir(), "garden_db.sqlite"))
 }
 
 // initialize creates database tables if they don't exist
@@ -107,19 +195,17 @@ func (db *Database) initialize() error {
 	}
 
 	if !hasStatusColumn {
-		fmt.Println("Status column does not exist in products table, adding it...")
+		db.log.Info("Adding status column to products table")
 		_, err = db.db.Exec(`ALTER TABLE products ADD COLUMN status TEXT`)
 		if err != nil {
 			return fmt.Errorf("failed to add status column to products table: %v", err)
 		}
-		fmt.Println("Status column added successfully")
 
 		// Set default status for existing products
 		_, err = db.db.Exec(`UPDATE products SET status = 'In Stock' WHERE status IS NULL`)
 		if err != nil {
 			return fmt.Errorf("failed to set default status for existing products: %v", err)
 		}
-		fmt.Println("Default status set for existing products")
 	}
 
 	// Create orders table
@@ -212,17 +298,169 @@ func (db *Database) initialize() error {
 		return fmt.Errorf("failed to create stock_items table: %v", err)
 	}
 
+	// Create product_variants table and link order items to variants
+	if err := db.initializeVariants(); err != nil {
+		return err
+	}
+
+	// Create categories and product_tags tables
+	if err := db.initializeCategories(); err != nil {
+		return err
+	}
+
+	// Add SKU and barcode columns to products, variants and stock items
+	if err := db.initializeBarcodes(); err != nil {
+		return err
+	}
+
+	// Create product_prices table for price history and scheduled changes
+	if err := db.initializePrices(); err != nil {
+		return err
+	}
+
+	// Create users and sessions tables for logins and roles
+	if err := db.initializeUsers(); err != nil {
+		return err
+	}
+
+	// Create order_history table for changes made to orders after creation
+	if err := db.initializeOrderHistory(); err != nil {
+		return err
+	}
+
+	// Create shipments and shipment_items tables for partial shipments
+	if err := db.initializeShipments(); err != nil {
+		return err
+	}
+
+	// Create returns and return_items tables for returns and refunds
+	if err := db.initializeReturns(); err != nil {
+		return err
+	}
+
+	// Create payments table for payments and deposits against orders
+	if err := db.initializePayments(); err != nil {
+		return err
+	}
+
+	// Create order template tables for saved and recurring orders
+	if err := db.initializeOrderTemplates(); err != nil {
+		return err
+	}
+
+	// Create quote tables for jobs priced before the customer commits
+	if err := db.initializeQuotes(); err != nil {
+		return err
+	}
+
+	// Record the product data order and quote lines were created with
+	if err := db.initializeLineSnapshots(); err != nil {
+		return err
+	}
+
+	// Create location tables for stock kept in more than one place
+	if err := db.initializeLocations(); err != nil {
+		return err
+	}
+
+	// Create lot tables for stock with lot numbers and expiry dates
+	if err := db.initializeLots(); err != nil {
+		return err
+	}
+
+	// Create stock count tables for periodic stock-takes
+	if err := db.initializeStockCounts(); err != nil {
+		return err
+	}
+
+	// Record how much of each refund was paid back to the customer
+	if err := db.initializeRefundPayouts(); err != nil {
+		return err
+	}
+
+	// Record which schema the file now has, for diagnostics
+	if _, err := db.db.Exec(fmt.Sprintf("PRAGMA user_version = %d", schemaVersion)); err != nil {
+		return fmt.Errorf("failed to set schema version: %v", err)
+	}
+
 	return nil
 }
 
-// Close closes the database connection
+// columnExists reports whether the given table has a column with the given name
+func (db *Database) columnExists(table string, column string) (bool, error) {
+	var count int
+	err := db.db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if %s column exists in %s: %v", column, table, err)
+	}
+	return count > 0, nil
+}
+
+// Backup writes a consistent copy of the database to dest, which must not exist yet.
+// It is safe to run while the app is using the database.
+func (db *Database) Backup(ctx context.Context, dest string) error {
+	if _, err := os.Stat(dest); err == nil {
+		return invalidf("backup destination %s already exists", dest)
+	}
+
+	// Copy from a read connection so writes carry on meanwhile. VACUUM INTO only writes
+	// the new file, but query_only refuses it, so it is lifted for the copy.
+	conn, err := db.read.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to back up database: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "PRAGMA query_only = 0"); err != nil {
+		return fmt.Errorf("failed to back up database: %v", err)
+	}
+	defer conn.ExecContext(context.Background(), "PRAGMA query_only = 1")
+
+	if _, err := conn.ExecContext(ctx, "VACUUM INTO ?", dest); err != nil {
+		return fmt.Errorf("failed to back up database: %v", err)
+	}
+	return nil
+}
+
+// Close closes the database connections
 func (db *Database) Close() error {
-	return db.db.Close()
+	readErr := db.read.Close()
+	if err := db.db.Close(); err != nil {
+		return err
+	}
+	return readErr
 }
 
 // GetProducts retrieves all products from the database
-func (db *Database) GetProducts() ([]Product, error) {
-	rows, err := db.db.Query("SELECT id, name, price, description, status FROM products")
+func (db *Database) GetProducts(ctx context.Context) ([]Product, error) {
+	return db.QueryProducts(ctx, ProductQuery{})
+}
+
+// QueryProducts retrieves the products matching the given filters
+func (db *Database) QueryProducts(ctx context.Context, query ProductQuery) ([]Product, error) {
+	where, args := productConditions(query)
+	return db.queryProducts(ctx, where, args...)
+}
+
+// queryProducts retrieves the products matching a WHERE clause with their variants and tags
+func (db *Database) queryProducts(ctx context.Context, where string, args ...interface{}) ([]Product, error) {
+	// Variants and tags are read first, because the read pool may not have a second
+	// connection free while the product rows are open
+	variantsByProduct, err := db.getVariantsByProduct(ctx, where, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	tagsByProduct, err := db.getTagsByProduct(ctx, where, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// The listed price is whichever price history entry is in effect right now
+	args = append([]interface{}{time.Now().Format(priceTimeLayout)}, args...)
+	rows, err := db.read.QueryContext(ctx,
+		"SELECT id, name, "+currentPriceSQL+", description, status, category, image, sku, barcode FROM products"+where,
+		args...,
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query products: %v", err)
 	}
@@ -231,9 +469,13 @@ func (db *Database) GetProducts() ([]Product, error) {
 	var products []Product
 	for rows.Next() {
 		var product Product
-		var status sql.NullString // Use sql.NullString to handle NULL values
+		var status sql.NullString   // Use sql.NullString to handle NULL values
+		var category sql.NullString // Use sql.NullString to handle NULL values
+		var image sql.NullString    // Use sql.NullString to handle NULL values
+		var sku, barcode sql.NullString
 
-		if err := rows.Scan(&product.ID, &product.Name, &product.Price, &product.Description, &status); err != nil {
+		if err := rows.Scan(&product.ID, &product.Name, &product.Price, &product.Description, &status, &category, &image,
+			&sku, &barcode); err != nil {
 			return nil, fmt.Errorf("failed to scan product: %v", err)
 		}
 
@@ -244,6 +486,20 @@ func (db *Database) GetProducts() ([]Product, error) {
 			product.Status = "In Stock" // Default value
 		}
 
+		product.Category = category.String
+		product.SKU = sku.String
+		product.Barcode = barcode.String
+		product.Image = image.String
+		product.ImageURL, product.ThumbnailURL = productImageURLs(product.Image)
+		product.Tags = tagsByProduct[product.ID]
+		if product.Tags == nil {
+			product.Tags = []string{}
+		}
+		product.Variants = variantsByProduct[product.ID]
+		if product.Variants == nil {
+			product.Variants = []ProductVariant{}
+		}
+
 		products = append(products, product)
 	}
 
@@ -255,82 +511,197 @@ func (db *Database) GetProducts() ([]Product, error) {
 }
 
 // AddProduct adds a new product to the database
-func (db *Database) AddProduct(product Product) (string, error) {
+func (db *Database) AddProduct(ctx context.Context, product Product) (string, error) {
 	// Generate a UUID if not provided
 	if product.ID == "" {
 		product.ID = uuid.New().String()
 	}
 
+	if err := db.validateIdentifiers(ctx, product.ID, product.SKU, product.Barcode); err != nil {
+		return "", err
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
 	// Insert the product
-	_, err := db.db.Exec(
-		"INSERT INTO products (id, name, price, description, status) VALUES (?, ?, ?, ?, ?)",
-		product.ID, product.Name, product.Price, product.Description, product.Status,
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO products (id, name, price, description, status, category, sku, barcode) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		product.ID, product.Name, product.Price, product.Description, product.Status, nullIfEmpty(product.Category),
+		nullIfEmpty(product.SKU), nullIfEmpty(product.Barcode),
 	)
 	if err != nil {
 		return "", fmt.Errorf("failed to insert product: %v", err)
 	}
 
+	if err = setProductTags(ctx, tx, product.ID, product.Tags); err != nil {
+		return "", err
+	}
+
+	now := time.Now().Format(priceTimeLayout)
+	err = recordPrice(ctx, tx, ProductPrice{
+		ID:            uuid.New().String(),
+		ProductID:     product.ID,
+		Price:         product.Price,
+		EffectiveFrom: now,
+		Reason:        "Initial price",
+		CreatedAt:     now,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	db.publish(EntityProduct, ActionCreated, product.ID)
 	return product.ID, nil
 }
 
 // UpdateProduct updates an existing product in the database
-func (db *Database) UpdateProduct(product Product) error {
-	_, err := db.db.Exec(
-		"UPDATE products SET name = ?, price = ?, description = ?, status = ? WHERE id = ?",
-		product.Name, product.Price, product.Description, product.Status, product.ID,
+func (db *Database) UpdateProduct(ctx context.Context, product Product) error {
+	if err := db.validateIdentifiers(ctx, product.ID, product.SKU, product.Barcode); err != nil {
+		return err
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// A changed price starts a new history entry instead of overwriting the old one
+	previousPrice, err := currentPrice(ctx, tx, product.ID)
+	if err != nil {
+		return err
+	}
+	if product.Price != previousPrice {
+		now := time.Now().Format(priceTimeLayout)
+		err = recordPrice(ctx, tx, ProductPrice{
+			ID:            uuid.New().String(),
+			ProductID:     product.ID,
+			Price:         product.Price,
+			EffectiveFrom: now,
+			Reason:        "Price updated",
+			CreatedAt:     now,
+		})
+		if err != nil {
+			return err
+		}
+
+		if _, err = tx.ExecContext(ctx, "UPDATE products SET price = ? WHERE id = ?", product.Price, product.ID); err != nil {
+			return fmt.Errorf("failed to update product price: %v", err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE products SET name = ?, description = ?, status = ?, category = ?, sku = ?, barcode = ? WHERE id = ?",
+		product.Name, product.Description, product.Status, nullIfEmpty(product.Category),
+		nullIfEmpty(product.SKU), nullIfEmpty(product.Barcode), product.ID,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to update product: %v", err)
 	}
+
+	if err = setProductTags(ctx, tx, product.ID, product.Tags); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	db.publish(EntityProduct, ActionUpdated, product.ID)
 	return nil
 }
 
 // DeleteProduct removes a product from the database
-func (db *Database) DeleteProduct(id string) error {
-	fmt.Printf("DB: Attempting to delete product with ID: %s\n", id)
+func (db *Database) DeleteProduct(ctx context.Context, id string) error {
+	db.log.Debug("Deleting product", "id", id)
 
-	// Enable foreign key constraints
-	_, err := db.db.Exec("PRAGMA foreign_keys = ON")
+	// Start a transaction so a failed delete leaves the product whole
+	tx, err := db.db.BeginTx(ctx, nil)
 	if err != nil {
-		fmt.Printf("DB: Error enabling foreign keys: %v\n", err)
+		return fmt.Errorf("failed to begin transaction: %v", err)
 	}
+	defer func() {
+		if err != nil {
+			db.log.Debug("Rolling back product delete", "id", id, "err", err)
+			tx.Rollback()
+		}
+	}()
 
-	// Try to parse the ID as an integer
-	fmt.Printf("DB: Exact SQL query: DELETE FROM products WHERE id = '%s'\n", id)
+	// Remove the product's variants first so they don't outlive the product
+	if _, err = tx.ExecContext(ctx, "DELETE FROM product_variants WHERE product_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete product variants: %v", err)
+	}
 
-	// Execute the delete operation with prepared statement to avoid SQL injection
-	stmt, err := db.db.Prepare("DELETE FROM products WHERE id = ?")
-	if err != nil {
-		fmt.Printf("DB: Error preparing statement: %v\n", err)
-		return fmt.Errorf("failed to prepare delete statement: %v", err)
+	// Remove the product's tags as well
+	if _, err = tx.ExecContext(ctx, "DELETE FROM product_tags WHERE product_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete product tags: %v", err)
+	}
+
+	// And its price history
+	if _, err = tx.ExecContext(ctx, "DELETE FROM product_prices WHERE product_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete product prices: %v", err)
 	}
-	defer stmt.Close()
 
-	result, err := stmt.Exec(id)
+	// Delete the product
+	result, err := tx.ExecContext(ctx, "DELETE FROM products WHERE id = ?", id)
 	if err != nil {
-		fmt.Printf("DB: Error executing delete query: %v\n", err)
 		return fmt.Errorf("failed to delete product: %v", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		fmt.Printf("DB: Error getting rows affected: %v\n", err)
 		return fmt.Errorf("failed to get rows affected: %v", err)
 	}
 
-	fmt.Printf("DB: Delete operation completed. Rows affected: %d\n", rowsAffected)
+	db.log.Debug("Product delete completed", "id", id, "rowsAffected", rowsAffected)
 	if rowsAffected == 0 {
-		fmt.Printf("DB: No product found with ID: %s\n", id)
-		return fmt.Errorf("no product found with ID: %s", id)
+		// Returning with err set rolls the transaction back
+		err = &NotFoundError{Kind: "product", ID: id}
+		return err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
 	}
 
+	db.publish(EntityProduct, ActionDeleted, id)
 	return nil
 }
 
 // GetOrders retrieves all orders with their items from the database
-func (db *Database) GetOrders() ([]Order, error) {
+func (db *Database) GetOrders(ctx context.Context) ([]Order, error) {
+	// Items are read first, because the read pool may not have a second connection
+	// free while the order rows are open
+	itemsByOrder, err := db.getOrderItems(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	// Query all orders
-	rows, err := db.db.Query("SELECT id, date, name, description, total, status FROM orders")
+	rows, err := db.read.QueryContext(ctx, `
+		SELECT id, date, name, description, total, status,
+			COALESCE((SELECT SUM(refund) FROM returns WHERE order_id = orders.id), 0),
+			COALESCE((SELECT SUM(paid_out) FROM returns WHERE order_id = orders.id), 0),
+			COALESCE((SELECT SUM(amount) FROM payments WHERE order_id = orders.id), 0)
+		FROM orders
+	`)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query orders: %v", err)
 	}
@@ -342,7 +713,8 @@ func (db *Database) GetOrders() ([]Order, error) {
 		var description sql.NullString // Use NullString to handle NULL values
 		var name sql.NullString        // Use NullString to handle NULL values
 
-		if err := rows.Scan(&order.ID, &order.Date, &name, &description, &order.Total, &order.Status); err != nil {
+		if err := rows.Scan(&order.ID, &order.Date, &name, &description, &order.Total, &order.Status, &order.Refunded, &order.RefundsPaidOut,
+			&order.Paid); err != nil {
 			return nil, fmt.Errorf("failed to scan order: %v", err)
 		}
 
@@ -359,30 +731,8 @@ func (db *Database) GetOrders() ([]Order, error) {
 			order.Description = ""
 		}
 
-		// Get items for this order
-		itemRows, err := db.db.Query(
-			"SELECT product_id, name, price, quantity FROM order_items WHERE order_id = ?",
-			order.ID,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to query order items: %v", err)
-		}
-		defer itemRows.Close()
-
-		var items []OrderItem
-		for itemRows.Next() {
-			var item OrderItem
-			if err := itemRows.Scan(&item.ProductID, &item.ProductName, &item.Price, &item.Quantity); err != nil {
-				return nil, fmt.Errorf("failed to scan order item: %v", err)
-			}
-			items = append(items, item)
-		}
-
-		if err := itemRows.Err(); err != nil {
-			return nil, fmt.Errorf("error iterating order items: %v", err)
-		}
-
-		order.Items = items
+		order.Items = itemsByOrder[order.ID]
+		setPaymentStatus(&order)
 		orders = append(orders, order)
 	}
 
@@ -393,10 +743,55 @@ func (db *Database) GetOrders() ([]Order, error) {
 	return orders, nil
 }
 
+// getOrderItems retrieves the items of all orders, keyed by order ID
+func (db *Database) getOrderItems(ctx context.Context) (map[string][]OrderItem, error) {
+	rows, err := db.read.QueryContext(ctx, `
+		SELECT order_id, id, product_id, variant_id, name, price, quantity, description, sku, list_price, override_reason,
+			COALESCE((SELECT SUM(quantity) FROM shipment_items WHERE order_item_id = order_items.id), 0),
+			COALESCE((SELECT SUM(quantity) FROM return_items WHERE order_item_id = order_items.id), 0)
+		FROM order_items ORDER BY rowid
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order items: %v", err)
+	}
+	defer rows.Close()
+
+	itemsByOrder := make(map[string][]OrderItem)
+	for rows.Next() {
+		var orderID string
+		var item OrderItem
+		var variantID, description, sku, overrideReason sql.NullString
+		var listPrice sql.NullFloat64
+		if err := rows.Scan(&orderID, &item.ID, &item.ProductID, &variantID, &item.ProductName, &item.Price, &item.Quantity,
+			&description, &sku, &listPrice, &overrideReason, &item.Shipped, &item.Returned); err != nil {
+			return nil, fmt.Errorf("failed to scan order item: %v", err)
+		}
+		item.VariantID = variantID.String
+		scanLineSnapshot(&item, description, sku, listPrice, overrideReason)
+		item.Remaining = item.Quantity - item.Shipped
+		itemsByOrder[orderID] = append(itemsByOrder[orderID], item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating order items: %v", err)
+	}
+
+	return itemsByOrder, nil
+}
+
+// GetOrder retrieves a single order with its items
+func (db *Database) GetOrder(ctx context.Context, id string) (*Order, error) {
+	order, err := loadOrder(ctx, db.read, id)
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
 // CreateOrder creates a new order with its items in the database
-func (db *Database) CreateOrder(name string, description string, items []OrderItem) (string, error) {
+func (db *Database) CreateOrder(ctx context.Context, name string, description string, items []OrderItem) (string, error) {
 	// Start a transaction
-	tx, err := db.db.Begin()
+	tx, err := db.db.BeginTx(ctx, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to begin transaction: %v", err)
 	}
@@ -406,15 +801,36 @@ func (db *Database) CreateOrder(name string, description string, items []OrderIt
 		}
 	}()
 
-	// Calculate the total amount
-	var total float64
-	for _, item := range items {
-		total += item.Price * float64(item.Quantity)
+	// Lines are priced from the catalogue rather than trusting the caller
+	if items, err = resolveOrderItems(ctx, tx, items); err != nil {
+		return "", err
+	}
+
+	orderID, err := db.insertOrder(ctx, tx, name, description, items)
+	if err != nil {
+		return "", err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %v", err)
 	}
 
+	db.log.Debug("Order committed", "id", orderID, "items", len(items))
+	db.publish(EntityOrder, ActionCreated, orderID)
+	db.publishReservations(orderID, nil)
+	return orderID, nil
+}
+
+// insertOrder adds a pending order with its items within a transaction and returns
+// the new order's ID
+func (db *Database) insertOrder(ctx context.Context, tx *sql.Tx, name string, description string, items []OrderItem) (string, error) {
+	// Calculate the total amount
+	total := orderTotal(items)
+
 	// Get the next order ID
 	var maxID int
-	err = tx.QueryRow("SELECT COALESCE(MAX(CAST(id AS INTEGER)), 0) FROM orders").Scan(&maxID)
+	err := tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(CAST(id AS INTEGER)), 0) FROM orders").Scan(&maxID)
 	if err != nil {
 		return "", fmt.Errorf("failed to get max order ID: %v", err)
 	}
@@ -422,118 +838,160 @@ func (db *Database) CreateOrder(name string, description string, items []OrderIt
 	orderID := strconv.Itoa(maxID + 1)
 	date := fmt.Sprintf("%s", strings.Replace(strings.Split(fmt.Sprint(GetFormattedDate()), "+")[0], "T", " ", -1))
 
-	fmt.Printf("Creating order: ID=%s, Name=%s, Total=%.2f\n", orderID, name, total)
+	db.log.Debug("Inserting order", "id", orderID, "total", total)
 
 	// Create the order
-	_, err = tx.Exec(
+	_, err = tx.ExecContext(ctx,
 		"INSERT INTO orders (id, date, name, description, total, status) VALUES (?, ?, ?, ?, ?, ?)",
 		orderID, date, name, description, total, "Pending",
 	)
 	if err != nil {
-		fmt.Printf("Error inserting order: %v\n", err)
 		return "", fmt.Errorf("failed to insert order: %v", err)
 	}
-	fmt.Printf("Order record created successfully\n")
 
 	// Insert the order items
-	for i, item := range items {
+	for _, item := range items {
 		// Generate a unique ID for each order item
 		itemID := uuid.New().String()
 
-		fmt.Printf("Inserting order item %d: ID=%s, ProductID=%s, Name=%s, Price=%.2f, Quantity=%d\n",
-			i+1, itemID, item.ProductID, item.ProductName, item.Price, item.Quantity)
+		db.log.Debug("Inserting order item", "order", orderID, "id", itemID, "product", item.ProductID,
+			"variant", item.VariantID, "price", item.Price, "quantity", item.Quantity)
 
-		_, err = tx.Exec(
-			"INSERT INTO order_items (id, order_id, product_id, name, price, quantity) VALUES (?, ?, ?, ?, ?, ?)",
-			itemID, orderID, item.ProductID, item.ProductName, item.Price, item.Quantity,
-		)
-		if err != nil {
-			fmt.Printf("Error inserting order item: %v\n", err)
-			return "", fmt.Errorf("failed to insert order item: %v", err)
+		if err = insertOrderItem(ctx, tx, itemID, orderID, item); err != nil {
+			return "", err
 		}
 	}
-	fmt.Printf("All order items inserted successfully\n")
 
-	// Commit the transaction
-	if err = tx.Commit(); err != nil {
-		fmt.Printf("Error committing transaction: %v\n", err)
-		return "", fmt.Errorf("failed to commit transaction: %v", err)
-	}
-
-	fmt.Printf("Order %s created successfully with %d items\n", orderID, len(items))
 	return orderID, nil
 }
 
-// UpdateOrderStatus updates the status of an order in the database
-func (db *Database) UpdateOrderStatus(orderID string, status string) error {
-	_, err := db.db.Exec("UPDATE orders SET status = ? WHERE id = ?", status, orderID)
+// insertOrderItem adds a line with its product snapshot to an order within a transaction
+func insertOrderItem(ctx context.Context, tx *sql.Tx, id string, orderID string, item OrderItem) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO order_items (id, order_id, product_id, variant_id, name, price, quantity, description, sku, list_price, override_reason)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, id, orderID, item.ProductID, nullIfEmpty(item.VariantID), item.ProductName, item.Price, item.Quantity,
+		nullIfEmpty(item.Description), nullIfEmpty(item.SKU), item.ListPrice, nullIfEmpty(item.OverrideReason))
 	if err != nil {
-		return fmt.Errorf("failed to update order status: %v", err)
+		return fmt.Errorf("failed to insert order item: %v", err)
 	}
 	return nil
 }
 
-// DeleteOrder removes an order and its items from the database
-func (db *Database) DeleteOrder(id string) error {
-	fmt.Printf("DB: Attempting to delete order with ID: %s\n", id)
+// UpdateOrderStatus updates the status of an order in the database. Partially Shipped
+// and Shipped follow from the order's shipments, so they cannot be set here.
+func (db *Database) UpdateOrderStatus(ctx context.Context, orderID string, status string) error {
+	if shippedOrderStatuses[status] {
+		return invalidf("%s is set by recording shipments, not by hand", status)
+	}
+
+	result, err := db.db.ExecContext(ctx, "UPDATE orders SET status = ? WHERE id = ?", status, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to update order status: %v", err)
+	}
 
-	// Enable foreign key constraints
-	_, err := db.db.Exec("PRAGMA foreign_keys = ON")
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		fmt.Printf("DB: Error enabling foreign keys: %v\n", err)
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return &NotFoundError{Kind: "order", ID: orderID}
 	}
 
+	db.publish(EntityOrder, ActionUpdated, orderID)
+	db.publishReservations(orderID, nil)
+	return nil
+}
+
+// DeleteOrder removes an order and its items from the database
+func (db *Database) DeleteOrder(ctx context.Context, id string) error {
+	db.log.Debug("Deleting order", "id", id)
+
 	// Start a transaction
-	tx, err := db.db.Begin()
+	tx, err := db.db.BeginTx(ctx, nil)
 	if err != nil {
-		fmt.Printf("DB: Error beginning transaction: %v\n", err)
 		return fmt.Errorf("failed to begin transaction: %v", err)
 	}
 	defer func() {
 		if err != nil {
-			fmt.Printf("DB: Rolling back transaction due to error\n")
+			db.log.Debug("Rolling back order delete", "id", id, "err", err)
 			tx.Rollback()
 		}
 	}()
 
-	// Delete the order items first
-	fmt.Printf("DB: Deleting order items for order: %s\n", id)
-	result, err := tx.Exec("DELETE FROM order_items WHERE order_id = ?", id)
+	// Deleting the order releases the stock its lines reserved
+	reserved, err := orderStockItems(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	// Delete the order's payments, returns, shipments, history and items first
+	if _, err = tx.ExecContext(ctx, "DELETE FROM payments WHERE order_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete payments: %v", err)
+	}
+	_, err = tx.ExecContext(ctx,
+		"DELETE FROM return_items WHERE return_id IN (SELECT id FROM returns WHERE order_id = ?)", id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete return items: %v", err)
+	}
+	if _, err = tx.ExecContext(ctx, "DELETE FROM returns WHERE order_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete returns: %v", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM shipment_item_lots WHERE shipment_item_id IN (
+			SELECT si.id FROM shipment_items si JOIN shipments s ON s.id = si.shipment_id WHERE s.order_id = ?
+		)`, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete shipment lots: %v", err)
+	}
+	_, err = tx.ExecContext(ctx,
+		"DELETE FROM shipment_items WHERE shipment_id IN (SELECT id FROM shipments WHERE order_id = ?)", id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete shipment items: %v", err)
+	}
+	if _, err = tx.ExecContext(ctx, "DELETE FROM shipments WHERE order_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete shipments: %v", err)
+	}
+	if _, err = tx.ExecContext(ctx, "DELETE FROM order_history WHERE order_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete order history: %v", err)
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM order_items WHERE order_id = ?", id)
 	if err != nil {
-		fmt.Printf("DB: Error deleting order items: %v\n", err)
 		return fmt.Errorf("failed to delete order items: %v", err)
 	}
 
 	rowsAffected, _ := result.RowsAffected()
-	fmt.Printf("DB: %d order items deleted\n", rowsAffected)
+	db.log.Debug("Order items deleted", "id", id, "rowsAffected", rowsAffected)
 
 	// Delete the order
-	fmt.Printf("DB: Deleting order: %s\n", id)
-	result, err = tx.Exec("DELETE FROM orders WHERE id = ?", id)
+	result, err = tx.ExecContext(ctx, "DELETE FROM orders WHERE id = ?", id)
 	if err != nil {
-		fmt.Printf("DB: Error deleting order: %v\n", err)
 		return fmt.Errorf("failed to delete order: %v", err)
 	}
 
 	rowsAffected, err = result.RowsAffected()
 	if err != nil {
-		fmt.Printf("DB: Error getting rows affected: %v\n", err)
 		return fmt.Errorf("failed to get rows affected: %v", err)
 	}
 
-	fmt.Printf("DB: Delete operation completed. Rows affected: %d\n", rowsAffected)
+	db.log.Debug("Order delete completed", "id", id, "rowsAffected", rowsAffected)
 	if rowsAffected == 0 {
-		fmt.Printf("DB: No order found with ID: %s\n", id)
-		return fmt.Errorf("no order found with ID: %s", id)
+		// Returning with err set rolls the transaction back
+		err = &NotFoundError{Kind: "order", ID: id}
+		return err
 	}
 
 	// Commit the transaction
 	if err = tx.Commit(); err != nil {
-		fmt.Printf("DB: Error committing transaction: %v\n", err)
 		return fmt.Errorf("failed to commit transaction: %v", err)
 	}
 
-	fmt.Printf("DB: Order %s successfully deleted\n", id)
+	db.publish(EntityOrder, ActionDeleted, id)
+	db.publishReservations(id, reserved)
 	return nil
 }
 
@@ -542,11 +1000,12 @@ func GetFormattedDate() string {
 	return fmt.Sprintf("%s", strings.Split(fmt.Sprint(fmt.Sprint(time.Now().Format(time.RFC3339))), "+")[0])
 }
 
-// GetStockItems retrieves all stock items from the database
-func (db *Database) GetStockItems() ([]StockItem, error) {
-	rows, err := db.db.Query(`
-		SELECT id, name, description, quantity 
-		FROM stock_items 
+// GetStockItems retrieves all stock items from the database with their quantities
+// per location
+func (db *Database) GetStockItems(ctx context.Context) ([]StockItem, error) {
+	rows, err := db.read.QueryContext(ctx, `
+		SELECT id, name, description, quantity, sku, barcode
+		FROM stock_items
 		ORDER BY name
 	`)
 	if err != nil {
@@ -557,11 +1016,13 @@ func (db *Database) GetStockItems() ([]StockItem, error) {
 	var items []StockItem
 	for rows.Next() {
 		var item StockItem
-		var description sql.NullString
-		err := rows.Scan(&item.ID, &item.Name, &description, &item.Quantity)
+		var description, sku, barcode sql.NullString
+		err := rows.Scan(&item.ID, &item.Name, &description, &item.Quantity, &sku, &barcode)
 		if err != nil {
 			return nil, err
 		}
+		item.SKU = sku.String
+		item.Barcode = barcode.String
 		if description.Valid {
 			item.Description = description.String
 		} else {
@@ -573,50 +1034,227 @@ func (db *Database) GetStockItems() ([]StockItem, error) {
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
+	rows.Close()
 
+	levels, err := queryStockLevels(ctx, db.read, "1 = 1")
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i].Locations = levels[items[i].ID]
+	}
+	reserved, err := queryReservations(ctx, db.read, "1 = 1")
+	if err != nil {
+		return nil, err
+	}
+	setReservations(items, reserved)
 	return items, nil
 }
 
-// AddStockItem adds a new stock item to the database
-func (db *Database) AddStockItem(item StockItem) (StockItem, error) {
+// AddStockItem adds a new stock item to the database. Its opening quantity is counted
+// at the default location.
+func (db *Database) AddStockItem(ctx context.Context, item StockItem) (saved StockItem, err error) {
 	// Generate a UUID if not provided
 	if item.ID == "" {
 		item.ID = uuid.NewString()
 	}
+	if item.Quantity < 0 {
+		return StockItem{}, invalidf("quantity cannot be negative")
+	}
+
+	if err := db.validateIdentifiers(ctx, item.ID, item.SKU, item.Barcode); err != nil {
+		return StockItem{}, err
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return StockItem{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
 
-	_, err := db.db.Exec(`
-		INSERT INTO stock_items (id, name, description, quantity)
-		VALUES (?, ?, ?, ?)
-	`, item.ID, item.Name, item.Description, item.Quantity)
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO stock_items (id, name, description, quantity, sku, barcode)
+		VALUES (?, ?, ?, 0, ?, ?)
+	`, item.ID, item.Name, item.Description, nullIfEmpty(item.SKU), nullIfEmpty(item.Barcode))
 	if err != nil {
 		return StockItem{}, err
 	}
+	if item.Quantity > 0 {
+		movement := adjustmentMovement(item.ID, DefaultLocationID, item.Quantity)
+		movement.Note = openingStockNote
+		if _, err = moveStock(ctx, tx, movement); err != nil {
+			return StockItem{}, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return StockItem{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
 
+	db.publish(EntityStock, ActionCreated, item.ID)
 	return item, nil
 }
 
-// UpdateStockItem updates an existing stock item
-func (db *Database) UpdateStockItem(item StockItem) (bool, error) {
-	result, err := db.db.Exec(`
+// UpdateStockItem updates an existing stock item. A changed quantity is recorded as an
+// adjustment at the default location.
+func (db *Database) UpdateStockItem(ctx context.Context, item StockItem) (updated bool, err error) {
+	if err := db.validateIdentifiers(ctx, item.ID, item.SKU, item.Barcode); err != nil {
+		return false, err
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var quantity float64
+	err = tx.QueryRowContext(ctx, "SELECT quantity FROM stock_items WHERE id = ?", item.ID).Scan(&quantity)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to query stock item: %v", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
 		UPDATE stock_items
-		SET name = ?, description = ?, quantity = ?
+		SET name = ?, description = ?, sku = ?, barcode = ?
 		WHERE id = ?
-	`, item.Name, item.Description, item.Quantity, item.ID)
+	`, item.Name, item.Description, nullIfEmpty(item.SKU), nullIfEmpty(item.Barcode), item.ID)
 	if err != nil {
 		return false, err
 	}
+	if delta := item.Quantity - quantity; delta != 0 {
+		if _, err = moveStock(ctx, tx, adjustmentMovement(item.ID, DefaultLocationID, delta)); err != nil {
+			return false, err
+		}
+	}
 
-	rowsAffected, err := result.RowsAffected()
+	if err = tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	db.publish(EntityStock, ActionUpdated, item.ID)
+	return true, nil
+}
+
+// GetStockItem retrieves a single stock item with its quantities per location
+func (db *Database) GetStockItem(ctx context.Context, id string) (StockItem, error) {
+	var item StockItem
+	var description, sku, barcode sql.NullString
+	err := db.read.QueryRowContext(ctx,
+		"SELECT id, name, description, quantity, sku, barcode FROM stock_items WHERE id = ?", id,
+	).Scan(&item.ID, &item.Name, &description, &item.Quantity, &sku, &barcode)
+	if err == sql.ErrNoRows {
+		return StockItem{}, &NotFoundError{Kind: "stock item", ID: id}
+	}
 	if err != nil {
-		return false, err
+		return StockItem{}, fmt.Errorf("failed to query stock item: %v", err)
 	}
+	item.Description, item.SKU, item.Barcode = description.String, sku.String, barcode.String
 
-	return rowsAffected > 0, nil
+	levels, err := queryStockLevels(ctx, db.read, "id = ?", id)
+	if err != nil {
+		return StockItem{}, err
+	}
+	item.Locations = levels[id]
+	reserved, err := queryReservations(ctx, db.read, "id = ?", id)
+	if err != nil {
+		return StockItem{}, err
+	}
+	items := []StockItem{item}
+	setReservations(items, reserved)
+	return items[0], nil
+}
+
+// AdjustStockQuantity adds adjustment.Delta (negative to remove) to the quantity of a
+// stock item at one location, or at the default location when none is given. The
+// change is rejected if it would make the quantity there negative.
+func (db *Database) AdjustStockQuantity(ctx context.Context, id string, adjustment StockAdjustment, adjustedBy string) (item StockItem, err error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return StockItem{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	movement := adjustmentMovement(id, firstNonEmpty(adjustment.LocationID, DefaultLocationID), adjustment.Delta)
+	movement.Note, movement.MovedBy = adjustment.Note, adjustedBy
+	if _, err = moveStock(ctx, tx, movement); err != nil {
+		return StockItem{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return StockItem{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	db.publish(EntityStock, ActionUpdated, id)
+	return db.GetStockItem(ctx, id)
 }
 
-// DeleteStockItem removes a stock item by ID
-func (db *Database) DeleteStockItem(id string) (bool, error) {
-	result, err := db.db.Exec("DELETE FROM stock_items WHERE id = ?", id)
+// DeleteStockItem removes a stock item by ID, with its opening stock. Items with any
+// other stock history or with linked variants are kept, so the movement ledger and
+// lot traces stay complete.
+func (db *Database) DeleteStockItem(ctx context.Context, id string) (deleted bool, err error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	references := []struct{ table, what string }{
+		{"product_variants", "product variants"},
+		{"lots", "lots"},
+		{"stock_count_lines", "stock count lines"},
+	}
+	for _, ref := range references {
+		var count int
+		err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+ref.table+" WHERE stock_item_id = ?", id).Scan(&count)
+		if err != nil {
+			return false, fmt.Errorf("failed to query %s: %v", ref.what, err)
+		}
+		if count > 0 {
+			return false, invalidf("stock item %s has %d %s and cannot be deleted", id, count, ref.what)
+		}
+	}
+
+	// The opening stock entered with the item is not history of its own, so an item
+	// nothing has happened to since it was added can still be deleted
+	var movements, opening int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*), COUNT(CASE WHEN kind = ? AND note = ? THEN 1 END)
+		FROM stock_movements WHERE stock_item_id = ?
+	`, MovementAdjustment, openingStockNote, id).Scan(&movements, &opening)
+	if err != nil {
+		return false, fmt.Errorf("failed to query stock movements: %v", err)
+	}
+	if movements > 1 || movements > opening {
+		return false, invalidf("stock item %s has %d stock movements and cannot be deleted", id, movements)
+	}
+	if _, err = tx.ExecContext(ctx, "DELETE FROM stock_movements WHERE stock_item_id = ?", id); err != nil {
+		return false, fmt.Errorf("failed to delete opening stock: %v", err)
+	}
+	if _, err = tx.ExecContext(ctx, "DELETE FROM stock_levels WHERE stock_item_id = ?", id); err != nil {
+		return false, fmt.Errorf("failed to delete stock levels: %v", err)
+	}
+	result, err := tx.ExecContext(ctx, "DELETE FROM stock_items WHERE id = ?", id)
 	if err != nil {
 		return false, err
 	}
@@ -626,5 +1264,12 @@ func (db *Database) DeleteStockItem(id string) (bool, error) {
 		return false, err
 	}
 
+	if err = tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	if rowsAffected > 0 {
+		db.publish(EntityStock, ActionDeleted, id)
+	}
 	return rowsAffected > 0, nil
 }
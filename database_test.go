@@ -0,0 +1,850 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// openTestDatabaseAt opens the database at path, creating it if needed, and closes it
+// when the test ends. Every test database is opened through it.
+func openTestDatabaseAt(t *testing.T, path string) *Database {
+	t.Helper()
+
+	db, err := OpenDatabase(path, nil)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// openTestDatabase opens a fresh database in a temporary directory
+func openTestDatabase(t *testing.T) *Database {
+	t.Helper()
+
+	return openTestDatabaseAt(t, filepath.Join(t.TempDir(), "test.sqlite"))
+}
+
+func TestDatabaseUsesWAL(t *testing.T) {
+	db := openTestDatabase(t)
+
+	var mode string
+	if err := db.read.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil || mode != "wal" {
+		t.Fatalf("expected WAL journaling, got %q, %v", mode, err)
+	}
+	if _, err := db.read.Exec("DELETE FROM stock_items"); err == nil {
+		t.Fatal("expected the read pool to refuse writes")
+	}
+}
+
+func TestConcurrentReadsAndWrites(t *testing.T) {
+	db := openTestDatabase(t)
+	ctx := context.Background()
+
+	item, err := db.AddStockItem(ctx, StockItem{Name: "Compost", Quantity: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const writers, readers, rounds = 8, 8, 25
+	var wg sync.WaitGroup
+	errs := make(chan error, (writers+readers)*rounds)
+
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				if _, err := db.AdjustStockQuantity(ctx, item.ID, StockAdjustment{Delta: 1}, ""); err != nil {
+					errs <- err
+				}
+				if _, err := db.CreateOrder(ctx, fmt.Sprintf("Writer %d", w), "", []OrderItem{{ProductID: "1", ProductName: "Tomato Plant", Price: 5, Quantity: 1}}); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+	}
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				if _, err := db.GetOrders(ctx); err != nil {
+					errs <- err
+				}
+				if _, err := db.GetProducts(ctx); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("concurrent access failed: %v", err)
+	}
+
+	// Every adjustment and order was applied, none lost to a lock
+	if got, _ := db.GetStockItem(ctx, item.ID); got.Quantity != writers*rounds {
+		t.Fatalf("expected quantity %d, got %g", writers*rounds, got.Quantity)
+	}
+	if orders, _ := db.GetOrders(ctx); len(orders) != writers*rounds {
+		t.Fatalf("expected %d orders, got %d", writers*rounds, len(orders))
+	}
+}
+
+func TestConcurrentDatabaseHandles(t *testing.T) {
+	// The desktop app and a command-line invocation can have the same file open
+	path := filepath.Join(t.TempDir(), "shared.sqlite")
+	var handles []*Database
+	for i := 0; i < 2; i++ {
+		handles = append(handles, openTestDatabaseAt(t, path))
+	}
+
+	ctx := context.Background()
+	item, err := handles[0].AddStockItem(ctx, StockItem{Name: "Pots", Quantity: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const rounds = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, len(handles)*rounds)
+	for _, db := range handles {
+		wg.Add(1)
+		go func(db *Database) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				if _, err := db.AdjustStockQuantity(ctx, item.ID, StockAdjustment{Delta: 1}, ""); err != nil {
+					errs <- err
+				}
+			}
+		}(db)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("write from a second handle failed: %v", err)
+	}
+	if got, _ := handles[1].GetStockItem(ctx, item.ID); got.Quantity != float64(len(handles)*rounds) {
+		t.Fatalf("expected quantity %d, got %g", len(handles)*rounds, got.Quantity)
+	}
+}
+
+func TestForeignKeysEnforced(t *testing.T) {
+	db := openTestDatabase(t)
+	ctx := context.Background()
+
+	if _, err := db.db.Exec("INSERT INTO order_history (id, order_id, changed_at, change) VALUES ('h', 'missing', '', '')"); err == nil {
+		t.Fatal("expected history for a missing order to be refused")
+	}
+
+	item, err := db.AddStockItem(ctx, StockItem{Name: "Terracotta pots"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	productID := addTestProduct(t, db, "Fern", 9)
+	variant, err := db.AddProductVariant(ctx, ProductVariant{ProductID: productID, Size: "Large", StockItemID: item.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var invalid *ValidationError
+	if _, err := db.DeleteStockItem(ctx, item.ID); !errors.As(err, &invalid) {
+		t.Fatalf("expected a linked stock item to be kept, got %v", err)
+	}
+	if err := db.DeleteProductVariant(ctx, variant.ID); err != nil {
+		t.Fatal(err)
+	}
+	if deleted, err := db.DeleteStockItem(ctx, item.ID); err != nil || !deleted {
+		t.Fatalf("expected the stock item to be deleted, got %v, %v", deleted, err)
+	}
+}
+
+func TestCancelledQuery(t *testing.T) {
+	db := openTestDatabase(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := db.GetProducts(ctx); err == nil || !strings.Contains(err.Error(), "context canceled") {
+		t.Fatalf("expected the query to be cancelled, got %v", err)
+	}
+	if _, err := db.AddStockItem(ctx, StockItem{Name: "Never"}); err == nil {
+		t.Fatal("expected the write to be cancelled")
+	}
+	if items, err := db.GetStockItems(context.Background()); err != nil || len(items) != 0 {
+		t.Fatalf("expected no stock items, got %d, %v", len(items), err)
+	}
+}
+
+func TestUpdateOrderKeepsLines(t *testing.T) {
+	db := openTestDatabase(t)
+	ctx := context.Background()
+
+	tomatoID, compostID := addTestProduct(t, db, "Tomato Plant", 5), addTestProduct(t, db, "Compost", 12.5)
+	id, err := db.CreateOrder(ctx, "Dana", "Pickup Friday", []OrderItem{
+		{ProductID: tomatoID, Quantity: 3},
+		{ProductID: compostID, Quantity: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lineID := func(productID string) string {
+		var lineID string
+		db.read.QueryRow("SELECT id FROM order_items WHERE order_id = ? AND product_id = ?", id, productID).Scan(&lineID)
+		return lineID
+	}
+	tomato := lineID(tomatoID)
+
+	err = db.UpdateOrder(ctx, id, NewOrder{Name: "Dana", Description: "Pickup Friday", Items: []OrderItem{
+		{ProductID: tomatoID, Quantity: 5},
+	}}, "staff")
+	if err != nil {
+		t.Fatalf("UpdateOrder failed: %v", err)
+	}
+
+	order, err := db.GetOrder(ctx, id)
+	if err != nil || order.Total != 25 || len(order.Items) != 1 || order.Items[0].Quantity != 5 {
+		t.Fatalf("unexpected order %+v, %v", order, err)
+	}
+	if got := lineID(tomatoID); got != tomato {
+		t.Fatalf("expected the edited line to keep ID %s, got %s", tomato, got)
+	}
+	if history, _ := db.GetOrderHistory(ctx, id); len(history) != 2 || history[0].ChangedBy != "staff" {
+		t.Fatalf("unexpected history %+v", history)
+	}
+
+	// Saving without changes records nothing
+	if err := db.UpdateOrder(ctx, id, NewOrder{Name: "Dana", Description: "Pickup Friday", Items: order.Items}, "staff"); err != nil {
+		t.Fatal(err)
+	}
+	if history, _ := db.GetOrderHistory(ctx, id); len(history) != 2 {
+		t.Fatalf("expected no new history, got %+v", history)
+	}
+
+	if err := db.UpdateOrderStatus(ctx, id, "Delivered"); err != nil {
+		t.Fatal(err)
+	}
+	var invalid *ValidationError
+	if err := db.UpdateOrder(ctx, id, NewOrder{Name: "Late", Items: order.Items}, "staff"); !errors.As(err, &invalid) {
+		t.Fatalf("expected a delivered order to be rejected, got %v", err)
+	}
+
+	if err := db.DeleteOrder(ctx, id); err != nil {
+		t.Fatalf("failed to delete an order with history: %v", err)
+	}
+	if history, _ := db.GetOrderHistory(ctx, id); len(history) != 0 {
+		t.Fatalf("expected the history to be deleted with the order, got %+v", history)
+	}
+}
+
+func TestOrdersReserveStock(t *testing.T) {
+	db := openTestDatabase(t)
+	ctx := context.Background()
+
+	pots, err := db.AddStockItem(ctx, StockItem{Name: "Terracotta pots", Quantity: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	productID := addTestProduct(t, db, "Fern", 9)
+	variant, err := db.AddProductVariant(ctx, ProductVariant{ProductID: productID, Size: "Potted", Price: 12, StockItemID: pots.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectReserved := func(reserved, available float64) {
+		t.Helper()
+		item, err := db.GetStockItem(ctx, pots.ID)
+		if err != nil || item.Reserved != reserved || item.Available != available {
+			t.Fatalf("expected %g reserved and %g available, got %+v, %v", reserved, available, item, err)
+		}
+	}
+
+	orderID, err := db.CreateOrder(ctx, "Dana", "", []OrderItem{{ProductID: productID, VariantID: variant.ID, Quantity: 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectReserved(3, 7)
+
+	// Editing the order reserves the difference
+	err = db.UpdateOrder(ctx, orderID, NewOrder{Name: "Dana", Items: []OrderItem{{ProductID: productID, VariantID: variant.ID, Quantity: 5}}}, "staff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectReserved(5, 5)
+
+	// Shipping takes the stock and the reservation with it
+	order, _ := db.GetOrder(ctx, orderID)
+	_, err = db.CreateShipment(ctx, orderID, NewShipment{
+		Items: []ShipmentLine{{OrderItemID: order.Items[0].ID, Quantity: 2}}, LocationID: DefaultLocationID,
+	}, "staff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectReserved(3, 5)
+
+	if err := db.UpdateOrderStatus(ctx, orderID, "Cancelled"); err != nil {
+		t.Fatal(err)
+	}
+	expectReserved(0, 8)
+	if err := db.UpdateOrderStatus(ctx, orderID, "Processing"); err != nil {
+		t.Fatal(err)
+	}
+	expectReserved(3, 5)
+
+	if err := db.DeleteOrder(ctx, orderID); err != nil {
+		t.Fatal(err)
+	}
+	expectReserved(0, 8)
+}
+
+func TestOrderLineSnapshots(t *testing.T) {
+	db := openTestDatabase(t)
+	ctx := context.Background()
+
+	fernID, err := db.AddProduct(ctx, Product{Name: "Fern", Price: 20, Description: "Boston fern", SKU: "FERN", Status: "In Stock"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	large, err := db.AddProductVariant(ctx, ProductVariant{ProductID: fernID, Size: "Large", SKU: "FERN-L", Price: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Client prices and names are ignored unless the price comes with a reason
+	id, err := db.CreateOrder(ctx, "Dana", "", []OrderItem{
+		{ProductID: fernID, ProductName: "Cheap fern", Price: 1, Quantity: 2},
+		{ProductID: fernID, VariantID: large.ID, Price: 25, Quantity: 1, OverrideReason: "Damaged"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, _ := db.GetOrder(ctx, id)
+	fern, damaged := order.Items[0], order.Items[1]
+	if fern.ProductName != "Fern" || fern.Price != 20 || fern.ListPrice != 20 || fern.Description != "Boston fern" ||
+		fern.SKU != "FERN" || fern.OverrideReason != "" {
+		t.Fatalf("unexpected line %+v", fern)
+	}
+	if damaged.ProductName != "Fern (Large)" || damaged.SKU != "FERN-L" || damaged.Price != 25 || damaged.ListPrice != 32 ||
+		damaged.OverrideReason != "Damaged" || order.Total != 65 {
+		t.Fatalf("unexpected overridden line %+v", damaged)
+	}
+
+	// The snapshot survives catalogue changes while the line is left alone
+	if err := db.UpdateProduct(ctx, Product{ID: fernID, Name: "Fern, potted", Price: 22, Status: "In Stock"}); err != nil {
+		t.Fatal(err)
+	}
+	order.Items[0].Quantity = 3
+	if err := db.UpdateOrder(ctx, id, NewOrder{Name: "Dana", Items: order.Items}, "staff"); err != nil {
+		t.Fatal(err)
+	}
+	if order, _ = db.GetOrder(ctx, id); order.Items[0].ProductName != "Fern" || order.Items[0].Price != 20 || order.Items[0].Quantity != 3 {
+		t.Fatalf("expected the edited line to keep its snapshot, got %+v", order.Items[0])
+	}
+
+	rejected := [][]OrderItem{
+		{{ProductID: fernID, Price: 5, Quantity: 1, OverrideReason: "Friend"}},
+		{{ProductID: fernID, Price: -5, Quantity: 1, OverrideReason: "Discount"}},
+		{{ProductID: "no-such-product", Quantity: 1}},
+		{{ProductID: fernID, VariantID: "no-such-variant", Quantity: 1}},
+	}
+	for _, items := range rejected {
+		if _, err := db.CreateOrder(ctx, "Noa", "", items); err == nil {
+			t.Fatalf("expected order lines %+v to be rejected", items)
+		}
+	}
+}
+
+func TestGenerateDueOrdersCatchesUpSafely(t *testing.T) {
+	db := openTestDatabase(t)
+	ctx := context.Background()
+	now := time.Now()
+	start := now.Format(scheduleDateLayout)
+
+	fernID := addTestProduct(t, db, "Fern", 9)
+	goneID := addTestProduct(t, db, "Discontinued palm", 40)
+	brokenID, err := db.SaveOrderTemplate(ctx, OrderTemplate{Name: "Old palms", Frequency: FrequencyWeekly, StartDate: start,
+		Items: []OrderItem{{ProductID: goneID, Quantity: 1}}}, "staff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	weeklyID, err := db.SaveOrderTemplate(ctx, OrderTemplate{Name: "Office ferns", Frequency: FrequencyWeekly, StartDate: start,
+		Items: []OrderItem{{ProductID: fernID, Quantity: 2}}}, "staff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteProduct(ctx, goneID); err != nil {
+		t.Fatal(err)
+	}
+
+	// The preview shows the same catch-up orders the scheduler will create
+	preview, err := db.PreviewScheduledOrders(ctx, now.AddDate(0, 3, 0), 1)
+	if err != nil || len(preview) != 2*maxCatchUpOrders {
+		t.Fatalf("expected %d orders in the preview, got %+v, %v", 2*maxCatchUpOrders, preview, err)
+	}
+
+	// Three months closed: the broken schedule is paused and the other catches up with
+	// its latest orders only
+	created, err := db.GenerateDueOrders(ctx, now.AddDate(0, 3, 0))
+	if err == nil || !strings.Contains(err.Error(), brokenID) {
+		t.Fatalf("expected the broken template's error, got %v", err)
+	}
+	if len(created) != maxCatchUpOrders {
+		t.Fatalf("expected %d orders, got %v", maxCatchUpOrders, created)
+	}
+	history, err := db.GetOrderHistory(ctx, created[0])
+	if err != nil || len(history) != 2 || !strings.HasPrefix(history[1].Change, "Skipped ") {
+		t.Fatalf("expected the skipped orders to be noted, got %+v, %v", history, err)
+	}
+
+	templates, err := db.GetOrderTemplates(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, template := range templates {
+		if template.ID == brokenID && !template.Paused {
+			t.Fatal("expected the broken template to be paused")
+		}
+		if template.ID == weeklyID && (template.Paused || template.NextRun <= now.AddDate(0, 3, 0).Format(scheduleDateLayout)) {
+			t.Fatalf("expected the weekly schedule to move on, got %+v", template)
+		}
+	}
+	if again, err := db.GenerateDueOrders(ctx, now.AddDate(0, 3, 0)); err != nil || len(again) != 0 {
+		t.Fatalf("expected nothing left to generate, got %v, %v", again, err)
+	}
+}
+
+func TestRefundsAndBalanceDue(t *testing.T) {
+	db := openTestDatabase(t)
+	ctx := context.Background()
+
+	productID := addTestProduct(t, db, "Fern", 25)
+	orderID, err := db.CreateOrder(ctx, "Dana", "", []OrderItem{{ProductID: productID, Quantity: 4}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, _ := db.GetOrder(ctx, orderID)
+	line := order.Items[0].ID
+	if _, err := db.CreateShipment(ctx, orderID, NewShipment{Items: []ShipmentLine{{OrderItemID: line, Quantity: 4}}}, "staff"); err != nil {
+		t.Fatal(err)
+	}
+	returnOne := func(refund float64) Return {
+		t.Helper()
+		if _, err := db.CreateReturn(ctx, orderID, NewReturn{
+			Items: []ReturnLine{{OrderItemID: line, Quantity: 1, Condition: "Opened"}}, Reason: "Wilted", Refund: refund,
+		}, "staff"); err != nil {
+			t.Fatal(err)
+		}
+		returns, _ := db.GetReturns(ctx, orderID)
+		return returns[len(returns)-1]
+	}
+
+	// Returned before anything was paid, the refund comes off what is owed
+	if ret := returnOne(25); ret.PaidOut != 0 {
+		t.Fatalf("expected nothing to be paid back, got %+v", ret)
+	}
+	if order, _ := db.GetOrder(ctx, orderID); order.BalanceDue != 75 || order.PaymentStatus != PaymentUnpaid {
+		t.Fatalf("expected 75 left to pay, got %+v", order)
+	}
+	if _, err := db.AddPayment(ctx, orderID, NewPayment{Method: "Card", Amount: 100}, "staff"); err == nil {
+		t.Fatal("expected paying for the returned item to be refused")
+	}
+	if _, err := db.AddPayment(ctx, orderID, NewPayment{Method: "Card", Amount: 75}, "staff"); err != nil {
+		t.Fatal(err)
+	}
+	if order, _ := db.GetOrder(ctx, orderID); order.BalanceDue != 0 || order.PaymentStatus != PaymentPaid {
+		t.Fatalf("expected the order to be paid, got %+v", order)
+	}
+
+	// Once paid, a refund is paid back and the order stays settled
+	if ret := returnOne(25); ret.PaidOut != 25 {
+		t.Fatalf("expected the refund to be paid back, got %+v", ret)
+	}
+	order, _ = db.GetOrder(ctx, orderID)
+	if order.Refunded != 50 || order.RefundsPaidOut != 25 || order.BalanceDue != 0 || order.PaymentStatus != PaymentPaid {
+		t.Fatalf("unexpected order after the second return %+v", order)
+	}
+
+	// What was paid back is no longer held against the order's total
+	splitID, err := db.CreateOrder(ctx, "Dana", "", []OrderItem{{ProductID: productID, Quantity: 4}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, _ = db.GetOrder(ctx, splitID)
+	line = order.Items[0].ID
+	if _, err := db.AddPayment(ctx, splitID, NewPayment{Method: "Card", Amount: 100}, "staff"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateShipment(ctx, splitID, NewShipment{Items: []ShipmentLine{{OrderItemID: line, Quantity: 1}}}, "staff"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateReturn(ctx, splitID, NewReturn{
+		Items: []ReturnLine{{OrderItemID: line, Quantity: 1, Condition: "Opened"}}, Reason: "Wilted", Refund: 25,
+	}, "staff"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SplitOrder(ctx, splitID, OrderSplit{Items: []ShipmentLine{{OrderItemID: line, Quantity: 1}}}, "staff"); err != nil {
+		t.Fatalf("expected the refunded amount to be left out of the paid check: %v", err)
+	}
+}
+
+func TestLocationsMigrateExistingStock(t *testing.T) {
+	db := openTestDatabase(t)
+	ctx := context.Background()
+
+	// Stock counted before locations existed has a quantity but no levels
+	if _, err := db.db.Exec("INSERT INTO stock_items (id, name, quantity) VALUES ('old', 'Old pots', 12)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.initializeLocations(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.initializeLocations(); err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := db.GetStockItem(ctx, "old")
+	if err != nil || item.Quantity != 12 || len(item.Locations) != 1 || item.Locations[0].LocationID != DefaultLocationID ||
+		item.Locations[0].Quantity != 12 {
+		t.Fatalf("expected the stock at the default location, got %+v, %v", item, err)
+	}
+}
+
+func TestLotsShipFirstExpiredFirst(t *testing.T) {
+	db := openTestDatabase(t)
+	ctx := context.Background()
+
+	seeds, err := db.AddStockItem(ctx, StockItem{Name: "Basil seeds", Quantity: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	soon := time.Now().AddDate(0, 0, 10).Format(scheduleDateLayout)
+	later := time.Now().AddDate(1, 0, 0).Format(scheduleDateLayout)
+	for _, receipt := range []StockReceipt{
+		{LotNumber: "B-LATE", ExpiresOn: later, Quantity: 5},
+		{LotNumber: "B-SOON", ExpiresOn: soon, Quantity: 3},
+	} {
+		if _, err := db.ReceiveStock(ctx, seeds.ID, receipt, "staff"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.ReceiveStock(ctx, seeds.ID, StockReceipt{LotNumber: "B-SOON", ExpiresOn: later, Quantity: 1}, "staff"); err == nil {
+		t.Fatal("expected a second expiry date for the same lot to be rejected")
+	}
+
+	productID := addTestProduct(t, db, "Basil", 3)
+	orderID, err := db.CreateOrder(ctx, "Dana", "", []OrderItem{{ProductID: productID, Quantity: 4}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, _ := db.GetOrder(ctx, orderID)
+	_, err = db.CreateShipment(ctx, orderID, NewShipment{
+		Items:      []ShipmentLine{{OrderItemID: order.Items[0].ID, Quantity: 4, StockItemID: seeds.ID}},
+		LocationID: DefaultLocationID,
+	}, "staff")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The lot expiring soonest goes first, then the next one; stock without a lot is left
+	shipments, err := db.GetShipments(ctx, orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lots := shipments[0].Items[0].Lots
+	if len(lots) != 2 || lots[0].LotNumber != "B-SOON" || lots[0].Quantity != 3 || lots[1].LotNumber != "B-LATE" || lots[1].Quantity != 1 {
+		t.Fatalf("unexpected shipped lots %+v", lots)
+	}
+	remaining, err := db.GetLots(ctx, seeds.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining) != 2 || remaining[0].Quantity != 0 || remaining[1].Quantity != 4 {
+		t.Fatalf("unexpected lots after shipping %+v", remaining)
+	}
+	if item, _ := db.GetStockItem(ctx, seeds.ID); item.Quantity != 6 {
+		t.Fatalf("expected 6 left in total, got %g", item.Quantity)
+	}
+
+	traced, err := db.GetLotShipments(ctx, lots[0].LotID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(traced) != 1 || traced[0].OrderID != orderID || traced[0].OrderName != "Dana" || traced[0].Quantity != 3 {
+		t.Fatalf("unexpected lot shipments %+v", traced)
+	}
+
+	// Lots travel with transferred stock
+	shed, err := db.SaveLocation(ctx, Location{Name: "Shed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.TransferStock(ctx, StockTransfer{StockItemID: seeds.ID, FromLocationID: DefaultLocationID, ToLocationID: shed.ID, Quantity: 5}, "staff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	remaining, _ = db.GetLots(ctx, seeds.ID)
+	if late := remaining[1]; late.Quantity != 4 || len(late.Locations) != 1 || late.Locations[0].LocationID != shed.ID {
+		t.Fatalf("expected lot B-LATE to move to the shed, got %+v", late)
+	}
+
+	expiring, err := db.GetExpiringLots(ctx, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expiring) != 0 {
+		t.Fatalf("expected no expiring lots with stock left, got %+v", expiring)
+	}
+	if expiring, _ = db.GetExpiringLots(ctx, 400); len(expiring) != 1 || expiring[0].LotNumber != "B-LATE" {
+		t.Fatalf("unexpected expiring lots %+v", expiring)
+	}
+}
+
+func TestExpiredLotsDoNotShip(t *testing.T) {
+	db := openTestDatabase(t)
+	ctx := context.Background()
+
+	seeds, err := db.AddStockItem(ctx, StockItem{Name: "Basil seeds"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	yesterday := time.Now().AddDate(0, 0, -1).Format(scheduleDateLayout)
+	later := time.Now().AddDate(1, 0, 0).Format(scheduleDateLayout)
+	for _, receipt := range []StockReceipt{
+		{LotNumber: "B-OLD", ExpiresOn: yesterday, Quantity: 2},
+		{LotNumber: "B-NEW", ExpiresOn: later, Quantity: 3},
+	} {
+		if _, err := db.ReceiveStock(ctx, seeds.ID, receipt, "staff"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	productID := addTestProduct(t, db, "Basil", 3)
+	orderID, err := db.CreateOrder(ctx, "Dana", "", []OrderItem{{ProductID: productID, Quantity: 4}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, _ := db.GetOrder(ctx, orderID)
+	ship := func(quantity int) error {
+		_, err := db.CreateShipment(ctx, orderID, NewShipment{
+			Items:      []ShipmentLine{{OrderItemID: order.Items[0].ID, Quantity: quantity, StockItemID: seeds.ID}},
+			LocationID: DefaultLocationID,
+		}, "staff")
+		return err
+	}
+
+	// The expired lot is passed over even though it expires first
+	if err := ship(2); err != nil {
+		t.Fatal(err)
+	}
+	shipments, _ := db.GetShipments(ctx, orderID)
+	if lots := shipments[0].Items[0].Lots; len(lots) != 1 || lots[0].LotNumber != "B-NEW" || lots[0].Quantity != 2 {
+		t.Fatalf("expected the lot in date to ship, got %+v", lots)
+	}
+
+	// Only one in date is left, so two more cannot ship
+	var invalid *ValidationError
+	if err := ship(2); !errors.As(err, &invalid) || !strings.Contains(err.Error(), "B-OLD") {
+		t.Fatalf("expected the expired lot to be named, got %v", err)
+	}
+	if item, _ := db.GetStockItem(ctx, seeds.ID); item.Quantity != 3 {
+		t.Fatalf("expected the refused shipment to leave 3, got %g", item.Quantity)
+	}
+
+	// Expired stock can still be moved out of the way
+	if _, err := db.AdjustStockQuantity(ctx, seeds.ID, StockAdjustment{Delta: -2, Note: "Expired"}, "staff"); err != nil {
+		t.Fatal(err)
+	}
+	if lots, _ := db.GetLots(ctx, seeds.ID); len(lots) != 2 || lots[0].LotNumber != "B-OLD" || lots[0].Quantity != 0 {
+		t.Fatalf("expected the write-off to take the expired lot, got %+v", lots)
+	}
+}
+
+func TestStockCountPostsVariances(t *testing.T) {
+	db := openTestDatabase(t)
+	ctx := context.Background()
+
+	var items []StockItem
+	for _, item := range []StockItem{{Name: "Compost", Quantity: 10}, {Name: "Pots", Quantity: 5}, {Name: "Twine", Quantity: 3}} {
+		added, err := db.AddStockItem(ctx, item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, added)
+	}
+	compost, pots, twine := items[0], items[1], items[2]
+
+	id, err := db.CreateStockCount(ctx, NewStockCount{Name: "Spring count"}, "staff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateStockCount(ctx, NewStockCount{Name: "Pots again", StockItemIDs: []string{pots.ID}}, "staff"); err == nil {
+		t.Fatal("expected an item already in an open count to be rejected")
+	}
+
+	// Counting happens over several days, and stock keeps moving meanwhile
+	if err := db.RecordStockCounts(ctx, id, []StockCountEntry{{StockItemID: compost.ID, Counted: 8}}, "staff"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.AdjustStockQuantity(ctx, compost.ID, StockAdjustment{Delta: 5, Note: "Delivery"}, "staff"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RecordStockCounts(ctx, id, []StockCountEntry{{StockItemID: pots.ID, Counted: 6}}, "staff"); err != nil {
+		t.Fatal(err)
+	}
+	for _, entries := range [][]StockCountEntry{{{StockItemID: pots.ID, Counted: -1}}, {{StockItemID: "no-such-item", Counted: 1}}} {
+		if err := db.RecordStockCounts(ctx, id, entries, "staff"); err == nil {
+			t.Fatalf("expected counts %+v to be rejected", entries)
+		}
+	}
+
+	count, err := db.GetStockCount(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count.Status != StockCountOpen || len(count.Lines) != 3 || count.Counted != 2 || count.Variances != 2 ||
+		count.UnitsOver != 1 || count.UnitsShort != 2 {
+		t.Fatalf("unexpected stock count %+v", count)
+	}
+
+	if err := db.PostStockCount(ctx, id, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]float64{compost.ID: 13, pots.ID: 6, twine.ID: 3}
+	for itemID, quantity := range expected {
+		if item, _ := db.GetStockItem(ctx, itemID); item.Quantity != quantity {
+			t.Fatalf("expected %s to have %g after posting, got %g", item.Name, quantity, item.Quantity)
+		}
+	}
+	movements, _ := db.GetStockMovements(ctx, compost.ID, 1)
+	if len(movements) != 1 || movements[0].Kind != MovementCount || movements[0].Quantity != 2 ||
+		movements[0].Reference != "Stock count #"+id || movements[0].MovedBy != "admin" {
+		t.Fatalf("unexpected count movement %+v", movements)
+	}
+
+	if err := db.PostStockCount(ctx, id, "admin"); err == nil {
+		t.Fatal("expected a posted count to be posted only once")
+	}
+	if err := db.RecordStockCounts(ctx, id, []StockCountEntry{{StockItemID: twine.ID, Counted: 1}}, "staff"); err == nil {
+		t.Fatal("expected a posted count to reject counts")
+	}
+	if err := db.DeleteStockCount(ctx, id); err == nil {
+		t.Fatal("expected a posted count to be kept")
+	}
+	if _, err := db.CreateStockCount(ctx, NewStockCount{Name: "Pots again", StockItemIDs: []string{pots.ID}}, "staff"); err != nil {
+		t.Fatalf("expected a new count once the first was posted: %v", err)
+	}
+}
+
+// addTestProduct adds a product with the given name and list price and returns its ID
+func TestStockCountKeepsMovementsBeforeCounting(t *testing.T) {
+	db := openTestDatabase(t)
+	ctx := context.Background()
+
+	item, err := db.AddStockItem(ctx, StockItem{Name: "Compost", Quantity: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := db.CreateStockCount(ctx, NewStockCount{Name: "Spring count"}, "staff")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Three go out after the count starts, and the shelf then holds the seven left
+	if _, err := db.AdjustStockQuantity(ctx, item.ID, StockAdjustment{Delta: -3, Note: "Sold"}, "staff"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RecordStockCounts(ctx, id, []StockCountEntry{{StockItemID: item.ID, Counted: 7}}, "staff"); err != nil {
+		t.Fatal(err)
+	}
+	count, err := db.GetStockCount(ctx, id)
+	if err != nil || count.Lines[0].Expected != 7 || count.Variances != 0 {
+		t.Fatalf("expected no variance, got %+v, %v", count, err)
+	}
+
+	if err := db.PostStockCount(ctx, id, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if item, _ := db.GetStockItem(ctx, item.ID); item.Quantity != 7 {
+		t.Fatalf("expected 7 after posting, got %g", item.Quantity)
+	}
+}
+
+func TestAnonymizedCopy(t *testing.T) {
+	db := openTestDatabase(t)
+	ctx := context.Background()
+
+	// Every free-text and username column holds something that identifies the customer
+	seed := []string{
+		"INSERT INTO users (id, username, password_hash, role, created_at) VALUES ('u', 'dana.levi', 'hash', 'staff', '')",
+		"INSERT INTO sessions (token_hash, user_id, created_at, expires_at) VALUES ('t', 'u', '', '')",
+		"INSERT INTO products (id, name, price) VALUES ('p', 'Fern', 9)",
+		"INSERT INTO product_prices (id, product_id, price, effective_from, reason, created_at) VALUES ('pp', 'p', 8, '', 'Deal for Dana Levi', '')",
+		"INSERT INTO orders (id, date, name, description, status, total) VALUES ('o', '', 'Dana Levi', 'Call Dana first', 'Pending', 9)",
+		`INSERT INTO order_items (id, order_id, product_id, name, price, quantity, override_reason)
+			VALUES ('oi', 'o', 'p', 'Fern', 9, 1, 'Dana Levi is a regular')`,
+		"INSERT INTO order_history (id, order_id, changed_at, changed_by, change) VALUES ('h', 'o', '', 'dana.levi', 'Name changed to Dana Levi')",
+		"INSERT INTO shipments (id, order_id, shipped_at, shipped_by, note) VALUES ('s', 'o', '', 'dana.levi', 'Left with Dana Levi')",
+		"INSERT INTO returns (id, order_id, returned_at, returned_by, reason) VALUES ('r', 'o', '', 'dana.levi', 'Dana Levi changed her mind')",
+		"INSERT INTO payments (id, order_id, method, amount, paid_at, reference, recorded_by) VALUES ('pa', 'o', 'Card', 9, '', 'Dana Levi card', 'dana.levi')",
+		"INSERT INTO order_templates (id, name, description, created_by, created_at) VALUES ('ot', 'Dana Levi weekly', 'For Dana', 'dana.levi', '')",
+		`INSERT INTO quotes (id, date, name, description, total, expires_on, status, created_by)
+			VALUES ('q', '', 'Dana Levi garden', 'Dana wants ferns', 9, '', 'Open', 'dana.levi')`,
+		`INSERT INTO quote_items (id, quote_id, product_id, name, price, quantity, override_reason)
+			VALUES ('qi', 'q', 'p', 'Fern', 9, 1, 'Dana Levi discount')`,
+		"INSERT INTO locations (id, name, created_at) VALUES ('l', 'Dana Levi garden shed', '')",
+		"INSERT INTO stock_items (id, name, quantity) VALUES ('si', 'Compost', 0)",
+		`INSERT INTO stock_movements (id, stock_item_id, kind, quantity, note, moved_at, moved_by)
+			VALUES ('m', 'si', 'Adjustment', 1, 'Gift for Dana Levi', '', 'dana.levi')`,
+		`INSERT INTO stock_counts (id, name, location_id, status, started_at, started_by, posted_by)
+			VALUES ('c', 'Count at Dana Levi', 'l', 'Open', '', 'dana.levi', 'dana.levi')`,
+		"INSERT INTO stock_count_lines (count_id, stock_item_id, expected, counted_by) VALUES ('c', 'si', 0, 'dana.levi')",
+	}
+	for _, statement := range seed {
+		if _, err := db.db.Exec(statement); err != nil {
+			t.Fatalf("failed to seed %q: %v", statement, err)
+		}
+	}
+
+	dest := filepath.Join(t.TempDir(), "anonymized.db")
+	if err := db.AnonymizedCopy(ctx, dest); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i := bytes.Index(bytes.ToLower(data), []byte("dana")); i >= 0 {
+		t.Fatalf("expected no customer data in the copy, found %q", data[max(i-40, 0):min(i+40, len(data))])
+	}
+
+	copied, err := sql.Open("sqlite3", dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer copied.Close()
+	var shippedBy string
+	if err := copied.QueryRow("SELECT shipped_by FROM shipments").Scan(&shippedBy); err != nil || shippedBy != "user-1" {
+		t.Fatalf("expected the shipment to keep its anonymized user, got %q, %v", shippedBy, err)
+	}
+}
+
+func addTestProduct(t *testing.T, db *Database, name string, price float64) string {
+	t.Helper()
+
+	id, err := db.AddProduct(context.Background(), Product{Name: name, Price: price, Status: "In Stock"})
+	if err != nil {
+		t.Fatalf("failed to add product %s: %v", name, err)
+	}
+	return id
+}
@@ -0,0 +1,543 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// appVersion is the version of the application, matching productVersion in wails.json
+const appVersion = "1.0.0"
+
+const (
+	// defaultLogLimit is the number of log entries GetRecentLogs returns when no limit is given
+	defaultLogLimit = 200
+	// maxLogLimit caps the number of log entries returned at once
+	maxLogLimit = 5000
+)
+
+// TableRowCount is the number of rows in one database table
+type TableRowCount struct {
+	Table string `json:"table"`
+	Rows  int64  `json:"rows"`
+}
+
+// Diagnostics describes the running app and its database, for support requests
+type Diagnostics struct {
+	Version       string          `json:"version"`
+	GoVersion     string          `json:"goVersion"`
+	Platform      string          `json:"platform"`
+	DBPath        string          `json:"dbPath"`
+	DBSize        int64           `json:"dbSize"`
+	SchemaVersion int             `json:"schemaVersion"`
+	SQLiteVersion string          `json:"sqliteVersion"`
+	Integrity     string          `json:"integrity"`
+	Tables        []TableRowCount `json:"tables"`
+	LogPath       string          `json:"logPath"`
+	GeneratedAt   string          `json:"generatedAt"`
+}
+
+// LogEntry is one message read back from the log file
+type LogEntry struct {
+	Time      string            `json:"time"`
+	Level     string            `json:"level"`
+	Subsystem string            `json:"subsystem"`
+	Message   string            `json:"message"`
+	Attrs     map[string]string `json:"attrs"`
+}
+
+// Diagnostics reports the database file, schema and SQLite versions, row counts and
+// the result of SQLite's integrity check
+func (db *Database) Diagnostics(ctx context.Context) (Diagnostics, error) {
+	diagnostics := Diagnostics{
+		Version:     appVersion,
+		GoVersion:   runtime.Version(),
+		Platform:    runtime.GOOS + "/" + runtime.GOARCH,
+		DBPath:      db.path,
+		GeneratedAt: time.Now().Format(priceTimeLayout),
+	}
+
+	if info, err := os.Stat(db.path); err == nil {
+		diagnostics.DBSize = info.Size()
+	}
+
+	if err := db.read.QueryRowContext(ctx, "PRAGMA user_version").Scan(&diagnostics.SchemaVersion); err != nil {
+		return diagnostics, fmt.Errorf("failed to get schema version: %v", err)
+	}
+	if err := db.read.QueryRowContext(ctx, "SELECT sqlite_version()").Scan(&diagnostics.SQLiteVersion); err != nil {
+		return diagnostics, fmt.Errorf("failed to get SQLite version: %v", err)
+	}
+
+	// A damaged file is exactly what diagnostics are for, so report the failure instead
+	integrity, err := db.integrityCheck(ctx)
+	if err != nil {
+		integrity = err.Error()
+	}
+	diagnostics.Integrity = integrity
+
+	tables, err := db.tableRowCounts(ctx)
+	if err != nil {
+		return diagnostics, err
+	}
+	diagnostics.Tables = tables
+
+	return diagnostics, nil
+}
+
+// integrityCheck runs SQLite's integrity check and returns "ok" or the problems found
+func (db *Database) integrityCheck(ctx context.Context) (string, error) {
+	rows, err := db.read.QueryContext(ctx, "PRAGMA integrity_check(20)")
+	if err != nil {
+		return "", fmt.Errorf("failed to check database integrity: %v", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var problem string
+		if err := rows.Scan(&problem); err != nil {
+			return "", fmt.Errorf("failed to scan integrity check result: %v", err)
+		}
+		problems = append(problems, problem)
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("failed to check database integrity: %v", err)
+	}
+	return strings.Join(problems, "; "), nil
+}
+
+// tableRowCounts counts the rows of every table in the database
+func (db *Database) tableRowCounts(ctx context.Context) ([]TableRowCount, error) {
+	rows, err := db.read.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %v", err)
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan table name: %v", err)
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+
+	counts := []TableRowCount{}
+	for _, name := range names {
+		count := TableRowCount{Table: name}
+		// Table names come from sqlite_master, not user input
+		query := `SELECT COUNT(*) FROM "` + strings.ReplaceAll(name, `"`, `""`) + `"`
+		if err := db.read.QueryRowContext(ctx, query).Scan(&count.Rows); err != nil {
+			return nil, fmt.Errorf("failed to count rows in %s: %v", name, err)
+		}
+		counts = append(counts, count)
+	}
+	return counts, nil
+}
+
+// AnonymizedCopy writes a copy of the database to dest with customer names, notes and
+// other free text, usernames, password hashes and sessions removed, so it can be shared
+// for support. The catalog, quantities, amounts and dates are kept.
+func (db *Database) AnonymizedCopy(ctx context.Context, dest string) error {
+	if err := db.Backup(ctx, dest); err != nil {
+		return err
+	}
+
+	copied, err := sql.Open("sqlite3", dest)
+	if err != nil {
+		return fmt.Errorf("failed to open database copy: %v", err)
+	}
+	defer copied.Close()
+
+	// Usernames are recorded in every *_by column. They become the same user-N as in the
+	// users table, so who did what can still be followed.
+	byColumns, err := usernameColumns(ctx, copied)
+	if err != nil {
+		return err
+	}
+	var statements []string
+	for _, column := range byColumns {
+		statements = append(statements, fmt.Sprintf(`UPDATE %[1]s SET %[2]s = COALESCE(
+			(SELECT 'user-' || u.rowid FROM users u WHERE u.username = %[1]s.%[2]s COLLATE NOCASE), 'former user'
+		) WHERE %[2]s IS NOT NULL AND %[2]s <> ''`, column.table, column.name))
+	}
+	statements = append(statements,
+		"UPDATE orders SET name = 'Customer ' || id, description = NULL",
+		"UPDATE order_items SET override_reason = 'Price override' WHERE override_reason IS NOT NULL",
+		"UPDATE order_history SET change = 'Order changed'",
+		"UPDATE shipments SET note = NULL",
+		"UPDATE returns SET reason = 'Returned'",
+		"UPDATE payments SET reference = NULL",
+		"UPDATE order_templates SET name = 'Template ' || rowid, description = NULL",
+		"UPDATE quotes SET name = 'Customer ' || id, description = NULL",
+		"UPDATE quote_items SET override_reason = 'Price override' WHERE override_reason IS NOT NULL",
+		"UPDATE product_prices SET reason = NULL",
+		"UPDATE locations SET name = 'Location ' || rowid WHERE id <> 'main'",
+		"UPDATE stock_movements SET note = NULL",
+		"UPDATE stock_counts SET name = 'Stock count ' || id",
+		"UPDATE users SET username = 'user-' || rowid, password_hash = ''",
+		"DELETE FROM sessions",
+	)
+	for _, statement := range statements {
+		if _, err := copied.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to anonymize database copy: %v", err)
+		}
+	}
+
+	// Drop the freed pages so removed values do not linger in the file
+	if _, err := copied.ExecContext(ctx, "VACUUM"); err != nil {
+		return fmt.Errorf("failed to compact database copy: %v", err)
+	}
+	return nil
+}
+
+// tableColumn names a column of a table
+type tableColumn struct {
+	table, name string
+}
+
+// usernameColumns lists the *_by columns of every table, which record who made a change
+func usernameColumns(ctx context.Context, q queryer) ([]tableColumn, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT m.name, p.name FROM sqlite_master m JOIN pragma_table_info(m.name) p
+		WHERE m.type = 'table' AND p.name LIKE '%\_by' ESCAPE '\'
+		ORDER BY m.name, p.cid
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query username columns: %v", err)
+	}
+	defer rows.Close()
+
+	var columns []tableColumn
+	for rows.Next() {
+		var column tableColumn
+		if err := rows.Scan(&column.table, &column.name); err != nil {
+			return nil, fmt.Errorf("failed to scan username column: %v", err)
+		}
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
+
+// readRecentLogs returns up to limit of the newest entries at or above minLevel from
+// the log at path and the files it was rotated to, oldest first
+func readRecentLogs(path string, minLevel slog.Level, limit int) ([]LogEntry, error) {
+	entries := []LogEntry{}
+
+	for _, file := range append([]string{path}, rotatedLogFiles(path)...) {
+		lines, err := readLines(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read log file: %v", err)
+		}
+
+		// Walk the file backwards so the newest entries are collected first
+		for i := len(lines) - 1; i >= 0 && len(entries) < limit; i-- {
+			entry, ok := parseLogLine(lines[i])
+			if !ok {
+				continue
+			}
+			if level, err := parseLogLevel(entry.Level); err == nil && level < minLevel {
+				continue
+			}
+			entries = append(entries, entry)
+		}
+		if len(entries) >= limit {
+			break
+		}
+	}
+
+	// Return the entries in the order they were written
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// readLines reads a file as lines
+func readLines(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// parseLogLine parses a line written by the text or JSON log handler. Lines in any
+// other format, such as those written before structured logging, become INFO entries.
+func parseLogLine(line string) (LogEntry, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return LogEntry{}, false
+	}
+
+	var fields [][2]string
+	if strings.HasPrefix(line, "{") {
+		var values map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &values); err != nil {
+			return LogEntry{Level: "INFO", Message: line}, true
+		}
+		for key, value := range values {
+			text, ok := value.(string)
+			if !ok {
+				encoded, _ := json.Marshal(value)
+				text = string(encoded)
+			}
+			fields = append(fields, [2]string{key, text})
+		}
+	} else {
+		var ok bool
+		if fields, ok = parseLogfmt(line); !ok {
+			return LogEntry{Level: "INFO", Message: line}, true
+		}
+	}
+
+	entry := LogEntry{Attrs: map[string]string{}}
+	for _, field := range fields {
+		switch field[0] {
+		case slog.TimeKey:
+			entry.Time = field[1]
+		case slog.LevelKey:
+			entry.Level = field[1]
+		case slog.MessageKey:
+			entry.Message = field[1]
+		case "subsystem":
+			entry.Subsystem = field[1]
+		default:
+			entry.Attrs[field[0]] = field[1]
+		}
+	}
+	if entry.Level == "" {
+		return LogEntry{Level: "INFO", Message: line}, true
+	}
+	return entry, true
+}
+
+// parseLogfmt splits a line written by slog's text handler into key/value pairs
+func parseLogfmt(line string) ([][2]string, bool) {
+	var fields [][2]string
+	for line != "" {
+		key, rest, ok := strings.Cut(line, "=")
+		if !ok || key == "" || strings.ContainsAny(key, ` "`) {
+			return nil, false
+		}
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			// Quoted values use Go string syntax; find the closing quote
+			end := 1
+			for end < len(rest) && rest[end] != '"' {
+				if rest[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(rest) {
+				return nil, false
+			}
+			unquoted, err := strconv.Unquote(rest[:end+1])
+			if err != nil {
+				return nil, false
+			}
+			value, rest = unquoted, rest[end+1:]
+		} else {
+			value, rest, _ = strings.Cut(rest, " ")
+		}
+
+		fields = append(fields, [2]string{key, value})
+		line = strings.TrimLeft(rest, " ")
+	}
+	return fields, len(fields) > 0
+}
+
+// writeSupportBundle writes a zip archive with the diagnostics, the log files and an
+// anonymized copy of the database
+func writeSupportBundle(ctx context.Context, w io.Writer, db *Database, diagnostics Diagnostics, logPath string) error {
+	archive := zip.NewWriter(w)
+
+	data, err := json.MarshalIndent(diagnostics, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode diagnostics: %v", err)
+	}
+	if err := addZipFile(archive, "diagnostics.json", data); err != nil {
+		return err
+	}
+
+	for _, file := range append([]string{logPath}, rotatedLogFiles(logPath)...) {
+		data, err := os.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read log file: %v", err)
+		}
+		if err := addZipFile(archive, "logs/"+filepath.Base(file), data); err != nil {
+			return err
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "support-bundle")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	copyPath := filepath.Join(dir, "database.sqlite")
+	if err := db.AnonymizedCopy(ctx, copyPath); err != nil {
+		return err
+	}
+	data, err = os.ReadFile(copyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read database copy: %v", err)
+	}
+	if err := addZipFile(archive, "database.sqlite", data); err != nil {
+		return err
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to finish support bundle: %v", err)
+	}
+	return nil
+}
+
+// addZipFile adds a file with the given contents to a zip archive
+func addZipFile(archive *zip.Writer, name string, data []byte) error {
+	file, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return fmt.Errorf("failed to add %s to support bundle: %v", name, err)
+	}
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("failed to add %s to support bundle: %v", name, err)
+	}
+	return nil
+}
+
+// GetDiagnostics returns the app version, database details and integrity check result
+func (a *App) GetDiagnostics() Diagnostics {
+	ctx, logger, db, done := a.call("GetDiagnostics")
+	defer done()
+
+	if err := a.authorize(RoleAdmin); err != nil {
+		logger.Error("Error getting diagnostics", "err", err)
+		return Diagnostics{}
+	}
+
+	diagnostics, err := db.Diagnostics(ctx)
+	if err != nil {
+		logger.Error("Error getting diagnostics", "err", err)
+		return Diagnostics{}
+	}
+	diagnostics.LogPath = a.logPath
+	return diagnostics
+}
+
+// GetRecentLogs returns the newest log entries at or above the given level ("" for
+// all), oldest first. A limit of 0 returns the default number of entries.
+func (a *App) GetRecentLogs(level string, limit int) []LogEntry {
+	logger, _ := a.begin("GetRecentLogs")
+
+	if err := a.authorize(RoleAdmin); err != nil {
+		logger.Error("Error getting recent logs", "err", err)
+		return []LogEntry{}
+	}
+
+	minLevel := slog.LevelDebug
+	if level != "" {
+		var err error
+		if minLevel, err = parseLogLevel(level); err != nil {
+			logger.Error("Error getting recent logs", "err", err)
+			return []LogEntry{}
+		}
+	}
+	if limit <= 0 {
+		limit = defaultLogLimit
+	}
+	if limit > maxLogLimit {
+		limit = maxLogLimit
+	}
+
+	entries, err := readRecentLogs(a.logPath, minLevel, limit)
+	if err != nil {
+		logger.Error("Error getting recent logs", "err", err)
+		return []LogEntry{}
+	}
+	return entries
+}
+
+// ExportSupportBundle asks where to save and writes a zip with the diagnostics, logs
+// and an anonymized copy of the database
+func (a *App) ExportSupportBundle() bool {
+	ctx, logger, db, done := a.callWithTimeout("ExportSupportBundle", exportTimeout)
+	defer done()
+
+	if err := a.authorize(RoleAdmin); err != nil {
+		logger.Error("Error exporting support bundle", "err", err)
+		return false
+	}
+
+	filePath, err := wailsruntime.SaveFileDialog(a.ctx, wailsruntime.SaveDialogOptions{
+		Title:           "Save Support Bundle",
+		DefaultFilename: "support-bundle-" + time.Now().Format("20060102-150405") + ".zip",
+		Filters: []wailsruntime.FileFilter{
+			{DisplayName: "Zip Archives (*.zip)", Pattern: "*.zip"},
+		},
+	})
+	if err != nil {
+		logger.Error("Error opening save dialog", "err", err)
+		return false
+	}
+	if filePath == "" {
+		// The user cancelled the dialog
+		return false
+	}
+
+	diagnostics, err := db.Diagnostics(ctx)
+	if err != nil {
+		logger.Error("Error exporting support bundle", "err", err)
+		return false
+	}
+	diagnostics.LogPath = a.logPath
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		logger.Error("Error exporting support bundle", "err", err)
+		return false
+	}
+	if err := writeSupportBundle(ctx, file, db, diagnostics, a.logPath); err != nil {
+		file.Close()
+		os.Remove(filePath)
+		logger.Error("Error exporting support bundle", "err", err)
+		return false
+	}
+	if err := file.Close(); err != nil {
+		logger.Error("Error exporting support bundle", "err", err)
+		return false
+	}
+
+	logger.Info("Support bundle written", "path", filePath)
+	return true
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Kinds of records reported by change events
+const (
+	EntityProduct = "product"
+	EntityOrder   = "order"
+	EntityStock   = "stock"
+)
+
+// What happened to the record in a change event
+const (
+	ActionCreated = "created"
+	ActionUpdated = "updated"
+	ActionDeleted = "deleted"
+)
+
+// clockEvent is the frontend event carrying the current time, once a second
+const clockEvent = "clock:tick"
+
+// ChangeEvent reports that a product, order or stock item was created, updated or
+// deleted. Data holds the record as it is after the change (a Product, Order or
+// StockItem), or nil when it was deleted or could not be read back.
+type ChangeEvent struct {
+	Entity string      `json:"entity"`
+	Action string      `json:"action"`
+	ID     string      `json:"id"`
+	Data   interface{} `json:"data"`
+}
+
+// Topic returns the frontend event name the change is emitted under, such as "changed:product"
+func (e ChangeEvent) Topic() string {
+	return "changed:" + e.Entity
+}
+
+// EventBus delivers change events to subscribers. Publish calls each subscriber in
+// turn on the publishing goroutine, so subscribers must not block.
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers map[int]func(ChangeEvent)
+	next        int
+}
+
+// NewEventBus creates an event bus without subscribers
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: map[int]func(ChangeEvent){}}
+}
+
+// Subscribe registers a function called for every published event and returns a
+// function that removes it again
+func (b *EventBus) Subscribe(subscriber func(ChangeEvent)) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.next
+	b.next++
+	b.subscribers[id] = subscriber
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers, id)
+	}
+}
+
+// HasSubscribers reports whether anyone is listening, so publishers can skip
+// reading back records nobody will receive
+func (b *EventBus) HasSubscribers() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers) > 0
+}
+
+// Publish delivers an event to every subscriber
+func (b *EventBus) Publish(event ChangeEvent) {
+	b.mu.RLock()
+	subscribers := make([]func(ChangeEvent), 0, len(b.subscribers))
+	for _, subscriber := range b.subscribers {
+		subscribers = append(subscribers, subscriber)
+	}
+	b.mu.RUnlock()
+
+	for _, subscriber := range subscribers {
+		subscriber(event)
+	}
+}
+
+// Events returns the bus the database publishes its change events on
+func (db *Database) Events() *EventBus {
+	return db.events
+}
+
+// publish reports a committed change, reading back the changed record for subscribers
+// that patch their copy instead of reloading everything
+func (db *Database) publish(entity string, action string, id string) {
+	if !db.events.HasSubscribers() {
+		return
+	}
+
+	event := ChangeEvent{Entity: entity, Action: action, ID: id}
+	if action != ActionDeleted {
+		// The change is committed, so the record is read back even if the caller's
+		// context ends meanwhile
+		ctx := context.Background()
+		var err error
+		switch entity {
+		case EntityProduct:
+			event.Data, err = db.getProduct(ctx, id)
+		case EntityOrder:
+			event.Data, err = db.GetOrder(ctx, id)
+		case EntityStock:
+			event.Data, err = db.GetStockItem(ctx, id)
+		}
+		if err != nil {
+			// Subscribers reload the whole list when the record is missing
+			db.log.Warn("Error reading changed record", "entity", entity, "id", id, "err", err)
+			event.Data = nil
+		}
+	}
+
+	db.events.Publish(event)
+}
+
+// forwardEvents emits the database's change events to the frontend until ctx is done
+func (a *App) forwardEvents(ctx context.Context, db *Database) {
+	unsubscribe := db.Events().Subscribe(func(event ChangeEvent) {
+		wailsruntime.EventsEmit(ctx, event.Topic(), event)
+	})
+	go func() {
+		<-ctx.Done()
+		unsubscribe()
+	}()
+}
+
+// runClock emits the current time to the frontend every second until ctx is done
+func (a *App) runClock(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			wailsruntime.EventsEmit(ctx, clockEvent, now.Format(time.RFC1123))
+		}
+	}
+}
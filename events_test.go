@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// recordEvents subscribes to the database's events and returns the received events
+func recordEvents(t *testing.T, db *Database) *[]ChangeEvent {
+	t.Helper()
+
+	var events []ChangeEvent
+	unsubscribe := db.Events().Subscribe(func(event ChangeEvent) {
+		events = append(events, event)
+	})
+	t.Cleanup(unsubscribe)
+	return &events
+}
+
+// expectEvent checks that exactly one event was received and returns it
+func expectEvent(t *testing.T, events *[]ChangeEvent, entity string, action string, id string) ChangeEvent {
+	t.Helper()
+
+	if len(*events) != 1 {
+		t.Fatalf("expected one %s %s event, got %+v", entity, action, *events)
+	}
+	event := (*events)[0]
+	*events = nil
+
+	if event.Entity != entity || event.Action != action || event.ID != id {
+		t.Fatalf("expected %s %s %s, got %+v", entity, action, id, event)
+	}
+	if event.Topic() != "changed:"+entity {
+		t.Fatalf("unexpected topic %q", event.Topic())
+	}
+	return event
+}
+
+func TestEventBusSubscribe(t *testing.T) {
+	bus := NewEventBus()
+	if bus.HasSubscribers() {
+		t.Fatal("new bus should have no subscribers")
+	}
+
+	var first, second int
+	unsubscribeFirst := bus.Subscribe(func(ChangeEvent) { first++ })
+	bus.Subscribe(func(ChangeEvent) { second++ })
+
+	bus.Publish(ChangeEvent{Entity: EntityProduct, Action: ActionCreated, ID: "1"})
+	unsubscribeFirst()
+	bus.Publish(ChangeEvent{Entity: EntityProduct, Action: ActionDeleted, ID: "1"})
+
+	if first != 1 || second != 2 {
+		t.Fatalf("expected 1 and 2 deliveries, got %d and %d", first, second)
+	}
+}
+
+func TestProductEvents(t *testing.T) {
+	db := openTestDatabase(t)
+	events := recordEvents(t, db)
+
+	id, err := db.AddProduct(context.Background(), Product{Name: "Lavender", Price: 7.5, Status: "In Stock"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := expectEvent(t, events, EntityProduct, ActionCreated, id)
+	if product, ok := event.Data.(*Product); !ok || product.Name != "Lavender" {
+		t.Fatalf("expected the created product as data, got %#v", event.Data)
+	}
+
+	if err := db.UpdateProduct(context.Background(), Product{ID: id, Name: "Lavender", Price: 8, Status: "In Stock"}); err != nil {
+		t.Fatal(err)
+	}
+	event = expectEvent(t, events, EntityProduct, ActionUpdated, id)
+	if product := event.Data.(*Product); product.Price != 8 {
+		t.Fatalf("expected the updated price, got %v", product.Price)
+	}
+
+	variant, err := db.AddProductVariant(context.Background(), ProductVariant{ProductID: id, Size: "Large", Price: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event = expectEvent(t, events, EntityProduct, ActionUpdated, id)
+	if product := event.Data.(*Product); len(product.Variants) != 1 {
+		t.Fatalf("expected the product with its new variant, got %+v", product)
+	}
+	if err := db.DeleteProductVariant(context.Background(), variant.ID); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, events, EntityProduct, ActionUpdated, id)
+
+	if err := db.DeleteProduct(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	event = expectEvent(t, events, EntityProduct, ActionDeleted, id)
+	if event.Data != nil {
+		t.Fatalf("deleted events carry no data, got %#v", event.Data)
+	}
+	if _, err := db.getProduct(context.Background(), id); !errors.As(err, new(*NotFoundError)) {
+		t.Fatalf("expected a deleted product to be missing, got %v", err)
+	}
+
+	// Failed changes publish nothing
+	if err := db.DeleteProduct(context.Background(), id); err == nil {
+		t.Fatal("expected deleting a missing product to fail")
+	}
+	if len(*events) != 0 {
+		t.Fatalf("expected no events for a failed delete, got %+v", *events)
+	}
+}
+
+func TestOrderEvents(t *testing.T) {
+	db := openTestDatabase(t)
+	productID := addTestProduct(t, db, "Tomato Plant", 5)
+	events := recordEvents(t, db)
+
+	id, err := db.CreateOrder(context.Background(), "Dana", "", []OrderItem{{ProductID: productID, Quantity: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := expectEvent(t, events, EntityOrder, ActionCreated, id)
+	if order, ok := event.Data.(*Order); !ok || order.Total != 10 {
+		t.Fatalf("expected the created order as data, got %#v", event.Data)
+	}
+
+	if err := db.UpdateOrderStatus(context.Background(), id, "Completed"); err != nil {
+		t.Fatal(err)
+	}
+	event = expectEvent(t, events, EntityOrder, ActionUpdated, id)
+	if order := event.Data.(*Order); order.Status != "Completed" {
+		t.Fatalf("expected status Completed, got %s", order.Status)
+	}
+
+	if err := db.DeleteOrder(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, events, EntityOrder, ActionDeleted, id)
+	if _, err := db.GetOrder(context.Background(), id); !errors.As(err, new(*NotFoundError)) {
+		t.Fatalf("expected a deleted order to be missing, got %v", err)
+	}
+}
+
+func TestStockEvents(t *testing.T) {
+	db := openTestDatabase(t)
+	events := recordEvents(t, db)
+
+	item, err := db.AddStockItem(context.Background(), StockItem{Name: "Compost", Quantity: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, events, EntityStock, ActionCreated, item.ID)
+
+	if _, err := db.AdjustStockQuantity(context.Background(), item.ID, StockAdjustment{Delta: -4}, ""); err != nil {
+		t.Fatal(err)
+	}
+	event := expectEvent(t, events, EntityStock, ActionUpdated, item.ID)
+	if stock, ok := event.Data.(StockItem); !ok || stock.Quantity != 6 {
+		t.Fatalf("expected quantity 6 in the event, got %#v", event.Data)
+	}
+
+	if _, err := db.AdjustStockQuantity(context.Background(), item.ID, StockAdjustment{Delta: -100}, ""); err == nil {
+		t.Fatal("expected removing more than in stock to fail")
+	}
+	if updated, err := db.UpdateStockItem(context.Background(), StockItem{ID: "missing", Name: "Nothing"}); err != nil || updated {
+		t.Fatalf("expected updating a missing item to change nothing, got %v, %v", updated, err)
+	}
+	if len(*events) != 0 {
+		t.Fatalf("expected no events for failed changes, got %+v", *events)
+	}
+
+	if _, err := db.DeleteStockItem(context.Background(), item.ID); err == nil {
+		t.Fatal("expected an item with stock movements to be kept")
+	}
+	if len(*events) != 0 {
+		t.Fatalf("expected no events for a refused delete, got %+v", *events)
+	}
+
+	empty, err := db.AddStockItem(context.Background(), StockItem{Name: "Bark"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, events, EntityStock, ActionCreated, empty.ID)
+	if _, err := db.DeleteStockItem(context.Background(), empty.ID); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, events, EntityStock, ActionDeleted, empty.ID)
+}
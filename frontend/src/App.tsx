@@ -3,8 +3,9 @@
 import React, { useState, useEffect } from 'react';
 import './App.css';
 import { v4 as uuidv4 } from 'uuid';
-import { GetCurrentTime, GetProducts, GetOrders, GetStockItems, DatabaseStatus } from '../wailsjs/go/main/App';
+import { GetCurrentTime, GetProducts, GetOrders, GetStockItems, DatabaseStatus, GetDatabaseState, GetCurrentUser, NeedsSetup, Logout } from '../wailsjs/go/main/App';
 import { main } from '../wailsjs/go/models';
+import { EventsOn } from '../wailsjs/runtime';
 
 // Components
 import SidebarLayout from './layouts/SidebarLayout';
@@ -12,8 +13,15 @@ import Dashboard from './pages/Dashboard';
 import Products from './pages/Products';
 import Orders from './pages/Orders';
 import CreateOrder from './pages/CreateOrder';
+import Quotes from './pages/Quotes';
+import StockCounts from './pages/StockCounts';
 import NotificationSystem from './components/NotificationSystem';
 import Stock from './pages/Stock';
+import Users from './pages/Users';
+import Diagnostics from './pages/Diagnostics';
+import Login from './pages/Login';
+import DatabaseRecovery from './pages/DatabaseRecovery';
+import { onChange, applyChange, needsReload } from './utils/changeEvents';
 
 // Interfaces
 type Product = main.Product;
@@ -31,6 +39,8 @@ interface Order {
   items: OrderItem[];
   total: number;
   status: string;
+  refunded?: number;
+  balanceDue?: number;
 }
 
 interface Notification {
@@ -47,6 +57,12 @@ function App() {
   const [activePage, setActivePage] = useState('dashboard');
   const [dbStatus, setDbStatus] = useState('Checking database...');
   const [isLoading, setIsLoading] = useState(true);
+  const [dbState, setDbState] = useState<main.DatabaseState | null>(null);
+  
+  // Auth state
+  const [currentUser, setCurrentUser] = useState<main.User | null>(null);
+  const [needsSetup, setNeedsSetup] = useState(false);
+  const [authChecked, setAuthChecked] = useState(false);
   
   // Data state
   const [products, setProducts] = useState<Product[]>([]);
@@ -57,22 +73,94 @@ function App() {
   const [notifications, setNotifications] = useState<Notification[]>([]);
   
   useEffect(() => {
-    // Update time every second
-    const interval = setInterval(() => {
-      GetCurrentTime().then(setCurrentTime);
-    }, 1000);
+    // Show the time now, then whenever the backend's clock ticks
+    GetCurrentTime().then(setCurrentTime);
+    const stopClock = EventsOn('clock:tick', setCurrentTime);
     
-    // Load initial data
-    loadInitialData();
+    // The database may have failed to open, in which case the recovery screen
+    // is shown instead of the login
+    GetDatabaseState().then(setDbState).catch(err => {
+      console.error("Failed to get database state:", err);
+    });
+    
+    return stopClock;
+  }, []);
+  
+  useEffect(() => {
+    if (!dbState?.ready) {
+      return;
+    }
+    
+    // Find out whether someone is logged in or the first admin must be created
+    checkAuth();
     
     // Check database status
     DatabaseStatus().then(setDbStatus).catch(err => {
       console.error("Failed to get database status:", err);
       setDbStatus("Database error: Could not connect");
     });
+  }, [dbState?.ready]);
+  
+  // Load data once a user has logged in, then keep it in sync with changes made
+  // here or in another window
+  useEffect(() => {
+    if (!currentUser) {
+      return;
+    }
+    loadInitialData();
     
-    return () => clearInterval(interval);
-  }, []);
+    const subscriptions = [
+      onChange<Product>('product', event => {
+        if (needsReload(event)) {
+          loadProducts();
+          return;
+        }
+        setProducts(prev => applyChange(prev, event));
+      }),
+      onChange<Order>('order', event => {
+        if (needsReload(event)) {
+          loadOrders();
+          return;
+        }
+        setOrders(prev => applyChange(prev, event));
+      }),
+      onChange('stock', event => {
+        if (needsReload(event)) {
+          loadStockItems();
+          return;
+        }
+        setStockItems(prev => applyChange(prev, event));
+      }),
+    ];
+    return () => subscriptions.forEach(unsubscribe => unsubscribe());
+  }, [currentUser]);
+  
+  const checkAuth = async () => {
+    try {
+      const [user, setup] = await Promise.all([GetCurrentUser(), NeedsSetup()]);
+      setNeedsSetup(setup);
+      setCurrentUser(user && user.id ? user : null);
+    } catch (error) {
+      console.error("Failed to check login:", error);
+    } finally {
+      setAuthChecked(true);
+      setIsLoading(false);
+    }
+  };
+  
+  const handleLoggedIn = (user: main.User) => {
+    setNeedsSetup(false);
+    setCurrentUser(user);
+    setActivePage('dashboard');
+  };
+  
+  const handleLogout = async () => {
+    await Logout();
+    setCurrentUser(null);
+    setProducts([]);
+    setOrders([]);
+    setStockItems([]);
+  };
   
   const loadInitialData = async () => {
     setIsLoading(true);
@@ -142,6 +230,9 @@ function App() {
   // Calculate stats for dashboard
   const pendingOrderCount = orders.filter(order => order.status === 'Pending').length;
   const totalSales = orders.reduce((sum, order) => sum + order.total, 0);
+  const refunds = orders.reduce((sum, order) => sum + (order.refunded || 0), 0);
+  const outstandingOrders = orders.filter(order => order.status !== 'Cancelled' && (order.balanceDue || 0) > 0);
+  const outstandingBalance = outstandingOrders.reduce((sum, order) => sum + (order.balanceDue || 0), 0);
   
   // Render the appropriate page based on activePage state
   const renderPage = () => {
@@ -154,6 +245,9 @@ function App() {
             stockItemCount={stockItems.length}
             pendingOrderCount={pendingOrderCount}
             totalSales={totalSales}
+            refunds={refunds}
+            outstandingOrderCount={outstandingOrders.length}
+            outstandingBalance={outstandingBalance}
             darkMode={darkMode}
           />
         );
@@ -162,7 +256,6 @@ function App() {
           <Products 
             products={products}
             darkMode={darkMode}
-            onProductsChanged={loadProducts}
             showNotification={showNotification}
           />
         );
@@ -171,7 +264,6 @@ function App() {
           <CreateOrder 
             products={products}
             darkMode={darkMode}
-            onOrderCreated={loadOrders}
             showNotification={showNotification}
           />
         );
@@ -182,6 +274,13 @@ function App() {
             showNotification={showNotification}
           />
         );
+      case 'quotes':
+        return (
+          <Quotes
+            darkMode={darkMode}
+            showNotification={showNotification}
+          />
+        );
       case 'stock':
         return (
           <Stock
@@ -189,11 +288,59 @@ function App() {
             showNotification={showNotification}
           />
         );
+      case 'stock-counts':
+        return (
+          <StockCounts
+            darkMode={darkMode}
+            showNotification={showNotification}
+          />
+        );
+      case 'users':
+        return currentUser && currentUser.role === 'admin' ? (
+          <Users
+            darkMode={darkMode}
+            currentUser={currentUser}
+            showNotification={showNotification}
+          />
+        ) : <div>Page not found</div>;
+      case 'diagnostics':
+        return currentUser && currentUser.role === 'admin' ? (
+          <Diagnostics
+            darkMode={darkMode}
+            showNotification={showNotification}
+          />
+        ) : <div>Page not found</div>;
       default:
         return <div>Page not found</div>;
     }
   };
   
+  if (dbState && !dbState.ready) {
+    return (
+      <div className={darkMode ? 'dark-mode' : 'light-mode'}>
+        <DatabaseRecovery
+          darkMode={darkMode}
+          state={dbState}
+          onStateChanged={setDbState}
+        />
+      </div>
+    );
+  }
+  
+  if (!currentUser) {
+    return (
+      <div className={darkMode ? 'dark-mode' : 'light-mode'}>
+        {authChecked && (
+          <Login
+            darkMode={darkMode}
+            needsSetup={needsSetup}
+            onLoggedIn={handleLoggedIn}
+          />
+        )}
+      </div>
+    );
+  }
+  
   return (
     <div className={darkMode ? 'dark-mode' : 'light-mode'}>
       {isLoading && (
@@ -209,6 +356,8 @@ function App() {
         dbStatus={dbStatus}
         activePage={activePage}
         setActivePage={setActivePage}
+        currentUser={currentUser}
+        onLogout={handleLogout}
       >
         {renderPage()}
       </SidebarLayout>
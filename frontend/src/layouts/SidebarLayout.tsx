@@ -1,7 +1,8 @@
 import React, { useState } from 'react';
 import styled, { keyframes } from 'styled-components';
 import { GardenLogo } from '../components/GardenLogo';
-import { FaBoxes } from 'react-icons/fa';
+import { FaBoxes, FaUsers, FaStethoscope, FaFileInvoice, FaClipboardCheck } from 'react-icons/fa';
+import { main } from '../../wailsjs/go/models';
 
 interface SidebarLayoutProps {
   children: React.ReactNode;
@@ -11,6 +12,8 @@ interface SidebarLayoutProps {
   dbStatus: string;
   activePage: string;
   setActivePage: (page: string) => void;
+  currentUser: main.User;
+  onLogout: () => void;
 }
 
 // Animations
@@ -514,6 +517,34 @@ const ThemeToggle = styled.button<{ darkMode: boolean }>`
   }
 `;
 
+const UserBadge = styled.div<{ darkMode: boolean }>`
+  display: flex;
+  align-items: center;
+  gap: 8px;
+  font-size: 0.8rem;
+  font-weight: 500;
+  padding: 6px 12px;
+  border-radius: 20px;
+  background-color: ${props => props.darkMode ? 'rgba(255, 255, 255, 0.05)' : 'rgba(0, 0, 0, 0.05)'};
+  color: ${props => props.darkMode ? 'rgba(255, 255, 255, 0.8)' : 'rgba(0, 0, 0, 0.8)'};
+
+  button {
+    background: none;
+    border: none;
+    padding: 0;
+    cursor: pointer;
+    font-size: 0.8rem;
+    color: ${props => props.darkMode ? '#4ade80' : '#16a34a'};
+  }
+`;
+
+// Role names shown in the header
+const roleLabels: Record<string, string> = {
+  'admin': 'מנהל',
+  'staff': 'עובד',
+  'read-only': 'צפייה בלבד',
+};
+
 const SidebarFooter = styled.div<{ darkMode: boolean, collapsed: boolean }>`
   padding: ${props => props.collapsed ? '16px 8px' : '16px 24px'};
   border-top: 1px solid ${props => props.darkMode ? 'rgba(255, 255, 255, 0.05)' : 'rgba(0, 0, 0, 0.03)'};
@@ -605,7 +636,9 @@ const SidebarLayout: React.FC<SidebarLayoutProps> = ({
   toggleDarkMode,
   dbStatus,
   activePage,
-  setActivePage
+  setActivePage,
+  currentUser,
+  onLogout
 }) => {
   const [collapsed, setCollapsed] = useState(false);
   
@@ -674,6 +707,15 @@ const SidebarLayout: React.FC<SidebarLayoutProps> = ({
               <span className="label">הזמנות</span>
               <span className="icon"><OrdersIcon /></span>
             </NavItem>
+            <NavItem 
+              active={activePage === "quotes"}
+              darkMode={darkMode} 
+              collapsed={collapsed}
+              onClick={() => setActivePage("quotes")}
+            >
+              <span className="label">הצעות מחיר</span>
+              <span className="icon">{renderIcon(FaFileInvoice)}</span>
+            </NavItem>
             <NavItem 
               active={activePage === "stock"}
               darkMode={darkMode} 
@@ -683,6 +725,37 @@ const SidebarLayout: React.FC<SidebarLayoutProps> = ({
               <span className="label">מלאי</span>
               <span className="icon">{renderIcon(FaBoxes)}</span>
             </NavItem>
+            <NavItem 
+              active={activePage === "stock-counts"}
+              darkMode={darkMode} 
+              collapsed={collapsed}
+              onClick={() => setActivePage("stock-counts")}
+            >
+              <span className="label">ספירת מלאי</span>
+              <span className="icon">{renderIcon(FaClipboardCheck)}</span>
+            </NavItem>
+            {currentUser.role === "admin" && (
+              <NavItem 
+                active={activePage === "users"}
+                darkMode={darkMode} 
+                collapsed={collapsed}
+                onClick={() => setActivePage("users")}
+              >
+                <span className="label">משתמשים</span>
+                <span className="icon">{renderIcon(FaUsers)}</span>
+              </NavItem>
+            )}
+            {currentUser.role === "admin" && (
+              <NavItem 
+                active={activePage === "diagnostics"}
+                darkMode={darkMode} 
+                collapsed={collapsed}
+                onClick={() => setActivePage("diagnostics")}
+              >
+                <span className="label">אבחון</span>
+                <span className="icon">{renderIcon(FaStethoscope)}</span>
+              </NavItem>
+            )}
           </NavMenu>
         </NavMenuContainer>
         
@@ -698,11 +771,20 @@ const SidebarLayout: React.FC<SidebarLayoutProps> = ({
              activePage === "products" ? "מוצרים" :
              activePage === "create-order" ? "צור הזמנה" :
              activePage === "orders" ? "הזמנות" :
+             activePage === "quotes" ? "הצעות מחיר" :
              activePage === "stock" ? "מלאי" :
+             activePage === "stock-counts" ? "ספירת מלאי" :
+             activePage === "users" ? "משתמשים" :
+             activePage === "diagnostics" ? "אבחון ותמיכה" :
              activePage.charAt(0).toUpperCase() + activePage.slice(1).replace('-', ' ')}
           </PageTitle>
           
           <HeaderRight>
+            <UserBadge darkMode={darkMode}>
+              <span>{currentUser.username} ({roleLabels[currentUser.role] || currentUser.role})</span>
+              <button onClick={onLogout}>התנתק</button>
+            </UserBadge>
+            
             <StatusIndicator isError={dbStatus.includes('error')} darkMode={darkMode}>
               <div className="dot"></div>
               <span>{dbStatus.includes('error') ? 'שגיאת מסד נתונים' : 'מחובר'}</span>
@@ -1,12 +1,42 @@
 import React, { useState, useEffect } from 'react';
 import styled from 'styled-components';
-import { CreateOrder as CreateOrderAPI } from '../../wailsjs/go/main/App';
+import {
+  CreateOrder as CreateOrderAPI,
+  LookupByBarcode,
+  GetOrderTemplates,
+  SaveOrderTemplate,
+  DeleteOrderTemplate,
+  CreateOrderFromTemplate,
+  PreviewScheduledOrders,
+  CreateQuote
+} from '../../wailsjs/go/main/App';
 import { main } from '../../wailsjs/go/models';
-import { formatPrice } from '../utils/formatters';
+import { formatPrice, priceOverrideLabels } from '../utils/formatters';
 
 // Backend types - only import what we use
 type Product = main.Product;
 type OrderItem = main.OrderItem;
+type ProductVariant = main.ProductVariant;
+type OrderTemplate = main.OrderTemplate;
+type ScheduledOrder = main.ScheduledOrder;
+
+// How far ahead the preview of scheduled orders looks
+const previewDays = 30;
+
+const frequencyLabels: Record<string, string> = {
+  '': 'ללא תזמון',
+  weekly: 'שבועי',
+  monthly: 'חודשי'
+};
+
+// Schedule of the template being edited
+interface TemplateSchedule {
+  frequency: string;
+  startDate: string;
+  paused: boolean;
+}
+
+const emptySchedule: TemplateSchedule = { frequency: '', startDate: '', paused: false };
 
 // Define order details interface for frontend use
 interface OrderDetails {
@@ -18,7 +48,6 @@ interface OrderDetails {
 interface CreateOrderProps {
   products: Product[];
   darkMode: boolean;
-  onOrderCreated: () => void;
   showNotification: (options: { message: string; type: 'success' | 'error' | 'info' | 'warning' }) => void;
 }
 
@@ -532,6 +561,27 @@ const ItemSubtotal = styled.div<{ darkMode: boolean }>`
   color: ${props => props.darkMode ? 'rgba(56, 189, 248, 0.9)' : 'rgba(2, 132, 199, 0.9)'};
 `;
 
+const PriceOverride = styled.div`
+  display: flex;
+  align-items: center;
+  gap: 8px;
+`;
+
+const OverrideSelect = styled.select<{ darkMode: boolean }>`
+  padding: 6px 8px;
+  border-radius: 6px;
+  border: 1px solid ${props => props.darkMode ? 'rgba(255, 255, 255, 0.1)' : 'rgba(0, 0, 0, 0.1)'};
+  background: ${props => props.darkMode ? 'rgba(15, 23, 42, 0.3)' : 'white'};
+  color: ${props => props.darkMode ? 'white' : 'black'};
+  font-size: 0.85rem;
+`;
+
+const ListPrice = styled.span`
+  text-decoration: line-through;
+  opacity: 0.6;
+  font-size: 0.85rem;
+`;
+
 const RemoveButton = styled.button<{ darkMode: boolean }>`
   width: 30px;
   height: 30px;
@@ -622,10 +672,64 @@ const TextArea = styled.textarea<{ darkMode: boolean }>`
   }
 `;
 
+const Select = styled.select<{ darkMode: boolean }>`
+  width: 100%;
+  padding: 12px 16px;
+  border-radius: 10px;
+  border: 1px solid ${props => props.darkMode 
+    ? 'rgba(255, 255, 255, 0.1)' 
+    : 'rgba(0, 0, 0, 0.05)'};
+  background: ${props => props.darkMode 
+    ? 'rgba(15, 23, 42, 0.5)' 
+    : 'rgba(255, 255, 255, 0.95)'};
+  color: ${props => props.darkMode 
+    ? 'rgba(255, 255, 255, 0.9)' 
+    : 'rgba(0, 0, 0, 0.8)'};
+  font-size: 0.95rem;
+`;
+
+const TemplatesPanel = styled(OrderPanel)`
+  margin-top: 24px;
+`;
+
+const TemplateList = styled.div`
+  display: flex;
+  flex-direction: column;
+  gap: 8px;
+  margin-bottom: 16px;
+`;
+
+const TemplateRow = styled.div<{ darkMode: boolean }>`
+  display: flex;
+  justify-content: space-between;
+  align-items: center;
+  gap: 12px;
+  padding: 12px 16px;
+  border-radius: 10px;
+  background: ${props => props.darkMode
+    ? 'rgba(15, 23, 42, 0.5)'
+    : 'rgba(248, 250, 252, 0.8)'};
+  color: ${props => props.darkMode ? 'rgba(255, 255, 255, 0.9)' : 'rgba(0, 0, 0, 0.8)'};
+
+  small {
+    display: block;
+    opacity: 0.7;
+  }
+`;
+
+const ScheduleFields = styled.div`
+  display: flex;
+  gap: 12px;
+  align-items: flex-end;
+
+  ${FormField} {
+    flex: 1;
+  }
+`;
+
 const CreateOrder: React.FC<CreateOrderProps> = ({
   products,
   darkMode,
-  onOrderCreated,
   showNotification
 }) => {
   // Use localStorage to persist order details
@@ -639,7 +743,25 @@ const CreateOrder: React.FC<CreateOrderProps> = ({
   });
   
   const [searchTerm, setSearchTerm] = useState('');
+  const [scanCode, setScanCode] = useState('');
   const [expandedDescriptions, setExpandedDescriptions] = useState<Set<string>>(new Set());
+  const [templates, setTemplates] = useState<OrderTemplate[]>([]);
+  const [upcoming, setUpcoming] = useState<ScheduledOrder[]>([]);
+  const [templateId, setTemplateId] = useState('');
+  const [schedule, setSchedule] = useState<TemplateSchedule>(emptySchedule);
+  
+  const loadTemplates = async () => {
+    try {
+      setTemplates(await GetOrderTemplates() || []);
+      setUpcoming(await PreviewScheduledOrders(previewDays) || []);
+    } catch (error) {
+      console.error('Error loading order templates:', error);
+    }
+  };
+  
+  useEffect(() => {
+    loadTemplates();
+  }, []);
   
   // Save order details to localStorage whenever they change
   useEffect(() => {
@@ -672,15 +794,16 @@ const CreateOrder: React.FC<CreateOrderProps> = ({
     }));
   };
   
-  const handleAddItem = (product: Product) => {
-    const existingItem = orderDetails.items.find(item => item.productId === product.id);
+  const handleAddItem = (product: Product, variant?: ProductVariant) => {
+    const variantId = variant ? variant.id : '';
+    const existingItem = orderDetails.items.find(item => item.productId === product.id && (item.variantId || '') === variantId);
     
     if (existingItem) {
       // Increment quantity if item already exists
       setOrderDetails(prev => ({
         ...prev,
         items: prev.items.map(item => 
-          item.productId === product.id 
+          item.productId === product.id && (item.variantId || '') === variantId
             ? { ...item, quantity: item.quantity + 1 } 
             : item
         )
@@ -689,16 +812,39 @@ const CreateOrder: React.FC<CreateOrderProps> = ({
       // Add new item
       setOrderDetails(prev => ({
         ...prev,
-        items: [...prev.items, {
+        items: [...prev.items, main.OrderItem.createFrom({
           productId: product.id,
-          productName: product.name,
-          price: product.price,
+          variantId,
+          productName: variant ? `${product.name} (${[variant.size, variant.color].filter(Boolean).join(', ') || variant.sku})` : product.name,
+          price: variant ? variant.price : product.price,
+          listPrice: variant ? variant.price : product.price,
           quantity: 1
-        }]
+        })]
       }));
     }
   };
   
+  // Keyboard-wedge scanners type the code and press Enter
+  const handleScanKeyDown = async (e: React.KeyboardEvent<HTMLInputElement>) => {
+    if (e.key !== 'Enter') {
+      return;
+    }
+    e.preventDefault();
+    
+    const code = scanCode.trim();
+    setScanCode('');
+    if (!code) {
+      return;
+    }
+    
+    const result = await LookupByBarcode(code);
+    if (result.found && result.product) {
+      handleAddItem(result.product, result.variant || undefined);
+    } else {
+      showNotification({ message: `לא נמצא מוצר עבור הברקוד ${code}`, type: 'warning' });
+    }
+  };
+  
   const handleRemoveItem = (index: number) => {
     setOrderDetails(prev => ({
       ...prev,
@@ -720,6 +866,29 @@ const CreateOrder: React.FC<CreateOrderProps> = ({
     }));
   };
   
+  // A line keeps its list price unless a reason is chosen; the server prices the rest
+  const handleOverrideReasonChange = (index: number, overrideReason: string) => {
+    setOrderDetails(prev => ({
+      ...prev,
+      items: prev.items.map((item, i) =>
+        i === index
+          ? main.OrderItem.createFrom({ ...item, overrideReason, price: overrideReason ? item.price : item.listPrice })
+          : item
+      )
+    }));
+  };
+  
+  const handlePriceChange = (index: number, newPrice: number) => {
+    const price = isNaN(newPrice) ? 0 : newPrice;
+    
+    setOrderDetails(prev => ({
+      ...prev,
+      items: prev.items.map((item, i) =>
+        i === index ? main.OrderItem.createFrom({ ...item, price }) : item
+      )
+    }));
+  };
+  
   const calculateTotal = () => {
     return orderDetails.items.reduce((total, item) => total + (item.price * item.quantity), 0);
   };
@@ -732,6 +901,8 @@ const CreateOrder: React.FC<CreateOrderProps> = ({
           description: '',
           items: []
         });
+        setTemplateId('');
+        setSchedule(emptySchedule);
         // Clear from localStorage
         localStorage.removeItem('savedOrderDetails');
         
@@ -779,8 +950,8 @@ const CreateOrder: React.FC<CreateOrderProps> = ({
         description: '',
         items: []
       });
-      
-      onOrderCreated();
+      setTemplateId('');
+      setSchedule(emptySchedule);
       
       // Navigate back to orders page
       window.location.href = '/#/orders';
@@ -793,6 +964,135 @@ const CreateOrder: React.FC<CreateOrderProps> = ({
     }
   };
   
+  const handleSaveTemplate = async () => {
+    if (orderDetails.items.length === 0 || !orderDetails.name.trim()) {
+      showNotification({
+        message: 'נדרשים שם ופריטים כדי לשמור תבנית',
+        type: 'error'
+      });
+      return;
+    }
+    if (schedule.frequency && !schedule.startDate) {
+      showNotification({
+        message: 'נדרש תאריך התחלה להזמנה קבועה',
+        type: 'error'
+      });
+      return;
+    }
+    
+    try {
+      const id = await SaveOrderTemplate(main.OrderTemplate.createFrom({
+        id: templateId,
+        name: orderDetails.name,
+        description: orderDetails.description,
+        items: orderDetails.items,
+        frequency: schedule.frequency,
+        startDate: schedule.frequency ? schedule.startDate : '',
+        paused: schedule.paused
+      }));
+      if (!id) {
+        showNotification({ message: 'שגיאה בשמירת התבנית', type: 'error' });
+        return;
+      }
+      
+      setTemplateId(id);
+      showNotification({ message: 'התבנית נשמרה בהצלחה', type: 'success' });
+      loadTemplates();
+    } catch (error) {
+      console.error('Error saving order template:', error);
+      showNotification({ message: 'שגיאה בשמירת התבנית', type: 'error' });
+    }
+  };
+  
+  // Save the order as a quote for the customer to accept later
+  const handleSaveQuote = async () => {
+    if (orderDetails.items.length === 0 || !orderDetails.name.trim()) {
+      showNotification({
+        message: 'נדרשים שם ופריטים כדי לשמור הצעת מחיר',
+        type: 'error'
+      });
+      return;
+    }
+    
+    try {
+      const id = await CreateQuote(main.NewQuote.createFrom({
+        name: orderDetails.name,
+        description: orderDetails.description,
+        items: orderDetails.items,
+        expiresOn: ''
+      }));
+      if (!id) {
+        showNotification({ message: 'שגיאה בשמירת הצעת המחיר', type: 'error' });
+        return;
+      }
+      showNotification({ message: `הצעת מחיר #${id} נשמרה`, type: 'success' });
+    } catch (error) {
+      console.error('Error creating quote:', error);
+      showNotification({ message: 'שגיאה בשמירת הצעת המחיר', type: 'error' });
+    }
+  };
+  
+  // Fill the order with a template's items so it can be ordered or the template edited
+  const handleLoadTemplate = (template: OrderTemplate) => {
+    setOrderDetails({
+      name: template.name,
+      description: template.description,
+      items: template.items.map(item => ({ ...item }))
+    });
+    setTemplateId(template.id);
+    setSchedule({
+      frequency: template.frequency,
+      startDate: template.startDate,
+      paused: template.paused
+    });
+  };
+  
+  const handleOrderFromTemplate = async (template: OrderTemplate) => {
+    try {
+      const id = await CreateOrderFromTemplate(template.id);
+      if (!id) {
+        showNotification({ message: 'שגיאה ביצירת ההזמנה מהתבנית', type: 'error' });
+        return;
+      }
+      showNotification({ message: `הזמנה #${id} נוצרה מהתבנית`, type: 'success' });
+    } catch (error) {
+      console.error('Error creating order from template:', error);
+      showNotification({ message: 'שגיאה ביצירת ההזמנה מהתבנית', type: 'error' });
+    }
+  };
+  
+  const handleDeleteTemplate = async (template: OrderTemplate) => {
+    if (!window.confirm(`האם למחוק את התבנית "${template.name}"? הזמנות שכבר נוצרו ממנה יישמרו.`)) {
+      return;
+    }
+    
+    try {
+      if (!await DeleteOrderTemplate(template.id)) {
+        showNotification({ message: 'שגיאה במחיקת התבנית', type: 'error' });
+        return;
+      }
+      if (template.id === templateId) {
+        setTemplateId('');
+        setSchedule(emptySchedule);
+      }
+      showNotification({ message: 'התבנית נמחקה', type: 'success' });
+      loadTemplates();
+    } catch (error) {
+      console.error('Error deleting order template:', error);
+      showNotification({ message: 'שגיאה במחיקת התבנית', type: 'error' });
+    }
+  };
+  
+  const describeSchedule = (template: OrderTemplate) => {
+    if (!template.frequency) {
+      return frequencyLabels[''];
+    }
+    if (template.paused) {
+      return `${frequencyLabels[template.frequency]} · מושהה`;
+    }
+    return `${frequencyLabels[template.frequency]} · הבא ב-${template.nextRun}`;
+  };
+  
   const toggleDescriptionExpansion = (productId: string, e: React.MouseEvent) => {
     e.stopPropagation(); // Prevent triggering the product item click
     setExpandedDescriptions(prev => {
@@ -818,6 +1118,30 @@ const CreateOrder: React.FC<CreateOrderProps> = ({
             onChange={handleSearch}
             darkMode={darkMode}
           />
+          <SearchBox 
+            type="text" 
+            placeholder="סריקת ברקוד / מק״ט"
+            value={scanCode}
+            onChange={(e) => setScanCode(e.target.value)}
+            onKeyDown={handleScanKeyDown}
+            darkMode={darkMode}
+          />
+          <Button 
+            onClick={handleSaveQuote}
+            disabled={orderDetails.items.length === 0 || !orderDetails.name.trim()}
+            darkMode={darkMode}
+            variant="secondary"
+          >
+            שמור כהצעת מחיר
+          </Button>
+          <Button 
+            onClick={handleSaveTemplate}
+            disabled={orderDetails.items.length === 0 || !orderDetails.name.trim()}
+            darkMode={darkMode}
+            variant="secondary"
+          >
+            {templateId ? 'עדכן תבנית' : 'שמור כתבנית'}
+          </Button>
           <Button 
             onClick={handleCreateOrder}
             disabled={orderDetails.items.length === 0 || !orderDetails.name.trim()}
@@ -855,6 +1179,45 @@ const CreateOrder: React.FC<CreateOrderProps> = ({
             />
           </FormField>
           
+          <ScheduleFields>
+            <FormField>
+              <Label darkMode={darkMode}>הזמנה קבועה</Label>
+              <Select
+                value={schedule.frequency}
+                onChange={(e) => setSchedule({ ...schedule, frequency: e.target.value })}
+                darkMode={darkMode}
+              >
+                {Object.entries(frequencyLabels).map(([value, label]) => (
+                  <option key={value} value={value}>{label}</option>
+                ))}
+              </Select>
+            </FormField>
+            
+            {schedule.frequency && (
+              <FormField>
+                <Label darkMode={darkMode}>החל מתאריך</Label>
+                <Input
+                  type="date"
+                  value={schedule.startDate}
+                  onChange={(e) => setSchedule({ ...schedule, startDate: e.target.value })}
+                  darkMode={darkMode}
+                />
+              </FormField>
+            )}
+            
+            {schedule.frequency && templateId && (
+              <FormField>
+                <Label darkMode={darkMode}>
+                  <input
+                    type="checkbox"
+                    checked={schedule.paused}
+                    onChange={(e) => setSchedule({ ...schedule, paused: e.target.checked })}
+                  /> מושהה
+                </Label>
+              </FormField>
+            )}
+          </ScheduleFields>
+          
           <SectionTitle darkMode={darkMode}>פריטי הזמנה</SectionTitle>
           
           {orderDetails.items.length > 0 ? (
@@ -874,8 +1237,37 @@ const CreateOrder: React.FC<CreateOrderProps> = ({
                           />
                           <ItemName darkMode={darkMode}>{item.productName}</ItemName>
                         </div>
-                        <ItemPrice darkMode={darkMode}>{formatPrice(item.price)}</ItemPrice>
+                        <ItemPrice darkMode={darkMode}>
+                          {item.overrideReason && item.price !== item.listPrice && (
+                            <ListPrice>{formatPrice(item.listPrice)}</ListPrice>
+                          )}{' '}
+                          {formatPrice(item.price)}
+                        </ItemPrice>
                       </ItemRow>
+                      <PriceOverride>
+                        <OverrideSelect
+                          darkMode={darkMode}
+                          value={item.overrideReason || ''}
+                          onChange={(e) => handleOverrideReasonChange(index, e.target.value)}
+                          aria-label="סיבת שינוי מחיר"
+                        >
+                          <option value="">מחיר מחירון</option>
+                          {Object.entries(priceOverrideLabels).map(([reason, label]) => (
+                            <option key={reason} value={reason}>{label}</option>
+                          ))}
+                        </OverrideSelect>
+                        {item.overrideReason && (
+                          <QuantityInput
+                            type="number"
+                            value={item.price}
+                            onChange={(e) => handlePriceChange(index, parseFloat(e.target.value))}
+                            darkMode={darkMode}
+                            min="0"
+                            step="0.01"
+                            aria-label="מחיר"
+                          />
+                        )}
+                      </PriceOverride>
                     </ItemDetails>
                     
                     <ItemActions>
@@ -970,6 +1362,65 @@ const CreateOrder: React.FC<CreateOrderProps> = ({
           )}
         </ProductsPanel>
       </OrderContent>
+      
+      <TemplatesPanel darkMode={darkMode}>
+        <SectionTitle darkMode={darkMode}>תבניות והזמנות קבועות</SectionTitle>
+        
+        {templates.length > 0 ? (
+          <TemplateList>
+            {templates.map(template => (
+              <TemplateRow key={template.id} darkMode={darkMode}>
+                <div>
+                  <strong>{template.name}</strong> · {formatPrice(template.total)}
+                  <small>{describeSchedule(template)}</small>
+                </div>
+                <ButtonsContainer>
+                  <Button darkMode={darkMode} variant="secondary" onClick={() => handleLoadTemplate(template)}>
+                    טען
+                  </Button>
+                  <Button darkMode={darkMode} variant="primary" onClick={() => handleOrderFromTemplate(template)}>
+                    הזמן עכשיו
+                  </Button>
+                  <RemoveButton
+                    darkMode={darkMode}
+                    onClick={() => handleDeleteTemplate(template)}
+                    aria-label="מחק תבנית"
+                  >
+                    <TrashIcon />
+                  </RemoveButton>
+                </ButtonsContainer>
+              </TemplateRow>
+            ))}
+          </TemplateList>
+        ) : (
+          <EmptyMessage darkMode={darkMode}>
+            אין תבניות שמורות עדיין.<br/>
+            בנה הזמנה ולחץ על "שמור כתבנית".
+          </EmptyMessage>
+        )}
+        
+        <SectionTitle darkMode={darkMode}>הזמנות צפויות ב-{previewDays} הימים הקרובים</SectionTitle>
+        
+        {upcoming.length > 0 ? (
+          <TemplateList>
+            {upcoming.map((order, index) => (
+              <TemplateRow key={`${order.templateId}-${order.date}-${index}`} darkMode={darkMode}>
+                <div>
+                  <strong>{order.templateName}</strong>
+                  <small>{order.items.length} פריטים</small>
+                </div>
+                <div>
+                  {order.date} · {formatPrice(order.total)}
+                </div>
+              </TemplateRow>
+            ))}
+          </TemplateList>
+        ) : (
+          <EmptyMessage darkMode={darkMode}>
+            אין הזמנות קבועות מתוזמנות.
+          </EmptyMessage>
+        )}
+      </TemplatesPanel>
     </PageContainer>
   );
 };
@@ -6,6 +6,9 @@ interface DashboardProps {
   orderCount: number;
   pendingOrderCount: number;
   totalSales: number;
+  refunds: number;
+  outstandingOrderCount: number;
+  outstandingBalance: number;
   stockItemCount: number;
   darkMode: boolean;
 }
@@ -111,6 +114,9 @@ const Dashboard: React.FC<DashboardProps> = ({
   orderCount,
   pendingOrderCount,
   totalSales,
+  refunds,
+  outstandingOrderCount,
+  outstandingBalance,
   stockItemCount,
   darkMode
 }) => {
@@ -155,6 +161,20 @@ const Dashboard: React.FC<DashboardProps> = ({
           <StatFooter darkMode={darkMode}>Revenue from all orders</StatFooter>
         </StatCard>
         
+        <StatCard darkMode={darkMode} color="var(--color-success)">
+          <StatIcon color="var(--color-success)">↩️</StatIcon>
+          <StatTitle darkMode={darkMode}>Net Sales</StatTitle>
+          <StatValue darkMode={darkMode}>₪{(totalSales - refunds).toFixed(2)}</StatValue>
+          <StatFooter darkMode={darkMode}>After ₪{refunds.toFixed(2)} in refunds</StatFooter>
+        </StatCard>
+        
+        <StatCard darkMode={darkMode} color="var(--color-warning)">
+          <StatIcon color="var(--color-warning)">🧾</StatIcon>
+          <StatTitle darkMode={darkMode}>Balance Due</StatTitle>
+          <StatValue darkMode={darkMode}>₪{outstandingBalance.toFixed(2)}</StatValue>
+          <StatFooter darkMode={darkMode}>{outstandingOrderCount} orders awaiting payment</StatFooter>
+        </StatCard>
+        
         <StatCard darkMode={darkMode} color="var(--color-secondary)">
           <StatIcon color="var(--color-secondary)">🏬</StatIcon>
           <StatTitle darkMode={darkMode}>Stock Items</StatTitle>
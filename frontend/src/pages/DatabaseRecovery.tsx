@@ -0,0 +1,143 @@
+import React, { useState } from 'react';
+import styled from 'styled-components';
+import { RetryOpenDatabase, OpenDatabaseFile, RestoreLatestBackup, RecoverDatabase } from '../../wailsjs/go/main/App';
+import { main } from '../../wailsjs/go/models';
+import { GardenLogo } from '../components/GardenLogo';
+
+interface DatabaseRecoveryProps {
+  darkMode: boolean;
+  state: main.DatabaseState;
+  onStateChanged: (state: main.DatabaseState) => void;
+}
+
+// What to tell the user for each kind of problem
+const problemDescriptions: Record<string, string> = {
+  'locked': 'מסד הנתונים נעול על ידי תוכנה אחרת. סגור אותה ונסה שוב.',
+  'damaged': 'קובץ מסד הנתונים פגום. ניתן לשחזר מהגיבוי האחרון או לנסות להציל את הנתונים הקריאים.',
+  'read-only': 'אין הרשאת כתיבה לקובץ מסד הנתונים או לתיקייה שלו. ניתן לפתוח קובץ אחר.',
+  'other': 'לא ניתן לפתוח את מסד הנתונים.',
+};
+
+// Styled components
+const RecoveryContainer = styled.div<{ darkMode: boolean }>`
+  display: flex;
+  align-items: center;
+  justify-content: center;
+  height: 100vh;
+  direction: rtl;
+  background-color: ${props => props.darkMode ? '#0f172a' : '#f8fafc'};
+  color: ${props => props.darkMode ? '#e2e8f0' : '#1e293b'};
+  font-family: 'Inter', -apple-system, BlinkMacSystemFont, sans-serif;
+`;
+
+const RecoveryPanel = styled.div<{ darkMode: boolean }>`
+  width: 480px;
+  padding: 32px;
+  border-radius: 12px;
+  background: ${props => props.darkMode ? 'rgba(15, 23, 42, 0.6)' : 'rgba(255, 255, 255, 0.9)'};
+  border: 1px solid ${props => props.darkMode ? 'rgba(255, 255, 255, 0.1)' : 'rgba(0, 0, 0, 0.05)'};
+  box-shadow: 0 10px 30px ${props => props.darkMode ? 'rgba(0, 0, 0, 0.3)' : 'rgba(0, 0, 0, 0.1)'};
+  display: flex;
+  flex-direction: column;
+  gap: 16px;
+
+  .logo {
+    width: 48px;
+    height: 48px;
+    align-self: center;
+  }
+
+  h1 {
+    font-size: 22px;
+    margin: 0;
+    text-align: center;
+    color: #ef4444;
+  }
+
+  p {
+    margin: 0;
+    font-size: 14px;
+    color: ${props => props.darkMode ? 'rgba(255, 255, 255, 0.7)' : 'rgba(0, 0, 0, 0.7)'};
+  }
+`;
+
+const Details = styled.div<{ darkMode: boolean }>`
+  direction: ltr;
+  text-align: left;
+  font-family: 'SFMono-Regular', Consolas, monospace;
+  font-size: 12px;
+  padding: 8px;
+  border-radius: 4px;
+  word-break: break-all;
+  background: ${props => props.darkMode ? 'rgba(0, 0, 0, 0.3)' : 'rgba(0, 0, 0, 0.05)'};
+`;
+
+const ActionButton = styled.button`
+  background: linear-gradient(135deg, #4ade80, #22c55e);
+  border: none;
+  border-radius: 6px;
+  padding: 10px 16px;
+  color: white;
+  font-weight: 600;
+  cursor: pointer;
+
+  &:disabled {
+    opacity: 0.6;
+    cursor: default;
+  }
+`;
+
+const DatabaseRecovery: React.FC<DatabaseRecoveryProps> = ({ darkMode, state, onStateChanged }) => {
+  const [isWorking, setIsWorking] = useState(false);
+
+  const run = async (action: () => Promise<main.DatabaseState>) => {
+    setIsWorking(true);
+    try {
+      onStateChanged(await action());
+    } catch (error) {
+      console.error('Database recovery failed:', error);
+    } finally {
+      setIsWorking(false);
+    }
+  };
+
+  return (
+    <RecoveryContainer darkMode={darkMode}>
+      <RecoveryPanel darkMode={darkMode}>
+        <GardenLogo className="logo" />
+        <h1>לא ניתן לפתוח את מסד הנתונים</h1>
+        <p>{problemDescriptions[state.problem] || problemDescriptions['other']}</p>
+
+        <Details darkMode={darkMode}>
+          {state.path}
+          <br />
+          {state.error}
+          {state.message && state.message !== state.error && (
+            <>
+              <br />
+              {state.message}
+            </>
+          )}
+        </Details>
+
+        <ActionButton type="button" onClick={() => run(RetryOpenDatabase)} disabled={isWorking}>
+          נסה שוב
+        </ActionButton>
+        <ActionButton type="button" onClick={() => run(OpenDatabaseFile)} disabled={isWorking}>
+          פתח קובץ מסד נתונים אחר
+        </ActionButton>
+        <ActionButton type="button" onClick={() => run(RestoreLatestBackup)} disabled={isWorking || !state.latestBackup}>
+          {state.latestBackup
+            ? `שחזר מהגיבוי האחרון (${new Date(state.latestBackupTime).toLocaleString('he-IL')})`
+            : 'אין גיבוי זמין'}
+        </ActionButton>
+        <ActionButton type="button" onClick={() => run(RecoverDatabase)} disabled={isWorking}>
+          {isWorking ? 'עובד...' : 'הצל את הנתונים הקריאים'}
+        </ActionButton>
+        <p>הקובץ הפגום נשמר לצד מסד הנתונים לפני שחזור או הצלה.</p>
+      </RecoveryPanel>
+    </RecoveryContainer>
+  );
+};
+
+export default DatabaseRecovery;
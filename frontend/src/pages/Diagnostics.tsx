@@ -0,0 +1,215 @@
+import React, { useState, useEffect } from 'react';
+import styled from 'styled-components';
+import { GetDiagnostics, GetRecentLogs, ExportSupportBundle } from '../../wailsjs/go/main/App';
+import { main } from '../../wailsjs/go/models';
+
+interface DiagnosticsProps {
+  darkMode: boolean;
+  showNotification: (options: { message: string; type: 'success' | 'error' | 'info' | 'warning' }) => void;
+}
+
+// Log levels offered in the filter, lowest first
+const levelLabels: Record<string, string> = {
+  'debug': 'הכל',
+  'info': 'מידע ומעלה',
+  'warn': 'אזהרות ושגיאות',
+  'error': 'שגיאות בלבד',
+};
+
+// Styled components
+const PageContainer = styled.div`
+  padding: 24px;
+  height: 100%;
+  overflow-y: auto;
+`;
+
+const Panel = styled.div<{ darkMode: boolean }>`
+  background: ${props => props.darkMode ? 'rgba(15, 23, 42, 0.3)' : 'rgba(248, 250, 252, 0.8)'};
+  border-radius: 8px;
+  padding: 24px;
+  margin-bottom: 24px;
+  box-shadow: 0 4px 6px ${props => props.darkMode ? 'rgba(0, 0, 0, 0.2)' : 'rgba(0, 0, 0, 0.1)'};
+  border: 1px solid ${props => props.darkMode ? 'rgba(255, 255, 255, 0.1)' : 'rgba(0, 0, 0, 0.05)'};
+`;
+
+const PanelHeader = styled.div`
+  display: flex;
+  justify-content: space-between;
+  align-items: center;
+  gap: 12px;
+  margin-bottom: 16px;
+`;
+
+const SectionTitle = styled.h2<{ darkMode: boolean }>`
+  font-size: 18px;
+  margin: 0;
+  color: ${props => props.darkMode ? '#fff' : '#1e293b'};
+`;
+
+const Select = styled.select<{ darkMode: boolean }>`
+  background: ${props => props.darkMode ? 'rgba(15, 23, 42, 0.5)' : 'rgba(248, 250, 252, 0.8)'};
+  border: 1px solid ${props => props.darkMode ? 'rgba(255, 255, 255, 0.1)' : 'rgba(0, 0, 0, 0.1)'};
+  border-radius: 4px;
+  padding: 8px 12px;
+  color: ${props => props.darkMode ? '#fff' : '#1e293b'};
+`;
+
+const Button = styled.button`
+  background: linear-gradient(135deg, #4ade80, #22c55e);
+  border: none;
+  border-radius: 4px;
+  padding: 8px 16px;
+  color: white;
+  cursor: pointer;
+
+  &:disabled {
+    opacity: 0.5;
+    cursor: default;
+  }
+`;
+
+const Table = styled.table<{ darkMode: boolean }>`
+  width: 100%;
+  border-collapse: collapse;
+  text-align: right;
+
+  th, td {
+    padding: 8px 12px;
+    border-bottom: 1px solid ${props => props.darkMode ? 'rgba(255, 255, 255, 0.08)' : 'rgba(0, 0, 0, 0.06)'};
+  }
+
+  th {
+    font-weight: 600;
+    width: 200px;
+    color: ${props => props.darkMode ? 'rgba(255, 255, 255, 0.7)' : 'rgba(0, 0, 0, 0.6)'};
+  }
+
+  td {
+    direction: ltr;
+    text-align: left;
+  }
+`;
+
+const LogList = styled.div<{ darkMode: boolean }>`
+  direction: ltr;
+  font-family: 'SFMono-Regular', Consolas, monospace;
+  font-size: 12px;
+  max-height: 480px;
+  overflow-y: auto;
+  background: ${props => props.darkMode ? 'rgba(0, 0, 0, 0.3)' : 'rgba(255, 255, 255, 0.9)'};
+  border-radius: 4px;
+  padding: 8px;
+`;
+
+const LogLine = styled.div<{ level: string }>`
+  padding: 2px 0;
+  white-space: pre-wrap;
+  word-break: break-all;
+  color: ${props => props.level === 'ERROR' ? '#ef4444' : props.level === 'WARN' ? '#f59e0b' : 'inherit'};
+`;
+
+// formatBytes shows a file size in KB or MB
+const formatBytes = (bytes: number) =>
+  bytes >= 1024 * 1024 ? `${(bytes / 1024 / 1024).toFixed(1)} MB` : `${(bytes / 1024).toFixed(0)} KB`;
+
+// formatAttrs shows the extra fields of a log entry as key=value pairs
+const formatAttrs = (attrs: Record<string, string>) =>
+  Object.entries(attrs || {}).map(([key, value]) => `${key}=${value}`).join(' ');
+
+const Diagnostics: React.FC<DiagnosticsProps> = ({ darkMode, showNotification }) => {
+  const [diagnostics, setDiagnostics] = useState<main.Diagnostics | null>(null);
+  const [logs, setLogs] = useState<main.LogEntry[]>([]);
+  const [level, setLevel] = useState('info');
+  const [isExporting, setIsExporting] = useState(false);
+
+  useEffect(() => {
+    loadDiagnostics();
+  }, []);
+
+  useEffect(() => {
+    loadLogs();
+  }, [level]);
+
+  const loadDiagnostics = async () => {
+    try {
+      setDiagnostics(await GetDiagnostics());
+    } catch (error) {
+      console.error('Failed to load diagnostics:', error);
+      showNotification({ message: 'טעינת נתוני האבחון נכשלה', type: 'error' });
+    }
+  };
+
+  const loadLogs = async () => {
+    try {
+      const entries = await GetRecentLogs(level, 500);
+      setLogs(entries || []);
+    } catch (error) {
+      console.error('Failed to load logs:', error);
+      showNotification({ message: 'טעינת היומן נכשלה', type: 'error' });
+    }
+  };
+
+  const handleExport = async () => {
+    setIsExporting(true);
+    try {
+      if (await ExportSupportBundle()) {
+        showNotification({ message: 'חבילת התמיכה נשמרה', type: 'success' });
+      }
+    } finally {
+      setIsExporting(false);
+    }
+  };
+
+  return (
+    <PageContainer>
+      <Panel darkMode={darkMode}>
+        <PanelHeader>
+          <SectionTitle darkMode={darkMode}>מידע מערכת</SectionTitle>
+          <Button type="button" onClick={handleExport} disabled={isExporting}>
+            {isExporting ? 'מייצא...' : 'ייצוא חבילת תמיכה'}
+          </Button>
+        </PanelHeader>
+        {diagnostics && (
+          <Table darkMode={darkMode}>
+            <tbody>
+              <tr><th>גרסה</th><td>{diagnostics.version} ({diagnostics.goVersion}, {diagnostics.platform})</td></tr>
+              <tr><th>מסד נתונים</th><td>{diagnostics.dbPath} ({formatBytes(diagnostics.dbSize)})</td></tr>
+              <tr><th>גרסת סכמה</th><td>{diagnostics.schemaVersion}</td></tr>
+              <tr><th>גרסת SQLite</th><td>{diagnostics.sqliteVersion}</td></tr>
+              <tr><th>בדיקת תקינות</th><td>{diagnostics.integrity}</td></tr>
+              <tr><th>קובץ יומן</th><td>{diagnostics.logPath}</td></tr>
+              <tr>
+                <th>שורות בטבלאות</th>
+                <td>{(diagnostics.tables || []).map(table => `${table.table}: ${table.rows}`).join(', ')}</td>
+              </tr>
+            </tbody>
+          </Table>
+        )}
+      </Panel>
+
+      <Panel darkMode={darkMode}>
+        <PanelHeader>
+          <SectionTitle darkMode={darkMode}>יומן אחרון</SectionTitle>
+          <div>
+            <Select darkMode={darkMode} value={level} onChange={(e) => setLevel(e.target.value)}>
+              {Object.entries(levelLabels).map(([value, label]) => (
+                <option key={value} value={value}>{label}</option>
+              ))}
+            </Select>{' '}
+            <Button type="button" onClick={loadLogs}>רענן</Button>
+          </div>
+        </PanelHeader>
+        <LogList darkMode={darkMode}>
+          {logs.length === 0 && <div>אין רשומות</div>}
+          {logs.map((entry, index) => (
+            <LogLine key={index} level={entry.level}>
+              {entry.time} {entry.level} [{entry.subsystem}] {entry.message} {formatAttrs(entry.attrs)}
+            </LogLine>
+          ))}
+        </LogList>
+      </Panel>
+    </PageContainer>
+  );
+};
+
+export default Diagnostics;
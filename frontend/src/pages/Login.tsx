@@ -0,0 +1,190 @@
+import React, { useState } from 'react';
+import styled from 'styled-components';
+import { Login as LoginUser, CreateInitialAdmin } from '../../wailsjs/go/main/App';
+import { main } from '../../wailsjs/go/models';
+import { GardenLogo } from '../components/GardenLogo';
+
+interface LoginProps {
+  darkMode: boolean;
+  needsSetup: boolean;
+  onLoggedIn: (user: main.User) => void;
+}
+
+// Styled components
+const LoginContainer = styled.div<{ darkMode: boolean }>`
+  display: flex;
+  align-items: center;
+  justify-content: center;
+  height: 100vh;
+  direction: rtl;
+  background-color: ${props => props.darkMode ? '#0f172a' : '#f8fafc'};
+  color: ${props => props.darkMode ? '#e2e8f0' : '#1e293b'};
+  font-family: 'Inter', -apple-system, BlinkMacSystemFont, sans-serif;
+`;
+
+const LoginPanel = styled.form<{ darkMode: boolean }>`
+  width: 360px;
+  padding: 32px;
+  border-radius: 12px;
+  background: ${props => props.darkMode ? 'rgba(15, 23, 42, 0.6)' : 'rgba(255, 255, 255, 0.9)'};
+  border: 1px solid ${props => props.darkMode ? 'rgba(255, 255, 255, 0.1)' : 'rgba(0, 0, 0, 0.05)'};
+  box-shadow: 0 10px 30px ${props => props.darkMode ? 'rgba(0, 0, 0, 0.3)' : 'rgba(0, 0, 0, 0.1)'};
+  display: flex;
+  flex-direction: column;
+  gap: 16px;
+
+  .logo {
+    width: 48px;
+    height: 48px;
+    align-self: center;
+  }
+
+  h1 {
+    font-size: 22px;
+    margin: 0;
+    text-align: center;
+    color: ${props => props.darkMode ? '#4ade80' : '#16a34a'};
+  }
+
+  p {
+    margin: 0;
+    font-size: 14px;
+    text-align: center;
+    color: ${props => props.darkMode ? 'rgba(255, 255, 255, 0.6)' : 'rgba(0, 0, 0, 0.6)'};
+  }
+`;
+
+const FormGroup = styled.div<{ darkMode: boolean }>`
+  display: flex;
+  flex-direction: column;
+  gap: 6px;
+
+  label {
+    font-size: 14px;
+    color: ${props => props.darkMode ? 'rgba(255, 255, 255, 0.8)' : 'rgba(0, 0, 0, 0.7)'};
+  }
+
+  input {
+    background: ${props => props.darkMode ? 'rgba(15, 23, 42, 0.5)' : 'rgba(248, 250, 252, 0.8)'};
+    border: 1px solid ${props => props.darkMode ? 'rgba(255, 255, 255, 0.1)' : 'rgba(0, 0, 0, 0.1)'};
+    border-radius: 4px;
+    padding: 10px 12px;
+    color: ${props => props.darkMode ? '#fff' : '#1e293b'};
+  }
+`;
+
+const SubmitButton = styled.button`
+  background: linear-gradient(135deg, #4ade80, #22c55e);
+  border: none;
+  border-radius: 6px;
+  padding: 10px 16px;
+  color: white;
+  font-weight: 600;
+  cursor: pointer;
+
+  &:disabled {
+    opacity: 0.6;
+    cursor: default;
+  }
+`;
+
+const ErrorMessage = styled.div`
+  color: #ef4444;
+  font-size: 14px;
+  text-align: center;
+`;
+
+const Login: React.FC<LoginProps> = ({ darkMode, needsSetup, onLoggedIn }) => {
+  const [username, setUsername] = useState('');
+  const [password, setPassword] = useState('');
+  const [confirmPassword, setConfirmPassword] = useState('');
+  const [error, setError] = useState('');
+  const [isSubmitting, setIsSubmitting] = useState(false);
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError('');
+
+    if (needsSetup) {
+      if (password.length < 8) {
+        setError('הסיסמה חייבת להכיל לפחות 8 תווים');
+        return;
+      }
+      if (password !== confirmPassword) {
+        setError('הסיסמאות אינן תואמות');
+        return;
+      }
+    }
+
+    setIsSubmitting(true);
+    try {
+      const user = needsSetup
+        ? await CreateInitialAdmin(username, password)
+        : await LoginUser(username, password);
+
+      if (user && user.id) {
+        onLoggedIn(user);
+      } else {
+        setError(needsSetup ? 'יצירת המשתמש נכשלה' : 'שם משתמש או סיסמה שגויים');
+      }
+    } catch (err) {
+      console.error('Login failed:', err);
+      setError('ההתחברות נכשלה');
+    } finally {
+      setIsSubmitting(false);
+    }
+  };
+
+  return (
+    <LoginContainer darkMode={darkMode}>
+      <LoginPanel darkMode={darkMode} onSubmit={handleSubmit}>
+        <GardenLogo className="logo" />
+        <h1>{needsSetup ? 'יצירת מנהל מערכת' : 'כניסה למערכת'}</h1>
+        {needsSetup && <p>זוהי ההפעלה הראשונה. צור את חשבון המנהל הראשון.</p>}
+
+        <FormGroup darkMode={darkMode}>
+          <label htmlFor="username">שם משתמש</label>
+          <input
+            id="username"
+            value={username}
+            onChange={(e) => setUsername(e.target.value)}
+            autoFocus
+            required
+          />
+        </FormGroup>
+
+        <FormGroup darkMode={darkMode}>
+          <label htmlFor="password">סיסמה</label>
+          <input
+            id="password"
+            type="password"
+            value={password}
+            onChange={(e) => setPassword(e.target.value)}
+            required
+          />
+        </FormGroup>
+
+        {needsSetup && (
+          <FormGroup darkMode={darkMode}>
+            <label htmlFor="confirmPassword">אימות סיסמה</label>
+            <input
+              id="confirmPassword"
+              type="password"
+              value={confirmPassword}
+              onChange={(e) => setConfirmPassword(e.target.value)}
+              required
+            />
+          </FormGroup>
+        )}
+
+        {error && <ErrorMessage>{error}</ErrorMessage>}
+
+        <SubmitButton type="submit" disabled={isSubmitting}>
+          {needsSetup ? 'צור חשבון' : 'התחבר'}
+        </SubmitButton>
+      </LoginPanel>
+    </LoginContainer>
+  );
+};
+
+export default Login;
@@ -1,8 +1,9 @@
 import React, { useState, useEffect } from 'react';
 import styled from 'styled-components';
-import { GetOrders, UpdateOrderStatus, DeleteOrder } from '../../wailsjs/go/main/App';
+import { GetOrders, UpdateOrderStatus, DeleteOrder, UpdateOrder, GetOrderHistory, GetProducts, ShipOrder, SplitOrder, GetShipments, ReturnItems, GetReturns, GetStockItems, AddPayment, GetPayments, GetLocations } from '../../wailsjs/go/main/App';
 import { main } from '../../wailsjs/go/models';
-import { formatPrice } from '../utils/formatters';
+import { formatPrice, priceOverrideLabels } from '../utils/formatters';
+import { onChange, applyChange, needsReload } from '../utils/changeEvents';
 
 // Backend types - only import what we use
 type OrderItem = main.OrderItem;
@@ -16,8 +17,48 @@ interface OrderData {
   status: string;
   items: OrderItem[];
   total: number;
+  refunded: number;
+  paid: number;
+  balanceDue: number;
+  paymentStatus: string;
 }
 
+// An order's name, description and items while they are being edited
+interface OrderDraft {
+  name: string;
+  description: string;
+  items: OrderItem[];
+}
+
+// Orders can be edited until they ship, and shipped or split until everything has left
+const editableStatuses = ['Pending', 'Processing'];
+const shippableStatuses = ['Pending', 'Processing', 'Partially Shipped'];
+
+// Conditions a returned item can come back in
+const returnConditions: Record<string, string> = {
+  Resellable: 'ניתן למכירה',
+  Opened: 'נפתח',
+  Damaged: 'פגום'
+};
+
+type LineAction = 'ship' | 'split' | 'return';
+
+const paymentMethods: Record<string, string> = {
+  Cash: 'מזומן',
+  Card: 'כרטיס אשראי',
+  Transfer: 'העברה בנקאית'
+};
+
+const paymentStatusLabels: Record<string, string> = {
+  Unpaid: 'לא שולם',
+  'Partially Paid': 'שולם חלקית',
+  Paid: 'שולם'
+};
+
+// How much of a line a ship, split or return can still take
+const availableQuantity = (item: OrderItem, action: LineAction) =>
+  action === 'return' ? item.shipped - item.returned : item.remaining;
+
 interface OrdersProps {
   darkMode: boolean;
   showNotification: (options: { message: string; type: 'success' | 'error' | 'info' | 'warning' }) => void;
@@ -147,6 +188,8 @@ const StatusBadge = styled.span<{ status: string; darkMode: boolean }>`
         return props.darkMode ? 'rgba(245, 158, 11, 0.2)' : 'rgba(245, 158, 11, 0.1)';
       case 'Processing':
         return props.darkMode ? 'rgba(14, 165, 233, 0.2)' : 'rgba(14, 165, 233, 0.1)';
+      case 'Partially Shipped':
+        return props.darkMode ? 'rgba(132, 204, 22, 0.2)' : 'rgba(132, 204, 22, 0.1)';
       case 'Shipped':
         return props.darkMode ? 'rgba(22, 163, 74, 0.2)' : 'rgba(22, 163, 74, 0.1)';
       case 'Delivered':
@@ -164,6 +207,8 @@ const StatusBadge = styled.span<{ status: string; darkMode: boolean }>`
         return props.darkMode ? 'rgba(253, 230, 138, 0.9)' : 'rgba(245, 158, 11, 0.9)';
       case 'Processing':
         return props.darkMode ? 'rgba(56, 189, 248, 0.9)' : 'rgba(14, 165, 233, 0.9)';
+      case 'Partially Shipped':
+        return props.darkMode ? 'rgba(190, 242, 100, 0.9)' : 'rgba(101, 163, 13, 0.9)';
       case 'Shipped':
         return props.darkMode ? 'rgba(134, 239, 172, 0.9)' : 'rgba(22, 163, 74, 0.9)';
       case 'Delivered':
@@ -623,10 +668,60 @@ const ActionIconButton = styled.button<{ darkMode: boolean; variant?: 'primary'
   }
 `;
 
+const HistoryList = styled.ul<{ darkMode: boolean }>`
+  list-style: none;
+  margin: 20px 0 0 0;
+  padding: 0;
+  font-size: 0.85rem;
+  color: ${props => props.darkMode ? 'rgba(255, 255, 255, 0.7)' : 'rgba(0, 0, 0, 0.6)'};
+
+  li {
+    padding: 6px 0;
+    border-bottom: 1px solid ${props => props.darkMode
+      ? 'rgba(255, 255, 255, 0.05)'
+      : 'rgba(0, 0, 0, 0.05)'};
+  }
+`;
+
+// Convert backend Order type to our OrderData type
+const toOrderData = (order: main.Order): OrderData => ({
+  id: order.id,
+  date: order.date,
+  name: order.name || `הזמנה #${order.id}`, // Fallback for old orders
+  description: order.description || '',
+  status: order.status,
+  items: order.items || [],
+  total: order.total,
+  refunded: order.refunded || 0,
+  paid: order.paid || 0,
+  balanceDue: order.balanceDue ?? order.total,
+  paymentStatus: order.paymentStatus || 'Unpaid'
+});
+
 const Orders: React.FC<OrdersProps> = ({ darkMode, showNotification }) => {
   const [orders, setOrders] = useState<OrderData[]>([]);
   const [selectedOrder, setSelectedOrder] = useState<OrderData | null>(null);
-  const [orderItems, setOrderItems] = useState<OrderItem[]>([]);
+  const [draft, setDraft] = useState<OrderDraft | null>(null);
+  const [history, setHistory] = useState<main.OrderChange[]>([]);
+  const [products, setProducts] = useState<main.Product[]>([]);
+  const [productToAdd, setProductToAdd] = useState<string>('');
+  const [shipments, setShipments] = useState<main.Shipment[]>([]);
+  const [lineAction, setLineAction] = useState<LineAction | null>(null);
+  const [lineQuantities, setLineQuantities] = useState<Record<string, number>>({});
+  const [returns, setReturns] = useState<main.Return[]>([]);
+  const [stockItems, setStockItems] = useState<main.StockItem[]>([]);
+  const [returnConditionsByLine, setReturnConditionsByLine] = useState<Record<string, string>>({});
+  const [restockByLine, setRestockByLine] = useState<Record<string, string>>({});
+  const [returnReason, setReturnReason] = useState<string>('');
+  const [locations, setLocations] = useState<main.Location[]>([]);
+  const [locationId, setLocationId] = useState<string>('main');
+  const [refund, setRefund] = useState<string>('');
+  const [payments, setPayments] = useState<main.Payment[]>([]);
+  const [paymentMethod, setPaymentMethod] = useState<string>('Cash');
+  const [paymentAmount, setPaymentAmount] = useState<string>('');
+  const [paymentReference, setPaymentReference] = useState<string>('');
+  const [paymentIsDeposit, setPaymentIsDeposit] = useState<boolean>(false);
+  const [unpaidOnly, setUnpaidOnly] = useState<boolean>(false);
   const [statusFilter, setStatusFilter] = useState<string>('All');
   const [searchTerm, setSearchTerm] = useState<string>('');
   const [dateFilter, setDateFilter] = useState<string>('');
@@ -638,16 +733,7 @@ const Orders: React.FC<OrdersProps> = ({ darkMode, showNotification }) => {
     try {
       setLoading(true);
       const data = await GetOrders();
-      // Convert backend Order type to our OrderData type
-      const orderData: OrderData[] = Array.isArray(data) ? data.map(order => ({
-        id: order.id,
-        date: order.date,
-        name: order.name || `הזמנה #${order.id}`, // Fallback for old orders
-        description: order.description || '',
-        status: order.status,
-        items: order.items || [],
-        total: order.total
-      })) : [];
+      const orderData: OrderData[] = Array.isArray(data) ? data.map(toOrderData) : [];
       setOrders(orderData);
       setLoading(false);
     } catch (error) {
@@ -662,11 +748,289 @@ const Orders: React.FC<OrdersProps> = ({ darkMode, showNotification }) => {
   
   useEffect(() => {
     loadOrders();
+    
+    // Patch the list when an order changes here or in another window
+    return onChange<main.Order>('order', event => {
+      if (needsReload(event)) {
+        loadOrders();
+        return;
+      }
+      setOrders(prev => applyChange(prev, event, toOrderData));
+      setSelectedOrder(prev => {
+        if (!prev || prev.id !== event.id) {
+          return prev;
+        }
+        return event.data ? toOrderData(event.data) : null;
+      });
+    });
   }, []);
   
+  // Reload the change history and shipments whenever the selected order changes
+  useEffect(() => {
+    if (!selectedOrder) {
+      setHistory([]);
+      setShipments([]);
+      setReturns([]);
+      setPayments([]);
+      return;
+    }
+    GetOrderHistory(selectedOrder.id)
+      .then(changes => setHistory(changes || []))
+      .catch(error => console.error('Error loading order history:', error));
+    GetShipments(selectedOrder.id)
+      .then(list => setShipments(list || []))
+      .catch(error => console.error('Error loading shipments:', error));
+    GetPayments(selectedOrder.id)
+      .then(list => setPayments(list || []))
+      .catch(error => console.error('Error loading payments:', error));
+    GetReturns(selectedOrder.id)
+      .then(list => setReturns(list || []))
+      .catch(error => console.error('Error loading returns:', error));
+  }, [selectedOrder]);
+  
   const handleViewOrder = (order: OrderData) => {
     setSelectedOrder(order);
-    setOrderItems(order.items || []);
+    setDraft(null);
+    setLineAction(null);
+  };
+  
+  // Start shipping, splitting or a return. Shipping offers everything left on each line.
+  const handleStartLineAction = async (action: LineAction) => {
+    if (!selectedOrder) return;
+    
+    const quantities: Record<string, number> = {};
+    const conditions: Record<string, string> = {};
+    selectedOrder.items.forEach(item => {
+      if (item.id && availableQuantity(item, action) > 0) {
+        quantities[item.id] = action === 'ship' ? item.remaining : 0;
+        conditions[item.id] = 'Resellable';
+      }
+    });
+    setLineQuantities(quantities);
+    setLineAction(action);
+    setLocationId('main');
+    
+    if (action !== 'split') {
+      try {
+        setLocations(await GetLocations() || []);
+      } catch (error) {
+        console.error('Error loading locations:', error);
+      }
+    }
+    
+    if (action === 'return') {
+      setReturnConditionsByLine(conditions);
+      setRestockByLine({});
+      setReturnReason('');
+      setRefund('');
+      try {
+        setStockItems(await GetStockItems() || []);
+      } catch (error) {
+        console.error('Error loading stock items:', error);
+      }
+    }
+  };
+  
+  // The refund suggested for a return is the price of the returned items, up to what is
+  // left to refund on the order
+  const suggestedRefund = () => {
+    if (!selectedOrder) return 0;
+    
+    const value = selectedOrder.items.reduce(
+      (sum, item) => sum + (item.id ? (lineQuantities[item.id] || 0) * item.price : 0), 0);
+    return Math.min(value, selectedOrder.total - selectedOrder.refunded);
+  };
+  
+  const handleConfirmLineAction = async () => {
+    if (!selectedOrder || !lineAction) return;
+    
+    const items = Object.entries(lineQuantities)
+      .filter(([, quantity]) => quantity > 0)
+      .map(([orderItemId, quantity]) => main.ShipmentLine.createFrom({ orderItemId, quantity }));
+    if (items.length === 0) {
+      showNotification({
+        message: 'בחר לפחות פריט אחד',
+        type: 'warning'
+      });
+      return;
+    }
+    
+    try {
+      if (lineAction === 'return') {
+        if (!returnReason.trim()) {
+          showNotification({
+            message: 'יש לציין סיבת החזרה',
+            type: 'warning'
+          });
+          return;
+        }
+        const lines = items.map(line => main.ReturnLine.createFrom({
+          orderItemId: line.orderItemId,
+          quantity: line.quantity,
+          condition: returnConditionsByLine[line.orderItemId] || 'Resellable',
+          restockItemId: restockByLine[line.orderItemId] || ''
+        }));
+        const amount = refund === '' ? suggestedRefund() : parseFloat(refund) || 0;
+        if (!await ReturnItems(selectedOrder.id, main.NewReturn.createFrom({ items: lines, reason: returnReason, refund: amount, locationId }))) {
+          throw new Error('ReturnItems failed');
+        }
+        showNotification({
+          message: 'ההחזרה נרשמה בהצלחה',
+          type: 'success'
+        });
+      } else if (lineAction === 'ship') {
+        if (!await ShipOrder(selectedOrder.id, main.NewShipment.createFrom({ items, note: '', locationId }))) {
+          throw new Error('ShipOrder failed');
+        }
+        showNotification({
+          message: 'המשלוח נרשם בהצלחה',
+          type: 'success'
+        });
+      } else {
+        const newId = await SplitOrder(selectedOrder.id, main.OrderSplit.createFrom({ items }));
+        if (!newId) {
+          throw new Error('SplitOrder failed');
+        }
+        showNotification({
+          message: `הפריטים הועברו להזמנה #${newId}`,
+          type: 'success'
+        });
+      }
+      setLineAction(null);
+    } catch (error) {
+      console.error('Error shipping, splitting or returning order items:', error);
+      showNotification({
+        message: lineAction === 'ship' ? 'שגיאה ברישום המשלוח' :
+          lineAction === 'split' ? 'שגיאה בפיצול ההזמנה' : 'שגיאה ברישום ההחזרה',
+        type: 'error'
+      });
+    }
+  };
+  
+  const handleAddPayment = async () => {
+    if (!selectedOrder) return;
+    
+    // An empty amount pays off the balance
+    const amount = paymentAmount === '' ? selectedOrder.balanceDue : parseFloat(paymentAmount);
+    if (!(amount > 0) || amount > selectedOrder.balanceDue + 0.005) {
+      showNotification({
+        message: `יש להזין סכום עד ${formatPrice(selectedOrder.balanceDue)}`,
+        type: 'warning'
+      });
+      return;
+    }
+    
+    try {
+      const saved = await AddPayment(selectedOrder.id, main.NewPayment.createFrom({
+        method: paymentMethod,
+        amount,
+        paidAt: '',
+        reference: paymentReference,
+        deposit: paymentIsDeposit
+      }));
+      if (!saved) {
+        throw new Error('AddPayment failed');
+      }
+      setPaymentAmount('');
+      setPaymentReference('');
+      setPaymentIsDeposit(false);
+      showNotification({
+        message: 'התשלום נרשם בהצלחה',
+        type: 'success'
+      });
+    } catch (error) {
+      console.error('Error recording payment:', error);
+      showNotification({
+        message: 'שגיאה ברישום התשלום',
+        type: 'error'
+      });
+    }
+  };
+  
+  const handleStartEdit = async () => {
+    if (!selectedOrder) return;
+    
+    setDraft({
+      name: selectedOrder.name,
+      description: selectedOrder.description,
+      items: selectedOrder.items.map(item => ({ ...item }))
+    });
+    if (products.length === 0) {
+      try {
+        setProducts(await GetProducts() || []);
+      } catch (error) {
+        console.error('Error loading products:', error);
+      }
+    }
+  };
+  
+  const updateDraftQuantity = (index: number, quantity: number) => {
+    setDraft(prev => prev ? {
+      ...prev,
+      items: prev.items.map((item, i) => i === index ? { ...item, quantity } : item)
+    } : null);
+  };
+  
+  const removeDraftItem = (index: number) => {
+    setDraft(prev => prev ? { ...prev, items: prev.items.filter((_, i) => i !== index) } : null);
+  };
+  
+  const addDraftItem = () => {
+    const product = products.find(p => p.id === productToAdd);
+    if (!product || !draft) return;
+    
+    const existing = draft.items.findIndex(item => item.productId === product.id && !item.variantId);
+    if (existing >= 0) {
+      updateDraftQuantity(existing, draft.items[existing].quantity + 1);
+    } else {
+      setDraft({
+        ...draft,
+        items: [...draft.items, main.OrderItem.createFrom({
+          productId: product.id,
+          productName: product.name,
+          price: product.price,
+          listPrice: product.price,
+          quantity: 1
+        })]
+      });
+    }
+    setProductToAdd('');
+  };
+  
+  const handleSaveEdit = async () => {
+    if (!selectedOrder || !draft) return;
+    
+    if (!draft.name.trim() || draft.items.length === 0 || draft.items.some(item => !(item.quantity > 0))) {
+      showNotification({
+        message: 'להזמנה חייבים להיות שם ולפחות פריט אחד בכמות חיובית',
+        type: 'warning'
+      });
+      return;
+    }
+    
+    try {
+      const saved = await UpdateOrder(selectedOrder.id, main.NewOrder.createFrom(draft));
+      if (!saved) {
+        showNotification({
+          message: 'שגיאה בעדכון ההזמנה',
+          type: 'error'
+        });
+        return;
+      }
+      
+      // The change event refreshes the order and its history
+      setDraft(null);
+      showNotification({
+        message: 'ההזמנה עודכנה בהצלחה',
+        type: 'success'
+      });
+    } catch (error) {
+      console.error('Error updating order:', error);
+      showNotification({
+        message: 'שגיאה בעדכון ההזמנה',
+        type: 'error'
+      });
+    }
   };
   
   const handleStatusChange = async (orderId: string, newStatus: string) => {
@@ -776,7 +1140,10 @@ const Orders: React.FC<OrdersProps> = ({ darkMode, showNotification }) => {
     const matchesDate = dateFilter === '' || 
       (order.date.split(' ')[0] === dateFilter);
     
-    return matchesFilter && matchesSearch && matchesDate;
+    // Balance filter
+    const matchesBalance = !unpaidOnly || (order.status !== 'Cancelled' && order.balanceDue > 0);
+    
+    return matchesFilter && matchesSearch && matchesDate && matchesBalance;
   });
   
   return (
@@ -812,10 +1179,16 @@ const Orders: React.FC<OrdersProps> = ({ darkMode, showNotification }) => {
             <option value="All">כל הסטטוסים</option>
             <option value="Pending">ממתין</option>
             <option value="Processing">בטיפול</option>
+            <option value="Partially Shipped">נשלח חלקית</option>
             <option value="Shipped">נשלח</option>
             <option value="Delivered">נמסר</option>
             <option value="Cancelled">בוטל</option>
           </StatusFilter>
+          
+          <label style={{ display: 'flex', alignItems: 'center', gap: '6px', color: darkMode ? 'rgba(255, 255, 255, 0.8)' : 'rgba(0, 0, 0, 0.7)' }}>
+            <input type="checkbox" checked={unpaidOnly} onChange={(e) => setUnpaidOnly(e.target.checked)} />
+            רק עם יתרה לתשלום
+          </label>
         </FilterContainer>
         
         {loading ? (
@@ -840,6 +1213,7 @@ const Orders: React.FC<OrdersProps> = ({ darkMode, showNotification }) => {
                   <StatusBadge status={order.status} darkMode={darkMode}>
                     {order.status === 'Pending' ? 'ממתין' :
                      order.status === 'Processing' ? 'בטיפול' :
+                     order.status === 'Partially Shipped' ? 'נשלח חלקית' :
                      order.status === 'Shipped' ? 'נשלח' :
                      order.status === 'Delivered' ? 'נמסר' :
                      order.status === 'Cancelled' ? 'בוטל' : order.status}
@@ -849,6 +1223,7 @@ const Orders: React.FC<OrdersProps> = ({ darkMode, showNotification }) => {
                 <div>
                   <div style={{ fontSize: '0.85rem', color: darkMode ? 'rgba(255, 255, 255, 0.7)' : 'rgba(0, 0, 0, 0.7)' }}>
                     {order.items.length} {order.items.length === 1 ? 'פריט' : 'פריטים'}
+                    {' · '}{paymentStatusLabels[order.paymentStatus] || order.paymentStatus}
                   </div>
                 </div>
                 
@@ -894,7 +1269,16 @@ const Orders: React.FC<OrdersProps> = ({ darkMode, showNotification }) => {
           <OrderMeta>
             <OrderMetaItem darkMode={darkMode}>
               <div className="label">שם הזמנה</div>
-              <div className="value">{selectedOrder.name}</div>
+              {draft ? (
+                <SearchBox
+                  type="text"
+                  value={draft.name}
+                  onChange={(e) => setDraft({ ...draft, name: e.target.value })}
+                  darkMode={darkMode}
+                />
+              ) : (
+                <div className="value">{selectedOrder.name}</div>
+              )}
             </OrderMetaItem>
             
             <OrderMetaItem darkMode={darkMode}>
@@ -917,7 +1301,9 @@ const Orders: React.FC<OrdersProps> = ({ darkMode, showNotification }) => {
                 >
                   <option value="Pending">ממתין</option>
                   <option value="Processing">בטיפול</option>
-                  <option value="Shipped">נשלח</option>
+                  {/* Shipped statuses follow from the order's shipments and are only shown */}
+                  {selectedOrder.status === 'Partially Shipped' && <option value="Partially Shipped" disabled>נשלח חלקית</option>}
+                  {selectedOrder.status === 'Shipped' && <option value="Shipped" disabled>נשלח</option>}
                   <option value="Delivered">נמסר</option>
                   <option value="Cancelled">בוטל</option>
                 </StatusSelector>
@@ -925,26 +1311,143 @@ const Orders: React.FC<OrdersProps> = ({ darkMode, showNotification }) => {
             </OrderMetaItem>
           </OrderMeta>
           
-          {selectedOrder.description && (
+          {draft ? (
+            <OrderMetaItem darkMode={darkMode} style={{ marginBottom: '20px' }}>
+              <div className="label">תיאור</div>
+              <SearchBox
+                as="textarea"
+                value={draft.description}
+                onChange={(e: React.ChangeEvent<HTMLTextAreaElement>) => setDraft({ ...draft, description: e.target.value })}
+                darkMode={darkMode}
+                style={{ width: '100%', minHeight: '60px' }}
+              />
+            </OrderMetaItem>
+          ) : selectedOrder.description && (
             <OrderMetaItem darkMode={darkMode} style={{ marginBottom: '20px' }}>
               <div className="label">תיאור</div>
               <div className="value" style={{ whiteSpace: 'pre-wrap' }}>{selectedOrder.description}</div>
             </OrderMetaItem>
           )}
           
-          {orderItems.length > 0 ? (
+          {draft ? (
             <OrderItemList>
-              {orderItems.map((item, index) => (
+              {draft.items.map((item, index) => (
                 <OrderItemRow key={`${item.productId}-${index}`} darkMode={darkMode}>
                   <ItemDetails>
                     <ItemRow>
                       <ItemName darkMode={darkMode}>{item.productName}</ItemName>
                       <ItemPrice darkMode={darkMode}>{formatPrice(item.price)} לפריט</ItemPrice>
                     </ItemRow>
+                    {item.overrideReason && (
+                      <ItemQuantity darkMode={darkMode}>
+                        {priceOverrideLabels[item.overrideReason] || item.overrideReason} · מחיר מחירון {formatPrice(item.listPrice)}
+                      </ItemQuantity>
+                    )}
                     <ItemRow>
-                      <ItemQuantity darkMode={darkMode}>כמות: {item.quantity}</ItemQuantity>
+                      <SearchBox
+                        type="number"
+                        min={1}
+                        value={item.quantity}
+                        onChange={(e) => updateDraftQuantity(index, parseInt(e.target.value, 10) || 0)}
+                        darkMode={darkMode}
+                        style={{ flex: 'none', minWidth: 0, width: '80px' }}
+                        aria-label="כמות"
+                      />
+                      <ActionIconButton
+                        darkMode={darkMode}
+                        variant="danger"
+                        onClick={() => removeDraftItem(index)}
+                        aria-label="הסר פריט"
+                      >
+                        <TrashIcon />
+                      </ActionIconButton>
+                    </ItemRow>
+                  </ItemDetails>
+                </OrderItemRow>
+              ))}
+              <div style={{ display: 'flex', gap: '8px', marginTop: '12px' }}>
+                <StatusSelector
+                  value={productToAdd}
+                  onChange={(e) => setProductToAdd(e.target.value)}
+                  darkMode={darkMode}
+                >
+                  <option value="">בחר מוצר להוספה...</option>
+                  {products.map(product => (
+                    <option key={product.id} value={product.id}>{product.name}</option>
+                  ))}
+                </StatusSelector>
+                <Button darkMode={darkMode} variant="secondary" onClick={addDraftItem} disabled={!productToAdd}>
+                  הוסף
+                </Button>
+              </div>
+            </OrderItemList>
+          ) : selectedOrder.items.length > 0 ? (
+            <OrderItemList>
+              {selectedOrder.items.map((item, index) => (
+                <OrderItemRow key={`${item.productId}-${index}`} darkMode={darkMode}>
+                  <ItemDetails>
+                    <ItemRow>
+                      <ItemName darkMode={darkMode}>{item.productName}</ItemName>
+                      <ItemPrice darkMode={darkMode}>{formatPrice(item.price)} לפריט</ItemPrice>
+                    </ItemRow>
+                    {item.overrideReason && (
+                      <ItemQuantity darkMode={darkMode}>
+                        {priceOverrideLabels[item.overrideReason] || item.overrideReason} · מחיר מחירון {formatPrice(item.listPrice)}
+                      </ItemQuantity>
+                    )}
+                    <ItemRow>
+                      <ItemQuantity darkMode={darkMode}>
+                        כמות: {item.quantity}
+                        {item.shipped > 0 && ` · נשלחו: ${item.shipped} · נותרו: ${item.remaining}`}
+                        {item.returned > 0 && ` · הוחזרו: ${item.returned}`}
+                      </ItemQuantity>
                       <ItemSubtotal darkMode={darkMode}>{formatPrice(item.price * item.quantity)}</ItemSubtotal>
                     </ItemRow>
+                    {lineAction && item.id && availableQuantity(item, lineAction) > 0 && (
+                      <ItemRow>
+                        <ItemQuantity darkMode={darkMode}>
+                          {lineAction === 'ship' ? 'לשלוח:' : lineAction === 'split' ? 'להעביר להזמנה חדשה:' : 'להחזיר:'}
+                        </ItemQuantity>
+                        <SearchBox
+                          type="number"
+                          min={0}
+                          max={availableQuantity(item, lineAction)}
+                          value={lineQuantities[item.id] ?? 0}
+                          onChange={(e) => setLineQuantities({
+                            ...lineQuantities,
+                            [item.id]: Math.min(availableQuantity(item, lineAction), Math.max(0, parseInt(e.target.value, 10) || 0))
+                          })}
+                          darkMode={darkMode}
+                          style={{ flex: 'none', minWidth: 0, width: '80px' }}
+                        />
+                      </ItemRow>
+                    )}
+                    {lineAction === 'return' && item.id && (lineQuantities[item.id] || 0) > 0 && (
+                      <ItemRow>
+                        <StatusSelector
+                          value={returnConditionsByLine[item.id] || 'Resellable'}
+                          onChange={(e) => setReturnConditionsByLine({ ...returnConditionsByLine, [item.id]: e.target.value })}
+                          darkMode={darkMode}
+                          style={{ minWidth: 0 }}
+                        >
+                          {Object.entries(returnConditions).map(([value, label]) => (
+                            <option key={value} value={value}>{label}</option>
+                          ))}
+                        </StatusSelector>
+                        <StatusSelector
+                          value={restockByLine[item.id] || ''}
+                          onChange={(e) => setRestockByLine({ ...restockByLine, [item.id]: e.target.value })}
+                          disabled={returnConditionsByLine[item.id] === 'Damaged'}
+                          darkMode={darkMode}
+                          style={{ minWidth: 0 }}
+                        >
+                          <option value="">ללא החזרה למלאי</option>
+                          {stockItems.map(stockItem => (
+                            <option key={stockItem.id} value={stockItem.id}>החזר ל: {stockItem.name}</option>
+                          ))}
+                        </StatusSelector>
+                      </ItemRow>
+                    )}
                   </ItemDetails>
                 </OrderItemRow>
               ))}
@@ -955,8 +1458,202 @@ const Orders: React.FC<OrdersProps> = ({ darkMode, showNotification }) => {
           
           <OrderTotal darkMode={darkMode}>
             <span>סה״כ:</span>
-            <strong>{formatPrice(calculateTotal(orderItems))}</strong>
+            <strong>{formatPrice(calculateTotal(draft ? draft.items : selectedOrder.items))}</strong>
+          </OrderTotal>
+          
+          {selectedOrder.paid > 0 && (
+            <OrderTotal darkMode={darkMode}>
+              <span>שולם:</span>
+              <strong>{formatPrice(selectedOrder.paid)}</strong>
+            </OrderTotal>
+          )}
+          
+          <OrderTotal darkMode={darkMode}>
+            <span>יתרה לתשלום ({paymentStatusLabels[selectedOrder.paymentStatus] || selectedOrder.paymentStatus}):</span>
+            <strong>{formatPrice(Math.max(0, selectedOrder.balanceDue))}</strong>
           </OrderTotal>
+          
+          {selectedOrder.refunded > 0 && (
+            <OrderTotal darkMode={darkMode}>
+              <span>הוחזר ללקוח:</span>
+              <strong>{formatPrice(selectedOrder.refunded)}</strong>
+            </OrderTotal>
+          )}
+          
+          {(lineAction === 'ship' || lineAction === 'return') && (
+            <div style={{ display: 'flex', flexWrap: 'wrap', gap: '12px', marginTop: '16px' }}>
+              <StatusSelector
+                value={locationId}
+                onChange={(e) => setLocationId(e.target.value)}
+                darkMode={darkMode}
+              >
+                {lineAction === 'ship' && <option value="">ללא הורדה מהמלאי</option>}
+                {locations.map(location => (
+                  <option key={location.id} value={location.id}>
+                    {lineAction === 'ship' ? 'שלח מ:' : 'החזר ל:'} {location.name}
+                  </option>
+                ))}
+              </StatusSelector>
+            </div>
+          )}
+          
+          {lineAction === 'return' && (
+            <div style={{ display: 'flex', flexWrap: 'wrap', gap: '12px', marginTop: '16px' }}>
+              <SearchBox
+                type="text"
+                placeholder="סיבת ההחזרה"
+                value={returnReason}
+                onChange={(e) => setReturnReason(e.target.value)}
+                darkMode={darkMode}
+              />
+              <SearchBox
+                type="number"
+                min={0}
+                step="0.01"
+                placeholder={`החזר כספי (${formatPrice(suggestedRefund())})`}
+                value={refund}
+                onChange={(e) => setRefund(e.target.value)}
+                darkMode={darkMode}
+              />
+            </div>
+          )}
+          
+          {(shippableStatuses.includes(selectedOrder.status) ||
+            selectedOrder.items.some(item => item.shipped > item.returned)) && (
+            <div style={{ display: 'flex', flexWrap: 'wrap', gap: '12px', marginTop: '16px' }}>
+              {draft ? (
+                <>
+                  <Button darkMode={darkMode} variant="primary" onClick={handleSaveEdit}>שמור שינויים</Button>
+                  <Button darkMode={darkMode} variant="secondary" onClick={() => setDraft(null)}>ביטול</Button>
+                </>
+              ) : lineAction ? (
+                <>
+                  <Button darkMode={darkMode} variant="primary" onClick={handleConfirmLineAction}>
+                    {lineAction === 'ship' ? 'אשר משלוח' : lineAction === 'split' ? 'פצל הזמנה' : 'רשום החזרה'}
+                  </Button>
+                  <Button darkMode={darkMode} variant="secondary" onClick={() => setLineAction(null)}>ביטול</Button>
+                </>
+              ) : (
+                <>
+                  {editableStatuses.includes(selectedOrder.status) && (
+                    <Button darkMode={darkMode} variant="secondary" onClick={handleStartEdit}>ערוך הזמנה</Button>
+                  )}
+                  {shippableStatuses.includes(selectedOrder.status) && (
+                    <>
+                      <Button darkMode={darkMode} variant="secondary" onClick={() => handleStartLineAction('ship')}>שלח פריטים</Button>
+                      <Button darkMode={darkMode} variant="secondary" onClick={() => handleStartLineAction('split')}>פצל הזמנה</Button>
+                    </>
+                  )}
+                  {selectedOrder.items.some(item => item.shipped > item.returned) && (
+                    <Button darkMode={darkMode} variant="secondary" onClick={() => handleStartLineAction('return')}>החזרת פריטים</Button>
+                  )}
+                </>
+              )}
+            </div>
+          )}
+          
+          {shipments.length > 0 && (
+            <>
+              <SectionTitle darkMode={darkMode} style={{ marginTop: '24px' }}>משלוחים</SectionTitle>
+              <HistoryList darkMode={darkMode}>
+                {shipments.map(shipment => (
+                  <li key={shipment.id}>
+                    {formatDate(shipment.shippedAt)}
+                    {shipment.shippedBy && ` · ${shipment.shippedBy}`} ·{' '}
+                    {shipment.items.map(item => `${item.quantity} × ${item.productName}` +
+                      (item.lots && item.lots.length > 0 ? ` (אצווה ${item.lots.map(lot => lot.lotNumber).join(', ')})` : '')
+                    ).join(', ')}
+                    {shipment.location && ` · מ${shipment.location}`}
+                    {shipment.note && ` · ${shipment.note}`}
+                  </li>
+                ))}
+              </HistoryList>
+            </>
+          )}
+          
+          {selectedOrder.status !== 'Cancelled' && selectedOrder.balanceDue > 0 && !draft && !lineAction && (
+            <div style={{ display: 'flex', flexWrap: 'wrap', gap: '12px', marginTop: '16px', alignItems: 'center' }}>
+              <StatusSelector
+                value={paymentMethod}
+                onChange={(e) => setPaymentMethod(e.target.value)}
+                darkMode={darkMode}
+                style={{ minWidth: '140px' }}
+              >
+                {Object.entries(paymentMethods).map(([value, label]) => (
+                  <option key={value} value={value}>{label}</option>
+                ))}
+              </StatusSelector>
+              <SearchBox
+                type="number"
+                min={0}
+                step="0.01"
+                placeholder={`סכום (${formatPrice(selectedOrder.balanceDue)})`}
+                value={paymentAmount}
+                onChange={(e) => setPaymentAmount(e.target.value)}
+                darkMode={darkMode}
+              />
+              <SearchBox
+                type="text"
+                placeholder="אסמכתא"
+                value={paymentReference}
+                onChange={(e) => setPaymentReference(e.target.value)}
+                darkMode={darkMode}
+              />
+              <label style={{ display: 'flex', alignItems: 'center', gap: '6px' }}>
+                <input type="checkbox" checked={paymentIsDeposit} onChange={(e) => setPaymentIsDeposit(e.target.checked)} />
+                מקדמה
+              </label>
+              <Button darkMode={darkMode} variant="primary" onClick={handleAddPayment}>רשום תשלום</Button>
+            </div>
+          )}
+          
+          {payments.length > 0 && (
+            <>
+              <SectionTitle darkMode={darkMode} style={{ marginTop: '24px' }}>תשלומים</SectionTitle>
+              <HistoryList darkMode={darkMode}>
+                {payments.map(payment => (
+                  <li key={payment.id}>
+                    {formatDate(payment.paidAt)} · {formatPrice(payment.amount)} · {paymentMethods[payment.method] || payment.method}
+                    {payment.deposit && ' · מקדמה'}
+                    {payment.reference && ` · ${payment.reference}`}
+                    {payment.recordedBy && ` · ${payment.recordedBy}`}
+                  </li>
+                ))}
+              </HistoryList>
+            </>
+          )}
+          
+          {returns.length > 0 && (
+            <>
+              <SectionTitle darkMode={darkMode} style={{ marginTop: '24px' }}>החזרות</SectionTitle>
+              <HistoryList darkMode={darkMode}>
+                {returns.map(ret => (
+                  <li key={ret.id}>
+                    {formatDate(ret.returnedAt)}
+                    {ret.returnedBy && ` · ${ret.returnedBy}`} ·{' '}
+                    {ret.items.map(item => `${item.quantity} × ${item.productName} (${returnConditions[item.condition] || item.condition})`).join(', ')}
+                    {` · ${ret.reason}`}
+                    {ret.refund > 0 && ` · ${formatPrice(ret.refund)}`}
+                    {ret.refund > ret.paidOut && ` (${formatPrice(ret.refund - ret.paidOut)} קוזז מהיתרה)`}
+                  </li>
+                ))}
+              </HistoryList>
+            </>
+          )}
+          
+          {history.length > 0 && (
+            <>
+              <SectionTitle darkMode={darkMode} style={{ marginTop: '24px' }}>היסטוריית שינויים</SectionTitle>
+              <HistoryList darkMode={darkMode}>
+                {history.map(change => (
+                  <li key={change.id}>
+                    {formatDate(change.changedAt)}
+                    {change.changedBy && ` · ${change.changedBy}`} · {change.change}
+                  </li>
+                ))}
+              </HistoryList>
+            </>
+          )}
         </OrderDetailsPanel>
       )}
       
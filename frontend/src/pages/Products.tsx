@@ -12,17 +12,24 @@ type Product = main.Product;
 interface ProductsProps {
   products: Product[];
   darkMode: boolean;
-  onProductsChanged: () => void;
   showNotification: (options: { message: string; type: 'success' | 'error' | 'info' | 'warning' }) => void;
 }
 
-const emptyProduct: Product = {
+const emptyProduct: Product = main.Product.createFrom({
   id: '',
   name: '',
   price: 0,
   description: '',
-  status: 'In Stock'
-};
+  status: 'In Stock',
+  sku: '',
+  barcode: '',
+  category: '',
+  tags: [],
+  image: '',
+  imageUrl: '',
+  thumbnailUrl: '',
+  variants: []
+});
 
 const ProductsContainer = styled.div`
   display: flex;
@@ -298,7 +305,6 @@ const NoProductsMessage = styled.div<{ darkMode: boolean }>`
 const Products: React.FC<ProductsProps> = ({
   products,
   darkMode,
-  onProductsChanged,
   showNotification
 }) => {
   const [searchTerm, setSearchTerm] = useState('');
@@ -343,7 +349,6 @@ const Products: React.FC<ProductsProps> = ({
           message: `${productToDelete.name} נמחק בהצלחה`,
           type: 'success'
         });
-        onProductsChanged();
       } else {
         console.error('Delete product returned false');
         showNotification({
@@ -387,7 +392,6 @@ const Products: React.FC<ProductsProps> = ({
         });
         setIsAdding(false);
         setIsEditing(false);
-        onProductsChanged();
       } else {
         showNotification({
           message: isEditing 
@@ -414,7 +418,6 @@ const Products: React.FC<ProductsProps> = ({
     try {
       const updatedProduct = { ...product, status: newStatus };
       await UpdateProduct(updatedProduct);
-      onProductsChanged();
       showNotification({
         message: `סטטוס המוצר עודכן ל-${newStatus}`,
         type: 'success'
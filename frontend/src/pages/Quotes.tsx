@@ -0,0 +1,275 @@
+import React, { useState, useEffect } from 'react';
+import styled from 'styled-components';
+import {
+  GetQuotes,
+  UpdateQuote,
+  UpdateQuoteStatus,
+  ConvertQuoteToOrder,
+  ExportQuotePDF,
+  DeleteQuote
+} from '../../wailsjs/go/main/App';
+import { main } from '../../wailsjs/go/models';
+import { formatPrice } from '../utils/formatters';
+
+interface QuotesProps {
+  darkMode: boolean;
+  showNotification: (options: { message: string; type: 'success' | 'error' | 'info' | 'warning' }) => void;
+}
+
+// Quote status names shown in the UI
+const statusLabels: Record<string, string> = {
+  'Draft': 'טיוטה',
+  'Sent': 'נשלחה',
+  'Accepted': 'התקבלה',
+  'Expired': 'פגה',
+};
+
+// Statuses staff can choose; a quote is accepted by converting it into an order
+const selectableStatuses = ['Draft', 'Sent', 'Expired'];
+
+// Styled components
+const PageContainer = styled.div`
+  padding: 24px;
+  height: 100%;
+  overflow-y: auto;
+`;
+
+const Panel = styled.div<{ darkMode: boolean }>`
+  background: ${props => props.darkMode ? 'rgba(15, 23, 42, 0.3)' : 'rgba(248, 250, 252, 0.8)'};
+  border-radius: 8px;
+  padding: 24px;
+  margin-bottom: 24px;
+  box-shadow: 0 4px 6px ${props => props.darkMode ? 'rgba(0, 0, 0, 0.2)' : 'rgba(0, 0, 0, 0.1)'};
+  border: 1px solid ${props => props.darkMode ? 'rgba(255, 255, 255, 0.1)' : 'rgba(0, 0, 0, 0.05)'};
+`;
+
+const SectionTitle = styled.h2<{ darkMode: boolean }>`
+  font-size: 18px;
+  margin-top: 0;
+  margin-bottom: 16px;
+  color: ${props => props.darkMode ? '#fff' : '#1e293b'};
+`;
+
+const Hint = styled.p<{ darkMode: boolean }>`
+  margin-top: 0;
+  color: ${props => props.darkMode ? 'rgba(255, 255, 255, 0.6)' : 'rgba(0, 0, 0, 0.55)'};
+`;
+
+const Input = styled.input<{ darkMode: boolean }>`
+  background: ${props => props.darkMode ? 'rgba(15, 23, 42, 0.5)' : 'rgba(248, 250, 252, 0.8)'};
+  border: 1px solid ${props => props.darkMode ? 'rgba(255, 255, 255, 0.1)' : 'rgba(0, 0, 0, 0.1)'};
+  border-radius: 4px;
+  padding: 8px 12px;
+  color: ${props => props.darkMode ? '#fff' : '#1e293b'};
+`;
+
+const Select = styled.select<{ darkMode: boolean }>`
+  background: ${props => props.darkMode ? 'rgba(15, 23, 42, 0.5)' : 'rgba(248, 250, 252, 0.8)'};
+  border: 1px solid ${props => props.darkMode ? 'rgba(255, 255, 255, 0.1)' : 'rgba(0, 0, 0, 0.1)'};
+  border-radius: 4px;
+  padding: 8px 12px;
+  color: ${props => props.darkMode ? '#fff' : '#1e293b'};
+`;
+
+const Button = styled.button<{ variant?: 'primary' | 'danger' }>`
+  background: ${props => props.variant === 'danger'
+    ? 'linear-gradient(135deg, #f87171, #ef4444)'
+    : 'linear-gradient(135deg, #4ade80, #22c55e)'};
+  border: none;
+  border-radius: 4px;
+  padding: 8px 16px;
+  color: white;
+  cursor: pointer;
+
+  &:disabled {
+    opacity: 0.5;
+    cursor: default;
+  }
+`;
+
+const Table = styled.table<{ darkMode: boolean }>`
+  width: 100%;
+  border-collapse: collapse;
+  text-align: right;
+  color: ${props => props.darkMode ? '#fff' : '#1e293b'};
+
+  th, td {
+    padding: 10px 12px;
+    border-bottom: 1px solid ${props => props.darkMode ? 'rgba(255, 255, 255, 0.08)' : 'rgba(0, 0, 0, 0.06)'};
+  }
+
+  th {
+    font-weight: 600;
+    color: ${props => props.darkMode ? 'rgba(255, 255, 255, 0.7)' : 'rgba(0, 0, 0, 0.6)'};
+  }
+
+  small {
+    display: block;
+    opacity: 0.7;
+  }
+`;
+
+const Quotes: React.FC<QuotesProps> = ({ darkMode, showNotification }) => {
+  const [quotes, setQuotes] = useState<main.Quote[]>([]);
+
+  useEffect(() => {
+    loadQuotes();
+  }, []);
+
+  const loadQuotes = async () => {
+    try {
+      const quoteList = await GetQuotes();
+      setQuotes(quoteList || []);
+    } catch (error) {
+      console.error('Failed to load quotes:', error);
+      showNotification({ message: 'טעינת הצעות המחיר נכשלה', type: 'error' });
+    }
+  };
+
+  const handleStatusChange = async (quote: main.Quote, status: string) => {
+    if (await UpdateQuoteStatus(quote.id, status)) {
+      showNotification({ message: 'סטטוס ההצעה עודכן', type: 'success' });
+      loadQuotes();
+    } else {
+      showNotification({ message: 'עדכון הסטטוס נכשל', type: 'error' });
+    }
+  };
+
+  // Changing the expiry date saves the quote with its lines unchanged
+  const handleExpiryChange = async (quote: main.Quote, expiresOn: string) => {
+    if (!expiresOn) {
+      return;
+    }
+    const update = main.NewQuote.createFrom({
+      name: quote.name,
+      description: quote.description,
+      items: quote.items,
+      expiresOn
+    });
+    if (await UpdateQuote(quote.id, update)) {
+      showNotification({ message: 'תוקף ההצעה עודכן', type: 'success' });
+      loadQuotes();
+    } else {
+      showNotification({ message: 'עדכון התוקף נכשל', type: 'error' });
+    }
+  };
+
+  const handleConvert = async (quote: main.Quote) => {
+    if (!window.confirm(`ליצור הזמנה מהצעת מחיר #${quote.id} במחירים שהוצעו?`)) {
+      return;
+    }
+
+    const orderId = await ConvertQuoteToOrder(quote.id);
+    if (orderId) {
+      showNotification({ message: `הזמנה #${orderId} נוצרה מההצעה`, type: 'success' });
+      loadQuotes();
+    } else {
+      showNotification({ message: 'המרת ההצעה להזמנה נכשלה', type: 'error' });
+    }
+  };
+
+  const handleExport = async (quote: main.Quote) => {
+    if (await ExportQuotePDF(quote.id)) {
+      showNotification({ message: 'קובץ ה-PDF נשמר', type: 'success' });
+    }
+  };
+
+  const handleDelete = async (quote: main.Quote) => {
+    if (!window.confirm(`למחוק את הצעת המחיר #${quote.id}?`)) {
+      return;
+    }
+
+    if (await DeleteQuote(quote.id)) {
+      showNotification({ message: 'ההצעה נמחקה', type: 'success' });
+      loadQuotes();
+    } else {
+      showNotification({ message: 'מחיקת ההצעה נכשלה', type: 'error' });
+    }
+  };
+
+  return (
+    <PageContainer>
+      <Panel darkMode={darkMode}>
+        <SectionTitle darkMode={darkMode}>הצעות מחיר</SectionTitle>
+        <Hint darkMode={darkMode}>
+          הצעות מחיר נשמרות מעמוד "צור הזמנה". הצעה שהתקבלה הופכת להזמנה במחירים שהוצעו.
+        </Hint>
+        <Table darkMode={darkMode}>
+          <thead>
+            <tr>
+              <th>#</th>
+              <th>לקוח</th>
+              <th>תאריך</th>
+              <th>בתוקף עד</th>
+              <th>סה״כ</th>
+              <th>סטטוס</th>
+              <th></th>
+            </tr>
+          </thead>
+          <tbody>
+            {quotes.map(quote => (
+              <tr key={quote.id}>
+                <td>{quote.id}</td>
+                <td>
+                  {quote.name}
+                  <small>{quote.items.length} פריטים</small>
+                </td>
+                <td>{quote.date.split(' ')[0]}</td>
+                <td>
+                  {quote.status === 'Accepted' ? quote.expiresOn : (
+                    <Input
+                      darkMode={darkMode}
+                      type="date"
+                      value={quote.expiresOn}
+                      onChange={(e) => handleExpiryChange(quote, e.target.value)}
+                    />
+                  )}
+                </td>
+                <td>{formatPrice(quote.total)}</td>
+                <td>
+                  {quote.status === 'Accepted' ? (
+                    <>
+                      {statusLabels[quote.status]}
+                      <small>הזמנה #{quote.orderId}</small>
+                    </>
+                  ) : (
+                    <Select
+                      darkMode={darkMode}
+                      value={quote.status}
+                      onChange={(e) => handleStatusChange(quote, e.target.value)}
+                    >
+                      {selectableStatuses.map(status => (
+                        <option key={status} value={status}>{statusLabels[status]}</option>
+                      ))}
+                    </Select>
+                  )}
+                </td>
+                <td>
+                  <Button
+                    type="button"
+                    variant="primary"
+                    disabled={quote.status === 'Accepted' || quote.status === 'Expired'}
+                    onClick={() => handleConvert(quote)}
+                  >
+                    המר להזמנה
+                  </Button>{' '}
+                  <Button type="button" onClick={() => handleExport(quote)}>PDF</Button>{' '}
+                  <Button
+                    type="button"
+                    variant="danger"
+                    disabled={quote.status === 'Accepted'}
+                    onClick={() => handleDelete(quote)}
+                  >
+                    מחק
+                  </Button>
+                </td>
+              </tr>
+            ))}
+          </tbody>
+        </Table>
+      </Panel>
+    </PageContainer>
+  );
+};
+
+export default Quotes;
@@ -1,6 +1,20 @@
 import React, { useState, useEffect } from 'react';
 import styled from 'styled-components';
-import { GetStockItems, AddStockItem, UpdateStockItem, DeleteStockItem } from '../../wailsjs/go/main/App';
+import {
+  GetStockItems,
+  AddStockItem,
+  UpdateStockItem,
+  DeleteStockItem,
+  GetLocations,
+  SaveLocation,
+  DeleteLocation,
+  TransferStock,
+  GetStockMovements,
+  ReceiveStock,
+  GetExpiringLots,
+  GetLotShipments
+} from '../../wailsjs/go/main/App';
+import { onChange, applyChange, needsReload } from '../utils/changeEvents';
 import { main } from '../../wailsjs/go/models';
 
 // Import the base StockItem type
@@ -12,6 +26,16 @@ interface StockItemData extends BackendStockItem {
   unit: string;
 }
 
+// Stock movement kinds shown in the UI
+const movementLabels: Record<string, string> = {
+  'Adjustment': 'התאמה',
+  'Transfer': 'העברה',
+  'Shipment': 'משלוח',
+  'Return': 'החזרה',
+  'Receipt': 'קבלה',
+  'Count': 'ספירה',
+};
+
 interface StockProps {
   darkMode: boolean;
   showNotification: (options: { message: string; type: 'success' | 'error' | 'info' | 'warning' }) => void;
@@ -287,7 +311,7 @@ const FormGroup = styled.div`
     font-weight: 500;
   }
   
-  input, textarea {
+  input, textarea, select {
     width: 100%;
     padding: 8px 12px;
     border-radius: 4px;
@@ -306,6 +330,50 @@ const FormGroup = styled.div`
   }
 `;
 
+const LocationList = styled.ul<{ darkMode: boolean }>`
+  list-style: none;
+  padding: 0;
+  margin: 0 0 12px;
+  font-size: 0.85rem;
+  color: ${props => props.darkMode ? 'rgba(255, 255, 255, 0.7)' : 'rgba(0, 0, 0, 0.6)'};
+  
+  li {
+    display: flex;
+    justify-content: space-between;
+    padding: 2px 0;
+  }
+`;
+
+const LocationsBar = styled.div`
+  display: flex;
+  gap: 12px;
+  margin-bottom: 16px;
+`;
+
+const MovementsTable = styled.table<{ darkMode: boolean }>`
+  width: 100%;
+  border-collapse: collapse;
+  text-align: right;
+  font-size: 0.9rem;
+  color: ${props => props.darkMode ? '#fff' : '#1e293b'};
+  
+  th, td {
+    padding: 8px 10px;
+    border-bottom: 1px solid ${props => props.darkMode ? 'rgba(255, 255, 255, 0.08)' : 'rgba(0, 0, 0, 0.06)'};
+  }
+  
+  th {
+    font-weight: 600;
+    color: ${props => props.darkMode ? 'rgba(255, 255, 255, 0.7)' : 'rgba(0, 0, 0, 0.6)'};
+  }
+`;
+
+const FormHint = styled.small`
+  display: block;
+  margin-top: 4px;
+  opacity: 0.7;
+`;
+
 const ButtonGroup = styled.div`
   display: flex;
   justify-content: flex-end;
@@ -356,6 +424,13 @@ const ConfirmModalContent = styled(ModalContent)`
   }
 `;
 
+// Make sure minQuantity and unit are set on an item from the backend
+const toStockItemData = (item: any): StockItemData => ({
+  ...item,
+  minQuantity: item.minQuantity !== undefined ? item.minQuantity : 5,
+  unit: item.unit || 'יחידות'
+});
+
 // Main component
 const Stock: React.FC<StockProps> = ({ darkMode, showNotification }) => {
   const [stockItems, setStockItems] = useState<StockItemData[]>([]);
@@ -376,8 +451,21 @@ const Stock: React.FC<StockProps> = ({ darkMode, showNotification }) => {
     description: '',
     quantity: 0,
     minQuantity: 5,
-    unit: 'יחידות'
+    unit: 'יחידות',
+    sku: '',
+    barcode: '',
+    locations: [],
+    reserved: 0,
+    available: 0
   });
+  const [locations, setLocations] = useState<main.Location[]>([]);
+  const [movements, setMovements] = useState<main.StockMovement[]>([]);
+  const [newLocationName, setNewLocationName] = useState<string>('');
+  const [transfer, setTransfer] = useState<main.StockTransfer | null>(null);
+  const [receipt, setReceipt] = useState<{ stockItemId: string; receipt: main.StockReceipt } | null>(null);
+  const [expiringLots, setExpiringLots] = useState<main.Lot[]>([]);
+  const [tracedLot, setTracedLot] = useState<main.Lot | null>(null);
+  const [lotShipments, setLotShipments] = useState<main.LotShipment[]>([]);
   
   const loadStockItems = async () => {
     try {
@@ -386,11 +474,7 @@ const Stock: React.FC<StockProps> = ({ darkMode, showNotification }) => {
       
       if (Array.isArray(data)) {
         // Convert the data to ensure minQuantity and unit are set
-        const itemsWithDefaults = data.map(item => ({
-          ...item,
-          minQuantity: (item as any).minQuantity !== undefined ? (item as any).minQuantity : 5,
-          unit: (item as any).unit || 'יחידות'
-        })) as StockItemData[];
+        const itemsWithDefaults = data.map(toStockItemData);
         
         setStockItems(itemsWithDefaults);
         setFilteredItems(itemsWithDefaults);
@@ -425,11 +509,134 @@ const Stock: React.FC<StockProps> = ({ darkMode, showNotification }) => {
     setFilteredItems(filtered);
   }, [searchTerm, stockItems]);
   
-  // Load items on mount
+  // Locations, movements and lots change with every stock change, so they are reloaded whole
+  const loadLocationsAndMovements = async () => {
+    try {
+      const [locationList, movementList, lotList] = await Promise.all([
+        GetLocations(),
+        GetStockMovements(''),
+        GetExpiringLots(0)
+      ]);
+      setLocations(locationList || []);
+      setMovements(movementList || []);
+      setExpiringLots(lotList || []);
+    } catch (error) {
+      console.error('Error loading locations:', error);
+    }
+  };
+  
+  // Load items on mount, then patch them when an item changes here or in another window
   useEffect(() => {
     loadStockItems();
+    loadLocationsAndMovements();
+    
+    return onChange('stock', event => {
+      loadLocationsAndMovements();
+      if (needsReload(event)) {
+        loadStockItems();
+        return;
+      }
+      setStockItems(prev => applyChange(prev, event, toStockItemData));
+    });
   }, []);
   
+  const handleAddLocation = async () => {
+    if (!newLocationName.trim()) {
+      return;
+    }
+    const saved = await SaveLocation(main.Location.createFrom({ name: newLocationName }));
+    if (saved.id) {
+      setNewLocationName('');
+      loadLocationsAndMovements();
+    } else {
+      showNotification({ message: 'הוספת המיקום נכשלה, ייתכן שהשם כבר קיים', type: 'error' });
+    }
+  };
+  
+  const handleRenameLocation = async (location: main.Location) => {
+    const name = window.prompt('שם חדש למיקום', location.name);
+    if (!name || name === location.name) {
+      return;
+    }
+    const saved = await SaveLocation(main.Location.createFrom({ ...location, name }));
+    if (saved.id) {
+      loadLocationsAndMovements();
+      loadStockItems();
+    } else {
+      showNotification({ message: 'שינוי שם המיקום נכשל', type: 'error' });
+    }
+  };
+  
+  const handleDeleteLocation = async (location: main.Location) => {
+    if (!window.confirm(`למחוק את המיקום ${location.name}?`)) {
+      return;
+    }
+    if (await DeleteLocation(location.id)) {
+      loadLocationsAndMovements();
+    } else {
+      showNotification({ message: 'ניתן למחוק רק מיקום ריק שאינו המיקום הראשי', type: 'error' });
+    }
+  };
+  
+  const handleReceiptPrompt = (item: StockItemData) => {
+    setReceipt({
+      stockItemId: item.id,
+      receipt: main.StockReceipt.createFrom({ locationId: 'main', lotNumber: '', expiresOn: '', quantity: 1, note: '' })
+    });
+  };
+  
+  const updateReceipt = (changes: Partial<main.StockReceipt>) => {
+    if (!receipt) return;
+    setReceipt({ ...receipt, receipt: main.StockReceipt.createFrom({ ...receipt.receipt, ...changes }) });
+  };
+  
+  const handleReceiptSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    if (!receipt) return;
+    
+    if (await ReceiveStock(receipt.stockItemId, receipt.receipt)) {
+      showNotification({ message: `אצווה ${receipt.receipt.lotNumber} התקבלה למלאי`, type: 'success' });
+      setReceipt(null);
+    } else {
+      showNotification({ message: 'קבלת המלאי נכשלה, בדוק את מספר האצווה ותאריך התפוגה', type: 'error' });
+    }
+  };
+  
+  const handleTraceLot = async (lot: main.Lot) => {
+    try {
+      setLotShipments(await GetLotShipments(lot.id) || []);
+      setTracedLot(lot);
+    } catch (error) {
+      console.error('Error loading lot shipments:', error);
+    }
+  };
+  
+  const today = new Date().toISOString().slice(0, 10);
+  
+  const handleTransferPrompt = (item: StockItemData) => {
+    const from = item.locations && item.locations.length > 0 ? item.locations[0].locationId : '';
+    const to = locations.find(location => location.id !== from);
+    setTransfer(main.StockTransfer.createFrom({
+      stockItemId: item.id,
+      fromLocationId: from,
+      toLocationId: to ? to.id : '',
+      quantity: 1,
+      note: ''
+    }));
+  };
+  
+  const handleTransferSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    if (!transfer) return;
+    
+    if (await TransferStock(transfer)) {
+      showNotification({ message: 'המלאי הועבר', type: 'success' });
+      setTransfer(null);
+    } else {
+      showNotification({ message: 'ההעברה נכשלה, בדוק שיש מספיק מלאי במיקום המקור', type: 'error' });
+    }
+  };
+  
   const handleAddItem = () => {
     setIsEditing(false);
     setCurrentItem(null);
@@ -439,7 +646,12 @@ const Stock: React.FC<StockProps> = ({ darkMode, showNotification }) => {
       description: '',
       quantity: 0,
       minQuantity: 5,
-      unit: 'יחידות'
+      unit: 'יחידות',
+      sku: '',
+      barcode: '',
+      locations: [],
+      reserved: 0,
+      available: 0
     });
     setShowAddEditModal(true);
   };
@@ -475,7 +687,7 @@ const Stock: React.FC<StockProps> = ({ darkMode, showNotification }) => {
         await loadStockItems();
       } else {
         showNotification({
-          message: 'שגיאה במחיקת הפריט',
+          message: 'לא ניתן למחוק פריט שיש לו היסטוריית מלאי או וריאנטים מקושרים',
           type: 'error'
         });
       }
@@ -535,7 +747,10 @@ const Stock: React.FC<StockProps> = ({ darkMode, showNotification }) => {
           id: currentItem.id,
           name: formData.name,
           description: formData.description,
-          quantity: formData.quantity
+          quantity: formData.quantity,
+          sku: currentItem.sku || '',
+          barcode: currentItem.barcode || '',
+          locations: currentItem.locations || []
         };
         
         // Save the extra fields we need in a separate storage if needed
@@ -562,7 +777,10 @@ const Stock: React.FC<StockProps> = ({ darkMode, showNotification }) => {
           id: formData.id || '',
           name: formData.name,
           description: formData.description,
-          quantity: formData.quantity
+          quantity: formData.quantity,
+          sku: '',
+          barcode: '',
+          locations: []
         };
         
         // Save the extra fields we need in a separate storage if needed
@@ -611,6 +829,37 @@ const Stock: React.FC<StockProps> = ({ darkMode, showNotification }) => {
         </AddButton>
       </PageHeader>
       
+      <StockPanel darkMode={darkMode}>
+        <SectionTitle darkMode={darkMode}>מיקומים</SectionTitle>
+        <LocationList darkMode={darkMode}>
+          {locations.map(location => (
+            <li key={location.id}>
+              <span>{location.name} · {location.units} יחידות</span>
+              <ActionButtons>
+                <EditButton darkMode={darkMode} variant="secondary" onClick={() => handleRenameLocation(location)}>
+                  שינוי שם
+                </EditButton>
+                <DeleteButton darkMode={darkMode} variant="danger" onClick={() => handleDeleteLocation(location)}>
+                  מחיקה
+                </DeleteButton>
+              </ActionButtons>
+            </li>
+          ))}
+        </LocationList>
+        <LocationsBar>
+          <SearchBox
+            type="text"
+            placeholder="מיקום חדש, למשל חממה"
+            value={newLocationName}
+            onChange={(e) => setNewLocationName(e.target.value)}
+            darkMode={darkMode}
+          />
+          <AddButton onClick={handleAddLocation} darkMode={darkMode} disabled={!newLocationName.trim()}>
+            הוסף מיקום
+          </AddButton>
+        </LocationsBar>
+      </StockPanel>
+      
       <StockPanel darkMode={darkMode}>
         <SectionTitle darkMode={darkMode}>פריטי מלאי</SectionTitle>
         
@@ -637,6 +886,28 @@ const Stock: React.FC<StockProps> = ({ darkMode, showNotification }) => {
                     <OutOfStockLabel>אזל מהמלאי</OutOfStockLabel>
                   )}
                 </QuantityIndicator>
+                {item.reserved > 0 && (
+                  <LocationList darkMode={darkMode}>
+                    <li>
+                      <span>שמור להזמנות פתוחות</span>
+                      <span>{item.reserved}</span>
+                    </li>
+                    <li>
+                      <span>זמין</span>
+                      <span>{item.available}</span>
+                    </li>
+                  </LocationList>
+                )}
+                {item.locations && item.locations.length > 0 && (
+                  <LocationList darkMode={darkMode}>
+                    {item.locations.map(level => (
+                      <li key={level.locationId}>
+                        <span>{level.locationName}</span>
+                        <span>{level.quantity}</span>
+                      </li>
+                    ))}
+                  </LocationList>
+                )}
                 <ActionButtons>
                   <EditButton 
                     darkMode={darkMode} 
@@ -645,6 +916,21 @@ const Stock: React.FC<StockProps> = ({ darkMode, showNotification }) => {
                   >
                     עריכה
                   </EditButton>
+                  <EditButton
+                    darkMode={darkMode}
+                    variant="secondary"
+                    onClick={() => handleReceiptPrompt(item)}
+                  >
+                    קבלה
+                  </EditButton>
+                  <EditButton
+                    darkMode={darkMode}
+                    variant="secondary"
+                    disabled={locations.length < 2 || item.quantity <= 0}
+                    onClick={() => handleTransferPrompt(item)}
+                  >
+                    העברה
+                  </EditButton>
                   <DeleteButton 
                     darkMode={darkMode} 
                     variant="danger"
@@ -658,6 +944,255 @@ const Stock: React.FC<StockProps> = ({ darkMode, showNotification }) => {
           </StockItemsGrid>
         )}
       </StockPanel>
+      
+      <StockPanel darkMode={darkMode}>
+        <SectionTitle darkMode={darkMode}>אצוות שפג תוקפן או יפוג בחודש הקרוב</SectionTitle>
+        {expiringLots.length === 0 ? (
+          <NoItemsMessage darkMode={darkMode}>אין אצוות שתוקפן עומד לפוג</NoItemsMessage>
+        ) : (
+          <MovementsTable darkMode={darkMode}>
+            <thead>
+              <tr>
+                <th>פריט</th>
+                <th>אצווה</th>
+                <th>תוקף</th>
+                <th>כמות</th>
+                <th>מיקומים</th>
+                <th></th>
+              </tr>
+            </thead>
+            <tbody>
+              {expiringLots.map(lot => (
+                <tr key={lot.id}>
+                  <td>{lot.stockItemName}</td>
+                  <td>{lot.lotNumber}</td>
+                  <td style={{ color: lot.expiresOn < today ? '#ef4444' : undefined }}>
+                    {lot.expiresOn}{lot.expiresOn < today && ' (פג תוקף)'}
+                  </td>
+                  <td>{lot.quantity}</td>
+                  <td>{(lot.locations || []).map(level => `${level.locationName} ${level.quantity}`).join(', ')}</td>
+                  <td>
+                    <EditButton darkMode={darkMode} variant="secondary" onClick={() => handleTraceLot(lot)}>
+                      לאן נשלחה
+                    </EditButton>
+                  </td>
+                </tr>
+              ))}
+            </tbody>
+          </MovementsTable>
+        )}
+      </StockPanel>
+      
+      <StockPanel darkMode={darkMode}>
+        <SectionTitle darkMode={darkMode}>תנועות מלאי אחרונות</SectionTitle>
+        {movements.length === 0 ? (
+          <NoItemsMessage darkMode={darkMode}>אין תנועות מלאי</NoItemsMessage>
+        ) : (
+          <MovementsTable darkMode={darkMode}>
+            <thead>
+              <tr>
+                <th>תאריך</th>
+                <th>פריט</th>
+                <th>סוג</th>
+                <th>ממיקום</th>
+                <th>למיקום</th>
+                <th>כמות</th>
+                <th>אצווה</th>
+                <th>אסמכתא</th>
+                <th>בוצע ע״י</th>
+              </tr>
+            </thead>
+            <tbody>
+              {movements.map(movement => (
+                <tr key={movement.id}>
+                  <td>{movement.movedAt}</td>
+                  <td>{movement.stockItemName}</td>
+                  <td>{movementLabels[movement.kind] || movement.kind}</td>
+                  <td>{movement.fromLocation || '—'}</td>
+                  <td>{movement.toLocation || '—'}</td>
+                  <td>{movement.quantity}</td>
+                  <td>{movement.lotNumber || '—'}</td>
+                  <td>{movement.reference || movement.note}</td>
+                  <td>{movement.movedBy}</td>
+                </tr>
+              ))}
+            </tbody>
+          </MovementsTable>
+        )}
+      </StockPanel>
+      
+      {/* Receipt Modal */}
+      {receipt && (
+        <Modal darkMode={darkMode}>
+          <ModalContent darkMode={darkMode}>
+            <ModalHeader>
+              <h2>קבלת מלאי</h2>
+              <CloseButton onClick={() => setReceipt(null)}>×</CloseButton>
+            </ModalHeader>
+            <form onSubmit={handleReceiptSubmit}>
+              <FormGroup>
+                <label htmlFor="lotNumber">מספר אצווה</label>
+                <input
+                  type="text"
+                  id="lotNumber"
+                  value={receipt.receipt.lotNumber}
+                  onChange={(e) => updateReceipt({ lotNumber: e.target.value })}
+                  required
+                />
+              </FormGroup>
+              <FormGroup>
+                <label htmlFor="expiresOn">תאריך תפוגה</label>
+                <input
+                  type="date"
+                  id="expiresOn"
+                  value={receipt.receipt.expiresOn}
+                  onChange={(e) => updateReceipt({ expiresOn: e.target.value })}
+                />
+                <FormHint>השאר ריק למוצרים ללא תפוגה</FormHint>
+              </FormGroup>
+              <FormGroup>
+                <label htmlFor="receiptQuantity">כמות</label>
+                <input
+                  type="number"
+                  id="receiptQuantity"
+                  value={receipt.receipt.quantity}
+                  onChange={(e) => updateReceipt({ quantity: parseFloat(e.target.value) || 0 })}
+                  min="0"
+                />
+              </FormGroup>
+              <FormGroup>
+                <label htmlFor="receiptLocation">מיקום</label>
+                <select
+                  id="receiptLocation"
+                  value={receipt.receipt.locationId}
+                  onChange={(e) => updateReceipt({ locationId: e.target.value })}
+                >
+                  {locations.map(location => (
+                    <option key={location.id} value={location.id}>{location.name}</option>
+                  ))}
+                </select>
+              </FormGroup>
+              <ButtonGroup>
+                <SaveButton
+                  type="submit"
+                  darkMode={darkMode}
+                  disabled={!receipt.receipt.lotNumber.trim() || !(receipt.receipt.quantity > 0)}
+                >
+                  קבל למלאי
+                </SaveButton>
+                <CancelButton type="button" onClick={() => setReceipt(null)} darkMode={darkMode}>
+                  ביטול
+                </CancelButton>
+              </ButtonGroup>
+            </form>
+          </ModalContent>
+        </Modal>
+      )}
+      
+      {/* Lot Trace Modal */}
+      {tracedLot && (
+        <Modal darkMode={darkMode}>
+          <ModalContent darkMode={darkMode}>
+            <ModalHeader>
+              <h2>אצווה {tracedLot.lotNumber} · {tracedLot.stockItemName}</h2>
+              <CloseButton onClick={() => setTracedLot(null)}>×</CloseButton>
+            </ModalHeader>
+            {lotShipments.length === 0 ? (
+              <NoItemsMessage darkMode={darkMode}>האצווה עדיין לא נשלחה ללקוחות</NoItemsMessage>
+            ) : (
+              <MovementsTable darkMode={darkMode}>
+                <thead>
+                  <tr>
+                    <th>תאריך</th>
+                    <th>הזמנה</th>
+                    <th>לקוח</th>
+                    <th>פריט</th>
+                    <th>כמות</th>
+                  </tr>
+                </thead>
+                <tbody>
+                  {lotShipments.map(shipment => (
+                    <tr key={`${shipment.shipmentId}-${shipment.orderItemId}`}>
+                      <td>{shipment.shippedAt}</td>
+                      <td>#{shipment.orderId}</td>
+                      <td>{shipment.orderName}</td>
+                      <td>{shipment.productName}</td>
+                      <td>{shipment.quantity}</td>
+                    </tr>
+                  ))}
+                </tbody>
+              </MovementsTable>
+            )}
+          </ModalContent>
+        </Modal>
+      )}
+      
+      {/* Transfer Modal */}
+      {transfer && (
+        <Modal darkMode={darkMode}>
+          <ModalContent darkMode={darkMode}>
+            <ModalHeader>
+              <h2>העברת מלאי</h2>
+              <CloseButton onClick={() => setTransfer(null)}>×</CloseButton>
+            </ModalHeader>
+            <form onSubmit={handleTransferSubmit}>
+              <FormGroup>
+                <label htmlFor="fromLocation">ממיקום</label>
+                <select
+                  id="fromLocation"
+                  value={transfer.fromLocationId}
+                  onChange={(e) => setTransfer(main.StockTransfer.createFrom({ ...transfer, fromLocationId: e.target.value }))}
+                >
+                  {(stockItems.find(item => item.id === transfer.stockItemId)?.locations || []).map(level => (
+                    <option key={level.locationId} value={level.locationId}>
+                      {level.locationName} ({level.quantity})
+                    </option>
+                  ))}
+                </select>
+              </FormGroup>
+              <FormGroup>
+                <label htmlFor="toLocation">למיקום</label>
+                <select
+                  id="toLocation"
+                  value={transfer.toLocationId}
+                  onChange={(e) => setTransfer(main.StockTransfer.createFrom({ ...transfer, toLocationId: e.target.value }))}
+                >
+                  {locations.filter(location => location.id !== transfer.fromLocationId).map(location => (
+                    <option key={location.id} value={location.id}>{location.name}</option>
+                  ))}
+                </select>
+              </FormGroup>
+              <FormGroup>
+                <label htmlFor="transferQuantity">כמות</label>
+                <input
+                  type="number"
+                  id="transferQuantity"
+                  value={transfer.quantity}
+                  onChange={(e) => setTransfer(main.StockTransfer.createFrom({ ...transfer, quantity: parseFloat(e.target.value) || 0 }))}
+                  min="0"
+                />
+              </FormGroup>
+              <FormGroup>
+                <label htmlFor="transferNote">הערה</label>
+                <input
+                  type="text"
+                  id="transferNote"
+                  value={transfer.note}
+                  onChange={(e) => setTransfer(main.StockTransfer.createFrom({ ...transfer, note: e.target.value }))}
+                />
+              </FormGroup>
+              <ButtonGroup>
+                <SaveButton type="submit" darkMode={darkMode} disabled={!(transfer.quantity > 0) || !transfer.toLocationId}>
+                  העבר
+                </SaveButton>
+                <CancelButton type="button" onClick={() => setTransfer(null)} darkMode={darkMode}>
+                  ביטול
+                </CancelButton>
+              </ButtonGroup>
+            </form>
+          </ModalContent>
+        </Modal>
+      )}
 
       {/* Add/Edit Modal */}
       {showAddEditModal && (
@@ -698,6 +1233,7 @@ const Stock: React.FC<StockProps> = ({ darkMode, showNotification }) => {
                   onChange={handleFormChange}
                   min="0"
                 />
+                {isEditing && <FormHint>שינוי הכמות נרשם כהתאמה במיקום הראשי</FormHint>}
               </FormGroup>
               <FormGroup>
                 <label htmlFor="minQuantity">כמות מינימלית להתראה</label>
@@ -0,0 +1,358 @@
+import React, { useState, useEffect } from 'react';
+import styled from 'styled-components';
+import {
+  GetStockCounts,
+  CreateStockCount,
+  RecordStockCounts,
+  PostStockCount,
+  DeleteStockCount,
+  GetLocations
+} from '../../wailsjs/go/main/App';
+import { main } from '../../wailsjs/go/models';
+
+interface StockCountsProps {
+  darkMode: boolean;
+  showNotification: (options: { message: string; type: 'success' | 'error' | 'info' | 'warning' }) => void;
+}
+
+// Stock count status names shown in the UI
+const statusLabels: Record<string, string> = {
+  'Open': 'בספירה',
+  'Posted': 'נרשמה',
+};
+
+// Styled components
+const PageContainer = styled.div`
+  padding: 24px;
+  height: 100%;
+  overflow-y: auto;
+`;
+
+const Panel = styled.div<{ darkMode: boolean }>`
+  background: ${props => props.darkMode ? 'rgba(15, 23, 42, 0.3)' : 'rgba(248, 250, 252, 0.8)'};
+  border-radius: 8px;
+  padding: 24px;
+  margin-bottom: 24px;
+  box-shadow: 0 4px 6px ${props => props.darkMode ? 'rgba(0, 0, 0, 0.2)' : 'rgba(0, 0, 0, 0.1)'};
+  border: 1px solid ${props => props.darkMode ? 'rgba(255, 255, 255, 0.1)' : 'rgba(0, 0, 0, 0.05)'};
+`;
+
+const SectionTitle = styled.h2<{ darkMode: boolean }>`
+  font-size: 18px;
+  margin-top: 0;
+  margin-bottom: 16px;
+  color: ${props => props.darkMode ? '#fff' : '#1e293b'};
+`;
+
+const Hint = styled.p<{ darkMode: boolean }>`
+  margin-top: 0;
+  color: ${props => props.darkMode ? 'rgba(255, 255, 255, 0.6)' : 'rgba(0, 0, 0, 0.55)'};
+`;
+
+const Row = styled.div`
+  display: flex;
+  flex-wrap: wrap;
+  gap: 12px;
+  align-items: center;
+  margin-bottom: 16px;
+`;
+
+const Input = styled.input<{ darkMode: boolean }>`
+  background: ${props => props.darkMode ? 'rgba(15, 23, 42, 0.5)' : 'rgba(248, 250, 252, 0.8)'};
+  border: 1px solid ${props => props.darkMode ? 'rgba(255, 255, 255, 0.1)' : 'rgba(0, 0, 0, 0.1)'};
+  border-radius: 4px;
+  padding: 8px 12px;
+  color: ${props => props.darkMode ? '#fff' : '#1e293b'};
+`;
+
+const Select = styled.select<{ darkMode: boolean }>`
+  background: ${props => props.darkMode ? 'rgba(15, 23, 42, 0.5)' : 'rgba(248, 250, 252, 0.8)'};
+  border: 1px solid ${props => props.darkMode ? 'rgba(255, 255, 255, 0.1)' : 'rgba(0, 0, 0, 0.1)'};
+  border-radius: 4px;
+  padding: 8px 12px;
+  color: ${props => props.darkMode ? '#fff' : '#1e293b'};
+`;
+
+const Button = styled.button<{ variant?: 'primary' | 'danger' }>`
+  background: ${props => props.variant === 'danger'
+    ? 'linear-gradient(135deg, #f87171, #ef4444)'
+    : 'linear-gradient(135deg, #4ade80, #22c55e)'};
+  border: none;
+  border-radius: 4px;
+  padding: 8px 16px;
+  color: white;
+  cursor: pointer;
+
+  &:disabled {
+    opacity: 0.5;
+    cursor: default;
+  }
+`;
+
+const Table = styled.table<{ darkMode: boolean }>`
+  width: 100%;
+  border-collapse: collapse;
+  text-align: right;
+  color: ${props => props.darkMode ? '#fff' : '#1e293b'};
+
+  th, td {
+    padding: 10px 12px;
+    border-bottom: 1px solid ${props => props.darkMode ? 'rgba(255, 255, 255, 0.08)' : 'rgba(0, 0, 0, 0.06)'};
+  }
+
+  th {
+    font-weight: 600;
+    color: ${props => props.darkMode ? 'rgba(255, 255, 255, 0.7)' : 'rgba(0, 0, 0, 0.6)'};
+  }
+
+  tr.selected td {
+    background: ${props => props.darkMode ? 'rgba(74, 222, 128, 0.08)' : 'rgba(34, 197, 94, 0.08)'};
+  }
+
+  small {
+    display: block;
+    opacity: 0.7;
+  }
+`;
+
+const Variance = styled.span<{ value: number }>`
+  font-weight: 600;
+  color: ${props => props.value > 0 ? '#22c55e' : props.value < 0 ? '#ef4444' : 'inherit'};
+`;
+
+// formatVariance shows a variance with its sign
+const formatVariance = (value: number) => (value > 0 ? `+${value}` : `${value}`);
+
+const StockCounts: React.FC<StockCountsProps> = ({ darkMode, showNotification }) => {
+  const [counts, setCounts] = useState<main.StockCount[]>([]);
+  const [locations, setLocations] = useState<main.Location[]>([]);
+  const [selectedId, setSelectedId] = useState<string>('');
+  const [name, setName] = useState<string>('');
+  const [locationId, setLocationId] = useState<string>('main');
+  // Counted quantities typed in but not saved yet, by stock item
+  const [drafts, setDrafts] = useState<Record<string, string>>({});
+
+  useEffect(() => {
+    loadCounts();
+    GetLocations().then(list => setLocations(list || [])).catch(error => {
+      console.error('Failed to load locations:', error);
+    });
+  }, []);
+
+  const loadCounts = async () => {
+    try {
+      const countList = await GetStockCounts();
+      setCounts(countList || []);
+    } catch (error) {
+      console.error('Failed to load stock counts:', error);
+      showNotification({ message: 'טעינת ספירות המלאי נכשלה', type: 'error' });
+    }
+  };
+
+  const selected = counts.find(count => count.id === selectedId);
+
+  const handleSelect = (count: main.StockCount) => {
+    setSelectedId(count.id);
+    setDrafts({});
+  };
+
+  const handleStart = async () => {
+    const id = await CreateStockCount(main.NewStockCount.createFrom({ name, locationId, stockItemIds: [] }));
+    if (id) {
+      showNotification({ message: `ספירה #${id} נפתחה`, type: 'success' });
+      setName('');
+      setSelectedId(id);
+      setDrafts({});
+      loadCounts();
+    } else {
+      showNotification({ message: 'פתיחת הספירה נכשלה, ייתכן שפריטים כבר נספרים במיקום זה', type: 'error' });
+    }
+  };
+
+  const handleSaveCounts = async () => {
+    if (!selected) return;
+
+    const entries = Object.entries(drafts)
+      .filter(([, value]) => value !== '')
+      .map(([stockItemId, value]) => main.StockCountEntry.createFrom({ stockItemId, counted: parseFloat(value) || 0 }));
+    if (entries.length === 0) {
+      showNotification({ message: 'לא הוזנו כמויות', type: 'warning' });
+      return;
+    }
+
+    if (await RecordStockCounts(selected.id, entries)) {
+      showNotification({ message: 'הכמויות שנספרו נשמרו', type: 'success' });
+      setDrafts({});
+      loadCounts();
+    } else {
+      showNotification({ message: 'שמירת הכמויות נכשלה', type: 'error' });
+    }
+  };
+
+  const handlePost = async () => {
+    if (!selected) return;
+    if (Object.values(drafts).some(value => value !== '')) {
+      showNotification({ message: 'יש לשמור את הכמויות שהוזנו לפני הרישום', type: 'warning' });
+      return;
+    }
+    if (!window.confirm(`לרשום את ההפרשים של ספירה #${selected.id} כהתאמות מלאי? לא ניתן לשנות ספירה שנרשמה.`)) {
+      return;
+    }
+
+    if (await PostStockCount(selected.id)) {
+      showNotification({ message: 'הספירה נרשמה והמלאי עודכן', type: 'success' });
+      loadCounts();
+    } else {
+      showNotification({ message: 'רישום הספירה נכשל. רק מנהל יכול לרשום ספירה', type: 'error' });
+    }
+  };
+
+  const handleDelete = async () => {
+    if (!selected || !window.confirm(`לבטל את ספירה #${selected.id}? הכמויות שנספרו יימחקו.`)) {
+      return;
+    }
+
+    if (await DeleteStockCount(selected.id)) {
+      showNotification({ message: 'הספירה בוטלה', type: 'success' });
+      setSelectedId('');
+      loadCounts();
+    } else {
+      showNotification({ message: 'ביטול הספירה נכשל', type: 'error' });
+    }
+  };
+
+  return (
+    <PageContainer>
+      <Panel darkMode={darkMode}>
+        <SectionTitle darkMode={darkMode}>ספירת מלאי</SectionTitle>
+        <Hint darkMode={darkMode}>
+          הכמות הצפויה של כל פריט נלקחת מהמלאי במיקום ברגע שהוא נספר. ניתן להזין כמויות במשך כמה ימים, לבדוק את ההפרשים
+          ולרשום את כולם יחד כהתאמות מלאי.
+        </Hint>
+        <Row>
+          <Input
+            darkMode={darkMode}
+            type="text"
+            placeholder="שם הספירה, למשל ספירת אביב"
+            value={name}
+            onChange={(e) => setName(e.target.value)}
+          />
+          <Select darkMode={darkMode} value={locationId} onChange={(e) => setLocationId(e.target.value)}>
+            {locations.map(location => (
+              <option key={location.id} value={location.id}>{location.name}</option>
+            ))}
+          </Select>
+          <Button type="button" variant="primary" disabled={!name.trim()} onClick={handleStart}>
+            פתח ספירה
+          </Button>
+        </Row>
+        <Table darkMode={darkMode}>
+          <thead>
+            <tr>
+              <th>#</th>
+              <th>שם</th>
+              <th>מיקום</th>
+              <th>נפתחה</th>
+              <th>נספרו</th>
+              <th>הפרשים</th>
+              <th>סטטוס</th>
+            </tr>
+          </thead>
+          <tbody>
+            {counts.map(count => (
+              <tr
+                key={count.id}
+                className={count.id === selectedId ? 'selected' : undefined}
+                style={{ cursor: 'pointer' }}
+                onClick={() => handleSelect(count)}
+              >
+                <td>{count.id}</td>
+                <td>{count.name}</td>
+                <td>{count.location}</td>
+                <td>
+                  {count.startedAt.split(' ')[0]}
+                  {count.startedBy && <small>{count.startedBy}</small>}
+                </td>
+                <td>{count.counted} / {count.lines.length}</td>
+                <td>{count.variances}</td>
+                <td>
+                  {statusLabels[count.status] || count.status}
+                  {count.postedAt && <small>{count.postedAt.split(' ')[0]} · {count.postedBy}</small>}
+                </td>
+              </tr>
+            ))}
+          </tbody>
+        </Table>
+      </Panel>
+
+      {selected && (
+        <Panel darkMode={darkMode}>
+          <SectionTitle darkMode={darkMode}>
+            ספירה #{selected.id} · {selected.name} · {selected.location}
+          </SectionTitle>
+          <Hint darkMode={darkMode}>
+            נספרו {selected.counted} מתוך {selected.lines.length} פריטים · {selected.variances} הפרשים ·
+            עודף {selected.unitsOver} · חוסר {selected.unitsShort}
+          </Hint>
+          <Table darkMode={darkMode}>
+            <thead>
+              <tr>
+                <th>פריט</th>
+                <th>צפוי</th>
+                <th>נספר</th>
+                <th>הפרש</th>
+                <th>נספר ע״י</th>
+              </tr>
+            </thead>
+            <tbody>
+              {selected.lines.map(line => (
+                <tr key={line.stockItemId}>
+                  <td>
+                    {line.stockItemName}
+                    {line.sku && <small>{line.sku}</small>}
+                  </td>
+                  <td>{line.expected}</td>
+                  <td>
+                    {selected.status === 'Open' ? (
+                      <Input
+                        darkMode={darkMode}
+                        type="number"
+                        min={0}
+                        style={{ width: '100px' }}
+                        placeholder={line.counted === undefined || line.counted === null ? '—' : String(line.counted)}
+                        value={drafts[line.stockItemId] ?? ''}
+                        onChange={(e) => setDrafts({ ...drafts, [line.stockItemId]: e.target.value })}
+                      />
+                    ) : (
+                      line.counted ?? '—'
+                    )}
+                  </td>
+                  <td>
+                    {line.counted === undefined || line.counted === null ? '—' : (
+                      <Variance value={line.variance}>{formatVariance(line.variance)}</Variance>
+                    )}
+                  </td>
+                  <td>
+                    {line.countedBy}
+                    {line.countedAt && <small>{line.countedAt}</small>}
+                  </td>
+                </tr>
+              ))}
+            </tbody>
+          </Table>
+          {selected.status === 'Open' && (
+            <Row style={{ marginTop: '16px', marginBottom: 0 }}>
+              <Button type="button" onClick={handleSaveCounts}>שמור כמויות</Button>
+              <Button type="button" variant="primary" disabled={selected.counted === 0} onClick={handlePost}>
+                רשום הפרשים
+              </Button>
+              <Button type="button" variant="danger" onClick={handleDelete}>בטל ספירה</Button>
+            </Row>
+          )}
+        </Panel>
+      )}
+    </PageContainer>
+  );
+};
+
+export default StockCounts;
@@ -0,0 +1,250 @@
+import React, { useState, useEffect } from 'react';
+import styled from 'styled-components';
+import { GetUsers, AddUser, UpdateUser, DeleteUser } from '../../wailsjs/go/main/App';
+import { main } from '../../wailsjs/go/models';
+
+interface UsersProps {
+  darkMode: boolean;
+  currentUser: main.User;
+  showNotification: (options: { message: string; type: 'success' | 'error' | 'info' | 'warning' }) => void;
+}
+
+// Role names shown in the UI
+const roleLabels: Record<string, string> = {
+  'admin': 'מנהל',
+  'staff': 'עובד',
+  'read-only': 'צפייה בלבד',
+};
+
+// Styled components
+const PageContainer = styled.div`
+  padding: 24px;
+  height: 100%;
+  overflow-y: auto;
+`;
+
+const Panel = styled.div<{ darkMode: boolean }>`
+  background: ${props => props.darkMode ? 'rgba(15, 23, 42, 0.3)' : 'rgba(248, 250, 252, 0.8)'};
+  border-radius: 8px;
+  padding: 24px;
+  margin-bottom: 24px;
+  box-shadow: 0 4px 6px ${props => props.darkMode ? 'rgba(0, 0, 0, 0.2)' : 'rgba(0, 0, 0, 0.1)'};
+  border: 1px solid ${props => props.darkMode ? 'rgba(255, 255, 255, 0.1)' : 'rgba(0, 0, 0, 0.05)'};
+`;
+
+const SectionTitle = styled.h2<{ darkMode: boolean }>`
+  font-size: 18px;
+  margin-top: 0;
+  margin-bottom: 16px;
+  color: ${props => props.darkMode ? '#fff' : '#1e293b'};
+`;
+
+const Form = styled.form`
+  display: flex;
+  gap: 12px;
+  flex-wrap: wrap;
+  align-items: center;
+`;
+
+const Input = styled.input<{ darkMode: boolean }>`
+  background: ${props => props.darkMode ? 'rgba(15, 23, 42, 0.5)' : 'rgba(248, 250, 252, 0.8)'};
+  border: 1px solid ${props => props.darkMode ? 'rgba(255, 255, 255, 0.1)' : 'rgba(0, 0, 0, 0.1)'};
+  border-radius: 4px;
+  padding: 8px 12px;
+  color: ${props => props.darkMode ? '#fff' : '#1e293b'};
+`;
+
+const Select = styled.select<{ darkMode: boolean }>`
+  background: ${props => props.darkMode ? 'rgba(15, 23, 42, 0.5)' : 'rgba(248, 250, 252, 0.8)'};
+  border: 1px solid ${props => props.darkMode ? 'rgba(255, 255, 255, 0.1)' : 'rgba(0, 0, 0, 0.1)'};
+  border-radius: 4px;
+  padding: 8px 12px;
+  color: ${props => props.darkMode ? '#fff' : '#1e293b'};
+`;
+
+const Button = styled.button<{ variant?: 'primary' | 'danger' }>`
+  background: ${props => props.variant === 'danger'
+    ? 'linear-gradient(135deg, #f87171, #ef4444)'
+    : 'linear-gradient(135deg, #4ade80, #22c55e)'};
+  border: none;
+  border-radius: 4px;
+  padding: 8px 16px;
+  color: white;
+  cursor: pointer;
+
+  &:disabled {
+    opacity: 0.5;
+    cursor: default;
+  }
+`;
+
+const Table = styled.table<{ darkMode: boolean }>`
+  width: 100%;
+  border-collapse: collapse;
+  text-align: right;
+
+  th, td {
+    padding: 10px 12px;
+    border-bottom: 1px solid ${props => props.darkMode ? 'rgba(255, 255, 255, 0.08)' : 'rgba(0, 0, 0, 0.06)'};
+  }
+
+  th {
+    font-weight: 600;
+    color: ${props => props.darkMode ? 'rgba(255, 255, 255, 0.7)' : 'rgba(0, 0, 0, 0.6)'};
+  }
+`;
+
+const Users: React.FC<UsersProps> = ({ darkMode, currentUser, showNotification }) => {
+  const [users, setUsers] = useState<main.User[]>([]);
+  const [username, setUsername] = useState('');
+  const [password, setPassword] = useState('');
+  const [role, setRole] = useState('staff');
+
+  useEffect(() => {
+    loadUsers();
+  }, []);
+
+  const loadUsers = async () => {
+    try {
+      const userList = await GetUsers();
+      setUsers(userList || []);
+    } catch (error) {
+      console.error('Failed to load users:', error);
+      showNotification({ message: 'טעינת המשתמשים נכשלה', type: 'error' });
+    }
+  };
+
+  const handleAddUser = async (e: React.FormEvent) => {
+    e.preventDefault();
+    if (password.length < 8) {
+      showNotification({ message: 'הסיסמה חייבת להכיל לפחות 8 תווים', type: 'warning' });
+      return;
+    }
+
+    const user = await AddUser(username, password, role);
+    if (user && user.id) {
+      showNotification({ message: `המשתמש ${user.username} נוצר בהצלחה`, type: 'success' });
+      setUsername('');
+      setPassword('');
+      loadUsers();
+    } else {
+      showNotification({ message: 'יצירת המשתמש נכשלה. ייתכן ששם המשתמש תפוס', type: 'error' });
+    }
+  };
+
+  const handleRoleChange = async (user: main.User, newRole: string) => {
+    if (await UpdateUser(user.id, newRole, '')) {
+      showNotification({ message: 'ההרשאה עודכנה', type: 'success' });
+      loadUsers();
+    } else {
+      showNotification({ message: 'עדכון ההרשאה נכשל. נדרש לפחות מנהל אחד', type: 'error' });
+    }
+  };
+
+  const handleResetPassword = async (user: main.User) => {
+    const newPassword = window.prompt(`סיסמה חדשה עבור ${user.username}:`);
+    if (!newPassword) {
+      return;
+    }
+    if (newPassword.length < 8) {
+      showNotification({ message: 'הסיסמה חייבת להכיל לפחות 8 תווים', type: 'warning' });
+      return;
+    }
+
+    if (await UpdateUser(user.id, user.role, newPassword)) {
+      showNotification({ message: 'הסיסמה עודכנה', type: 'success' });
+    } else {
+      showNotification({ message: 'עדכון הסיסמה נכשל', type: 'error' });
+    }
+  };
+
+  const handleDeleteUser = async (user: main.User) => {
+    if (!window.confirm(`למחוק את המשתמש ${user.username}?`)) {
+      return;
+    }
+
+    if (await DeleteUser(user.id)) {
+      showNotification({ message: 'המשתמש נמחק', type: 'success' });
+      loadUsers();
+    } else {
+      showNotification({ message: 'מחיקת המשתמש נכשלה', type: 'error' });
+    }
+  };
+
+  return (
+    <PageContainer>
+      <Panel darkMode={darkMode}>
+        <SectionTitle darkMode={darkMode}>הוספת משתמש</SectionTitle>
+        <Form onSubmit={handleAddUser}>
+          <Input
+            darkMode={darkMode}
+            placeholder="שם משתמש"
+            value={username}
+            onChange={(e) => setUsername(e.target.value)}
+            required
+          />
+          <Input
+            darkMode={darkMode}
+            type="password"
+            placeholder="סיסמה"
+            value={password}
+            onChange={(e) => setPassword(e.target.value)}
+            required
+          />
+          <Select darkMode={darkMode} value={role} onChange={(e) => setRole(e.target.value)}>
+            {Object.entries(roleLabels).map(([value, label]) => (
+              <option key={value} value={value}>{label}</option>
+            ))}
+          </Select>
+          <Button type="submit">הוסף</Button>
+        </Form>
+      </Panel>
+
+      <Panel darkMode={darkMode}>
+        <SectionTitle darkMode={darkMode}>משתמשים</SectionTitle>
+        <Table darkMode={darkMode}>
+          <thead>
+            <tr>
+              <th>שם משתמש</th>
+              <th>הרשאה</th>
+              <th>נוצר</th>
+              <th></th>
+            </tr>
+          </thead>
+          <tbody>
+            {users.map(user => (
+              <tr key={user.id}>
+                <td>{user.username}</td>
+                <td>
+                  <Select
+                    darkMode={darkMode}
+                    value={user.role}
+                    onChange={(e) => handleRoleChange(user, e.target.value)}
+                  >
+                    {Object.entries(roleLabels).map(([value, label]) => (
+                      <option key={value} value={value}>{label}</option>
+                    ))}
+                  </Select>
+                </td>
+                <td>{user.createdAt}</td>
+                <td>
+                  <Button type="button" onClick={() => handleResetPassword(user)}>איפוס סיסמה</Button>{' '}
+                  <Button
+                    type="button"
+                    variant="danger"
+                    disabled={user.id === currentUser.id}
+                    onClick={() => handleDeleteUser(user)}
+                  >
+                    מחק
+                  </Button>
+                </td>
+              </tr>
+            ))}
+          </tbody>
+        </Table>
+      </Panel>
+    </PageContainer>
+  );
+};
+
+export default Users;
@@ -0,0 +1,57 @@
+import { EventsOn } from '../../wailsjs/runtime';
+
+/**
+ * A change to a product, order or stock item, as published by the Go database layer
+ */
+export interface ChangeEvent<T> {
+  entity: 'product' | 'order' | 'stock';
+  action: 'created' | 'updated' | 'deleted';
+  id: string;
+  data: T | null;
+}
+
+/**
+ * Tells whether a change event lacks the changed record, so the list must be reloaded
+ * @param event The change event
+ * @returns True when a created or updated record could not be read back
+ */
+export function needsReload(event: ChangeEvent<any>): boolean {
+  return event.action !== 'deleted' && !event.data;
+}
+
+/**
+ * Patches a list with a change event: deleted records are removed and created or
+ * updated records are replaced in place or added at the start
+ * @param list The current list
+ * @param event The change event
+ * @param map Converts the event's record to the list's item type (default: unchanged)
+ * @returns The patched list, or the same list when the event has no record
+ */
+export function applyChange<T extends { id: string }>(
+  list: T[],
+  event: ChangeEvent<any>,
+  map: (data: any) => T = data => data
+): T[] {
+  if (event.action === 'deleted') {
+    return list.filter(item => item.id !== event.id);
+  }
+  if (!event.data) {
+    return list;
+  }
+
+  const record = map(event.data);
+  if (!list.some(item => item.id === event.id)) {
+    return [record, ...list];
+  }
+  return list.map(item => item.id === event.id ? record : item);
+}
+
+/**
+ * Subscribes to the change events of one kind of record
+ * @param entity The kind of record to listen for
+ * @param callback Called with every change event
+ * @returns A function that removes the subscription
+ */
+export function onChange<T>(entity: ChangeEvent<T>['entity'], callback: (event: ChangeEvent<T>) => void): () => void {
+  return EventsOn(`changed:${entity}`, callback);
+}
@@ -19,4 +19,14 @@ export function formatNumber(value: number, decimals: number = 2): string {
  */
 export function formatPrice(price: number, currencySymbol: string = '₪'): string {
   return `${currencySymbol}${formatNumber(price)}`;
-} 
\ No newline at end of file
+} 
+/**
+ * Hebrew names of the reason codes for selling an order line below or above its list price
+ */
+export const priceOverrideLabels: Record<string, string> = {
+  'Discount': 'הנחה',
+  'Bulk': 'כמות',
+  'Damaged': 'פגום',
+  'PriceMatch': 'השוואת מחיר',
+  'Quote': 'הצעת מחיר',
+};
@@ -2,32 +2,190 @@
 // This file is automatically generated. DO NOT EDIT
 import {main} from '../models';
 
+export function AddCategory(arg1:main.Category):Promise<main.Category>;
+
+export function AddPayment(arg1:string,arg2:main.NewPayment):Promise<boolean>;
+
 export function AddProduct(arg1:main.Product):Promise<boolean>;
 
+export function AddProductVariant(arg1:main.ProductVariant):Promise<main.ProductVariant>;
+
 export function AddStockItem(arg1:main.StockItem):Promise<main.StockItem>;
 
+export function AddUser(arg1:string,arg2:string,arg3:string):Promise<main.User>;
+
+export function AdjustStock(arg1:string,arg2:main.StockAdjustment):Promise<boolean>;
+
+export function AttachProductImage(arg1:string,arg2:string):Promise<boolean>;
+
+export function CancelScheduledPrice(arg1:string):Promise<boolean>;
+
+export function ChangePassword(arg1:string,arg2:string):Promise<boolean>;
+
+export function ConvertQuoteToOrder(arg1:string):Promise<string>;
+
+export function CreateInitialAdmin(arg1:string,arg2:string):Promise<main.User>;
+
 export function CreateOrder(arg1:any):Promise<boolean>;
 
+export function CreateOrderFromTemplate(arg1:string):Promise<string>;
+
+export function CreateQuote(arg1:main.NewQuote):Promise<string>;
+
+export function CreateStockCount(arg1:main.NewStockCount):Promise<string>;
+
 export function DatabaseStatus():Promise<string>;
 
+export function DeleteCategory(arg1:string):Promise<boolean>;
+
+export function DeleteLocation(arg1:string):Promise<boolean>;
+
 export function DeleteOrder(arg1:string):Promise<boolean>;
 
+export function DeleteOrderTemplate(arg1:string):Promise<boolean>;
+
+export function DeletePayment(arg1:string):Promise<boolean>;
+
 export function DeleteProduct(arg1:string):Promise<boolean>;
 
+export function DeleteProductVariant(arg1:string):Promise<boolean>;
+
+export function DeleteQuote(arg1:string):Promise<boolean>;
+
+export function DeleteStockCount(arg1:string):Promise<boolean>;
+
 export function DeleteStockItem(arg1:string):Promise<boolean>;
 
+export function DeleteUser(arg1:string):Promise<boolean>;
+
+export function ExportQuotePDF(arg1:string):Promise<boolean>;
+
+export function ExportSupportBundle():Promise<boolean>;
+
+export function GenerateBarcodeLabels(arg1:Array<string>):Promise<boolean>;
+
+export function GetCategories():Promise<Array<main.Category>>;
+
+export function GetCategorySales(arg1:string,arg2:string):Promise<Array<main.CategorySales>>;
+
 export function GetCurrentTime():Promise<string>;
 
+export function GetCurrentUser():Promise<main.User>;
+
+export function GetDatabaseState():Promise<main.DatabaseState>;
+
+export function GetDiagnostics():Promise<main.Diagnostics>;
+
+export function GetExpiringLots(arg1:number):Promise<Array<main.Lot>>;
+
+export function GetLocations():Promise<Array<main.Location>>;
+
+export function GetLotShipments(arg1:string):Promise<Array<main.LotShipment>>;
+
+export function GetLots(arg1:string):Promise<Array<main.Lot>>;
+
+export function GetOrderHistory(arg1:string):Promise<Array<main.OrderChange>>;
+
+export function GetOrderTemplates():Promise<Array<main.OrderTemplate>>;
+
 export function GetOrders():Promise<Array<main.Order>>;
 
+export function GetOutstandingOrders():Promise<Array<main.Order>>;
+
+export function GetPayments(arg1:string):Promise<Array<main.Payment>>;
+
+export function GetPriceAt(arg1:string,arg2:string):Promise<number>;
+
+export function GetPriceHistory(arg1:string):Promise<Array<main.ProductPrice>>;
+
 export function GetProductByID(arg1:string):Promise<main.Product>;
 
+export function GetProductVariants(arg1:string):Promise<Array<main.ProductVariant>>;
+
 export function GetProducts():Promise<Array<main.Product>>;
 
+export function GetProductsByCategory():Promise<Array<main.CategoryProducts>>;
+
+export function GetQuotes():Promise<Array<main.Quote>>;
+
+export function GetRecentLogs(arg1:string,arg2:number):Promise<Array<main.LogEntry>>;
+
+export function GetReturns(arg1:string):Promise<Array<main.Return>>;
+
+export function GetSalesSummary(arg1:string,arg2:string):Promise<main.SalesSummary>;
+
+export function GetShipments(arg1:string):Promise<Array<main.Shipment>>;
+
+export function GetStockCounts():Promise<Array<main.StockCount>>;
+
 export function GetStockItems():Promise<Array<main.StockItem>>;
 
+export function GetStockMovements(arg1:string):Promise<Array<main.StockMovement>>;
+
+export function GetTags():Promise<Array<string>>;
+
+export function GetUsers():Promise<Array<main.User>>;
+
+export function Login(arg1:string,arg2:string):Promise<main.User>;
+
+export function Logout():Promise<boolean>;
+
+export function LookupByBarcode(arg1:string):Promise<main.BarcodeLookup>;
+
+export function NeedsSetup():Promise<boolean>;
+
+export function OpenDatabaseFile():Promise<main.DatabaseState>;
+
+export function PostStockCount(arg1:string):Promise<boolean>;
+
+export function PreviewScheduledOrders(arg1:number):Promise<Array<main.ScheduledOrder>>;
+
+export function QueryProducts(arg1:main.ProductQuery):Promise<Array<main.Product>>;
+
+export function ReceiveStock(arg1:string,arg2:main.StockReceipt):Promise<boolean>;
+
+export function RecordStockCounts(arg1:string,arg2:Array<main.StockCountEntry>):Promise<boolean>;
+
+export function RecoverDatabase():Promise<main.DatabaseState>;
+
+export function RemoveProductImage(arg1:string):Promise<boolean>;
+
+export function RestoreLatestBackup():Promise<main.DatabaseState>;
+
+export function RetryOpenDatabase():Promise<main.DatabaseState>;
+
+export function ReturnItems(arg1:string,arg2:main.NewReturn):Promise<boolean>;
+
+export function SaveLocation(arg1:main.Location):Promise<main.Location>;
+
+export function SaveOrderTemplate(arg1:main.OrderTemplate):Promise<string>;
+
+export function SchedulePriceChange(arg1:string,arg2:number,arg3:string,arg4:string,arg5:string):Promise<main.ProductPrice>;
+
+export function SelectProductImage(arg1:string):Promise<boolean>;
+
+export function ShipOrder(arg1:string,arg2:main.NewShipment):Promise<boolean>;
+
+export function SplitOrder(arg1:string,arg2:main.OrderSplit):Promise<string>;
+
+export function TransferStock(arg1:main.StockTransfer):Promise<boolean>;
+
+export function UpdateCategory(arg1:main.Category):Promise<boolean>;
+
+export function UpdateOrder(arg1:string,arg2:main.NewOrder):Promise<boolean>;
+
 export function UpdateOrderStatus(arg1:string,arg2:string):Promise<boolean>;
 
 export function UpdateProduct(arg1:main.Product):Promise<boolean>;
 
+export function UpdateProductVariant(arg1:main.ProductVariant):Promise<boolean>;
+
+export function UpdateQuote(arg1:string,arg2:main.NewQuote):Promise<boolean>;
+
+export function UpdateQuoteStatus(arg1:string,arg2:string):Promise<boolean>;
+
 export function UpdateStockItem(arg1:main.StockItem):Promise<boolean>;
+
+export function UpdateUser(arg1:string,arg2:string,arg3:string):Promise<boolean>;
+
+export function ValidateBarcode(arg1:string):Promise<string>;
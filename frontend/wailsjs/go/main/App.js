@@ -2,54 +2,350 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function AddCategory(arg1) {
+  return window['go']['main']['App']['AddCategory'](arg1);
+}
+
+export function AddPayment(arg1, arg2) {
+  return window['go']['main']['App']['AddPayment'](arg1, arg2);
+}
+
 export function AddProduct(arg1) {
   return window['go']['main']['App']['AddProduct'](arg1);
 }
 
+export function AddProductVariant(arg1) {
+  return window['go']['main']['App']['AddProductVariant'](arg1);
+}
+
 export function AddStockItem(arg1) {
   return window['go']['main']['App']['AddStockItem'](arg1);
 }
 
+export function AddUser(arg1, arg2, arg3) {
+  return window['go']['main']['App']['AddUser'](arg1, arg2, arg3);
+}
+
+export function AdjustStock(arg1, arg2) {
+  return window['go']['main']['App']['AdjustStock'](arg1, arg2);
+}
+
+export function AttachProductImage(arg1, arg2) {
+  return window['go']['main']['App']['AttachProductImage'](arg1, arg2);
+}
+
+export function CancelScheduledPrice(arg1) {
+  return window['go']['main']['App']['CancelScheduledPrice'](arg1);
+}
+
+export function ChangePassword(arg1, arg2) {
+  return window['go']['main']['App']['ChangePassword'](arg1, arg2);
+}
+
+export function ConvertQuoteToOrder(arg1) {
+  return window['go']['main']['App']['ConvertQuoteToOrder'](arg1);
+}
+
+export function CreateInitialAdmin(arg1, arg2) {
+  return window['go']['main']['App']['CreateInitialAdmin'](arg1, arg2);
+}
+
 export function CreateOrder(arg1) {
   return window['go']['main']['App']['CreateOrder'](arg1);
 }
 
+export function CreateOrderFromTemplate(arg1) {
+  return window['go']['main']['App']['CreateOrderFromTemplate'](arg1);
+}
+
+export function CreateQuote(arg1) {
+  return window['go']['main']['App']['CreateQuote'](arg1);
+}
+
+export function CreateStockCount(arg1) {
+  return window['go']['main']['App']['CreateStockCount'](arg1);
+}
+
 export function DatabaseStatus() {
   return window['go']['main']['App']['DatabaseStatus']();
 }
 
+export function DeleteCategory(arg1) {
+  return window['go']['main']['App']['DeleteCategory'](arg1);
+}
+
+export function DeleteLocation(arg1) {
+  return window['go']['main']['App']['DeleteLocation'](arg1);
+}
+
 export function DeleteOrder(arg1) {
   return window['go']['main']['App']['DeleteOrder'](arg1);
 }
 
+export function DeleteOrderTemplate(arg1) {
+  return window['go']['main']['App']['DeleteOrderTemplate'](arg1);
+}
+
+export function DeletePayment(arg1) {
+  return window['go']['main']['App']['DeletePayment'](arg1);
+}
+
 export function DeleteProduct(arg1) {
   return window['go']['main']['App']['DeleteProduct'](arg1);
 }
 
+export function DeleteProductVariant(arg1) {
+  return window['go']['main']['App']['DeleteProductVariant'](arg1);
+}
+
+export function DeleteQuote(arg1) {
+  return window['go']['main']['App']['DeleteQuote'](arg1);
+}
+
+export function DeleteStockCount(arg1) {
+  return window['go']['main']['App']['DeleteStockCount'](arg1);
+}
+
 export function DeleteStockItem(arg1) {
   return window['go']['main']['App']['DeleteStockItem'](arg1);
 }
 
+export function DeleteUser(arg1) {
+  return window['go']['main']['App']['DeleteUser'](arg1);
+}
+
+export function ExportQuotePDF(arg1) {
+  return window['go']['main']['App']['ExportQuotePDF'](arg1);
+}
+
+export function ExportSupportBundle() {
+  return window['go']['main']['App']['ExportSupportBundle']();
+}
+
+export function GenerateBarcodeLabels(arg1) {
+  return window['go']['main']['App']['GenerateBarcodeLabels'](arg1);
+}
+
+export function GetCategories() {
+  return window['go']['main']['App']['GetCategories']();
+}
+
+export function GetCategorySales(arg1, arg2) {
+  return window['go']['main']['App']['GetCategorySales'](arg1, arg2);
+}
+
 export function GetCurrentTime() {
   return window['go']['main']['App']['GetCurrentTime']();
 }
 
+export function GetCurrentUser() {
+  return window['go']['main']['App']['GetCurrentUser']();
+}
+
+export function GetDatabaseState() {
+  return window['go']['main']['App']['GetDatabaseState']();
+}
+
+export function GetDiagnostics() {
+  return window['go']['main']['App']['GetDiagnostics']();
+}
+
+export function GetExpiringLots(arg1) {
+  return window['go']['main']['App']['GetExpiringLots'](arg1);
+}
+
+export function GetLocations() {
+  return window['go']['main']['App']['GetLocations']();
+}
+
+export function GetLotShipments(arg1) {
+  return window['go']['main']['App']['GetLotShipments'](arg1);
+}
+
+export function GetLots(arg1) {
+  return window['go']['main']['App']['GetLots'](arg1);
+}
+
+export function GetOrderHistory(arg1) {
+  return window['go']['main']['App']['GetOrderHistory'](arg1);
+}
+
+export function GetOrderTemplates() {
+  return window['go']['main']['App']['GetOrderTemplates']();
+}
+
 export function GetOrders() {
   return window['go']['main']['App']['GetOrders']();
 }
 
+export function GetOutstandingOrders() {
+  return window['go']['main']['App']['GetOutstandingOrders']();
+}
+
+export function GetPayments(arg1) {
+  return window['go']['main']['App']['GetPayments'](arg1);
+}
+
+export function GetPriceAt(arg1, arg2) {
+  return window['go']['main']['App']['GetPriceAt'](arg1, arg2);
+}
+
+export function GetPriceHistory(arg1) {
+  return window['go']['main']['App']['GetPriceHistory'](arg1);
+}
+
 export function GetProductByID(arg1) {
   return window['go']['main']['App']['GetProductByID'](arg1);
 }
 
+export function GetProductVariants(arg1) {
+  return window['go']['main']['App']['GetProductVariants'](arg1);
+}
+
 export function GetProducts() {
   return window['go']['main']['App']['GetProducts']();
 }
 
+export function GetProductsByCategory() {
+  return window['go']['main']['App']['GetProductsByCategory']();
+}
+
+export function GetQuotes() {
+  return window['go']['main']['App']['GetQuotes']();
+}
+
+export function GetRecentLogs(arg1, arg2) {
+  return window['go']['main']['App']['GetRecentLogs'](arg1, arg2);
+}
+
+export function GetReturns(arg1) {
+  return window['go']['main']['App']['GetReturns'](arg1);
+}
+
+export function GetSalesSummary(arg1, arg2) {
+  return window['go']['main']['App']['GetSalesSummary'](arg1, arg2);
+}
+
+export function GetShipments(arg1) {
+  return window['go']['main']['App']['GetShipments'](arg1);
+}
+
+export function GetStockCounts() {
+  return window['go']['main']['App']['GetStockCounts']();
+}
+
 export function GetStockItems() {
   return window['go']['main']['App']['GetStockItems']();
 }
 
+export function GetStockMovements(arg1) {
+  return window['go']['main']['App']['GetStockMovements'](arg1);
+}
+
+export function GetTags() {
+  return window['go']['main']['App']['GetTags']();
+}
+
+export function GetUsers() {
+  return window['go']['main']['App']['GetUsers']();
+}
+
+export function Login(arg1, arg2) {
+  return window['go']['main']['App']['Login'](arg1, arg2);
+}
+
+export function Logout() {
+  return window['go']['main']['App']['Logout']();
+}
+
+export function LookupByBarcode(arg1) {
+  return window['go']['main']['App']['LookupByBarcode'](arg1);
+}
+
+export function NeedsSetup() {
+  return window['go']['main']['App']['NeedsSetup']();
+}
+
+export function OpenDatabaseFile() {
+  return window['go']['main']['App']['OpenDatabaseFile']();
+}
+
+export function PostStockCount(arg1) {
+  return window['go']['main']['App']['PostStockCount'](arg1);
+}
+
+export function PreviewScheduledOrders(arg1) {
+  return window['go']['main']['App']['PreviewScheduledOrders'](arg1);
+}
+
+export function QueryProducts(arg1) {
+  return window['go']['main']['App']['QueryProducts'](arg1);
+}
+
+export function ReceiveStock(arg1, arg2) {
+  return window['go']['main']['App']['ReceiveStock'](arg1, arg2);
+}
+
+export function RecordStockCounts(arg1, arg2) {
+  return window['go']['main']['App']['RecordStockCounts'](arg1, arg2);
+}
+
+export function RecoverDatabase() {
+  return window['go']['main']['App']['RecoverDatabase']();
+}
+
+export function RemoveProductImage(arg1) {
+  return window['go']['main']['App']['RemoveProductImage'](arg1);
+}
+
+export function RestoreLatestBackup() {
+  return window['go']['main']['App']['RestoreLatestBackup']();
+}
+
+export function RetryOpenDatabase() {
+  return window['go']['main']['App']['RetryOpenDatabase']();
+}
+
+export function ReturnItems(arg1, arg2) {
+  return window['go']['main']['App']['ReturnItems'](arg1, arg2);
+}
+
+export function SaveLocation(arg1) {
+  return window['go']['main']['App']['SaveLocation'](arg1);
+}
+
+export function SaveOrderTemplate(arg1) {
+  return window['go']['main']['App']['SaveOrderTemplate'](arg1);
+}
+
+export function SchedulePriceChange(arg1, arg2, arg3, arg4, arg5) {
+  return window['go']['main']['App']['SchedulePriceChange'](arg1, arg2, arg3, arg4, arg5);
+}
+
+export function SelectProductImage(arg1) {
+  return window['go']['main']['App']['SelectProductImage'](arg1);
+}
+
+export function ShipOrder(arg1, arg2) {
+  return window['go']['main']['App']['ShipOrder'](arg1, arg2);
+}
+
+export function SplitOrder(arg1, arg2) {
+  return window['go']['main']['App']['SplitOrder'](arg1, arg2);
+}
+
+export function TransferStock(arg1) {
+  return window['go']['main']['App']['TransferStock'](arg1);
+}
+
+export function UpdateCategory(arg1) {
+  return window['go']['main']['App']['UpdateCategory'](arg1);
+}
+
+export function UpdateOrder(arg1, arg2) {
+  return window['go']['main']['App']['UpdateOrder'](arg1, arg2);
+}
+
 export function UpdateOrderStatus(arg1, arg2) {
   return window['go']['main']['App']['UpdateOrderStatus'](arg1, arg2);
 }
@@ -58,6 +354,26 @@ export function UpdateProduct(arg1) {
   return window['go']['main']['App']['UpdateProduct'](arg1);
 }
 
+export function UpdateProductVariant(arg1) {
+  return window['go']['main']['App']['UpdateProductVariant'](arg1);
+}
+
+export function UpdateQuote(arg1, arg2) {
+  return window['go']['main']['App']['UpdateQuote'](arg1, arg2);
+}
+
+export function UpdateQuoteStatus(arg1, arg2) {
+  return window['go']['main']['App']['UpdateQuoteStatus'](arg1, arg2);
+}
+
 export function UpdateStockItem(arg1) {
   return window['go']['main']['App']['UpdateStockItem'](arg1);
 }
+
+export function UpdateUser(arg1, arg2, arg3) {
+  return window['go']['main']['App']['UpdateUser'](arg1, arg2, arg3);
+}
+
+export function ValidateBarcode(arg1) {
+  return window['go']['main']['App']['ValidateBarcode'](arg1);
+}
@@ -1,34 +1,956 @@
 export namespace main {
 	
+	export class StockLevel {
+	    locationId: string;
+	    locationName: string;
+	    quantity: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new StockLevel(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.locationId = source["locationId"];
+	        this.locationName = source["locationName"];
+	        this.quantity = source["quantity"];
+	    }
+	}
+	export class StockItem {
+	    id: string;
+	    name: string;
+	    description: string;
+	    quantity: number;
+	    sku: string;
+	    barcode: string;
+	    locations: StockLevel[];
+	    reserved: number;
+	    available: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new StockItem(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.description = source["description"];
+	        this.quantity = source["quantity"];
+	        this.sku = source["sku"];
+	        this.barcode = source["barcode"];
+	        this.locations = this.convertValues(source["locations"], StockLevel);
+	        this.reserved = source["reserved"];
+	        this.available = source["available"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ProductVariant {
+	    id: string;
+	    productId: string;
+	    sku: string;
+	    barcode: string;
+	    size: string;
+	    potDiameter: number;
+	    color: string;
+	    price: number;
+	    stockItemId: string;
+	    description: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ProductVariant(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.productId = source["productId"];
+	        this.sku = source["sku"];
+	        this.barcode = source["barcode"];
+	        this.size = source["size"];
+	        this.potDiameter = source["potDiameter"];
+	        this.color = source["color"];
+	        this.price = source["price"];
+	        this.stockItemId = source["stockItemId"];
+	        this.description = source["description"];
+	    }
+	}
+	export class Product {
+	    id: string;
+	    name: string;
+	    price: number;
+	    description: string;
+	    status: string;
+	    sku: string;
+	    barcode: string;
+	    category: string;
+	    tags: string[];
+	    image: string;
+	    imageUrl: string;
+	    thumbnailUrl: string;
+	    variants: ProductVariant[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Product(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.price = source["price"];
+	        this.description = source["description"];
+	        this.status = source["status"];
+	        this.sku = source["sku"];
+	        this.barcode = source["barcode"];
+	        this.category = source["category"];
+	        this.tags = source["tags"];
+	        this.image = source["image"];
+	        this.imageUrl = source["imageUrl"];
+	        this.thumbnailUrl = source["thumbnailUrl"];
+	        this.variants = this.convertValues(source["variants"], ProductVariant);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class BarcodeLookup {
+	    found: boolean;
+	    code: string;
+	    kind: string;
+	    product?: Product;
+	    variant?: ProductVariant;
+	    stockItem?: StockItem;
+	
+	    static createFrom(source: any = {}) {
+	        return new BarcodeLookup(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.found = source["found"];
+	        this.code = source["code"];
+	        this.kind = source["kind"];
+	        this.product = this.convertValues(source["product"], Product);
+	        this.variant = this.convertValues(source["variant"], ProductVariant);
+	        this.stockItem = this.convertValues(source["stockItem"], StockItem);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Category {
+	    id: string;
+	    name: string;
+	    parentId: string;
+	    path: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Category(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.parentId = source["parentId"];
+	        this.path = source["path"];
+	    }
+	}
+	export class CategoryProducts {
+	    category: Category;
+	    products: Product[];
+	
+	    static createFrom(source: any = {}) {
+	        return new CategoryProducts(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.category = this.convertValues(source["category"], Category);
+	        this.products = this.convertValues(source["products"], Product);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class CategorySales {
+	    categoryId: string;
+	    categoryName: string;
+	    productCount: number;
+	    orderCount: number;
+	    quantitySold: number;
+	    revenue: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new CategorySales(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.categoryId = source["categoryId"];
+	        this.categoryName = source["categoryName"];
+	        this.productCount = source["productCount"];
+	        this.orderCount = source["orderCount"];
+	        this.quantitySold = source["quantitySold"];
+	        this.revenue = source["revenue"];
+	    }
+	}
+	export class DatabaseState {
+	    ready: boolean;
+	    path: string;
+	    error: string;
+	    problem: string;
+	    latestBackup: string;
+	    latestBackupTime: string;
+	    message: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new DatabaseState(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ready = source["ready"];
+	        this.path = source["path"];
+	        this.error = source["error"];
+	        this.problem = source["problem"];
+	        this.latestBackup = source["latestBackup"];
+	        this.latestBackupTime = source["latestBackupTime"];
+	        this.message = source["message"];
+	    }
+	}
+	export class TableRowCount {
+	    table: string;
+	    rows: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new TableRowCount(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.table = source["table"];
+	        this.rows = source["rows"];
+	    }
+	}
+	export class Diagnostics {
+	    version: string;
+	    goVersion: string;
+	    platform: string;
+	    dbPath: string;
+	    dbSize: number;
+	    schemaVersion: number;
+	    sqliteVersion: string;
+	    integrity: string;
+	    tables: TableRowCount[];
+	    logPath: string;
+	    generatedAt: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Diagnostics(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.version = source["version"];
+	        this.goVersion = source["goVersion"];
+	        this.platform = source["platform"];
+	        this.dbPath = source["dbPath"];
+	        this.dbSize = source["dbSize"];
+	        this.schemaVersion = source["schemaVersion"];
+	        this.sqliteVersion = source["sqliteVersion"];
+	        this.integrity = source["integrity"];
+	        this.tables = this.convertValues(source["tables"], TableRowCount);
+	        this.logPath = source["logPath"];
+	        this.generatedAt = source["generatedAt"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Location {
+	    id: string;
+	    name: string;
+	    units: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Location(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.units = source["units"];
+	    }
+	}
+	export class LogEntry {
+	    time: string;
+	    level: string;
+	    subsystem: string;
+	    message: string;
+	    attrs: Record<string, string>;
+	
+	    static createFrom(source: any = {}) {
+	        return new LogEntry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.time = source["time"];
+	        this.level = source["level"];
+	        this.subsystem = source["subsystem"];
+	        this.message = source["message"];
+	        this.attrs = source["attrs"];
+	    }
+	}
+	export class Lot {
+	    id: string;
+	    stockItemId: string;
+	    stockItemName: string;
+	    lotNumber: string;
+	    expiresOn: string;
+	    receivedAt: string;
+	    quantity: number;
+	    locations: StockLevel[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Lot(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.stockItemId = source["stockItemId"];
+	        this.stockItemName = source["stockItemName"];
+	        this.lotNumber = source["lotNumber"];
+	        this.expiresOn = source["expiresOn"];
+	        this.receivedAt = source["receivedAt"];
+	        this.quantity = source["quantity"];
+	        this.locations = this.convertValues(source["locations"], StockLevel);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class LotQuantity {
+	    lotId: string;
+	    lotNumber: string;
+	    expiresOn: string;
+	    quantity: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new LotQuantity(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.lotId = source["lotId"];
+	        this.lotNumber = source["lotNumber"];
+	        this.expiresOn = source["expiresOn"];
+	        this.quantity = source["quantity"];
+	    }
+	}
+	export class LotShipment {
+	    orderId: string;
+	    orderName: string;
+	    orderItemId: string;
+	    productName: string;
+	    shipmentId: string;
+	    shippedAt: string;
+	    quantity: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new LotShipment(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.orderId = source["orderId"];
+	        this.orderName = source["orderName"];
+	        this.orderItemId = source["orderItemId"];
+	        this.productName = source["productName"];
+	        this.shipmentId = source["shipmentId"];
+	        this.shippedAt = source["shippedAt"];
+	        this.quantity = source["quantity"];
+	    }
+	}
 	export class OrderItem {
+	    id?: string;
+	    productId: string;
+	    variantId: string;
+	    productName: string;
+	    price: number;
+	    quantity: number;
+	    description: string;
+	    sku: string;
+	    listPrice: number;
+	    overrideReason: string;
+	    shipped: number;
+	    remaining: number;
+	    returned: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new OrderItem(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.productId = source["productId"];
+	        this.variantId = source["variantId"];
+	        this.productName = source["productName"];
+	        this.price = source["price"];
+	        this.quantity = source["quantity"];
+	        this.description = source["description"];
+	        this.sku = source["sku"];
+	        this.listPrice = source["listPrice"];
+	        this.overrideReason = source["overrideReason"];
+	        this.shipped = source["shipped"];
+	        this.remaining = source["remaining"];
+	        this.returned = source["returned"];
+	    }
+	}
+	export class NewOrder {
+	    name: string;
+	    description: string;
+	    items: OrderItem[];
+	
+	    static createFrom(source: any = {}) {
+	        return new NewOrder(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.description = source["description"];
+	        this.items = this.convertValues(source["items"], OrderItem);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class NewPayment {
+	    method: string;
+	    amount: number;
+	    paidAt: string;
+	    reference: string;
+	    deposit: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new NewPayment(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.method = source["method"];
+	        this.amount = source["amount"];
+	        this.paidAt = source["paidAt"];
+	        this.reference = source["reference"];
+	        this.deposit = source["deposit"];
+	    }
+	}
+	export class NewQuote {
+	    name: string;
+	    description: string;
+	    items: OrderItem[];
+	    expiresOn: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new NewQuote(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.description = source["description"];
+	        this.items = this.convertValues(source["items"], OrderItem);
+	        this.expiresOn = source["expiresOn"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ReturnLine {
+	    orderItemId: string;
+	    quantity: number;
+	    condition: string;
+	    restockItemId: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ReturnLine(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.orderItemId = source["orderItemId"];
+	        this.quantity = source["quantity"];
+	        this.condition = source["condition"];
+	        this.restockItemId = source["restockItemId"];
+	    }
+	}
+	export class NewReturn {
+	    items: ReturnLine[];
+	    reason: string;
+	    refund: number;
+	    locationId: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new NewReturn(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.items = this.convertValues(source["items"], ReturnLine);
+	        this.reason = source["reason"];
+	        this.refund = source["refund"];
+	        this.locationId = source["locationId"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ShipmentLine {
+	    orderItemId: string;
+	    quantity: number;
+	    stockItemId: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ShipmentLine(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.orderItemId = source["orderItemId"];
+	        this.quantity = source["quantity"];
+	        this.stockItemId = source["stockItemId"];
+	    }
+	}
+	export class NewShipment {
+	    items: ShipmentLine[];
+	    note: string;
+	    locationId: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new NewShipment(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.items = this.convertValues(source["items"], ShipmentLine);
+	        this.note = source["note"];
+	        this.locationId = source["locationId"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class NewStockCount {
+	    name: string;
+	    locationId: string;
+	    stockItemIds: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new NewStockCount(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.locationId = source["locationId"];
+	        this.stockItemIds = source["stockItemIds"];
+	    }
+	}
+	export class Order {
+	    id: string;
+	    date: string;
+	    name: string;
+	    description: string;
+	    items: OrderItem[];
+	    total: number;
+	    status: string;
+	    refunded: number;
+	    refundsPaidOut: number;
+	    paid: number;
+	    balanceDue: number;
+	    paymentStatus: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Order(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.date = source["date"];
+	        this.name = source["name"];
+	        this.description = source["description"];
+	        this.items = this.convertValues(source["items"], OrderItem);
+	        this.total = source["total"];
+	        this.status = source["status"];
+	        this.refunded = source["refunded"];
+	        this.refundsPaidOut = source["refundsPaidOut"];
+	        this.paid = source["paid"];
+	        this.balanceDue = source["balanceDue"];
+	        this.paymentStatus = source["paymentStatus"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class OrderChange {
+	    id: string;
+	    orderId: string;
+	    changedAt: string;
+	    changedBy: string;
+	    change: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new OrderChange(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.orderId = source["orderId"];
+	        this.changedAt = source["changedAt"];
+	        this.changedBy = source["changedBy"];
+	        this.change = source["change"];
+	    }
+	}
+	
+	export class OrderSplit {
+	    items: ShipmentLine[];
+	
+	    static createFrom(source: any = {}) {
+	        return new OrderSplit(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.items = this.convertValues(source["items"], ShipmentLine);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class OrderTemplate {
+	    id: string;
+	    name: string;
+	    description: string;
+	    items: OrderItem[];
+	    total: number;
+	    frequency: string;
+	    startDate: string;
+	    nextRun: string;
+	    lastRun: string;
+	    paused: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new OrderTemplate(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.description = source["description"];
+	        this.items = this.convertValues(source["items"], OrderItem);
+	        this.total = source["total"];
+	        this.frequency = source["frequency"];
+	        this.startDate = source["startDate"];
+	        this.nextRun = source["nextRun"];
+	        this.lastRun = source["lastRun"];
+	        this.paused = source["paused"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Payment {
+	    id: string;
+	    orderId: string;
+	    method: string;
+	    amount: number;
+	    paidAt: string;
+	    reference: string;
+	    deposit: boolean;
+	    recordedBy: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Payment(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.orderId = source["orderId"];
+	        this.method = source["method"];
+	        this.amount = source["amount"];
+	        this.paidAt = source["paidAt"];
+	        this.reference = source["reference"];
+	        this.deposit = source["deposit"];
+	        this.recordedBy = source["recordedBy"];
+	    }
+	}
+	
+	export class ProductPrice {
+	    id: string;
 	    productId: string;
-	    productName: string;
 	    price: number;
-	    quantity: number;
+	    effectiveFrom: string;
+	    effectiveTo: string;
+	    reason: string;
+	    createdAt: string;
 	
 	    static createFrom(source: any = {}) {
-	        return new OrderItem(source);
+	        return new ProductPrice(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
 	        this.productId = source["productId"];
-	        this.productName = source["productName"];
 	        this.price = source["price"];
-	        this.quantity = source["quantity"];
+	        this.effectiveFrom = source["effectiveFrom"];
+	        this.effectiveTo = source["effectiveTo"];
+	        this.reason = source["reason"];
+	        this.createdAt = source["createdAt"];
 	    }
 	}
-	export class Order {
+	export class ProductQuery {
+	    categoryId: string;
+	    includeSubcategories: boolean;
+	    uncategorized: boolean;
+	    tags: string[];
+	    search: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ProductQuery(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.categoryId = source["categoryId"];
+	        this.includeSubcategories = source["includeSubcategories"];
+	        this.uncategorized = source["uncategorized"];
+	        this.tags = source["tags"];
+	        this.search = source["search"];
+	    }
+	}
+	
+	export class Quote {
 	    id: string;
 	    date: string;
 	    name: string;
 	    description: string;
 	    items: OrderItem[];
 	    total: number;
+	    expiresOn: string;
 	    status: string;
+	    orderId: string;
+	    createdBy: string;
 	
 	    static createFrom(source: any = {}) {
-	        return new Order(source);
+	        return new Quote(source);
 	    }
 	
 	    constructor(source: any = {}) {
@@ -39,7 +961,10 @@ export namespace main {
 	        this.description = source["description"];
 	        this.items = this.convertValues(source["items"], OrderItem);
 	        this.total = source["total"];
+	        this.expiresOn = source["expiresOn"];
 	        this.status = source["status"];
+	        this.orderId = source["orderId"];
+	        this.createdBy = source["createdBy"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -60,43 +985,428 @@ export namespace main {
 		    return a;
 		}
 	}
+	export class ReturnItem {
+	    orderItemId: string;
+	    productName: string;
+	    quantity: number;
+	    condition: string;
+	    restockItemId: string;
 	
-	export class Product {
+	    static createFrom(source: any = {}) {
+	        return new ReturnItem(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.orderItemId = source["orderItemId"];
+	        this.productName = source["productName"];
+	        this.quantity = source["quantity"];
+	        this.condition = source["condition"];
+	        this.restockItemId = source["restockItemId"];
+	    }
+	}
+	export class Return {
+	    id: string;
+	    orderId: string;
+	    returnedAt: string;
+	    returnedBy: string;
+	    reason: string;
+	    refund: number;
+	    paidOut: number;
+	    items: ReturnItem[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Return(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.orderId = source["orderId"];
+	        this.returnedAt = source["returnedAt"];
+	        this.returnedBy = source["returnedBy"];
+	        this.reason = source["reason"];
+	        this.refund = source["refund"];
+	        this.paidOut = source["paidOut"];
+	        this.items = this.convertValues(source["items"], ReturnItem);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	
+	export class SalesSummary {
+	    orderCount: number;
+	    grossSales: number;
+	    returnCount: number;
+	    returnedUnits: number;
+	    refunds: number;
+	    netSales: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new SalesSummary(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.orderCount = source["orderCount"];
+	        this.grossSales = source["grossSales"];
+	        this.returnCount = source["returnCount"];
+	        this.returnedUnits = source["returnedUnits"];
+	        this.refunds = source["refunds"];
+	        this.netSales = source["netSales"];
+	    }
+	}
+	export class ScheduledOrder {
+	    templateId: string;
+	    templateName: string;
+	    date: string;
+	    items: OrderItem[];
+	    total: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ScheduledOrder(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.templateId = source["templateId"];
+	        this.templateName = source["templateName"];
+	        this.date = source["date"];
+	        this.items = this.convertValues(source["items"], OrderItem);
+	        this.total = source["total"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ShipmentItem {
+	    orderItemId: string;
+	    productName: string;
+	    quantity: number;
+	    lots: LotQuantity[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ShipmentItem(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.orderItemId = source["orderItemId"];
+	        this.productName = source["productName"];
+	        this.quantity = source["quantity"];
+	        this.lots = this.convertValues(source["lots"], LotQuantity);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Shipment {
+	    id: string;
+	    orderId: string;
+	    shippedAt: string;
+	    shippedBy: string;
+	    note: string;
+	    items: ShipmentItem[];
+	    locationId: string;
+	    location: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Shipment(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.orderId = source["orderId"];
+	        this.shippedAt = source["shippedAt"];
+	        this.shippedBy = source["shippedBy"];
+	        this.note = source["note"];
+	        this.items = this.convertValues(source["items"], ShipmentItem);
+	        this.locationId = source["locationId"];
+	        this.location = source["location"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	
+	export class StockAdjustment {
+	    locationId: string;
+	    delta: number;
+	    note: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new StockAdjustment(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.locationId = source["locationId"];
+	        this.delta = source["delta"];
+	        this.note = source["note"];
+	    }
+	}
+	export class StockCountLine {
+	    stockItemId: string;
+	    stockItemName: string;
+	    sku: string;
+	    expected: number;
+	    counted?: number;
+	    countedAt: string;
+	    countedBy: string;
+	    variance: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new StockCountLine(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.stockItemId = source["stockItemId"];
+	        this.stockItemName = source["stockItemName"];
+	        this.sku = source["sku"];
+	        this.expected = source["expected"];
+	        this.counted = source["counted"];
+	        this.countedAt = source["countedAt"];
+	        this.countedBy = source["countedBy"];
+	        this.variance = source["variance"];
+	    }
+	}
+	export class StockCount {
 	    id: string;
 	    name: string;
-	    price: number;
-	    description: string;
+	    locationId: string;
+	    location: string;
 	    status: string;
+	    startedAt: string;
+	    startedBy: string;
+	    postedAt: string;
+	    postedBy: string;
+	    lines: StockCountLine[];
+	    counted: number;
+	    variances: number;
+	    unitsOver: number;
+	    unitsShort: number;
 	
 	    static createFrom(source: any = {}) {
-	        return new Product(source);
+	        return new StockCount(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.id = source["id"];
 	        this.name = source["name"];
-	        this.price = source["price"];
-	        this.description = source["description"];
+	        this.locationId = source["locationId"];
+	        this.location = source["location"];
 	        this.status = source["status"];
+	        this.startedAt = source["startedAt"];
+	        this.startedBy = source["startedBy"];
+	        this.postedAt = source["postedAt"];
+	        this.postedBy = source["postedBy"];
+	        this.lines = this.convertValues(source["lines"], StockCountLine);
+	        this.counted = source["counted"];
+	        this.variances = source["variances"];
+	        this.unitsOver = source["unitsOver"];
+	        this.unitsShort = source["unitsShort"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class StockCountEntry {
+	    stockItemId: string;
+	    counted: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new StockCountEntry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.stockItemId = source["stockItemId"];
+	        this.counted = source["counted"];
 	    }
 	}
-	export class StockItem {
+	
+	
+	
+	export class StockMovement {
 	    id: string;
-	    name: string;
-	    description: string;
+	    stockItemId: string;
+	    stockItemName: string;
+	    kind: string;
+	    fromLocationId: string;
+	    fromLocation: string;
+	    toLocationId: string;
+	    toLocation: string;
 	    quantity: number;
+	    lotId: string;
+	    lotNumber: string;
+	    reference: string;
+	    note: string;
+	    movedAt: string;
+	    movedBy: string;
 	
 	    static createFrom(source: any = {}) {
-	        return new StockItem(source);
+	        return new StockMovement(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.id = source["id"];
-	        this.name = source["name"];
-	        this.description = source["description"];
+	        this.stockItemId = source["stockItemId"];
+	        this.stockItemName = source["stockItemName"];
+	        this.kind = source["kind"];
+	        this.fromLocationId = source["fromLocationId"];
+	        this.fromLocation = source["fromLocation"];
+	        this.toLocationId = source["toLocationId"];
+	        this.toLocation = source["toLocation"];
+	        this.quantity = source["quantity"];
+	        this.lotId = source["lotId"];
+	        this.lotNumber = source["lotNumber"];
+	        this.reference = source["reference"];
+	        this.note = source["note"];
+	        this.movedAt = source["movedAt"];
+	        this.movedBy = source["movedBy"];
+	    }
+	}
+	export class StockReceipt {
+	    locationId: string;
+	    lotNumber: string;
+	    expiresOn: string;
+	    quantity: number;
+	    note: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new StockReceipt(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.locationId = source["locationId"];
+	        this.lotNumber = source["lotNumber"];
+	        this.expiresOn = source["expiresOn"];
+	        this.quantity = source["quantity"];
+	        this.note = source["note"];
+	    }
+	}
+	export class StockTransfer {
+	    stockItemId: string;
+	    fromLocationId: string;
+	    toLocationId: string;
+	    quantity: number;
+	    note: string;
+	    lotId: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new StockTransfer(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.stockItemId = source["stockItemId"];
+	        this.fromLocationId = source["fromLocationId"];
+	        this.toLocationId = source["toLocationId"];
 	        this.quantity = source["quantity"];
+	        this.note = source["note"];
+	        this.lotId = source["lotId"];
+	    }
+	}
+	
+	export class User {
+	    id: string;
+	    username: string;
+	    role: string;
+	    createdAt: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new User(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.username = source["username"];
+	        this.role = source["role"];
+	        this.createdAt = source["createdAt"];
 	    }
 	}
 
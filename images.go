@@ -0,0 +1,465 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+const (
+	// imageURLPrefix is the asset server path product images are served from
+	imageURLPrefix = "/product-images/"
+	// thumbnailURLPrefix is the asset server path product thumbnails are served from
+	thumbnailURLPrefix = "/product-images/thumbs/"
+	// thumbnailSize is the maximum width and height of a generated thumbnail
+	thumbnailSize = 256
+	// maxImageSize limits the size of image files that can be attached to a product
+	maxImageSize = 20 << 20
+	// maxImagePixels limits the width times height of an image, since a small file can
+	// describe an image that takes gigabytes of memory to decode
+	maxImagePixels = 40_000_000
+)
+
+// imageNamePattern matches the content-addressed file names produced by ImageStore
+var imageNamePattern = regexp.MustCompile(`^[0-9a-f]{64}\.(png|jpg|gif)$`)
+
+// ImageStore keeps product images in the data directory, named by the SHA-256 of
+// their content so identical files are stored once.
+type ImageStore struct {
+	dir string
+}
+
+// NewImageStore creates an image store rooted at the given directory
+func NewImageStore(dir string) *ImageStore {
+	return &ImageStore{dir: dir}
+}
+
+// imagePath returns the location of a stored original image
+func (s *ImageStore) imagePath(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+// thumbnailPath returns the location of the thumbnail generated for an image
+func (s *ImageStore) thumbnailPath(name string) string {
+	return filepath.Join(s.dir, "thumbs", strings.TrimSuffix(name, filepath.Ext(name))+".png")
+}
+
+// Save validates and stores image data, generating its thumbnail, and returns the stored file name
+func (s *ImageStore) Save(data []byte) (string, error) {
+	// Check the dimensions from the header before decoding the pixels
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("file is not a supported image (PNG, JPEG or GIF): %v", err)
+	}
+	if pixels := int64(config.Width) * int64(config.Height); pixels > maxImagePixels {
+		return "", fmt.Errorf("image is %dx%d pixels, larger than the limit of %d megapixels",
+			config.Width, config.Height, maxImagePixels/1_000_000)
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("file is not a supported image (PNG, JPEG or GIF): %v", err)
+	}
+
+	ext := "." + format
+	if format == "jpeg" {
+		ext = ".jpg"
+	}
+
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:]) + ext
+
+	if err := os.MkdirAll(filepath.Join(s.dir, "thumbs"), 0755); err != nil {
+		return "", fmt.Errorf("failed to create image directory: %v", err)
+	}
+
+	// The same content always maps to the same name, so an existing file can be reused
+	if _, err := os.Stat(s.imagePath(name)); os.IsNotExist(err) {
+		if err := writeFileAtomic(s.imagePath(name), data); err != nil {
+			return "", fmt.Errorf("failed to store image: %v", err)
+		}
+	}
+
+	if _, err := os.Stat(s.thumbnailPath(name)); os.IsNotExist(err) {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, makeThumbnail(img, thumbnailSize)); err != nil {
+			return "", fmt.Errorf("failed to encode thumbnail: %v", err)
+		}
+		if err := writeFileAtomic(s.thumbnailPath(name), buf.Bytes()); err != nil {
+			return "", fmt.Errorf("failed to store thumbnail: %v", err)
+		}
+	}
+
+	return name, nil
+}
+
+// Remove deletes a stored image and its thumbnail
+func (s *ImageStore) Remove(name string) error {
+	if !imageNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid image name: %s", name)
+	}
+
+	for _, p := range []string{s.imagePath(name), s.thumbnailPath(name)} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove image file: %v", err)
+		}
+	}
+	return nil
+}
+
+// List returns the names of all stored images
+func (s *ImageStore) List() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %v", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && imageNamePattern.MatchString(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// ServeHTTP serves stored images and thumbnails to the frontend through the Wails asset server
+func (s *ImageStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var filePath string
+	switch {
+	case strings.HasPrefix(r.URL.Path, thumbnailURLPrefix):
+		name := path.Base(r.URL.Path)
+		if !imageNamePattern.MatchString(name) {
+			http.NotFound(w, r)
+			return
+		}
+		filePath = s.thumbnailPath(name)
+	case strings.HasPrefix(r.URL.Path, imageURLPrefix):
+		name := strings.TrimPrefix(r.URL.Path, imageURLPrefix)
+		if !imageNamePattern.MatchString(name) {
+			http.NotFound(w, r)
+			return
+		}
+		filePath = s.imagePath(name)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	// Content never changes for a given name, so it can be cached indefinitely
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	http.ServeFile(w, r, filePath)
+}
+
+// writeFileAtomic writes data to a temporary file and renames it into place
+func writeFileAtomic(filePath string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filePath)
+}
+
+// makeThumbnail scales an image down to fit within size x size, keeping its aspect ratio.
+// Each destination pixel is the average of the source pixels it covers.
+func makeThumbnail(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if srcW <= 0 || srcH <= 0 {
+		return image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	}
+
+	dstW, dstH := srcW, srcH
+	if srcW > size || srcH > size {
+		if srcW >= srcH {
+			dstW, dstH = size, max(1, srcH*size/srcW)
+		} else {
+			dstW, dstH = max(1, srcW*size/srcH), size
+		}
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0 := bounds.Min.Y + y*srcH/dstH
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*srcH/dstH)
+		for x := 0; x < dstW; x++ {
+			x0 := bounds.Min.X + x*srcW/dstW
+			x1 := max(x0+1, bounds.Min.X+(x+1)*srcW/dstW)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					b += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+
+			// Averaged values are alpha-premultiplied; convert back to straight alpha
+			c := color.NRGBA64{}
+			if a > 0 {
+				c.R = uint16(r * 0xffff / a)
+				c.G = uint16(g * 0xffff / a)
+				c.B = uint16(b * 0xffff / a)
+				c.A = uint16(a / n)
+			}
+			dst.Set(x, y, c)
+		}
+	}
+	return dst
+}
+
+// GetProductImage retrieves the stored image name of a product
+func (db *Database) GetProductImage(ctx context.Context, productID string) (string, error) {
+	var name sql.NullString
+	err := db.read.QueryRowContext(ctx, "SELECT image FROM products WHERE id = ?", productID).Scan(&name)
+	if err == sql.ErrNoRows {
+		return "", &NotFoundError{Kind: "product", ID: productID}
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to query product image: %v", err)
+	}
+	return name.String, nil
+}
+
+// SetProductImage sets or clears the stored image name of a product
+func (db *Database) SetProductImage(ctx context.Context, productID string, name string) error {
+	result, err := db.db.ExecContext(ctx, "UPDATE products SET image = ? WHERE id = ?", nullIfEmpty(name), productID)
+	if err != nil {
+		return fmt.Errorf("failed to update product image: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return &NotFoundError{Kind: "product", ID: productID}
+	}
+
+	db.publish(EntityProduct, ActionUpdated, productID)
+	return nil
+}
+
+// GetReferencedImages retrieves the set of image names still used by products
+func (db *Database) GetReferencedImages(ctx context.Context) (map[string]bool, error) {
+	rows, err := db.read.QueryContext(ctx, "SELECT DISTINCT image FROM products WHERE image IS NOT NULL AND image != ''")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query product images: %v", err)
+	}
+	defer rows.Close()
+
+	images := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan product image: %v", err)
+		}
+		images[name] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating product images: %v", err)
+	}
+
+	return images, nil
+}
+
+// productImageURLs returns the asset server URLs of a stored image and its thumbnail
+func productImageURLs(name string) (string, string) {
+	if name == "" {
+		return "", ""
+	}
+	return imageURLPrefix + name, thumbnailURLPrefix + name
+}
+
+// attachProductImage stores image data and links it to a product, releasing the previous image
+func (a *App) attachProductImage(ctx context.Context, logger *slog.Logger, db *Database, productID string, data []byte) error {
+	previous, err := db.GetProductImage(ctx, productID)
+	if err != nil {
+		return err
+	}
+
+	name, err := a.images.Save(data)
+	if err != nil {
+		return err
+	}
+
+	if err := db.SetProductImage(ctx, productID, name); err != nil {
+		return err
+	}
+
+	if previous != "" && previous != name {
+		a.releaseImage(ctx, logger, db, previous)
+	}
+	return nil
+}
+
+// releaseImage removes an image from disk once no product references it anymore
+func (a *App) releaseImage(ctx context.Context, logger *slog.Logger, products ProductRepository, name string) {
+	if err := releaseImage(ctx, products, a.images, name); err != nil {
+		logger.Error("Error removing orphaned image", "image", name, "err", err)
+	}
+}
+
+// releaseImage removes an image from the store unless a product still references it
+func releaseImage(ctx context.Context, products ProductRepository, images *ImageStore, name string) error {
+	referenced, err := products.GetReferencedImages(ctx)
+	if err != nil {
+		return err
+	}
+	if referenced[name] {
+		return nil
+	}
+	return images.Remove(name)
+}
+
+// pruneOrphanedImages removes every stored image that no product references
+func (a *App) pruneOrphanedImages(ctx context.Context, products ProductRepository) (int, error) {
+	referenced, err := products.GetReferencedImages(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	names, err := a.images.List()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, name := range names {
+		if referenced[name] {
+			continue
+		}
+		if err := a.images.Remove(name); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// AttachProductImage stores the image file at the given path and links it to a product
+func (a *App) AttachProductImage(productID string, filePath string) bool {
+	ctx, logger, db, done := a.call("AttachProductImage")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error attaching product image", "err", err)
+		return false
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		logger.Error("Error opening product image", "err", err)
+		return false
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxImageSize+1))
+	if err != nil {
+		logger.Error("Error reading product image", "err", err)
+		return false
+	}
+	if len(data) > maxImageSize {
+		logger.Warn("Product image exceeds the maximum size", "path", filePath, "maxBytes", maxImageSize)
+		return false
+	}
+
+	if err := a.attachProductImage(ctx, logger, db, productID, data); err != nil {
+		logger.Error("Error attaching product image", "err", err)
+		return false
+	}
+	return true
+}
+
+// SelectProductImage lets the user pick an image file and attaches it to a product
+func (a *App) SelectProductImage(productID string) bool {
+	logger, _ := a.begin("SelectProductImage")
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error selecting product image", "err", err)
+		return false
+	}
+
+	filePath, err := wailsruntime.OpenFileDialog(a.ctx, wailsruntime.OpenDialogOptions{
+		Title: "Select Product Image",
+		Filters: []wailsruntime.FileFilter{
+			{DisplayName: "Images (*.png;*.jpg;*.jpeg;*.gif)", Pattern: "*.png;*.jpg;*.jpeg;*.gif"},
+		},
+	})
+	if err != nil {
+		logger.Error("Error opening file dialog", "err", err)
+		return false
+	}
+	if filePath == "" {
+		// The user cancelled the dialog
+		return false
+	}
+	return a.AttachProductImage(productID, filePath)
+}
+
+// RemoveProductImage unlinks the image from a product and deletes it if it is no longer used
+func (a *App) RemoveProductImage(productID string) bool {
+	ctx, logger, db, done := a.call("RemoveProductImage")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error removing product image", "err", err)
+		return false
+	}
+
+	previous, err := db.GetProductImage(ctx, productID)
+	if err != nil {
+		logger.Error("Error getting product image", "err", err)
+		return false
+	}
+
+	if err := db.SetProductImage(ctx, productID, ""); err != nil {
+		logger.Error("Error removing product image", "err", err)
+		return false
+	}
+
+	if previous != "" {
+		a.releaseImage(ctx, logger, db, previous)
+	}
+	return true
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Label sheet geometry in PDF points (1/72 inch): A4 paper with 3 x 8 labels of 70 x 37 mm
+const (
+	pageWidth     = 595.28
+	pageHeight    = 841.89
+	labelColumns  = 3
+	labelRows     = 8
+	labelWidth    = 198.43
+	labelHeight   = 104.88
+	labelPadding  = 8.0
+	barcodeHeight = 40.0
+	maxModuleSize = 1.0
+)
+
+// BarcodeLabel is the content printed on a single label
+type BarcodeLabel struct {
+	Title string
+	Price float64
+	SKU   string
+	Code  string
+}
+
+// eanLeftOdd holds the L-code patterns of EAN digits; G and R codes are derived from it
+var eanLeftOdd = [10]string{
+	"0001101", "0011001", "0010011", "0111101", "0100011",
+	"0110001", "0101111", "0111011", "0110111", "0001011",
+}
+
+// eanParity selects L (odd) or G (even) encoding for the left half of an EAN-13 by its first digit
+var eanParity = [10]string{
+	"LLLLLL", "LLGLGG", "LLGGLG", "LLGGGL", "LGLLGG",
+	"LGGLLG", "LGGGLL", "GLLGLG", "GLGLLG", "GGLLLG",
+}
+
+// code128Patterns holds the bar/space widths of every Code 128 symbol; 106 is the stop pattern
+var code128Patterns = [107]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+// code128StartB is the start symbol of the Code 128 B character set (printable ASCII)
+const code128StartB = 104
+
+// eanDigitPattern returns the 7-module pattern of a digit in the L, G or R code set
+func eanDigitPattern(digit byte, set byte) string {
+	l := eanLeftOdd[digit-'0']
+	switch set {
+	case 'G':
+		// G codes are R codes read backwards
+		r := []byte(eanDigitPattern(digit, 'R'))
+		for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+			r[i], r[j] = r[j], r[i]
+		}
+		return string(r)
+	case 'R':
+		// R codes are the complement of L codes
+		return strings.Map(func(r rune) rune {
+			if r == '0' {
+				return '1'
+			}
+			return '0'
+		}, l)
+	default:
+		return l
+	}
+}
+
+// encodeEAN13 returns the 95 modules of an EAN-13 symbol ('1' is a bar)
+func encodeEAN13(code string) string {
+	var b strings.Builder
+	b.WriteString("101")
+	parity := eanParity[code[0]-'0']
+	for i := 1; i <= 6; i++ {
+		b.WriteString(eanDigitPattern(code[i], parity[i-1]))
+	}
+	b.WriteString("01010")
+	for i := 7; i <= 12; i++ {
+		b.WriteString(eanDigitPattern(code[i], 'R'))
+	}
+	b.WriteString("101")
+	return b.String()
+}
+
+// encodeEAN8 returns the 67 modules of an EAN-8 symbol ('1' is a bar)
+func encodeEAN8(code string) string {
+	var b strings.Builder
+	b.WriteString("101")
+	for i := 0; i < 4; i++ {
+		b.WriteString(eanDigitPattern(code[i], 'L'))
+	}
+	b.WriteString("01010")
+	for i := 4; i < 8; i++ {
+		b.WriteString(eanDigitPattern(code[i], 'R'))
+	}
+	b.WriteString("101")
+	return b.String()
+}
+
+// encodeCode128 returns the modules of a Code 128 B symbol ('1' is a bar)
+func encodeCode128(text string) (string, error) {
+	symbols := []int{code128StartB}
+	checksum := code128StartB
+	for i, r := range text {
+		if r < 32 || r > 126 {
+			return "", fmt.Errorf("character %q cannot be encoded in Code 128", r)
+		}
+		value := int(r) - 32
+		symbols = append(symbols, value)
+		checksum += value * (i + 1)
+	}
+	symbols = append(symbols, checksum%103, 106)
+
+	var b strings.Builder
+	for _, symbol := range symbols {
+		for i, width := range code128Patterns[symbol] {
+			// Patterns alternate bar, space, bar, ... starting with a bar
+			module := "1"
+			if i%2 == 1 {
+				module = "0"
+			}
+			b.WriteString(strings.Repeat(module, int(width-'0')))
+		}
+	}
+	return b.String(), nil
+}
+
+// encodeBarcode picks a symbology for the code and returns its modules
+func encodeBarcode(code string) (string, error) {
+	if ValidateBarcode(code) == nil {
+		switch len(code) {
+		case 13:
+			return encodeEAN13(code), nil
+		case 12:
+			// UPC-A is EAN-13 with a leading zero
+			return encodeEAN13("0" + code), nil
+		case 8:
+			return encodeEAN8(code), nil
+		}
+	}
+	return encodeCode128(code)
+}
+
+// pdfText converts a string to an escaped PDF literal in WinAnsi encoding.
+// Characters outside Latin-1 cannot be shown by the standard fonts and are replaced by '?'.
+func pdfText(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		case r >= 32 && r < 127, r >= 160 && r < 256:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// fitText shortens text so it fits the given width, approximating Helvetica glyphs as half an em wide
+func fitText(text string, fontSize float64, width float64) string {
+	maxChars := int(width / (fontSize * 0.5))
+	runes := []rune(text)
+	if len(runes) <= maxChars {
+		return text
+	}
+	if maxChars <= 3 {
+		return ""
+	}
+	return string(runes[:maxChars-3]) + "..."
+}
+
+// drawLabel appends the drawing operators of one label with its lower-left corner at (x, y)
+func drawLabel(content *bytes.Buffer, label BarcodeLabel, x float64, y float64) error {
+	innerWidth := labelWidth - 2*labelPadding
+	top := y + labelHeight - labelPadding
+
+	fmt.Fprintf(content, "BT /F2 10 Tf %.2f %.2f Td %s Tj ET\n",
+		x+labelPadding, top-10, pdfText(fitText(label.Title, 10, innerWidth)))
+
+	details := fmt.Sprintf("%.2f", label.Price)
+	if label.SKU != "" {
+		details = label.SKU + "   " + details
+	}
+	fmt.Fprintf(content, "BT /F1 9 Tf %.2f %.2f Td %s Tj ET\n",
+		x+labelPadding, top-23, pdfText(fitText(details, 9, innerWidth)))
+
+	modules, err := encodeBarcode(label.Code)
+	if err != nil {
+		return err
+	}
+
+	// Leave ten modules of quiet zone on either side of the symbol
+	moduleSize := innerWidth / float64(len(modules)+20)
+	if moduleSize > maxModuleSize {
+		moduleSize = maxModuleSize
+	}
+	barsX := x + (labelWidth-moduleSize*float64(len(modules)))/2
+	barsY := y + labelPadding + 10
+
+	for i := 0; i < len(modules); {
+		if modules[i] != '1' {
+			i++
+			continue
+		}
+		start := i
+		for i < len(modules) && modules[i] == '1' {
+			i++
+		}
+		fmt.Fprintf(content, "%.3f %.2f %.3f %.2f re\n",
+			barsX+moduleSize*float64(start), barsY, moduleSize*float64(i-start), barcodeHeight)
+	}
+	content.WriteString("f\n")
+
+	textWidth := float64(len(label.Code)) * 8 * 0.55
+	fmt.Fprintf(content, "BT /F3 8 Tf %.2f %.2f Td %s Tj ET\n",
+		x+(labelWidth-textWidth)/2, y+labelPadding, pdfText(label.Code))
+
+	return nil
+}
+
+// renderLabelSheet lays out labels on as many A4 pages as needed and returns the PDF document
+func renderLabelSheet(labels []BarcodeLabel) ([]byte, error) {
+	if len(labels) == 0 {
+		return nil, fmt.Errorf("no labels to print")
+	}
+
+	perPage := labelColumns * labelRows
+	marginY := (pageHeight - labelRows*labelHeight) / 2
+
+	var pages []string
+	for start := 0; start < len(labels); start += perPage {
+		var content bytes.Buffer
+		for i := start; i < len(labels) && i < start+perPage; i++ {
+			slot := i - start
+			col, row := slot%labelColumns, slot/labelColumns
+			x := float64(col) * labelWidth
+			y := pageHeight - marginY - float64(row+1)*labelHeight
+			if err := drawLabel(&content, labels[i], x, y); err != nil {
+				return nil, fmt.Errorf("failed to draw label for %s: %v", labels[i].Title, err)
+			}
+		}
+		pages = append(pages, content.String())
+	}
+
+	return buildPDF(pages), nil
+}
+
+// buildPDF assembles A4 pages of drawing operators into a PDF document. The pages can
+// use Helvetica as /F1, Helvetica-Bold as /F2 and Courier as /F3.
+func buildPDF(pages []string) []byte {
+	var doc pdfDocument
+	fonts := fmt.Sprintf("/F1 %d 0 R /F2 %d 0 R /F3 %d 0 R",
+		doc.add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>"),
+		doc.add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>"),
+		doc.add("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>"),
+	)
+	return doc.build(pages, fonts)
+}
+
+// pdfDocument collects the numbered objects of a PDF document
+type pdfDocument struct {
+	objects []string
+}
+
+// add appends an object and returns its number
+func (d *pdfDocument) add(object string) int {
+	d.objects = append(d.objects, object)
+	return len(d.objects)
+}
+
+// addStream appends a stream object, with any dictionary entries besides its length,
+// and returns its number
+func (d *pdfDocument) addStream(entries string, data []byte) int {
+	if entries != "" {
+		entries += " "
+	}
+	return d.add(fmt.Sprintf("<< /Length %d %s>>\nstream\n%s\nendstream", len(data), entries, data))
+}
+
+// build adds A4 pages of drawing operators, which use the given font resources, and
+// returns the finished document
+func (d *pdfDocument) build(pages []string, fonts string) []byte {
+	// The catalog and page tree refer to the pages, so they are filled in last
+	catalog, tree := d.add(""), d.add("")
+	var kids []string
+	for _, content := range pages {
+		contents := d.addStream("", []byte(content))
+		page := d.add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] "+
+			"/Resources << /Font << %s >> >> /Contents %d 0 R >>",
+			tree, pageWidth, pageHeight, fonts, contents))
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+	}
+	d.objects[catalog-1] = fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", tree)
+	d.objects[tree-1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages))
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(d.objects))
+	for i, object := range d.objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(d.objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(d.objects)+1, catalog, xref)
+
+	return out.Bytes()
+}
+
+// productLabels builds labels for a product and each of its variants that carries a code.
+// Products without any barcode or SKU fall back to their ID, which LookupByBarcode
+// resolves as well, so they can still be scanned.
+func productLabels(product Product) []BarcodeLabel {
+	var labels []BarcodeLabel
+
+	if code := firstNonEmpty(product.Barcode, product.SKU); code != "" {
+		labels = append(labels, BarcodeLabel{Title: product.Name, Price: product.Price, SKU: product.SKU, Code: code})
+	}
+
+	for _, variant := range product.Variants {
+		code := firstNonEmpty(variant.Barcode, variant.SKU)
+		if code == "" {
+			continue
+		}
+		labels = append(labels, BarcodeLabel{Title: variantTitle(product.Name, variant), Price: variant.Price, SKU: variant.SKU, Code: code})
+	}
+
+	if len(labels) == 0 {
+		labels = append(labels, BarcodeLabel{Title: product.Name, Price: product.Price, Code: product.ID})
+	}
+	return labels
+}
+
+// firstNonEmpty returns the first of the given strings that is not empty
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// buildProductLabelSheet renders a label sheet PDF for the products with the given IDs
+func (a *App) buildProductLabelSheet(ctx context.Context, db *Database, productIDs []string) ([]byte, error) {
+	var labels []BarcodeLabel
+	for _, id := range productIDs {
+		product, err := db.getProduct(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, productLabels(*product)...)
+	}
+	return renderLabelSheet(labels)
+}
+
+// GenerateBarcodeLabels asks where to save and writes a printable PDF sheet of
+// barcode labels for the selected products
+func (a *App) GenerateBarcodeLabels(productIDs []string) bool {
+	ctx, logger, db, done := a.call("GenerateBarcodeLabels")
+	defer done()
+
+	pdf, err := a.buildProductLabelSheet(ctx, db, productIDs)
+	if err != nil {
+		logger.Error("Error generating barcode labels", "err", err)
+		return false
+	}
+
+	filePath, err := wailsruntime.SaveFileDialog(a.ctx, wailsruntime.SaveDialogOptions{
+		Title:           "Save Barcode Labels",
+		DefaultFilename: "barcode-labels.pdf",
+		Filters: []wailsruntime.FileFilter{
+			{DisplayName: "PDF Documents (*.pdf)", Pattern: "*.pdf"},
+		},
+	})
+	if err != nil {
+		logger.Error("Error opening save dialog", "err", err)
+		return false
+	}
+	if filePath == "" {
+		// The user cancelled the dialog
+		return false
+	}
+
+	if err := os.WriteFile(filePath, pdf, 0644); err != nil {
+		logger.Error("Error writing barcode labels", "err", err)
+		return false
+	}
+
+	logger.Info("Barcode labels written", "products", len(productIDs), "path", filePath)
+	return true
+}
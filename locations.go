@@ -0,0 +1,625 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultLocationID is the location stock is counted at when none is named. Stock held
+// before locations were introduced was moved here.
+const DefaultLocationID = "main"
+
+// Kinds of stock movement
+const (
+	MovementAdjustment = "Adjustment"
+	MovementTransfer   = "Transfer"
+	MovementShipment   = "Shipment"
+	MovementReturn     = "Return"
+	MovementReceipt    = "Receipt"
+	MovementCount      = "Count"
+)
+
+// stockMovementLimit is the number of movements the desktop app lists at most
+const stockMovementLimit = 200
+
+// openingStockNote marks the adjustment recording the quantity a stock item was added with
+const openingStockNote = "Opening stock"
+
+// Location is a place stock is kept, such as the greenhouse or the shop floor
+type Location struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Units is the number of units of all stock items held there
+	Units float64 `json:"units"`
+}
+
+// StockLevel is the quantity of a stock item held at one location
+type StockLevel struct {
+	LocationID   string  `json:"locationId"`
+	LocationName string  `json:"locationName"`
+	Quantity     float64 `json:"quantity"`
+}
+
+// StockMovement records stock coming in, going out or moving between locations. Stock
+// coming in has no FromLocationID, and stock going out has no ToLocationID.
+type StockMovement struct {
+	ID             string  `json:"id"`
+	StockItemID    string  `json:"stockItemId"`
+	StockItemName  string  `json:"stockItemName"`
+	Kind           string  `json:"kind"`
+	FromLocationID string  `json:"fromLocationId"`
+	FromLocation   string  `json:"fromLocation"`
+	ToLocationID   string  `json:"toLocationId"`
+	ToLocation     string  `json:"toLocation"`
+	Quantity       float64 `json:"quantity"`
+	// LotID and LotNumber name the lot the stock belongs to, if it is tracked in lots
+	LotID     string `json:"lotId"`
+	LotNumber string `json:"lotNumber"`
+	// Reference names what caused the movement, such as the order it shipped with
+	Reference string `json:"reference"`
+	Note      string `json:"note"`
+	MovedAt   string `json:"movedAt"`
+	MovedBy   string `json:"movedBy"`
+}
+
+// StockTransfer is the request body for moving stock between two locations
+type StockTransfer struct {
+	StockItemID    string  `json:"stockItemId"`
+	FromLocationID string  `json:"fromLocationId"`
+	ToLocationID   string  `json:"toLocationId"`
+	Quantity       float64 `json:"quantity"`
+	Note           string  `json:"note"`
+	// LotID moves only stock of that lot. When it is "", lots move first-expired-first-out.
+	LotID string `json:"lotId"`
+}
+
+// StockAdjustment is the request body for adding stock to, or removing it from, one
+// location
+type StockAdjustment struct {
+	LocationID string  `json:"locationId"`
+	Delta      float64 `json:"delta"`
+	Note       string  `json:"note"`
+}
+
+// initializeLocations creates the locations, stock_levels and stock_movements tables and
+// moves stock counted before locations existed to the default location
+func (db *Database) initializeLocations() error {
+	_, err := db.db.Exec(`CREATE TABLE IF NOT EXISTS locations (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		created_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create locations table: %v", err)
+	}
+	_, err = db.db.Exec("INSERT OR IGNORE INTO locations (id, name, created_at) VALUES (?, ?, ?)",
+		DefaultLocationID, "Main", time.Now().Format(priceTimeLayout))
+	if err != nil {
+		return fmt.Errorf("failed to insert default location: %v", err)
+	}
+
+	_, err = db.db.Exec(`CREATE TABLE IF NOT EXISTS stock_levels (
+		stock_item_id TEXT NOT NULL,
+		location_id TEXT NOT NULL,
+		quantity REAL NOT NULL,
+		PRIMARY KEY (stock_item_id, location_id),
+		FOREIGN KEY (stock_item_id) REFERENCES stock_items(id),
+		FOREIGN KEY (location_id) REFERENCES locations(id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create stock_levels table: %v", err)
+	}
+
+	_, err = db.db.Exec(`CREATE TABLE IF NOT EXISTS stock_movements (
+		id TEXT PRIMARY KEY,
+		stock_item_id TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		from_location_id TEXT,
+		to_location_id TEXT,
+		quantity REAL NOT NULL,
+		reference TEXT,
+		note TEXT,
+		moved_at TEXT NOT NULL,
+		moved_by TEXT,
+		FOREIGN KEY (stock_item_id) REFERENCES stock_items(id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create stock_movements table: %v", err)
+	}
+	_, err = db.db.Exec("CREATE INDEX IF NOT EXISTS idx_stock_movements_item ON stock_movements(stock_item_id)")
+	if err != nil {
+		return fmt.Errorf("failed to create stock_movements index: %v", err)
+	}
+
+	exists, err := db.columnExists("shipments", "location_id")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if _, err := db.db.Exec("ALTER TABLE shipments ADD COLUMN location_id TEXT"); err != nil {
+			return fmt.Errorf("failed to add location_id column to shipments table: %v", err)
+		}
+	}
+
+	// Every item with stock has levels from now on, so only items counted before
+	// locations existed are picked up here
+	_, err = db.db.Exec(`
+		INSERT INTO stock_levels (stock_item_id, location_id, quantity)
+		SELECT id, ?, quantity FROM stock_items
+		WHERE quantity <> 0 AND id NOT IN (SELECT stock_item_id FROM stock_levels)
+	`, DefaultLocationID)
+	if err != nil {
+		return fmt.Errorf("failed to move stock to the default location: %v", err)
+	}
+	return nil
+}
+
+// locationName returns the name of a location within a transaction
+func locationName(ctx context.Context, tx *sql.Tx, id string) (string, error) {
+	var name string
+	err := tx.QueryRowContext(ctx, "SELECT name FROM locations WHERE id = ?", id).Scan(&name)
+	if err == sql.ErrNoRows {
+		return "", &NotFoundError{Kind: "location", ID: id}
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to query location: %v", err)
+	}
+	return name, nil
+}
+
+// moveStock records a movement within a transaction and applies it to the quantities at
+// its locations and to the stock item's total. Stock cannot be taken from a location
+// that does not hold enough of it.
+//
+// Stock taken from a location comes from the movement's lot if it names one, and
+// otherwise from the lots held there, first expired first out, before any stock not
+// tracked in lots. Those lots arrive at the destination with it, and are returned, each
+// recorded as a movement of its own. Stock coming in belongs to the movement's lot, or
+// to no lot.
+//
+// Shipments skip lots that have expired.
+func moveStock(ctx context.Context, tx *sql.Tx, movement StockMovement) ([]LotQuantity, error) {
+	if movement.Quantity <= 0 {
+		return nil, invalidf("quantity to move must be positive")
+	}
+	if movement.FromLocationID == movement.ToLocationID {
+		return nil, invalidf("stock must move from one location to another")
+	}
+
+	var itemName string
+	err := tx.QueryRowContext(ctx, "SELECT name FROM stock_items WHERE id = ?", movement.StockItemID).Scan(&itemName)
+	if err == sql.ErrNoRows {
+		return nil, &NotFoundError{Kind: "stock item", ID: movement.StockItemID}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stock item: %v", err)
+	}
+
+	var delta float64
+	var lots []LotQuantity
+	untracked := movement.Quantity
+	if movement.FromLocationID != "" {
+		from, err := locationName(ctx, tx, movement.FromLocationID)
+		if err != nil {
+			return nil, err
+		}
+		result, err := tx.ExecContext(ctx, `
+			UPDATE stock_levels SET quantity = quantity - ?1
+			WHERE stock_item_id = ?2 AND location_id = ?3 AND quantity - ?1 >= 0
+		`, movement.Quantity, movement.StockItemID, movement.FromLocationID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update stock level: %v", err)
+		}
+		if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+			var available float64
+			tx.QueryRowContext(ctx, "SELECT quantity FROM stock_levels WHERE stock_item_id = ? AND location_id = ?",
+				movement.StockItemID, movement.FromLocationID).Scan(&available)
+			return nil, invalidf("cannot take %g of %s from %s, only %g there", movement.Quantity, itemName, from, available)
+		}
+		delta -= movement.Quantity
+
+		// Expired lots can still be moved or written off, but not shipped to customers
+		sellable := movement.Kind == MovementShipment
+		lots, err = takeLots(ctx, tx, movement.StockItemID, movement.FromLocationID, movement.LotID, movement.Quantity, sellable)
+		if err != nil {
+			return nil, err
+		}
+	} else if movement.LotID != "" {
+		lot, err := loadLot(ctx, tx, movement.StockItemID, movement.LotID)
+		if err != nil {
+			return nil, err
+		}
+		lot.Quantity = movement.Quantity
+		lots = []LotQuantity{lot}
+	}
+	for _, lot := range lots {
+		untracked -= lot.Quantity
+	}
+
+	if movement.ToLocationID != "" {
+		if _, err := locationName(ctx, tx, movement.ToLocationID); err != nil {
+			return nil, err
+		}
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO stock_levels (stock_item_id, location_id, quantity) VALUES (?, ?, ?)
+			ON CONFLICT (stock_item_id, location_id) DO UPDATE SET quantity = quantity + excluded.quantity
+		`, movement.StockItemID, movement.ToLocationID, movement.Quantity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update stock level: %v", err)
+		}
+		delta += movement.Quantity
+
+		for _, lot := range lots {
+			if err := putLot(ctx, tx, lot.LotID, movement.ToLocationID, lot.Quantity); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if delta != 0 {
+		_, err = tx.ExecContext(ctx, "UPDATE stock_items SET quantity = quantity + ? WHERE id = ?", delta, movement.StockItemID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update stock quantity: %v", err)
+		}
+	}
+
+	parts := make([]LotQuantity, len(lots), len(lots)+1)
+	copy(parts, lots)
+	if untracked > 0 {
+		parts = append(parts, LotQuantity{Quantity: untracked})
+	}
+	movedAt := time.Now().Format(priceTimeLayout)
+	for _, part := range parts {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO stock_movements (id, stock_item_id, kind, from_location_id, to_location_id, quantity, lot_id, reference, note, moved_at, moved_by)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, uuid.New().String(), movement.StockItemID, movement.Kind, nullIfEmpty(movement.FromLocationID),
+			nullIfEmpty(movement.ToLocationID), part.Quantity, nullIfEmpty(part.LotID), nullIfEmpty(movement.Reference),
+			nullIfEmpty(movement.Note), movedAt, nullIfEmpty(movement.MovedBy))
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert stock movement: %v", err)
+		}
+	}
+	return lots, nil
+}
+
+// adjustmentMovement turns a signed change at one location into a movement in or out
+func adjustmentMovement(stockItemID string, locationID string, delta float64) StockMovement {
+	movement := StockMovement{StockItemID: stockItemID, Kind: MovementAdjustment, Quantity: delta}
+	if delta < 0 {
+		movement.FromLocationID, movement.Quantity = locationID, -delta
+	} else {
+		movement.ToLocationID = locationID
+	}
+	return movement
+}
+
+// shipmentStockItem returns the stock item a shipped line is taken from: the one named
+// on the line, or else the one linked to the line's variant. It returns "" for lines
+// that are not stocked.
+func shipmentStockItem(ctx context.Context, tx *sql.Tx, line ShipmentLine, item OrderItem) (string, error) {
+	if line.StockItemID != "" || item.VariantID == "" {
+		return line.StockItemID, nil
+	}
+	var stockItemID sql.NullString
+	err := tx.QueryRowContext(ctx, "SELECT stock_item_id FROM product_variants WHERE id = ?", item.VariantID).Scan(&stockItemID)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to query product variant: %v", err)
+	}
+	return stockItemID.String, nil
+}
+
+// queryStockLevels reads the non-zero quantities per location of the stock items
+// matching a condition on stock_items, keyed by stock item
+func queryStockLevels(ctx context.Context, q queryer, where string, args ...any) (map[string][]StockLevel, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT sl.stock_item_id, sl.location_id, l.name, sl.quantity
+		FROM stock_levels sl JOIN locations l ON l.id = sl.location_id
+		WHERE sl.quantity <> 0 AND sl.stock_item_id IN (SELECT id FROM stock_items WHERE `+where+`)
+		ORDER BY l.name
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stock levels: %v", err)
+	}
+	defer rows.Close()
+
+	levels := map[string][]StockLevel{}
+	for rows.Next() {
+		var stockItemID string
+		var level StockLevel
+		if err := rows.Scan(&stockItemID, &level.LocationID, &level.LocationName, &level.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan stock level: %v", err)
+		}
+		levels[stockItemID] = append(levels[stockItemID], level)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating stock levels: %v", err)
+	}
+	return levels, nil
+}
+
+// GetLocations returns every location by name with the units held there
+func (db *Database) GetLocations(ctx context.Context) ([]Location, error) {
+	rows, err := db.read.QueryContext(ctx, `
+		SELECT l.id, l.name, COALESCE((SELECT SUM(quantity) FROM stock_levels WHERE location_id = l.id), 0)
+		FROM locations l
+		ORDER BY l.name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query locations: %v", err)
+	}
+	defer rows.Close()
+
+	locations := []Location{}
+	for rows.Next() {
+		var location Location
+		if err := rows.Scan(&location.ID, &location.Name, &location.Units); err != nil {
+			return nil, fmt.Errorf("failed to scan location: %v", err)
+		}
+		locations = append(locations, location)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating locations: %v", err)
+	}
+	return locations, nil
+}
+
+// SaveLocation adds a location, or renames it when it has an ID
+func (db *Database) SaveLocation(ctx context.Context, location Location) (Location, error) {
+	location.Name = strings.TrimSpace(location.Name)
+	if location.Name == "" {
+		return Location{}, invalidf("a location must have a name")
+	}
+
+	var result sql.Result
+	var err error
+	if location.ID == "" {
+		location.ID = uuid.New().String()
+		result, err = db.db.ExecContext(ctx, "INSERT INTO locations (id, name, created_at) VALUES (?, ?, ?)",
+			location.ID, location.Name, time.Now().Format(priceTimeLayout))
+	} else {
+		result, err = db.db.ExecContext(ctx, "UPDATE locations SET name = ? WHERE id = ?", location.Name, location.ID)
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return Location{}, invalidf("a location named %q already exists", location.Name)
+		}
+		return Location{}, fmt.Errorf("failed to save location: %v", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return Location{}, &NotFoundError{Kind: "location", ID: location.ID}
+	}
+
+	err = db.db.QueryRowContext(ctx, "SELECT COALESCE(SUM(quantity), 0) FROM stock_levels WHERE location_id = ?",
+		location.ID).Scan(&location.Units)
+	if err != nil {
+		return Location{}, fmt.Errorf("failed to query location stock: %v", err)
+	}
+	return location, nil
+}
+
+// DeleteLocation removes a location that holds no stock. The default location cannot
+// be removed.
+func (db *Database) DeleteLocation(ctx context.Context, id string) (err error) {
+	if id == DefaultLocationID {
+		return invalidf("the default location cannot be deleted")
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	name, err := locationName(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	var held int
+	if err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM stock_levels WHERE location_id = ? AND quantity <> 0", id).Scan(&held); err != nil {
+		return fmt.Errorf("failed to query location stock: %v", err)
+	}
+	if held > 0 {
+		err = invalidf("%s still holds stock of %d items; move it elsewhere first", name, held)
+		return err
+	}
+
+	for _, table := range []string{"stock_levels", "lot_levels"} {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE location_id = ?", id); err != nil {
+			return fmt.Errorf("failed to delete from %s: %v", table, err)
+		}
+	}
+	if _, err = tx.ExecContext(ctx, "DELETE FROM locations WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete location: %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// TransferStock moves stock of one item from one location to another
+func (db *Database) TransferStock(ctx context.Context, transfer StockTransfer, movedBy string) (err error) {
+	if transfer.FromLocationID == "" || transfer.ToLocationID == "" {
+		return invalidf("a transfer needs a location to move from and one to move to")
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	_, err = moveStock(ctx, tx, StockMovement{
+		StockItemID: transfer.StockItemID, Kind: MovementTransfer, FromLocationID: transfer.FromLocationID,
+		ToLocationID: transfer.ToLocationID, Quantity: transfer.Quantity, LotID: transfer.LotID, Note: transfer.Note,
+		MovedBy: movedBy,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	db.log.Debug("Stock transferred", "item", transfer.StockItemID, "from", transfer.FromLocationID,
+		"to", transfer.ToLocationID, "quantity", transfer.Quantity)
+	db.publish(EntityStock, ActionUpdated, transfer.StockItemID)
+	return nil
+}
+
+// GetStockMovements returns the newest movements of a stock item, or of all stock when
+// stockItemID is "", newest first
+func (db *Database) GetStockMovements(ctx context.Context, stockItemID string, limit int) ([]StockMovement, error) {
+	rows, err := db.read.QueryContext(ctx, `
+		SELECT m.id, m.stock_item_id, COALESCE(s.name, ''), m.kind, m.from_location_id, COALESCE(f.name, ''),
+			m.to_location_id, COALESCE(t.name, ''), m.quantity, m.lot_id, COALESCE(lt.lot_number, ''), m.reference,
+			m.note, m.moved_at, m.moved_by
+		FROM stock_movements m
+		LEFT JOIN stock_items s ON s.id = m.stock_item_id
+		LEFT JOIN lots lt ON lt.id = m.lot_id
+		LEFT JOIN locations f ON f.id = m.from_location_id
+		LEFT JOIN locations t ON t.id = m.to_location_id
+		WHERE ?1 = '' OR m.stock_item_id = ?1
+		ORDER BY m.moved_at DESC, m.rowid DESC
+		LIMIT ?2
+	`, stockItemID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stock movements: %v", err)
+	}
+	defer rows.Close()
+
+	movements := []StockMovement{}
+	for rows.Next() {
+		var movement StockMovement
+		var from, to, lotID, reference, note, movedBy sql.NullString
+		err := rows.Scan(&movement.ID, &movement.StockItemID, &movement.StockItemName, &movement.Kind, &from,
+			&movement.FromLocation, &to, &movement.ToLocation, &movement.Quantity, &lotID, &movement.LotNumber,
+			&reference, &note, &movement.MovedAt, &movedBy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan stock movement: %v", err)
+		}
+		movement.FromLocationID, movement.ToLocationID, movement.LotID = from.String, to.String, lotID.String
+		movement.Reference, movement.Note, movement.MovedBy = reference.String, note.String, movedBy.String
+		movements = append(movements, movement)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating stock movements: %v", err)
+	}
+	return movements, nil
+}
+
+// GetLocations returns every location by name
+func (a *App) GetLocations() []Location {
+	ctx, logger, db, done := a.call("GetLocations")
+	defer done()
+
+	locations, err := db.GetLocations(ctx)
+	if err != nil {
+		logger.Error("Error getting locations", "err", err)
+		return []Location{}
+	}
+	return locations
+}
+
+// SaveLocation adds or renames a location and returns it, or an empty location on error
+func (a *App) SaveLocation(location Location) Location {
+	ctx, logger, db, done := a.call("SaveLocation")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error saving location", "err", err)
+		return Location{}
+	}
+
+	saved, err := db.SaveLocation(ctx, location)
+	if err != nil {
+		logger.Error("Error saving location", "id", location.ID, "err", err)
+		return Location{}
+	}
+	logger.Info("Location saved", "id", saved.ID, "name", saved.Name)
+	return saved
+}
+
+// DeleteLocation removes a location that holds no stock
+func (a *App) DeleteLocation(id string) bool {
+	ctx, logger, db, done := a.call("DeleteLocation")
+	defer done()
+
+	if err := a.authorize(RoleAdmin); err != nil {
+		logger.Error("Error deleting location", "err", err)
+		return false
+	}
+
+	if err := db.DeleteLocation(ctx, id); err != nil {
+		logger.Error("Error deleting location", "id", id, "err", err)
+		return false
+	}
+	logger.Info("Location deleted", "id", id)
+	return true
+}
+
+// TransferStock moves stock of one item between two locations
+func (a *App) TransferStock(transfer StockTransfer) bool {
+	ctx, logger, db, done := a.call("TransferStock")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error transferring stock", "err", err)
+		return false
+	}
+
+	if err := db.TransferStock(ctx, transfer, a.currentUser().Username); err != nil {
+		logger.Error("Error transferring stock", "item", transfer.StockItemID, "err", err)
+		return false
+	}
+	logger.Info("Stock transferred", "item", transfer.StockItemID, "quantity", transfer.Quantity)
+	return true
+}
+
+// AdjustStock adds stock to, or removes it from, one location of a stock item
+func (a *App) AdjustStock(stockItemID string, adjustment StockAdjustment) bool {
+	ctx, logger, db, done := a.call("AdjustStock")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error adjusting stock", "err", err)
+		return false
+	}
+
+	if _, err := db.AdjustStockQuantity(ctx, stockItemID, adjustment, a.currentUser().Username); err != nil {
+		logger.Error("Error adjusting stock", "item", stockItemID, "err", err)
+		return false
+	}
+	logger.Info("Stock adjusted", "item", stockItemID, "location", adjustment.LocationID, "delta", adjustment.Delta)
+	return true
+}
+
+// GetStockMovements returns the newest movements of a stock item, or of all stock when
+// stockItemID is ""
+func (a *App) GetStockMovements(stockItemID string) []StockMovement {
+	ctx, logger, db, done := a.call("GetStockMovements")
+	defer done()
+
+	movements, err := db.GetStockMovements(ctx, stockItemID, stockMovementLimit)
+	if err != nil {
+		logger.Error("Error getting stock movements", "item", stockItemID, "err", err)
+		return []StockMovement{}
+	}
+	return movements
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LogConfig selects the log format, levels and log file rotation. It is read from
+// logging.json in the app data directory; the GARDEN_LOG_FORMAT and GARDEN_LOG_LEVEL
+// environment variables override it.
+type LogConfig struct {
+	Format     string            `json:"format"`     // "text" or "json"
+	Level      string            `json:"level"`      // debug, info, warn or error
+	Levels     map[string]string `json:"levels"`     // per-subsystem levels, e.g. {"db": "debug"}
+	MaxSizeMB  int               `json:"maxSizeMB"`  // rotate the log file once it reaches this size
+	MaxAgeDays int               `json:"maxAgeDays"` // delete rotated log files older than this
+	MaxBackups int               `json:"maxBackups"` // keep at most this many rotated log files
+}
+
+// defaultLogConfig returns the configuration used when logging.json does not exist
+func defaultLogConfig() LogConfig {
+	return LogConfig{
+		Format:     "text",
+		Level:      "info",
+		Levels:     map[string]string{},
+		MaxSizeMB:  10,
+		MaxAgeDays: 30,
+		MaxBackups: 5,
+	}
+}
+
+// getLogConfigPath returns the path of the logging configuration file
+func getLogConfigPath() string {
+	return filepath.Join(getAppDataDir(), "logging.json")
+}
+
+// loadLogConfig reads the logging configuration from path, falling back to the defaults
+// for a missing file or missing fields, and applies the environment overrides. An
+// invalid configuration is reported along with the defaults to use instead.
+func loadLogConfig(path string) (LogConfig, error) {
+	config := defaultLogConfig()
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return config, fmt.Errorf("failed to read log config: %v", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &config); err != nil {
+			return defaultLogConfig(), fmt.Errorf("failed to parse log config %s: %v", path, err)
+		}
+		if config.Levels == nil {
+			config.Levels = map[string]string{}
+		}
+	}
+
+	if format := os.Getenv("GARDEN_LOG_FORMAT"); format != "" {
+		config.Format = format
+	}
+	if spec := os.Getenv("GARDEN_LOG_LEVEL"); spec != "" {
+		config.applyLevelSpec(spec)
+	}
+
+	if err := config.validate(); err != nil {
+		return defaultLogConfig(), err
+	}
+	return config, nil
+}
+
+// applyLevelSpec applies a comma-separated level list such as "warn,db=debug",
+// where a bare level sets the default and subsystem=level sets one subsystem
+func (c *LogConfig) applyLevelSpec(spec string) {
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if subsystem, level, ok := strings.Cut(part, "="); ok {
+			c.Levels[strings.TrimSpace(subsystem)] = strings.TrimSpace(level)
+		} else {
+			c.Level = part
+		}
+	}
+}
+
+// validate checks the format and every level name
+func (c LogConfig) validate() error {
+	if c.Format != "text" && c.Format != "json" {
+		return fmt.Errorf("invalid log format %q, expected text or json", c.Format)
+	}
+	if _, err := parseLogLevel(c.Level); err != nil {
+		return err
+	}
+	for subsystem, level := range c.Levels {
+		if _, err := parseLogLevel(level); err != nil {
+			return fmt.Errorf("log level for %s: %v", subsystem, err)
+		}
+	}
+	return nil
+}
+
+// parseLogLevel converts a level name to a slog.Level
+func parseLogLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return slog.LevelInfo, fmt.Errorf("invalid log level %q, expected debug, info, warn or error", name)
+	}
+	return level, nil
+}
+
+// Logging hands out a logger per subsystem ("app", "db", "api", ...). The loggers
+// share one output and format but each filters by its own level.
+type Logging struct {
+	handler slog.Handler
+	config  LogConfig
+}
+
+// NewLogging creates loggers writing to w in the configured format
+func NewLogging(w io.Writer, config LogConfig) *Logging {
+	// The shared handler passes everything; levelHandler filters per subsystem
+	options := &slog.HandlerOptions{Level: slog.LevelDebug}
+
+	var handler slog.Handler
+	if config.Format == "json" {
+		handler = slog.NewJSONHandler(w, options)
+	} else {
+		handler = slog.NewTextHandler(w, options)
+	}
+	return &Logging{handler: handler, config: config}
+}
+
+// Logger returns the logger of a subsystem
+func (l *Logging) Logger(subsystem string) *slog.Logger {
+	name, ok := l.config.Levels[subsystem]
+	if !ok {
+		name = l.config.Level
+	}
+	level, err := parseLogLevel(name)
+	if err != nil {
+		level = slog.LevelInfo
+	}
+
+	return slog.New(&levelHandler{level: level, inner: l.handler}).With("subsystem", subsystem)
+}
+
+// levelHandler drops records below its level before passing them to the shared handler
+type levelHandler struct {
+	level slog.Level
+	inner slog.Handler
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level && h.inner.Enabled(ctx, level)
+}
+
+func (h *levelHandler) Handle(ctx context.Context, record slog.Record) error {
+	return h.inner.Handle(ctx, record)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{level: h.level, inner: h.inner.WithAttrs(attrs)}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{level: h.level, inner: h.inner.WithGroup(name)}
+}
+
+// newCorrelationID returns a short random ID that ties together the log messages of one call
+func newCorrelationID() string {
+	return strings.ReplaceAll(uuid.NewString(), "-", "")[:12]
+}
+
+// rotatingFile is a log file that is renamed with a timestamp and replaced by a new
+// file once it reaches the maximum size. Rotated files past the maximum age or count
+// are deleted.
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// openRotatingFile opens (appending to) the log file at path
+func openRotatingFile(path string, config LogConfig) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %v", err)
+	}
+
+	f := &rotatingFile{
+		path:       path,
+		maxSize:    int64(config.MaxSizeMB) * 1024 * 1024,
+		maxAge:     time.Duration(config.MaxAgeDays) * 24 * time.Hour,
+		maxBackups: config.MaxBackups,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	f.prune()
+	return f, nil
+}
+
+// open opens the current log file and records its size
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %v", err)
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// Write implements io.Writer, rotating the file first if the write would exceed the maximum size
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate renames the current file with a timestamp and starts a new one
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %v", err)
+	}
+
+	ext := filepath.Ext(f.path)
+	base := strings.TrimSuffix(f.path, ext) + "-" + time.Now().Format("20060102-150405.000")
+	rotated := base + ext
+	for n := 1; ; n++ {
+		// Rotations within the same millisecond get a counter
+		if _, err := os.Stat(rotated); os.IsNotExist(err) {
+			break
+		}
+		rotated = fmt.Sprintf("%s-%d%s", base, n, ext)
+	}
+	if err := os.Rename(f.path, rotated); err != nil {
+		return fmt.Errorf("failed to rotate log file: %v", err)
+	}
+
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.prune()
+	return nil
+}
+
+// rotatedLogFiles returns the files a log at path has been rotated to, newest first
+func rotatedLogFiles(path string) []string {
+	ext := filepath.Ext(path)
+	matches, _ := filepath.Glob(strings.TrimSuffix(path, ext) + "-*" + ext)
+	// The timestamp (and counter) in the name sorts chronologically
+	sort.Slice(matches, func(i, j int) bool {
+		return strings.TrimSuffix(matches[i], ext) > strings.TrimSuffix(matches[j], ext)
+	})
+	return matches
+}
+
+// prune deletes rotated files beyond the maximum count or age
+func (f *rotatingFile) prune() {
+	for i, path := range rotatedLogFiles(f.path) {
+		expired := false
+		if f.maxAge > 0 {
+			if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > f.maxAge {
+				expired = true
+			}
+		}
+		if expired || (f.maxBackups > 0 && i >= f.maxBackups) {
+			os.Remove(path)
+		}
+	}
+}
+
+// Close closes the current log file
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
@@ -0,0 +1,536 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// expiringLotDays is how far ahead the expiring-soon report looks when no number of days
+// is given
+const expiringLotDays = 30
+
+// Lot is a batch of a stock item received together, such as one delivery of seed packets
+type Lot struct {
+	ID            string `json:"id"`
+	StockItemID   string `json:"stockItemId"`
+	StockItemName string `json:"stockItemName"`
+	LotNumber     string `json:"lotNumber"`
+	// ExpiresOn is the last day the lot can be sold, or "" if it does not expire
+	ExpiresOn  string `json:"expiresOn"`
+	ReceivedAt string `json:"receivedAt"`
+	// Quantity is what is left of the lot over all locations; Locations lists where
+	Quantity  float64      `json:"quantity"`
+	Locations []StockLevel `json:"locations"`
+}
+
+// LotQuantity is a quantity of stock from one lot. A LotQuantity without a LotID is
+// stock not tracked in lots.
+type LotQuantity struct {
+	LotID     string  `json:"lotId"`
+	LotNumber string  `json:"lotNumber"`
+	ExpiresOn string  `json:"expiresOn"`
+	Quantity  float64 `json:"quantity"`
+}
+
+// StockReceipt is the request body for receiving a lot of a stock item
+type StockReceipt struct {
+	LocationID string  `json:"locationId"`
+	LotNumber  string  `json:"lotNumber"`
+	ExpiresOn  string  `json:"expiresOn"`
+	Quantity   float64 `json:"quantity"`
+	Note       string  `json:"note"`
+}
+
+// LotShipment is a quantity of a lot that left with an order
+type LotShipment struct {
+	OrderID     string  `json:"orderId"`
+	OrderName   string  `json:"orderName"`
+	OrderItemID string  `json:"orderItemId"`
+	ProductName string  `json:"productName"`
+	ShipmentID  string  `json:"shipmentId"`
+	ShippedAt   string  `json:"shippedAt"`
+	Quantity    float64 `json:"quantity"`
+}
+
+// initializeLots creates the lots, lot_levels and shipment_item_lots tables and links
+// stock movements to lots
+func (db *Database) initializeLots() error {
+	_, err := db.db.Exec(`CREATE TABLE IF NOT EXISTS lots (
+		id TEXT PRIMARY KEY,
+		stock_item_id TEXT NOT NULL,
+		lot_number TEXT NOT NULL,
+		expires_on TEXT,
+		received_at TEXT NOT NULL,
+		UNIQUE (stock_item_id, lot_number),
+		FOREIGN KEY (stock_item_id) REFERENCES stock_items(id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create lots table: %v", err)
+	}
+	_, err = db.db.Exec("CREATE INDEX IF NOT EXISTS idx_lots_expires_on ON lots(expires_on)")
+	if err != nil {
+		return fmt.Errorf("failed to create lots index: %v", err)
+	}
+
+	_, err = db.db.Exec(`CREATE TABLE IF NOT EXISTS lot_levels (
+		lot_id TEXT NOT NULL,
+		location_id TEXT NOT NULL,
+		quantity REAL NOT NULL,
+		PRIMARY KEY (lot_id, location_id),
+		FOREIGN KEY (lot_id) REFERENCES lots(id),
+		FOREIGN KEY (location_id) REFERENCES locations(id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create lot_levels table: %v", err)
+	}
+
+	_, err = db.db.Exec(`CREATE TABLE IF NOT EXISTS shipment_item_lots (
+		shipment_item_id TEXT NOT NULL,
+		lot_id TEXT NOT NULL,
+		quantity REAL NOT NULL,
+		PRIMARY KEY (shipment_item_id, lot_id),
+		FOREIGN KEY (shipment_item_id) REFERENCES shipment_items(id),
+		FOREIGN KEY (lot_id) REFERENCES lots(id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create shipment_item_lots table: %v", err)
+	}
+	_, err = db.db.Exec("CREATE INDEX IF NOT EXISTS idx_shipment_item_lots_lot ON shipment_item_lots(lot_id)")
+	if err != nil {
+		return fmt.Errorf("failed to create shipment_item_lots index: %v", err)
+	}
+
+	exists, err := db.columnExists("stock_movements", "lot_id")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if _, err := db.db.Exec("ALTER TABLE stock_movements ADD COLUMN lot_id TEXT"); err != nil {
+			return fmt.Errorf("failed to add lot_id column to stock_movements table: %v", err)
+		}
+	}
+	return nil
+}
+
+// loadLot reads a lot of a stock item within a transaction
+func loadLot(ctx context.Context, tx *sql.Tx, stockItemID string, lotID string) (LotQuantity, error) {
+	lot := LotQuantity{LotID: lotID}
+	var expiresOn sql.NullString
+	err := tx.QueryRowContext(ctx, "SELECT lot_number, expires_on FROM lots WHERE id = ? AND stock_item_id = ?",
+		lotID, stockItemID).Scan(&lot.LotNumber, &expiresOn)
+	if err == sql.ErrNoRows {
+		return LotQuantity{}, &NotFoundError{Kind: "lot", ID: lotID}
+	}
+	if err != nil {
+		return LotQuantity{}, fmt.Errorf("failed to query lot: %v", err)
+	}
+	lot.ExpiresOn = expiresOn.String
+	return lot, nil
+}
+
+// takeLots removes a quantity of a stock item from the lots held at a location, within
+// a transaction, and returns how much came from each lot. With a lotID, all of it must
+// come from that lot. Otherwise the lots that expire first are used first, lots without
+// an expiry date after those with one, and what the lots there do not cover is left to
+// stock not tracked in lots. Lots past their expiry date are skipped when sellable is
+// set, and if the rest of the stock there does not cover the quantity, it is refused.
+func takeLots(ctx context.Context, tx *sql.Tx, stockItemID string, locationID string, lotID string, quantity float64, sellable bool) ([]LotQuantity, error) {
+	if lotID != "" {
+		lot, err := loadLot(ctx, tx, stockItemID, lotID)
+		if err != nil {
+			return nil, err
+		}
+		result, err := tx.ExecContext(ctx, `
+			UPDATE lot_levels SET quantity = quantity - ?1
+			WHERE lot_id = ?2 AND location_id = ?3 AND quantity - ?1 >= 0
+		`, quantity, lotID, locationID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update lot level: %v", err)
+		}
+		if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+			var available float64
+			tx.QueryRowContext(ctx, "SELECT quantity FROM lot_levels WHERE lot_id = ? AND location_id = ?",
+				lotID, locationID).Scan(&available)
+			return nil, invalidf("cannot take %g from lot %s, only %g there", quantity, lot.LotNumber, available)
+		}
+		lot.Quantity = quantity
+		return []LotQuantity{lot}, nil
+	}
+
+	today := time.Now().Format(scheduleDateLayout)
+	rows, err := tx.QueryContext(ctx, `
+		SELECT l.id, l.lot_number, l.expires_on, ll.quantity
+		FROM lot_levels ll JOIN lots l ON l.id = ll.lot_id
+		WHERE l.stock_item_id = ?1 AND ll.location_id = ?2 AND ll.quantity > 0
+			AND (NOT ?3 OR l.expires_on IS NULL OR l.expires_on >= ?4)
+		ORDER BY l.expires_on IS NULL, l.expires_on, l.received_at, l.rowid
+	`, stockItemID, locationID, sellable, today)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lot levels: %v", err)
+	}
+	var held []LotQuantity
+	for rows.Next() {
+		var lot LotQuantity
+		var expiresOn sql.NullString
+		if err := rows.Scan(&lot.LotID, &lot.LotNumber, &expiresOn, &lot.Quantity); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan lot level: %v", err)
+		}
+		lot.ExpiresOn = expiresOn.String
+		held = append(held, lot)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating lot levels: %v", err)
+	}
+
+	var taken []LotQuantity
+	for _, lot := range held {
+		if quantity <= 0 {
+			break
+		}
+		lot.Quantity = min(lot.Quantity, quantity)
+		_, err := tx.ExecContext(ctx, "UPDATE lot_levels SET quantity = quantity - ? WHERE lot_id = ? AND location_id = ?",
+			lot.Quantity, lot.LotID, locationID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update lot level: %v", err)
+		}
+		quantity -= lot.Quantity
+		taken = append(taken, lot)
+	}
+
+	// What is left must come from stock not tracked in lots, which the expired lots
+	// are not. The level there has already been reduced by the whole quantity.
+	if sellable && quantity > 0 {
+		var untracked float64
+		err := tx.QueryRowContext(ctx, `
+			SELECT COALESCE((SELECT quantity FROM stock_levels WHERE stock_item_id = ?1 AND location_id = ?2), 0)
+				- COALESCE((
+					SELECT SUM(ll.quantity) FROM lot_levels ll JOIN lots l ON l.id = ll.lot_id
+					WHERE l.stock_item_id = ?1 AND ll.location_id = ?2
+				), 0)
+		`, stockItemID, locationID).Scan(&untracked)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query untracked stock: %v", err)
+		}
+		if untracked < 0 {
+			var lotNumber, expiresOn string
+			tx.QueryRowContext(ctx, `
+				SELECT l.lot_number, l.expires_on FROM lot_levels ll JOIN lots l ON l.id = ll.lot_id
+				WHERE l.stock_item_id = ? AND ll.location_id = ? AND ll.quantity > 0 AND l.expires_on < ?
+				ORDER BY l.expires_on
+			`, stockItemID, locationID, today).Scan(&lotNumber, &expiresOn)
+			return nil, invalidf("not enough stock in date: lot %s expired on %s", lotNumber, expiresOn)
+		}
+	}
+	return taken, nil
+}
+
+// putLot adds a quantity of a lot to a location within a transaction
+func putLot(ctx context.Context, tx *sql.Tx, lotID string, locationID string, quantity float64) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO lot_levels (lot_id, location_id, quantity) VALUES (?, ?, ?)
+		ON CONFLICT (lot_id, location_id) DO UPDATE SET quantity = quantity + excluded.quantity
+	`, lotID, locationID, quantity)
+	if err != nil {
+		return fmt.Errorf("failed to update lot level: %v", err)
+	}
+	return nil
+}
+
+// lotNumbers lists the lot numbers of some lot quantities for an order's history
+func lotNumbers(lots []LotQuantity) string {
+	numbers := make([]string, len(lots))
+	for i, lot := range lots {
+		numbers[i] = lot.LotNumber
+	}
+	return strings.Join(numbers, ", ")
+}
+
+// insertShipmentLots records the lots a shipped line was taken from within a transaction
+func insertShipmentLots(ctx context.Context, tx *sql.Tx, shipmentItemID string, lots []LotQuantity) error {
+	for _, lot := range lots {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO shipment_item_lots (shipment_item_id, lot_id, quantity) VALUES (?, ?, ?)
+			ON CONFLICT (shipment_item_id, lot_id) DO UPDATE SET quantity = quantity + excluded.quantity
+		`, shipmentItemID, lot.LotID, lot.Quantity)
+		if err != nil {
+			return fmt.Errorf("failed to insert shipment lot: %v", err)
+		}
+	}
+	return nil
+}
+
+// queryShipmentLots reads the lots the shipped lines of an order were taken from, keyed
+// by shipment item
+func queryShipmentLots(ctx context.Context, q queryer, orderID string) (map[string][]LotQuantity, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT sil.shipment_item_id, l.id, l.lot_number, l.expires_on, sil.quantity
+		FROM shipment_item_lots sil
+		JOIN lots l ON l.id = sil.lot_id
+		JOIN shipment_items si ON si.id = sil.shipment_item_id
+		JOIN shipments s ON s.id = si.shipment_id
+		WHERE s.order_id = ?
+		ORDER BY l.expires_on IS NULL, l.expires_on, l.lot_number
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shipment lots: %v", err)
+	}
+	defer rows.Close()
+
+	lots := map[string][]LotQuantity{}
+	for rows.Next() {
+		var shipmentItemID string
+		var lot LotQuantity
+		var expiresOn sql.NullString
+		if err := rows.Scan(&shipmentItemID, &lot.LotID, &lot.LotNumber, &expiresOn, &lot.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan shipment lot: %v", err)
+		}
+		lot.ExpiresOn = expiresOn.String
+		lots[shipmentItemID] = append(lots[shipmentItemID], lot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating shipment lots: %v", err)
+	}
+	return lots, nil
+}
+
+// queryLots reads the lots matching a condition on lots l, those expiring first first,
+// with what is left of them at each location
+func queryLots(ctx context.Context, q queryer, where string, args ...any) ([]Lot, error) {
+	// Levels are read first, because the read pool may not have a second connection
+	// free while the lot rows are open
+	levelRows, err := q.QueryContext(ctx, `
+		SELECT ll.lot_id, ll.location_id, loc.name, ll.quantity
+		FROM lot_levels ll JOIN locations loc ON loc.id = ll.location_id
+		WHERE ll.quantity <> 0 AND ll.lot_id IN (SELECT l.id FROM lots l WHERE `+where+`)
+		ORDER BY loc.name
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lot levels: %v", err)
+	}
+	defer levelRows.Close()
+
+	levels := map[string][]StockLevel{}
+	for levelRows.Next() {
+		var lotID string
+		var level StockLevel
+		if err := levelRows.Scan(&lotID, &level.LocationID, &level.LocationName, &level.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan lot level: %v", err)
+		}
+		levels[lotID] = append(levels[lotID], level)
+	}
+	if err := levelRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating lot levels: %v", err)
+	}
+	levelRows.Close()
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT l.id, l.stock_item_id, COALESCE(s.name, ''), l.lot_number, l.expires_on, l.received_at
+		FROM lots l LEFT JOIN stock_items s ON s.id = l.stock_item_id
+		WHERE `+where+`
+		ORDER BY l.expires_on IS NULL, l.expires_on, l.received_at, l.rowid
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lots: %v", err)
+	}
+	defer rows.Close()
+
+	lots := []Lot{}
+	for rows.Next() {
+		var lot Lot
+		var expiresOn sql.NullString
+		err := rows.Scan(&lot.ID, &lot.StockItemID, &lot.StockItemName, &lot.LotNumber, &expiresOn, &lot.ReceivedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan lot: %v", err)
+		}
+		lot.ExpiresOn = expiresOn.String
+		lot.Locations = levels[lot.ID]
+		for _, level := range lot.Locations {
+			lot.Quantity += level.Quantity
+		}
+		lots = append(lots, lot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating lots: %v", err)
+	}
+	return lots, nil
+}
+
+// ReceiveStock adds a lot of a stock item at a location, or at the default location
+// when none is given. Receiving a lot number the item already has adds to that lot, as
+// long as the expiry dates agree.
+func (db *Database) ReceiveStock(ctx context.Context, stockItemID string, receipt StockReceipt, receivedBy string) (item StockItem, err error) {
+	receipt.LotNumber = strings.TrimSpace(receipt.LotNumber)
+	if receipt.LotNumber == "" {
+		return StockItem{}, invalidf("a receipt must have a lot number")
+	}
+	if receipt.ExpiresOn != "" {
+		if _, err := time.ParseInLocation(scheduleDateLayout, receipt.ExpiresOn, time.Local); err != nil {
+			return StockItem{}, invalidf("invalid expiry date %q, expected YYYY-MM-DD", receipt.ExpiresOn)
+		}
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return StockItem{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var lotID string
+	var expiresOn sql.NullString
+	err = tx.QueryRowContext(ctx, "SELECT id, expires_on FROM lots WHERE stock_item_id = ? AND lot_number = ?",
+		stockItemID, receipt.LotNumber).Scan(&lotID, &expiresOn)
+	switch {
+	case err == sql.ErrNoRows:
+		lotID = uuid.New().String()
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO lots (id, stock_item_id, lot_number, expires_on, received_at) VALUES (?, ?, ?, ?, ?)",
+			lotID, stockItemID, receipt.LotNumber, nullIfEmpty(receipt.ExpiresOn), time.Now().Format(priceTimeLayout),
+		)
+		if err != nil {
+			if strings.Contains(err.Error(), "FOREIGN KEY") {
+				return StockItem{}, &NotFoundError{Kind: "stock item", ID: stockItemID}
+			}
+			return StockItem{}, fmt.Errorf("failed to insert lot: %v", err)
+		}
+	case err != nil:
+		return StockItem{}, fmt.Errorf("failed to query lot: %v", err)
+	case expiresOn.String != receipt.ExpiresOn:
+		err = invalidf("lot %s was received before with expiry date %q", receipt.LotNumber, expiresOn.String)
+		return StockItem{}, err
+	}
+
+	_, err = moveStock(ctx, tx, StockMovement{
+		StockItemID: stockItemID, Kind: MovementReceipt, ToLocationID: firstNonEmpty(receipt.LocationID, DefaultLocationID),
+		Quantity: receipt.Quantity, LotID: lotID, Note: receipt.Note, MovedBy: receivedBy,
+	})
+	if err != nil {
+		return StockItem{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return StockItem{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	db.log.Debug("Stock received", "item", stockItemID, "lot", receipt.LotNumber, "quantity", receipt.Quantity)
+	db.publish(EntityStock, ActionUpdated, stockItemID)
+	return db.GetStockItem(ctx, stockItemID)
+}
+
+// GetLots returns every lot of a stock item, those expiring first first, including lots
+// with nothing left
+func (db *Database) GetLots(ctx context.Context, stockItemID string) ([]Lot, error) {
+	return queryLots(ctx, db.read, "l.stock_item_id = ?", stockItemID)
+}
+
+// GetExpiringLots returns the lots with stock left that expire within the given number
+// of days from today, or that have already expired, those expiring first first
+func (db *Database) GetExpiringLots(ctx context.Context, days int) ([]Lot, error) {
+	until := time.Now().AddDate(0, 0, days).Format(scheduleDateLayout)
+	return queryLots(ctx, db.read, `l.expires_on IS NOT NULL AND l.expires_on <= ?
+		AND EXISTS (SELECT 1 FROM lot_levels WHERE lot_id = l.id AND quantity > 0)`, until)
+}
+
+// GetLotShipments returns the order lines a lot was shipped with, oldest first
+func (db *Database) GetLotShipments(ctx context.Context, lotID string) ([]LotShipment, error) {
+	rows, err := db.read.QueryContext(ctx, `
+		SELECT o.id, o.name, oi.id, oi.name, s.id, s.shipped_at, sil.quantity
+		FROM shipment_item_lots sil
+		JOIN shipment_items si ON si.id = sil.shipment_item_id
+		JOIN shipments s ON s.id = si.shipment_id
+		JOIN order_items oi ON oi.id = si.order_item_id
+		JOIN orders o ON o.id = s.order_id
+		WHERE sil.lot_id = ?
+		ORDER BY s.shipped_at, s.rowid
+	`, lotID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lot shipments: %v", err)
+	}
+	defer rows.Close()
+
+	shipments := []LotShipment{}
+	for rows.Next() {
+		var shipment LotShipment
+		err := rows.Scan(&shipment.OrderID, &shipment.OrderName, &shipment.OrderItemID, &shipment.ProductName,
+			&shipment.ShipmentID, &shipment.ShippedAt, &shipment.Quantity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan lot shipment: %v", err)
+		}
+		shipments = append(shipments, shipment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating lot shipments: %v", err)
+	}
+	return shipments, nil
+}
+
+// ReceiveStock adds a lot of a stock item at a location
+func (a *App) ReceiveStock(stockItemID string, receipt StockReceipt) bool {
+	ctx, logger, db, done := a.call("ReceiveStock")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error receiving stock", "err", err)
+		return false
+	}
+
+	if _, err := db.ReceiveStock(ctx, stockItemID, receipt, a.currentUser().Username); err != nil {
+		logger.Error("Error receiving stock", "item", stockItemID, "lot", receipt.LotNumber, "err", err)
+		return false
+	}
+	logger.Info("Stock received", "item", stockItemID, "lot", receipt.LotNumber, "quantity", receipt.Quantity)
+	return true
+}
+
+// GetLots returns every lot of a stock item
+func (a *App) GetLots(stockItemID string) []Lot {
+	ctx, logger, db, done := a.call("GetLots")
+	defer done()
+
+	lots, err := db.GetLots(ctx, stockItemID)
+	if err != nil {
+		logger.Error("Error getting lots", "item", stockItemID, "err", err)
+		return []Lot{}
+	}
+	return lots
+}
+
+// GetExpiringLots returns the lots with stock left that expire within the given number
+// of days, or within 30 days when days is not positive
+func (a *App) GetExpiringLots(days int) []Lot {
+	ctx, logger, db, done := a.call("GetExpiringLots")
+	defer done()
+
+	if days <= 0 {
+		days = expiringLotDays
+	}
+	lots, err := db.GetExpiringLots(ctx, days)
+	if err != nil {
+		logger.Error("Error getting expiring lots", "days", days, "err", err)
+		return []Lot{}
+	}
+	return lots
+}
+
+// GetLotShipments returns the order lines a lot was shipped with
+func (a *App) GetLotShipments(lotID string) []LotShipment {
+	ctx, logger, db, done := a.call("GetLotShipments")
+	defer done()
+
+	shipments, err := db.GetLotShipments(ctx, lotID)
+	if err != nil {
+		logger.Error("Error getting lot shipments", "lot", lotID, "err", err)
+		return []LotShipment{}
+	}
+	return shipments
+}
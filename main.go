@@ -2,10 +2,12 @@ package main
 
 import (
 	"embed"
+	"io"
 	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
-	"runtime"
+	"strings"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/logger"
@@ -17,27 +19,57 @@ import (
 var assets embed.FS
 
 func main() {
-	// Create log file
+	// Subcommands run headless, without opening the desktop window
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
+	// Set up logging to the rotating log file
+	config, configErr := loadLogConfig(getLogConfigPath())
+
 	logPath := getLogFilePath()
-	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
+	var logOutput io.Writer = os.Stderr
+	if logFile, err := openRotatingFile(logPath, config); err != nil {
 		log.Printf("Failed to open log file: %v", err)
 	} else {
 		defer logFile.Close()
-		log.SetOutput(logFile)
-		log.Printf("Starting application, logs will be written to: %s", logPath)
+		logOutput = logFile
+	}
+
+	logging := NewLogging(logOutput, config)
+	// Messages from log.Printf and slog's top-level functions go to the app logger
+	slog.SetDefault(logging.Logger("app"))
+	slog.Info("Starting application", "logFile", logPath, "format", config.Format, "level", config.Level)
+	if configErr != nil {
+		slog.Warn("Invalid logging configuration, using defaults", "err", configErr)
+	}
+
+	// Open the database the app works on. If it cannot be opened the window still
+	// appears, showing the recovery screen instead of exiting.
+	var repos Repositories
+	db, dbErr := NewDatabase(logging.Logger("db"))
+	if dbErr != nil {
+		slog.Error("Failed to open database, starting in recovery mode", "path", getDBPath(), "err", dbErr)
+	} else {
+		repos = db.Repositories()
 	}
 
 	// Create application instance
-	app := NewApp()
+	images := NewImageStore(filepath.Join(getAppDataDir(), "images"))
+	app := NewApp(repos, db, images, logging.Logger("app"), logPath)
+	if dbErr != nil {
+		app.databaseFailed(getDBPath(), logging.Logger("db"), dbErr)
+	}
 
 	// Create application with options
-	err = wails.Run(&options.App{
+	err := wails.Run(&options.App{
 		Title:  "Garden Product Manager",
 		Width:  1024,
 		Height: 768,
 		AssetServer: &assetserver.Options{
 			Assets: assets,
+			// Product images and thumbnails are served from the data directory
+			Handler: app.images,
 		},
 		BackgroundColour: &options.RGBA{R: 46, G: 125, B: 50, A: 1},
 		OnStartup:        app.startup,
@@ -51,32 +83,15 @@ func main() {
 	})
 
 	if err != nil {
-		log.Fatalf("Error starting application: %v", err)
+		slog.Error("Error starting application", "err", err)
+		os.Exit(1)
 	}
 }
 
 // getLogFilePath returns the platform-specific log file path
 func getLogFilePath() string {
-	var appDataDir string
-
-	switch runtime.GOOS {
-	case "windows":
-		// For Windows - use %APPDATA%
-		appDataDir = os.Getenv("APPDATA")
-		if appDataDir == "" {
-			// Fallback if %APPDATA% is not available
-			appDataDir = filepath.Join(os.Getenv("USERPROFILE"), "AppData", "Roaming")
-		}
-	case "darwin":
-		// For macOS - use ~/Library/Application Support
-		appDataDir = filepath.Join(os.Getenv("HOME"), "Library", "Application Support")
-	default:
-		// For Linux and others - use ~/.local/share
-		appDataDir = filepath.Join(os.Getenv("HOME"), ".local", "share")
-	}
-
 	// Create application-specific folder
-	logDir := filepath.Join(appDataDir, "GardenProductManager", "logs")
+	logDir := filepath.Join(getAppDataDir(), "logs")
 	os.MkdirAll(logDir, 0755)
 
 	return filepath.Join(logDir, "app.log")
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// memoryStore keeps products, orders and stock in memory, so the App can be tested
+// without SQLite. Records are listed in the order they were added, and setting err
+// makes every method fail with it.
+type memoryStore struct {
+	mu       sync.Mutex
+	err      error
+	products []Product
+	orders   []Order
+	history  map[string][]OrderChange
+	stock    []StockItem
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{}
+}
+
+// Repositories returns the store as all three App repositories
+func (m *memoryStore) Repositories() Repositories {
+	return Repositories{Products: m, Orders: m, Stock: m}
+}
+
+// fail makes every following call return err, or succeed again for nil
+func (m *memoryStore) fail(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.err = err
+}
+
+func (m *memoryStore) GetProducts(ctx context.Context) ([]Product, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	return append([]Product{}, m.products...), nil
+}
+
+func (m *memoryStore) AddProduct(ctx context.Context, product Product) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return "", m.err
+	}
+
+	if product.ID == "" {
+		product.ID = uuid.NewString()
+	}
+	if m.productIndex(product.ID) >= 0 {
+		return "", invalidf("a product with ID %s already exists", product.ID)
+	}
+	m.products = append(m.products, product)
+	return product.ID, nil
+}
+
+func (m *memoryStore) UpdateProduct(ctx context.Context, product Product) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+
+	i := m.productIndex(product.ID)
+	if i < 0 {
+		return &NotFoundError{Kind: "product", ID: product.ID}
+	}
+	m.products[i] = product
+	return nil
+}
+
+func (m *memoryStore) DeleteProduct(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+
+	i := m.productIndex(id)
+	if i < 0 {
+		return &NotFoundError{Kind: "product", ID: id}
+	}
+	m.products = append(m.products[:i], m.products[i+1:]...)
+	return nil
+}
+
+func (m *memoryStore) GetProductImage(ctx context.Context, productID string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return "", m.err
+	}
+
+	i := m.productIndex(productID)
+	if i < 0 {
+		return "", &NotFoundError{Kind: "product", ID: productID}
+	}
+	return m.products[i].Image, nil
+}
+
+func (m *memoryStore) GetReferencedImages(ctx context.Context) (map[string]bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	referenced := map[string]bool{}
+	for _, product := range m.products {
+		if product.Image != "" {
+			referenced[product.Image] = true
+		}
+	}
+	return referenced, nil
+}
+
+func (m *memoryStore) productIndex(id string) int {
+	for i, product := range m.products {
+		if product.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *memoryStore) GetOrders(ctx context.Context) ([]Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	return append([]Order{}, m.orders...), nil
+}
+
+func (m *memoryStore) CreateOrder(ctx context.Context, name string, description string, items []OrderItem) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return "", m.err
+	}
+
+	total := orderTotal(items)
+
+	order := Order{
+		ID:          strconv.Itoa(len(m.orders) + 1),
+		Date:        GetFormattedDate(),
+		Name:        name,
+		Description: description,
+		Items:       append([]OrderItem{}, items...),
+		Total:       total,
+		Status:      "Pending",
+	}
+	setPaymentStatus(&order)
+	m.orders = append(m.orders, order)
+	return order.ID, nil
+}
+
+func (m *memoryStore) UpdateOrderStatus(ctx context.Context, orderID string, status string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+
+	for i := range m.orders {
+		if m.orders[i].ID == orderID {
+			m.orders[i].Status = status
+			return nil
+		}
+	}
+	return &NotFoundError{Kind: "order", ID: orderID}
+}
+
+func (m *memoryStore) DeleteOrder(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+
+	for i := range m.orders {
+		if m.orders[i].ID == id {
+			m.orders = append(m.orders[:i], m.orders[i+1:]...)
+			delete(m.history, id)
+			return nil
+		}
+	}
+	return &NotFoundError{Kind: "order", ID: id}
+}
+
+func (m *memoryStore) UpdateOrder(ctx context.Context, id string, update NewOrder, changedBy string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+
+	for i := range m.orders {
+		if m.orders[i].ID != id {
+			continue
+		}
+		if err := validateOrderEdit(m.orders[i].Status, update); err != nil {
+			return err
+		}
+
+		if m.history == nil {
+			m.history = make(map[string][]OrderChange)
+		}
+		for _, change := range describeOrderChanges(m.orders[i], update) {
+			m.history[id] = append(m.history[id], OrderChange{
+				ID: uuid.New().String(), OrderID: id, ChangedAt: GetFormattedDate(), ChangedBy: changedBy, Change: change,
+			})
+		}
+		m.orders[i].Name = update.Name
+		m.orders[i].Description = update.Description
+		m.orders[i].Items = append([]OrderItem{}, update.Items...)
+		m.orders[i].Total = orderTotal(update.Items)
+		setPaymentStatus(&m.orders[i])
+		return nil
+	}
+	return &NotFoundError{Kind: "order", ID: id}
+}
+
+func (m *memoryStore) GetOrderHistory(ctx context.Context, orderID string) ([]OrderChange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	return append([]OrderChange{}, m.history[orderID]...), nil
+}
+
+func (m *memoryStore) GetStockItems(ctx context.Context) ([]StockItem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	return append([]StockItem{}, m.stock...), nil
+}
+
+func (m *memoryStore) AddStockItem(ctx context.Context, item StockItem) (StockItem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return StockItem{}, m.err
+	}
+
+	if item.ID == "" {
+		item.ID = uuid.NewString()
+	}
+	m.stock = append(m.stock, item)
+	return item, nil
+}
+
+func (m *memoryStore) UpdateStockItem(ctx context.Context, item StockItem) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return false, m.err
+	}
+
+	for i := range m.stock {
+		if m.stock[i].ID == item.ID {
+			m.stock[i] = item
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memoryStore) DeleteStockItem(ctx context.Context, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return false, m.err
+	}
+
+	for i := range m.stock {
+		if m.stock[i].ID == id {
+			m.stock = append(m.stock[:i], m.stock[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
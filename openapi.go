@@ -0,0 +1,180 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// pathParamPattern matches {name} placeholders in route paths
+var pathParamPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// buildOpenAPIDocument generates an OpenAPI 3 document describing the given routes.
+// Request and response schemas are derived from the Go types by reflection.
+func buildOpenAPIDocument(routes []apiRoute) map[string]interface{} {
+	schemas := map[string]interface{}{}
+	schemas["APIError"] = schemaFor(reflect.TypeOf(APIError{}), schemas)
+
+	paths := map[string]interface{}{}
+	for _, route := range routes {
+		item, ok := paths[route.Path].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[route.Path] = item
+		}
+
+		var parameters []interface{}
+		for _, match := range pathParamPattern.FindAllStringSubmatch(route.Path, -1) {
+			parameters = append(parameters, map[string]interface{}{
+				"name": match[1], "in": "path", "required": true,
+				"schema": map[string]interface{}{"type": "string"},
+			})
+		}
+		for _, param := range route.Params {
+			parameters = append(parameters, map[string]interface{}{
+				"name": param.Name, "in": "query", "required": param.Required,
+				"description": param.Description,
+				"schema":      map[string]interface{}{"type": param.Type},
+			})
+		}
+
+		errorResponse := map[string]interface{}{
+			"description": "Error",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": map[string]interface{}{"$ref": "#/components/schemas/APIError"},
+				},
+			},
+		}
+		responses := map[string]interface{}{"default": errorResponse}
+		if route.Response == nil {
+			responses["204"] = map[string]interface{}{"description": "No Content"}
+		} else {
+			responses[strconv.Itoa(route.Status)] = map[string]interface{}{
+				"description": http.StatusText(route.Status),
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": schemaFor(reflect.TypeOf(route.Response), schemas),
+					},
+				},
+			}
+		}
+
+		operation := map[string]interface{}{
+			"summary":     route.Summary,
+			"operationId": operationID(route),
+			"tags":        []string{route.Tag},
+			"responses":   responses,
+		}
+		if route.Public {
+			operation["security"] = []interface{}{}
+		} else {
+			operation["description"] = "Requires the " + route.requiredRole() + " role."
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if route.Body != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": schemaFor(reflect.TypeOf(route.Body), schemas),
+					},
+				},
+			}
+		}
+
+		item[strings.ToLower(route.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Garden Product Manager API",
+			"version": appVersion,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+		},
+		// Every operation needs a session token unless it overrides this
+		"security": []interface{}{map[string]interface{}{"bearerAuth": []string{}}},
+	}
+}
+
+// operationID derives a stable operation name such as "getProductsIdVariants" from a route
+func operationID(route apiRoute) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(route.Method))
+	for _, part := range strings.Split(strings.TrimPrefix(route.Path, "/api/"), "/") {
+		part = strings.Trim(part, "{}")
+		for _, word := range strings.Split(part, "-") {
+			if word != "" {
+				b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+			}
+		}
+	}
+	return b.String()
+}
+
+// schemaFor returns the JSON schema of a Go type, registering named structs as components
+func schemaFor(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := schemaFor(t.Elem(), schemas)
+		return map[string]interface{}{"allOf": []interface{}{schema}, "nullable": true}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaFor(t.Elem(), schemas)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return structSchema(t, schemas)
+		}
+		if _, ok := schemas[t.Name()]; !ok {
+			// Register a placeholder first so self-referencing types terminate
+			schemas[t.Name()] = map[string]interface{}{}
+			schemas[t.Name()] = structSchema(t, schemas)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// structSchema returns the object schema of a struct type using its JSON field names
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Name
+		if tag := field.Tag.Get("json"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			if tagName := strings.Split(tag, ",")[0]; tagName != "" {
+				name = tagName
+			}
+		}
+		properties[name] = schemaFor(field.Type, schemas)
+	}
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
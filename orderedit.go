@@ -0,0 +1,394 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// editableOrderStatuses are the statuses in which an order's name, description and
+// lines may still be changed
+var editableOrderStatuses = map[string]bool{"Pending": true, "Processing": true}
+
+// OrderChange is one entry of an order's change history
+type OrderChange struct {
+	ID        string `json:"id"`
+	OrderID   string `json:"orderId"`
+	ChangedAt string `json:"changedAt"`
+	ChangedBy string `json:"changedBy"`
+	Change    string `json:"change"`
+}
+
+// orderLineMatch pairs a line of an edited order with the existing line it replaces,
+// or with -1 when the line is new
+type orderLineMatch struct {
+	before int
+	after  int
+}
+
+// initializeOrderHistory creates the order_history table
+func (db *Database) initializeOrderHistory() error {
+	_, err := db.db.Exec(`CREATE TABLE IF NOT EXISTS order_history (
+		id TEXT PRIMARY KEY,
+		order_id TEXT NOT NULL,
+		changed_at TEXT NOT NULL,
+		changed_by TEXT,
+		change TEXT NOT NULL,
+		FOREIGN KEY (order_id) REFERENCES orders(id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create order_history table: %v", err)
+	}
+
+	_, err = db.db.Exec("CREATE INDEX IF NOT EXISTS idx_order_history_order ON order_history(order_id, changed_at)")
+	if err != nil {
+		return fmt.Errorf("failed to create order_history index: %v", err)
+	}
+	return nil
+}
+
+// orderTotal sums the price of every line of an order
+func orderTotal(items []OrderItem) float64 {
+	var total float64
+	for _, item := range items {
+		total += item.Price * float64(item.Quantity)
+	}
+	return total
+}
+
+// validateOrderEdit checks an edited order before it replaces the stored one
+func validateOrderEdit(status string, update NewOrder) error {
+	if !editableOrderStatuses[status] {
+		return invalidf("an order that is %s can no longer be edited", status)
+	}
+	if strings.TrimSpace(update.Name) == "" {
+		return invalidf("an order must have a name")
+	}
+	if len(update.Items) == 0 {
+		return invalidf("an order must keep at least one item")
+	}
+	for _, item := range update.Items {
+		if item.Quantity <= 0 {
+			return invalidf("quantity of %s must be positive", item.ProductName)
+		}
+	}
+	return nil
+}
+
+// matchOrderLines pairs the lines of an edited order with the existing lines for the same
+// product and variant. Existing lines left unpaired are returned as removed.
+func matchOrderLines(before []OrderItem, after []OrderItem) (matches []orderLineMatch, removed []int) {
+	used := make([]bool, len(before))
+	for i, item := range after {
+		match := orderLineMatch{before: -1, after: i}
+		for j, existing := range before {
+			if !used[j] && existing.ProductID == item.ProductID && existing.VariantID == item.VariantID {
+				used[j] = true
+				match.before = j
+				break
+			}
+		}
+		matches = append(matches, match)
+	}
+
+	for j := range before {
+		if !used[j] {
+			removed = append(removed, j)
+		}
+	}
+	return matches, removed
+}
+
+// describeOrderChanges lists what an edit changes in an order, for its history
+func describeOrderChanges(order Order, update NewOrder) []string {
+	var changes []string
+	if update.Name != order.Name {
+		changes = append(changes, fmt.Sprintf("Name changed from %q to %q", order.Name, update.Name))
+	}
+	if update.Description != order.Description {
+		changes = append(changes, "Description changed")
+	}
+
+	matches, removed := matchOrderLines(order.Items, update.Items)
+	for _, match := range matches {
+		item := update.Items[match.after]
+		if match.before < 0 {
+			changes = append(changes, fmt.Sprintf("Added %d × %s", item.Quantity, item.ProductName))
+			continue
+		}
+
+		existing := order.Items[match.before]
+		if item.Quantity != existing.Quantity {
+			changes = append(changes, fmt.Sprintf("%s quantity changed from %d to %d", item.ProductName, existing.Quantity, item.Quantity))
+		}
+		if item.Price != existing.Price {
+			change := fmt.Sprintf("%s price changed from %s to %s", item.ProductName, formatAmount(existing.Price), formatAmount(item.Price))
+			if item.OverrideReason != "" {
+				change += " (" + item.OverrideReason + ")"
+			}
+			changes = append(changes, change)
+		}
+	}
+	for _, j := range removed {
+		changes = append(changes, fmt.Sprintf("Removed %d × %s", order.Items[j].Quantity, order.Items[j].ProductName))
+	}
+	return changes
+}
+
+// resolveEditedItems prices the lines of an edited order from the catalogue. Lines whose
+// price and override reason are unchanged keep the product data they were sold with.
+func resolveEditedItems(ctx context.Context, tx *sql.Tx, before []OrderItem, after []OrderItem) ([]OrderItem, error) {
+	matches, _ := matchOrderLines(before, after)
+	resolved := make([]OrderItem, len(after))
+	for _, match := range matches {
+		item := after[match.after]
+		if match.before >= 0 {
+			existing := before[match.before]
+			if toCents(item.Price) == toCents(existing.Price) && item.OverrideReason == existing.OverrideReason {
+				existing.Quantity = item.Quantity
+				resolved[match.after] = existing
+				continue
+			}
+		}
+
+		var err error
+		if resolved[match.after], err = resolveOrderItem(ctx, tx, item); err != nil {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
+
+// UpdateOrder changes the name, description and lines of an order that has not shipped
+// yet and records each change in the order's history. Lines are matched to the existing
+// ones by product and variant, so unchanged lines keep their identity.
+func (db *Database) UpdateOrder(ctx context.Context, id string, update NewOrder, changedBy string) (err error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	order, err := loadOrder(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if err = validateOrderEdit(order.Status, update); err != nil {
+		return err
+	}
+	if update.Items, err = resolveEditedItems(ctx, tx, order.Items, update.Items); err != nil {
+		return err
+	}
+	// Refunds paid back to the customer lower what they have paid
+	paid := toCents(order.Paid) - toCents(order.RefundsPaidOut)
+	if toCents(orderTotal(update.Items)) < paid {
+		return invalidf("order %s has %s paid, more than its new total", id, formatAmount(float64(paid)/100))
+	}
+
+	changes := describeOrderChanges(order, update)
+	if len(changes) == 0 {
+		return tx.Rollback()
+	}
+
+	// Stock reserved by lines that are removed is released
+	reserved, err := orderStockItems(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	// A line cannot be removed or reduced below what has already shipped
+	matches, removed := matchOrderLines(order.Items, update.Items)
+	for _, j := range removed {
+		if order.Items[j].Shipped > 0 {
+			return invalidf("%s has already shipped and cannot be removed", order.Items[j].ProductName)
+		}
+	}
+	for _, match := range matches {
+		if match.before >= 0 && update.Items[match.after].Quantity < order.Items[match.before].Shipped {
+			existing := order.Items[match.before]
+			return invalidf("%d × %s have already shipped", existing.Shipped, existing.ProductName)
+		}
+	}
+
+	for _, j := range removed {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM order_items WHERE id = ?", order.Items[j].ID); err != nil {
+			return fmt.Errorf("failed to delete order item: %v", err)
+		}
+	}
+	for _, match := range matches {
+		item := update.Items[match.after]
+		if match.before < 0 {
+			if err = insertOrderItem(ctx, tx, uuid.New().String(), id, item); err != nil {
+				return err
+			}
+			continue
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE order_items SET name = ?, price = ?, quantity = ?, description = ?, sku = ?, list_price = ?, override_reason = ?
+			WHERE id = ?
+		`, item.ProductName, item.Price, item.Quantity, nullIfEmpty(item.Description), nullIfEmpty(item.SKU), item.ListPrice,
+			nullIfEmpty(item.OverrideReason), order.Items[match.before].ID)
+		if err != nil {
+			return fmt.Errorf("failed to save order item: %v", err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE orders SET name = ?, description = ?, total = ? WHERE id = ?",
+		update.Name, update.Description, orderTotal(update.Items), id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update order: %v", err)
+	}
+
+	if err = insertOrderChanges(ctx, tx, id, changedBy, changes); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	db.log.Debug("Order updated", "id", id, "changes", len(changes))
+	db.publish(EntityOrder, ActionUpdated, id)
+	db.publishReservations(id, reserved)
+	return nil
+}
+
+// loadOrder reads an order and its items, with how much of each has shipped and come
+// back, from the database or within a transaction
+func loadOrder(ctx context.Context, q queryer, id string) (Order, error) {
+	order := Order{ID: id}
+	var name, description sql.NullString
+	err := q.QueryRowContext(ctx, `
+		SELECT date, name, description, total, status,
+			COALESCE((SELECT SUM(refund) FROM returns WHERE order_id = orders.id), 0),
+			COALESCE((SELECT SUM(paid_out) FROM returns WHERE order_id = orders.id), 0),
+			COALESCE((SELECT SUM(amount) FROM payments WHERE order_id = orders.id), 0)
+		FROM orders WHERE id = ?
+	`, id).Scan(&order.Date, &name, &description, &order.Total, &order.Status, &order.Refunded, &order.RefundsPaidOut, &order.Paid)
+	if err == sql.ErrNoRows {
+		return order, &NotFoundError{Kind: "order", ID: id}
+	}
+	if err != nil {
+		return order, fmt.Errorf("failed to query order: %v", err)
+	}
+	order.Name, order.Description = name.String, description.String
+	if !name.Valid {
+		order.Name = "Order #" + order.ID
+	}
+	setPaymentStatus(&order)
+
+	// Read the lines in the order they were added
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, product_id, variant_id, name, price, quantity, description, sku, list_price, override_reason,
+			COALESCE((SELECT SUM(quantity) FROM shipment_items WHERE order_item_id = order_items.id), 0),
+			COALESCE((SELECT SUM(quantity) FROM return_items WHERE order_item_id = order_items.id), 0)
+		FROM order_items WHERE order_id = ? ORDER BY rowid
+	`, id)
+	if err != nil {
+		return order, fmt.Errorf("failed to query order items: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item OrderItem
+		var variantID, description, sku, overrideReason sql.NullString
+		var listPrice sql.NullFloat64
+		if err := rows.Scan(&item.ID, &item.ProductID, &variantID, &item.ProductName, &item.Price, &item.Quantity,
+			&description, &sku, &listPrice, &overrideReason, &item.Shipped, &item.Returned); err != nil {
+			return order, fmt.Errorf("failed to scan order item: %v", err)
+		}
+		item.VariantID = variantID.String
+		scanLineSnapshot(&item, description, sku, listPrice, overrideReason)
+		item.Remaining = item.Quantity - item.Shipped
+		order.Items = append(order.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return order, fmt.Errorf("error iterating order items: %v", err)
+	}
+	return order, nil
+}
+
+// insertOrderChanges records changes to an order in its history within a transaction
+func insertOrderChanges(ctx context.Context, tx *sql.Tx, orderID string, changedBy string, changes []string) error {
+	changedAt := time.Now().Format(priceTimeLayout)
+	for _, change := range changes {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO order_history (id, order_id, changed_at, changed_by, change) VALUES (?, ?, ?, ?, ?)",
+			uuid.New().String(), orderID, changedAt, nullIfEmpty(changedBy), change,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record order change: %v", err)
+		}
+	}
+	return nil
+}
+
+// GetOrderHistory returns the changes made to an order, oldest first
+func (db *Database) GetOrderHistory(ctx context.Context, orderID string) ([]OrderChange, error) {
+	rows, err := db.read.QueryContext(ctx, `
+		SELECT id, order_id, changed_at, changed_by, change FROM order_history
+		WHERE order_id = ?
+		ORDER BY changed_at, rowid
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order history: %v", err)
+	}
+	defer rows.Close()
+
+	history := []OrderChange{}
+	for rows.Next() {
+		var change OrderChange
+		var changedBy sql.NullString
+		if err := rows.Scan(&change.ID, &change.OrderID, &change.ChangedAt, &changedBy, &change.Change); err != nil {
+			return nil, fmt.Errorf("failed to scan order change: %v", err)
+		}
+		change.ChangedBy = changedBy.String
+		history = append(history, change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating order history: %v", err)
+	}
+
+	return history, nil
+}
+
+// UpdateOrder changes the name, description and items of an order that has not shipped yet
+func (a *App) UpdateOrder(orderID string, order NewOrder) bool {
+	ctx, logger, repos, done := a.callRepositories("UpdateOrder")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error updating order", "err", err)
+		return false
+	}
+
+	if err := repos.Orders.UpdateOrder(ctx, orderID, order, a.currentUser().Username); err != nil {
+		logger.Error("Error updating order", "id", orderID, "err", err)
+		return false
+	}
+	logger.Info("Order updated", "id", orderID)
+	return true
+}
+
+// GetOrderHistory returns the changes made to an order, oldest first
+func (a *App) GetOrderHistory(orderID string) []OrderChange {
+	ctx, logger, repos, done := a.callRepositories("GetOrderHistory")
+	defer done()
+
+	history, err := repos.Orders.GetOrderHistory(ctx, orderID)
+	if err != nil {
+		logger.Error("Error getting order history", "err", err)
+		return []OrderChange{}
+	}
+	return history
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// priceOverrideReasons are the reason codes accepted for selling a line at a price
+// other than its list price
+var priceOverrideReasons = map[string]bool{
+	"Discount":   true,
+	"Bulk":       true,
+	"Damaged":    true,
+	"PriceMatch": true,
+	"Quote":      true,
+}
+
+// lineSnapshotColumns record the product data an order or quote line was created with
+var lineSnapshotColumns = []struct{ name, definition string }{
+	{"description", "TEXT"},
+	{"sku", "TEXT"},
+	{"list_price", "REAL"},
+	{"override_reason", "TEXT"},
+}
+
+// initializeLineSnapshots adds the snapshot columns to order_items and quote_items
+func (db *Database) initializeLineSnapshots() error {
+	for _, table := range []string{"order_items", "quote_items"} {
+		for _, column := range lineSnapshotColumns {
+			exists, err := db.columnExists(table, column.name)
+			if err != nil {
+				return err
+			}
+			if exists {
+				continue
+			}
+			_, err = db.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column.name, column.definition))
+			if err != nil {
+				return fmt.Errorf("failed to add %s column to %s table: %v", column.name, table, err)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveOrderItem fills a line with the name, description, SKU and list price of its
+// product or variant as they are now. The line is sold at the list price unless it
+// gives a reason code for its own price.
+func resolveOrderItem(ctx context.Context, tx *sql.Tx, item OrderItem) (OrderItem, error) {
+	var name string
+	var description, sku sql.NullString
+	var listPrice float64
+	err := tx.QueryRowContext(ctx, "SELECT name, description, sku, "+currentPriceSQL+" FROM products WHERE id = ?2",
+		time.Now().Format(priceTimeLayout), item.ProductID).Scan(&name, &description, &sku, &listPrice)
+	if err == sql.ErrNoRows {
+		return item, &NotFoundError{Kind: "product", ID: item.ProductID}
+	}
+	if err != nil {
+		return item, fmt.Errorf("failed to query product: %v", err)
+	}
+	item.ProductName, item.Description, item.SKU, item.ListPrice = name, description.String, sku.String, listPrice
+
+	if item.VariantID != "" {
+		rows, err := tx.QueryContext(ctx, "SELECT "+variantColumns+" FROM product_variants WHERE id = ? AND product_id = ?",
+			item.VariantID, item.ProductID)
+		if err != nil {
+			return item, fmt.Errorf("failed to query product variant: %v", err)
+		}
+		variants, err := scanVariants(rows)
+		rows.Close()
+		if err != nil {
+			return item, err
+		}
+		if len(variants) == 0 {
+			return item, &NotFoundError{Kind: "product variant", ID: item.VariantID}
+		}
+		variant := variants[0]
+		item.ProductName = variantTitle(name, variant)
+		item.Description = firstNonEmpty(variant.Description, item.Description)
+		item.SKU = firstNonEmpty(variant.SKU, item.SKU)
+		item.ListPrice = variant.Price
+	}
+
+	switch {
+	case item.OverrideReason == "":
+		item.Price = item.ListPrice
+	case !priceOverrideReasons[item.OverrideReason]:
+		return item, invalidf("unknown price override reason %q", item.OverrideReason)
+	case item.Price < 0:
+		return item, invalidf("price of %s cannot be negative", item.ProductName)
+	case toCents(item.Price) == toCents(item.ListPrice):
+		// The price was not actually overridden
+		item.OverrideReason = ""
+	}
+	return item, nil
+}
+
+// resolveOrderItems resolves every line of an order or quote within a transaction
+func resolveOrderItems(ctx context.Context, tx *sql.Tx, items []OrderItem) ([]OrderItem, error) {
+	resolved := make([]OrderItem, len(items))
+	for i, item := range items {
+		var err error
+		if resolved[i], err = resolveOrderItem(ctx, tx, item); err != nil {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
+
+// atListPrice returns copies of lines with their price overrides dropped, for lines
+// that are always sold at the catalogue's current price
+func atListPrice(items []OrderItem) []OrderItem {
+	plain := make([]OrderItem, len(items))
+	for i, item := range items {
+		item.OverrideReason = ""
+		plain[i] = item
+	}
+	return plain
+}
+
+// scanLineSnapshot copies the snapshot columns of a line read from the database onto
+// it. Lines created before snapshots were recorded report their price as list price.
+func scanLineSnapshot(item *OrderItem, description, sku sql.NullString, listPrice sql.NullFloat64, overrideReason sql.NullString) {
+	item.Description, item.SKU, item.OverrideReason = description.String, sku.String, overrideReason.String
+	item.ListPrice = item.Price
+	if listPrice.Valid {
+		item.ListPrice = listPrice.Float64
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// paymentMethods are the ways a customer can pay
+var paymentMethods = map[string]bool{"Cash": true, "Card": true, "Transfer": true}
+
+// Payment statuses derived from an order's payments
+const (
+	PaymentUnpaid        = "Unpaid"
+	PaymentPartiallyPaid = "Partially Paid"
+	PaymentPaid          = "Paid"
+)
+
+// NewPayment is the request body for recording a payment against an order
+type NewPayment struct {
+	Method string  `json:"method"`
+	Amount float64 `json:"amount"`
+	// PaidAt is the day or time the money came in (YYYY-MM-DD or YYYY-MM-DD HH:MM:SS),
+	// or "" for now
+	PaidAt    string `json:"paidAt"`
+	Reference string `json:"reference"`
+	// Deposit marks a payment taken before the order is fulfilled
+	Deposit bool `json:"deposit"`
+}
+
+// Payment is money received for an order
+type Payment struct {
+	ID         string  `json:"id"`
+	OrderID    string  `json:"orderId"`
+	Method     string  `json:"method"`
+	Amount     float64 `json:"amount"`
+	PaidAt     string  `json:"paidAt"`
+	Reference  string  `json:"reference"`
+	Deposit    bool    `json:"deposit"`
+	RecordedBy string  `json:"recordedBy"`
+}
+
+// initializePayments creates the payments table
+func (db *Database) initializePayments() error {
+	_, err := db.db.Exec(`CREATE TABLE IF NOT EXISTS payments (
+		id TEXT PRIMARY KEY,
+		order_id TEXT NOT NULL,
+		method TEXT NOT NULL,
+		amount REAL NOT NULL,
+		paid_at TEXT NOT NULL,
+		reference TEXT,
+		deposit INTEGER NOT NULL DEFAULT 0,
+		recorded_by TEXT,
+		FOREIGN KEY (order_id) REFERENCES orders(id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create payments table: %v", err)
+	}
+
+	_, err = db.db.Exec("CREATE INDEX IF NOT EXISTS idx_payments_order ON payments(order_id, paid_at)")
+	if err != nil {
+		return fmt.Errorf("failed to create payments index: %v", err)
+	}
+	return nil
+}
+
+// setPaymentStatus derives an order's balance due and payment status from its total,
+// its refunds and what has been paid. A refund lowers what the customer owes, and the
+// part of it paid back to them lowers what they have paid.
+func setPaymentStatus(order *Order) {
+	owed := toCents(order.Total) - toCents(order.Refunded)
+	paid := toCents(order.Paid) - toCents(order.RefundsPaidOut)
+	order.BalanceDue = float64(owed-paid) / 100
+	switch {
+	case order.BalanceDue <= 0:
+		order.PaymentStatus = PaymentPaid
+	case paid > 0:
+		order.PaymentStatus = PaymentPartiallyPaid
+	default:
+		order.PaymentStatus = PaymentUnpaid
+	}
+}
+
+// parsePaidAt reads the time of a payment, accepting a plain date
+func parsePaidAt(value string) (string, error) {
+	if value == "" {
+		return time.Now().Format(priceTimeLayout), nil
+	}
+	for _, layout := range []string{priceTimeLayout, "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t.Format(priceTimeLayout), nil
+		}
+	}
+	return "", invalidf("invalid payment date %q, expected YYYY-MM-DD", value)
+}
+
+// validatePayment checks a payment against the order it pays for
+func validatePayment(order Order, payment NewPayment) error {
+	if order.Status == "Cancelled" {
+		return invalidf("order %s is cancelled", order.ID)
+	}
+	if !paymentMethods[payment.Method] {
+		return invalidf("unknown payment method %q", payment.Method)
+	}
+	if payment.Amount <= 0 {
+		return invalidf("a payment must be positive")
+	}
+	if toCents(payment.Amount) > toCents(order.BalanceDue) {
+		return invalidf("order %s only has %s left to pay", order.ID, formatAmount(order.BalanceDue))
+	}
+	return nil
+}
+
+// AddPayment records money received for an order and returns the payment's ID
+func (db *Database) AddPayment(ctx context.Context, orderID string, payment NewPayment, recordedBy string) (id string, err error) {
+	paidAt, err := parsePaidAt(payment.PaidAt)
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	order, err := loadOrder(ctx, tx, orderID)
+	if err != nil {
+		return "", err
+	}
+	if err = validatePayment(order, payment); err != nil {
+		return "", err
+	}
+
+	id = uuid.New().String()
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO payments (id, order_id, method, amount, paid_at, reference, deposit, recorded_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, id, orderID, payment.Method, payment.Amount, paidAt, nullIfEmpty(payment.Reference), payment.Deposit, nullIfEmpty(recordedBy))
+	if err != nil {
+		return "", fmt.Errorf("failed to insert payment: %v", err)
+	}
+
+	change := fmt.Sprintf("Paid %s by %s", formatAmount(payment.Amount), payment.Method)
+	if payment.Deposit {
+		change += " (deposit)"
+	}
+	if err = insertOrderChanges(ctx, tx, orderID, recordedBy, []string{change}); err != nil {
+		return "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	db.log.Debug("Payment recorded", "order", orderID, "payment", id, "amount", payment.Amount)
+	db.publish(EntityOrder, ActionUpdated, orderID)
+	return id, nil
+}
+
+// GetPayments returns the payments of an order, oldest first
+func (db *Database) GetPayments(ctx context.Context, orderID string) ([]Payment, error) {
+	rows, err := db.read.QueryContext(ctx, `
+		SELECT id, order_id, method, amount, paid_at, reference, deposit, recorded_by FROM payments
+		WHERE order_id = ?
+		ORDER BY paid_at, rowid
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payments: %v", err)
+	}
+	defer rows.Close()
+
+	payments := []Payment{}
+	for rows.Next() {
+		var payment Payment
+		var reference, recordedBy sql.NullString
+		if err := rows.Scan(&payment.ID, &payment.OrderID, &payment.Method, &payment.Amount, &payment.PaidAt,
+			&reference, &payment.Deposit, &recordedBy); err != nil {
+			return nil, fmt.Errorf("failed to scan payment: %v", err)
+		}
+		payment.Reference, payment.RecordedBy = reference.String, recordedBy.String
+		payments = append(payments, payment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating payments: %v", err)
+	}
+
+	return payments, nil
+}
+
+// DeletePayment removes a payment recorded by mistake and notes it in the order's history
+func (db *Database) DeletePayment(ctx context.Context, id string, deletedBy string) (err error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var orderID, method string
+	var amount float64
+	err = tx.QueryRowContext(ctx, "SELECT order_id, method, amount FROM payments WHERE id = ?", id).
+		Scan(&orderID, &method, &amount)
+	if err == sql.ErrNoRows {
+		return &NotFoundError{Kind: "payment", ID: id}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query payment: %v", err)
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM payments WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete payment: %v", err)
+	}
+	change := fmt.Sprintf("Payment of %s by %s deleted", formatAmount(amount), method)
+	if err = insertOrderChanges(ctx, tx, orderID, deletedBy, []string{change}); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	db.publish(EntityOrder, ActionUpdated, orderID)
+	return nil
+}
+
+// GetOutstandingOrders returns the orders that still have a balance to pay, oldest
+// first. Cancelled orders are left out.
+func (db *Database) GetOutstandingOrders(ctx context.Context) ([]Order, error) {
+	orders, err := db.GetOrders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	outstanding := []Order{}
+	for _, order := range orders {
+		if order.Status != "Cancelled" && order.BalanceDue > 0 {
+			outstanding = append(outstanding, order)
+		}
+	}
+	sort.SliceStable(outstanding, func(i, j int) bool {
+		return outstanding[i].Date < outstanding[j].Date
+	})
+	return outstanding, nil
+}
+
+// AddPayment records a payment against an order
+func (a *App) AddPayment(orderID string, payment NewPayment) bool {
+	ctx, logger, db, done := a.call("AddPayment")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error recording payment", "err", err)
+		return false
+	}
+
+	id, err := db.AddPayment(ctx, orderID, payment, a.currentUser().Username)
+	if err != nil {
+		logger.Error("Error recording payment", "order", orderID, "err", err)
+		return false
+	}
+	logger.Info("Payment recorded", "order", orderID, "payment", id, "amount", payment.Amount)
+	return true
+}
+
+// GetPayments returns the payments of an order, oldest first
+func (a *App) GetPayments(orderID string) []Payment {
+	ctx, logger, db, done := a.call("GetPayments")
+	defer done()
+
+	payments, err := db.GetPayments(ctx, orderID)
+	if err != nil {
+		logger.Error("Error getting payments", "err", err)
+		return []Payment{}
+	}
+	return payments
+}
+
+// DeletePayment removes a payment recorded by mistake
+func (a *App) DeletePayment(id string) bool {
+	ctx, logger, db, done := a.call("DeletePayment")
+	defer done()
+
+	if err := a.authorize(RoleAdmin); err != nil {
+		logger.Error("Error deleting payment", "err", err)
+		return false
+	}
+
+	if err := db.DeletePayment(ctx, id, a.currentUser().Username); err != nil {
+		logger.Error("Error deleting payment", "id", id, "err", err)
+		return false
+	}
+	logger.Info("Payment deleted", "id", id)
+	return true
+}
+
+// GetOutstandingOrders returns the orders that still have a balance to pay, oldest first
+func (a *App) GetOutstandingOrders() []Order {
+	ctx, logger, db, done := a.call("GetOutstandingOrders")
+	defer done()
+
+	orders, err := db.GetOutstandingOrders(ctx)
+	if err != nil {
+		logger.Error("Error getting outstanding orders", "err", err)
+		return []Order{}
+	}
+	return orders
+}
@@ -0,0 +1,506 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	_ "embed"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// DejaVu Sans covers Latin and Hebrew, so documents with customer text embed it
+// instead of relying on the standard PDF fonts. See fonts/LICENSE.txt.
+var (
+	//go:embed fonts/DejaVuSansCondensed.ttf
+	dejaVuSans []byte
+	//go:embed fonts/DejaVuSansCondensed-Bold.ttf
+	dejaVuSansBold []byte
+)
+
+// parsedFonts parses the embedded fonts once; a parsed font is safe for concurrent use
+var parsedFonts = sync.OnceValues(func() ([]*sfnt.Font, error) {
+	var fonts []*sfnt.Font
+	for _, data := range [][]byte{dejaVuSans, dejaVuSansBold} {
+		f, err := sfnt.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse embedded font: %v", err)
+		}
+		fonts = append(fonts, f)
+	}
+	return fonts, nil
+})
+
+// pdfFont embeds a TrueType font in one PDF document. It records the glyphs the
+// document's text uses, so only those are written to the file.
+type pdfFont struct {
+	data []byte
+	font *sfnt.Font
+	buf  sfnt.Buffer
+	// used maps the glyphs drawn so far to the character each one shows
+	used map[sfnt.GlyphIndex]rune
+}
+
+// newDocumentFonts returns the regular and bold embedded fonts for a new document
+func newDocumentFonts() (regular *pdfFont, bold *pdfFont, err error) {
+	fonts, err := parsedFonts()
+	if err != nil {
+		return nil, nil, err
+	}
+	regular = &pdfFont{data: dejaVuSans, font: fonts[0], used: make(map[sfnt.GlyphIndex]rune)}
+	bold = &pdfFont{data: dejaVuSansBold, font: fonts[1], used: make(map[sfnt.GlyphIndex]rune)}
+	return regular, bold, nil
+}
+
+// glyph returns the glyph showing a character, or 0 (the missing glyph) when the font has none
+func (f *pdfFont) glyph(r rune) sfnt.GlyphIndex {
+	g, err := f.font.GlyphIndex(&f.buf, r)
+	if err != nil {
+		return 0
+	}
+	return g
+}
+
+// advance returns the width of a glyph in thousandths of an em
+func (f *pdfFont) advance(g sfnt.GlyphIndex) float64 {
+	// With the units per em as the size, advances come back in font units
+	unitsPerEm := f.font.UnitsPerEm()
+	advance, err := f.font.GlyphAdvance(&f.buf, g, fixed.Int26_6(unitsPerEm), font.HintingNone)
+	if err != nil {
+		return 0
+	}
+	return float64(advance) * 1000 / float64(unitsPerEm)
+}
+
+// text converts a string to a PDF hex string of glyph IDs in display order
+func (f *pdfFont) text(s string) string {
+	var b strings.Builder
+	b.WriteByte('<')
+	for _, r := range visualOrder(s) {
+		g := f.glyph(r)
+		f.used[g] = r
+		fmt.Fprintf(&b, "%04X", uint16(g))
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+// width returns how wide a string is drawn at the given size, in points
+func (f *pdfFont) width(s string, size float64) float64 {
+	var width float64
+	for _, r := range s {
+		width += f.advance(f.glyph(r))
+	}
+	return width * size / 1000
+}
+
+// fit shortens text so it is at most the given width when drawn at the given size
+func (f *pdfFont) fit(text string, size float64, width float64) string {
+	if f.width(text, size) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		if shortened := string(runes) + "..."; f.width(shortened, size) <= width {
+			return shortened
+		}
+	}
+	return ""
+}
+
+// addTo writes the font to a document as a Type 0 font with Identity-H encoding and
+// returns its object number. tag tells the font's subset apart from others in the file.
+func (f *pdfFont) addTo(doc *pdfDocument, tag string) (int, error) {
+	name, err := f.font.Name(&f.buf, sfnt.NameIDPostScript)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read font name: %v", err)
+	}
+	name = tag + "+" + name
+
+	glyphs := make([]sfnt.GlyphIndex, 0, len(f.used))
+	for g := range f.used {
+		glyphs = append(glyphs, g)
+	}
+	sort.Slice(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] })
+
+	subset, err := subsetTrueType(f.data, glyphs)
+	if err != nil {
+		return 0, err
+	}
+	var compressed bytes.Buffer
+	w := zlib.NewWriter(&compressed)
+	w.Write(subset)
+	w.Close()
+	file := doc.addStream(fmt.Sprintf("/Length1 %d /Filter /FlateDecode", len(subset)), compressed.Bytes())
+
+	// Metrics are given in thousandths of an em, as PDF expects for TrueType fonts
+	unitsPerEm := fixed.Int26_6(f.font.UnitsPerEm())
+	metrics, err := f.font.Metrics(&f.buf, unitsPerEm, font.HintingNone)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read font metrics: %v", err)
+	}
+	bounds, err := f.font.Bounds(&f.buf, unitsPerEm, font.HintingNone)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read font bounds: %v", err)
+	}
+	em := func(v fixed.Int26_6) int { return int(v) * 1000 / int(unitsPerEm) }
+	descriptor := doc.add(fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%d %d %d %d] "+
+		"/ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		name, em(bounds.Min.X), -em(bounds.Max.Y), em(bounds.Max.X), -em(bounds.Min.Y),
+		em(metrics.Ascent), -em(metrics.Descent), em(metrics.CapHeight), file))
+
+	var widths strings.Builder
+	for _, g := range glyphs {
+		fmt.Fprintf(&widths, "%d [%.0f] ", g, f.advance(g))
+	}
+	descendant := doc.add(fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s "+
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> "+
+		"/FontDescriptor %d 0 R /CIDToGIDMap /Identity /W [%s] >>",
+		name, descriptor, strings.TrimSpace(widths.String())))
+
+	// The ToUnicode map lets viewers copy and search the text
+	var cmap strings.Builder
+	cmap.WriteString("/CIDInit /ProcSet findresource begin 12 dict begin begincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def /CMapType 2 def\n" +
+		"1 begincodespacerange <0000> <FFFF> endcodespacerange\n")
+	for start := 0; start < len(glyphs); start += 100 {
+		chunk := glyphs[start:min(start+100, len(glyphs))]
+		fmt.Fprintf(&cmap, "%d beginbfchar\n", len(chunk))
+		for _, g := range chunk {
+			fmt.Fprintf(&cmap, "<%04X> <%s>\n", uint16(g), utf16Hex(f.used[g]))
+		}
+		cmap.WriteString("endbfchar\n")
+	}
+	cmap.WriteString("endcmap CMapName currentdict /CMap defineresource pop end end\n")
+	toUnicode := doc.addStream("", []byte(cmap.String()))
+
+	return doc.add(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H "+
+		"/DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>", name, descendant, toUnicode)), nil
+}
+
+// utf16Hex returns a character as hex UTF-16 code units, as ToUnicode maps expect
+func utf16Hex(r rune) string {
+	if r < 0x10000 {
+		return fmt.Sprintf("%04X", r)
+	}
+	r -= 0x10000
+	return fmt.Sprintf("%04X%04X", 0xD800+(r>>10), 0xDC00+(r&0x3FF))
+}
+
+// subsetTableTags lists the TrueType tables PDF viewers need to draw glyphs, in the
+// sorted order the table directory requires
+var subsetTableTags = []string{"OS/2", "cmap", "cvt ", "fpgm", "glyf", "head", "hhea", "hmtx", "loca", "maxp", "post", "prep"}
+
+// subsetTrueType returns a copy of a TrueType font that keeps the outlines of the given
+// glyphs (and the glyphs they are built from) and empties every other glyph. Glyph IDs
+// stay the same, so text can refer to them directly.
+func subsetTrueType(data []byte, glyphs []sfnt.GlyphIndex) ([]byte, error) {
+	tables := make(map[string][]byte)
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < numTables; i++ {
+		record := data[12+16*i:]
+		offset, length := binary.BigEndian.Uint32(record[8:]), binary.BigEndian.Uint32(record[12:])
+		if int(offset)+int(length) > len(data) {
+			return nil, fmt.Errorf("font table %q is out of bounds", record[:4])
+		}
+		tables[string(record[:4])] = data[offset : offset+length]
+	}
+	head, loca, glyf, maxp := tables["head"], tables["loca"], tables["glyf"], tables["maxp"]
+	if len(head) < 54 || len(maxp) < 6 || glyf == nil {
+		return nil, fmt.Errorf("font has no TrueType outlines")
+	}
+
+	numGlyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+	longOffsets := binary.BigEndian.Uint16(head[50:]) == 1
+	glyphData := func(g int) []byte {
+		var start, end int
+		if longOffsets {
+			start, end = int(binary.BigEndian.Uint32(loca[4*g:])), int(binary.BigEndian.Uint32(loca[4*g+4:]))
+		} else {
+			start, end = 2*int(binary.BigEndian.Uint16(loca[2*g:])), 2*int(binary.BigEndian.Uint16(loca[2*g+2:]))
+		}
+		if start > end || end > len(glyf) {
+			return nil
+		}
+		return glyf[start:end]
+	}
+
+	// Keep the missing glyph and everything composite glyphs are assembled from
+	keep := map[int]bool{0: true}
+	pending := []int{0}
+	for _, g := range glyphs {
+		pending = append(pending, int(g))
+	}
+	for len(pending) > 0 {
+		g := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if g >= numGlyphs {
+			continue
+		}
+		keep[g] = true
+		outline := glyphData(g)
+		if len(outline) < 10 || int16(binary.BigEndian.Uint16(outline)) >= 0 {
+			continue
+		}
+		for p := 10; p+4 <= len(outline); {
+			flags, component := binary.BigEndian.Uint16(outline[p:]), int(binary.BigEndian.Uint16(outline[p+2:]))
+			if !keep[component] {
+				pending = append(pending, component)
+			}
+			p += 4
+			if flags&0x0001 != 0 { // arguments are words
+				p += 4
+			} else {
+				p += 2
+			}
+			switch {
+			case flags&0x0008 != 0: // a scale
+				p += 2
+			case flags&0x0040 != 0: // an x and y scale
+				p += 4
+			case flags&0x0080 != 0: // a two by two matrix
+				p += 8
+			}
+			if flags&0x0020 == 0 { // no more components
+				break
+			}
+		}
+	}
+
+	// Rebuild the outlines with long offsets, 4-byte aligned
+	var newGlyf bytes.Buffer
+	newLoca := make([]byte, 4*(numGlyphs+1))
+	for g := 0; g < numGlyphs; g++ {
+		binary.BigEndian.PutUint32(newLoca[4*g:], uint32(newGlyf.Len()))
+		if keep[g] {
+			newGlyf.Write(glyphData(g))
+			for newGlyf.Len()%4 != 0 {
+				newGlyf.WriteByte(0)
+			}
+		}
+	}
+	binary.BigEndian.PutUint32(newLoca[4*numGlyphs:], uint32(newGlyf.Len()))
+	newHead := append([]byte(nil), head...)
+	binary.BigEndian.PutUint32(newHead[8:], 0) // checksum adjustment
+	binary.BigEndian.PutUint16(newHead[50:], 1)
+	tables["glyf"], tables["loca"], tables["head"] = newGlyf.Bytes(), newLoca, newHead
+	// Glyph names are not needed, and a version 3 post table leaves them out
+	if post := tables["post"]; len(post) >= 32 {
+		newPost := append([]byte(nil), post[:32]...)
+		binary.BigEndian.PutUint32(newPost, 0x00030000)
+		tables["post"] = newPost
+	}
+
+	var tags []string
+	for _, tag := range subsetTableTags {
+		if tables[tag] != nil {
+			tags = append(tags, tag)
+		}
+	}
+	searchRange, entrySelector := 1, 0
+	for searchRange*2 <= len(tags) {
+		searchRange *= 2
+		entrySelector++
+	}
+
+	var out bytes.Buffer
+	binary.Write(&out, binary.BigEndian, []uint16{
+		0x0001, 0x0000, uint16(len(tags)), uint16(searchRange * 16), uint16(entrySelector), uint16((len(tags) - searchRange) * 16),
+	})
+	offset := 12 + 16*len(tags)
+	for _, tag := range tags {
+		table := tables[tag]
+		out.WriteString(tag)
+		binary.Write(&out, binary.BigEndian, []uint32{tableChecksum(table), uint32(offset), uint32(len(table))})
+		offset += (len(table) + 3) &^ 3
+	}
+	for _, tag := range tags {
+		out.Write(tables[tag])
+		for out.Len()%4 != 0 {
+			out.WriteByte(0)
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// tableChecksum sums a TrueType table as big-endian 32-bit words, zero padded
+func tableChecksum(table []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(table); i += 4 {
+		var word [4]byte
+		copy(word[:], table[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
+
+// Bidirectional character types, reduced to what visualOrder needs
+const (
+	bidiNeutral = iota
+	bidiLeft
+	bidiRight
+	bidiNumber
+	bidiNumberSeparator
+	bidiNumberAffix
+)
+
+// bidiType classifies a character for visualOrder
+func bidiType(r rune) int {
+	switch {
+	case r >= 0x0590 && r <= 0x05FF, r >= 0xFB1D && r <= 0xFB4F:
+		return bidiRight
+	case r >= '0' && r <= '9':
+		return bidiNumber
+	case r == '.' || r == ',' || r == ':' || r == '/':
+		return bidiNumberSeparator
+	case r == '₪' || r == '$' || r == '€' || r == '%' || r == '+' || r == '-' || r == '#':
+		return bidiNumberAffix
+	case r < 0x80 && !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'):
+		return bidiNeutral
+	case r == 0xA0 || r == 0x2026 || r >= 0x2000 && r <= 0x206F:
+		return bidiNeutral
+	default:
+		return bidiLeft
+	}
+}
+
+// mirroredBrackets maps brackets to the ones shown in right-to-left text
+var mirroredBrackets = map[rune]rune{'(': ')', ')': '(', '[': ']', ']': '[', '{': '}', '}': '{', '<': '>', '>': '<'}
+
+// visualOrder rearranges a line from the order it is typed in to the left-to-right
+// order its characters are drawn in. It follows the Unicode bidirectional algorithm
+// closely enough for Hebrew text mixed with Latin words and numbers: Hebrew runs are
+// reversed, numbers inside them keep reading left to right, and brackets are mirrored.
+func visualOrder(s string) string {
+	runes := []rune(s)
+	types := make([]int, len(runes))
+	paragraph := -1
+	for i, r := range runes {
+		types[i] = bidiType(r)
+		if paragraph < 0 && (types[i] == bidiLeft || types[i] == bidiRight) {
+			paragraph = types[i]
+		}
+	}
+	if !containsType(types, bidiRight) {
+		return s
+	}
+	if paragraph < 0 {
+		paragraph = bidiLeft
+	}
+
+	// Separators between digits and signs next to digits belong to the number
+	for i, t := range types {
+		if t == bidiNumberSeparator && i > 0 && i+1 < len(types) && types[i-1] == bidiNumber && types[i+1] == bidiNumber {
+			types[i] = bidiNumber
+		}
+	}
+	for i := range types {
+		if types[i] == bidiNumberAffix {
+			j := i
+			for j < len(types) && types[j] == bidiNumberAffix {
+				j++
+			}
+			if i > 0 && types[i-1] == bidiNumber || j < len(types) && types[j] == bidiNumber {
+				for k := i; k < j; k++ {
+					types[k] = bidiNumber
+				}
+			}
+		}
+	}
+
+	// Neutrals take the direction of the text around them when both sides agree, and the
+	// paragraph's direction otherwise. Numbers count as right-to-left here.
+	strong := func(t int) int {
+		if t == bidiNumber {
+			return bidiRight
+		}
+		return t
+	}
+	for i := 0; i < len(types); {
+		if t := types[i]; t != bidiNeutral && t != bidiNumberSeparator && t != bidiNumberAffix {
+			i++
+			continue
+		}
+		j := i
+		for j < len(types) && (types[j] == bidiNeutral || types[j] == bidiNumberSeparator || types[j] == bidiNumberAffix) {
+			j++
+		}
+		before, after := paragraph, paragraph
+		if i > 0 {
+			before = strong(types[i-1])
+		}
+		if j < len(types) {
+			after = strong(types[j])
+		}
+		direction := paragraph
+		if before == after {
+			direction = before
+		}
+		for k := i; k < j; k++ {
+			types[k] = direction
+		}
+		i = j
+	}
+
+	// Embedding levels: even levels read left to right, odd ones right to left
+	levels := make([]int, len(runes))
+	base := 0
+	if paragraph == bidiRight {
+		base = 1
+	}
+	highest := base
+	for i, t := range types {
+		switch {
+		case base == 0 && t == bidiRight:
+			levels[i] = 1
+		case base == 0 && t == bidiNumber, base == 1 && t != bidiRight:
+			levels[i] = 2
+		default:
+			levels[i] = base
+		}
+		highest = max(highest, levels[i])
+	}
+
+	// Reverse every run at or above each level, from the highest level down to 1
+	for level := highest; level >= 1; level-- {
+		for i := 0; i < len(runes); {
+			if levels[i] < level {
+				i++
+				continue
+			}
+			j := i
+			for j < len(runes) && levels[j] >= level {
+				j++
+			}
+			for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+				runes[a], runes[b] = runes[b], runes[a]
+				levels[a], levels[b] = levels[b], levels[a]
+			}
+			i = j
+		}
+	}
+	for i, r := range runes {
+		if mirrored, ok := mirroredBrackets[r]; ok && levels[i]%2 == 1 {
+			runes[i] = mirrored
+		}
+	}
+	return string(runes)
+}
+
+// containsType reports whether any character has the given bidirectional type
+func containsType(types []int, t int) bool {
+	for _, candidate := range types {
+		if candidate == t {
+			return true
+		}
+	}
+	return false
+}
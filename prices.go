@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// priceTimeLayout is the format of the effective dates stored in product_prices.
+// It sorts lexically, so dates can be compared directly in SQL.
+const priceTimeLayout = "2006-01-02 15:04:05"
+
+// ProductPrice is one entry of a product's price history. An entry applies from
+// EffectiveFrom until EffectiveTo (exclusive, empty for open-ended); where entries
+// overlap, the one that started most recently wins.
+type ProductPrice struct {
+	ID            string  `json:"id"`
+	ProductID     string  `json:"productId"`
+	Price         float64 `json:"price"`
+	EffectiveFrom string  `json:"effectiveFrom"`
+	EffectiveTo   string  `json:"effectiveTo"`
+	Reason        string  `json:"reason"`
+	CreatedAt     string  `json:"createdAt"`
+}
+
+// currentPriceSQL resolves the price of products.id in effect at the bound time,
+// falling back to the base price stored on the product
+const currentPriceSQL = `COALESCE((
+	SELECT pp.price FROM product_prices pp
+	WHERE pp.product_id = products.id
+		AND pp.effective_from <= ?1
+		AND (pp.effective_to IS NULL OR pp.effective_to > ?1)
+	ORDER BY pp.effective_from DESC, pp.rowid DESC
+	LIMIT 1
+), products.price)`
+
+// initializePrices creates the product_prices table and seeds it with the current prices
+func (db *Database) initializePrices() error {
+	_, err := db.db.Exec(`CREATE TABLE IF NOT EXISTS product_prices (
+		id TEXT PRIMARY KEY,
+		product_id TEXT NOT NULL,
+		price REAL NOT NULL,
+		effective_from TEXT NOT NULL,
+		effective_to TEXT,
+		reason TEXT,
+		created_at TEXT NOT NULL,
+		FOREIGN KEY (product_id) REFERENCES products(id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create product_prices table: %v", err)
+	}
+
+	_, err = db.db.Exec("CREATE INDEX IF NOT EXISTS idx_product_prices_product ON product_prices(product_id, effective_from)")
+	if err != nil {
+		return fmt.Errorf("failed to create product_prices index: %v", err)
+	}
+
+	// Products created before price history existed get their current price as an entry
+	// reaching back to the start of time, so it never outranks a scheduled change
+	rows, err := db.db.Query(`
+		SELECT id, price FROM products
+		WHERE id NOT IN (SELECT DISTINCT product_id FROM product_prices)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to query products without price history: %v", err)
+	}
+	var missing []ProductPrice
+	for rows.Next() {
+		var entry ProductPrice
+		if err := rows.Scan(&entry.ProductID, &entry.Price); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan product price: %v", err)
+		}
+		missing = append(missing, entry)
+	}
+	rows.Close()
+
+	now := time.Now().Format(priceTimeLayout)
+	for _, entry := range missing {
+		_, err := db.db.Exec(
+			"INSERT INTO product_prices (id, product_id, price, effective_from, reason, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+			uuid.New().String(), entry.ProductID, entry.Price, time.Unix(0, 0).Format(priceTimeLayout),
+			"Price before history was recorded", now,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to seed price history: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// parsePriceTime accepts a date (YYYY-MM-DD) or date and time and returns it in priceTimeLayout
+func parsePriceTime(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{priceTimeLayout, "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t.Format(priceTimeLayout), nil
+		}
+	}
+	return "", invalidf("invalid date %q, expected YYYY-MM-DD or YYYY-MM-DD HH:MM", value)
+}
+
+// recordPrice inserts a price history entry within the given transaction
+func recordPrice(ctx context.Context, tx *sql.Tx, entry ProductPrice) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO product_prices (id, product_id, price, effective_from, effective_to, reason, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, entry.ID, entry.ProductID, entry.Price, entry.EffectiveFrom, nullIfEmpty(entry.EffectiveTo),
+		entry.Reason, entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert product price: %v", err)
+	}
+	return nil
+}
+
+// currentPrice resolves the price of a product in effect now within the given transaction
+func currentPrice(ctx context.Context, tx *sql.Tx, productID string) (float64, error) {
+	var price float64
+	err := tx.QueryRowContext(ctx, "SELECT "+currentPriceSQL+" FROM products WHERE id = ?2",
+		time.Now().Format(priceTimeLayout), productID).Scan(&price)
+	if err == sql.ErrNoRows {
+		return 0, &NotFoundError{Kind: "product", ID: productID}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve current price: %v", err)
+	}
+	return price, nil
+}
+
+// SchedulePriceChange adds a price that takes effect at a future (or past) date,
+// optionally ending at effectiveTo, after which the previous price applies again
+func (db *Database) SchedulePriceChange(ctx context.Context, entry ProductPrice) (ProductPrice, error) {
+	if entry.Price < 0 {
+		return ProductPrice{}, invalidf("price cannot be negative")
+	}
+
+	from, err := parsePriceTime(entry.EffectiveFrom)
+	if err != nil {
+		return ProductPrice{}, err
+	}
+	entry.EffectiveFrom = from
+
+	if entry.EffectiveTo != "" {
+		to, err := parsePriceTime(entry.EffectiveTo)
+		if err != nil {
+			return ProductPrice{}, err
+		}
+		if to <= from {
+			return ProductPrice{}, invalidf("price must end after it starts")
+		}
+		entry.EffectiveTo = to
+	}
+
+	var exists int
+	if err := db.read.QueryRowContext(ctx, "SELECT COUNT(*) FROM products WHERE id = ?", entry.ProductID).Scan(&exists); err != nil {
+		return ProductPrice{}, fmt.Errorf("failed to query product: %v", err)
+	}
+	if exists == 0 {
+		return ProductPrice{}, &NotFoundError{Kind: "product", ID: entry.ProductID}
+	}
+
+	entry.ID = uuid.New().String()
+	entry.CreatedAt = time.Now().Format(priceTimeLayout)
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ProductPrice{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = recordPrice(ctx, tx, entry); err != nil {
+		return ProductPrice{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return ProductPrice{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	// A change that is already in effect changes the listed price
+	db.publish(EntityProduct, ActionUpdated, entry.ProductID)
+	return entry, nil
+}
+
+// CancelScheduledPrice removes a price change that has not taken effect yet
+func (db *Database) CancelScheduledPrice(ctx context.Context, id string) error {
+	result, err := db.db.ExecContext(ctx,
+		"DELETE FROM product_prices WHERE id = ? AND effective_from > ?",
+		id, time.Now().Format(priceTimeLayout),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to cancel scheduled price: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no scheduled price found with ID %s, or it is already in effect", id)
+	}
+
+	return nil
+}
+
+// GetPriceHistory retrieves every price entry of a product, oldest first
+func (db *Database) GetPriceHistory(ctx context.Context, productID string) ([]ProductPrice, error) {
+	rows, err := db.read.QueryContext(ctx, `
+		SELECT id, product_id, price, effective_from, effective_to, reason, created_at
+		FROM product_prices
+		WHERE product_id = ?
+		ORDER BY effective_from, rowid
+	`, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query price history: %v", err)
+	}
+	defer rows.Close()
+
+	history := []ProductPrice{}
+	for rows.Next() {
+		var entry ProductPrice
+		var effectiveTo, reason sql.NullString
+		if err := rows.Scan(&entry.ID, &entry.ProductID, &entry.Price, &entry.EffectiveFrom, &effectiveTo,
+			&reason, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan product price: %v", err)
+		}
+		entry.EffectiveTo = effectiveTo.String
+		entry.Reason = reason.String
+		history = append(history, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating price history: %v", err)
+	}
+
+	return history, nil
+}
+
+// GetPriceAt resolves the price a product had at the given date
+func (db *Database) GetPriceAt(ctx context.Context, productID string, at string) (float64, error) {
+	when, err := parsePriceTime(at)
+	if err != nil {
+		return 0, err
+	}
+
+	var price float64
+	err = db.read.QueryRowContext(ctx, "SELECT "+currentPriceSQL+" FROM products WHERE id = ?2", when, productID).Scan(&price)
+	if err == sql.ErrNoRows {
+		return 0, &NotFoundError{Kind: "product", ID: productID}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve price: %v", err)
+	}
+	return price, nil
+}
+
+// GetPriceHistory returns the full price history of a product
+func (a *App) GetPriceHistory(productID string) []ProductPrice {
+	ctx, logger, db, done := a.call("GetPriceHistory")
+	defer done()
+
+	history, err := db.GetPriceHistory(ctx, productID)
+	if err != nil {
+		logger.Error("Error getting price history", "err", err)
+		return []ProductPrice{}
+	}
+	return history
+}
+
+// SchedulePriceChange schedules a new product price from effectiveFrom, optionally until effectiveTo
+func (a *App) SchedulePriceChange(productID string, price float64, effectiveFrom string, effectiveTo string, reason string) ProductPrice {
+	ctx, logger, db, done := a.call("SchedulePriceChange")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error scheduling price change", "err", err)
+		return ProductPrice{}
+	}
+
+	entry, err := db.SchedulePriceChange(ctx, ProductPrice{
+		ProductID:     productID,
+		Price:         price,
+		EffectiveFrom: effectiveFrom,
+		EffectiveTo:   effectiveTo,
+		Reason:        reason,
+	})
+	if err != nil {
+		logger.Error("Error scheduling price change", "err", err)
+		return ProductPrice{}
+	}
+	return entry
+}
+
+// CancelScheduledPrice removes a price change that has not started yet
+func (a *App) CancelScheduledPrice(id string) bool {
+	ctx, logger, db, done := a.call("CancelScheduledPrice")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error cancelling scheduled price", "err", err)
+		return false
+	}
+
+	err := db.CancelScheduledPrice(ctx, id)
+	if err != nil {
+		logger.Error("Error cancelling scheduled price", "err", err)
+		return false
+	}
+	return true
+}
+
+// GetPriceAt returns the price of a product at the given date, or -1 if it cannot be resolved
+func (a *App) GetPriceAt(productID string, date string) float64 {
+	ctx, logger, db, done := a.call("GetPriceAt")
+	defer done()
+
+	price, err := db.GetPriceAt(ctx, productID, date)
+	if err != nil {
+		logger.Error("Error getting price", "date", date, "err", err)
+		return -1
+	}
+	return price
+}
@@ -0,0 +1,700 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Quote statuses. A draft or sent quote whose expiry date has passed reports Expired.
+const (
+	QuoteDraft    = "Draft"
+	QuoteSent     = "Sent"
+	QuoteAccepted = "Accepted"
+	QuoteExpired  = "Expired"
+)
+
+// quoteValidity is how long a quote stays valid when no expiry date is given
+const quoteValidity = 30 * 24 * time.Hour
+
+// Quote document layout in PDF points
+const (
+	quoteMargin     = 56.0
+	quoteLineHeight = 16.0
+	quoteLinesFirst = 36
+	quoteLinesNext  = 44
+)
+
+// NewQuote is the request body for creating or editing a quote
+type NewQuote struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Items       []OrderItem `json:"items"`
+	// ExpiresOn is the last day the quote is valid (YYYY-MM-DD), or "" for 30 days from today
+	ExpiresOn string `json:"expiresOn"`
+}
+
+// QuoteStatusUpdate is the request body used to change the status of a quote
+type QuoteStatusUpdate struct {
+	Status string `json:"status"`
+}
+
+// Quote prices a job for a customer before they commit to an order
+type Quote struct {
+	ID          string      `json:"id"`
+	Date        string      `json:"date"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Items       []OrderItem `json:"items"`
+	Total       float64     `json:"total"`
+	ExpiresOn   string      `json:"expiresOn"`
+	Status      string      `json:"status"`
+	// OrderID is the order an accepted quote was converted into
+	OrderID   string `json:"orderId"`
+	CreatedBy string `json:"createdBy"`
+}
+
+// initializeQuotes creates the quotes and quote_items tables
+func (db *Database) initializeQuotes() error {
+	_, err := db.db.Exec(`CREATE TABLE IF NOT EXISTS quotes (
+		id TEXT PRIMARY KEY,
+		date TEXT NOT NULL,
+		name TEXT NOT NULL,
+		description TEXT,
+		total REAL NOT NULL,
+		expires_on TEXT NOT NULL,
+		status TEXT NOT NULL,
+		order_id TEXT,
+		created_by TEXT
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create quotes table: %v", err)
+	}
+
+	_, err = db.db.Exec(`CREATE TABLE IF NOT EXISTS quote_items (
+		id TEXT PRIMARY KEY,
+		quote_id TEXT NOT NULL,
+		product_id TEXT NOT NULL,
+		variant_id TEXT,
+		name TEXT NOT NULL,
+		price REAL NOT NULL,
+		quantity INTEGER NOT NULL,
+		FOREIGN KEY (quote_id) REFERENCES quotes(id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create quote_items table: %v", err)
+	}
+
+	_, err = db.db.Exec("CREATE INDEX IF NOT EXISTS idx_quote_items_quote ON quote_items(quote_id)")
+	if err != nil {
+		return fmt.Errorf("failed to create quote_items index: %v", err)
+	}
+	return nil
+}
+
+// validateQuote checks a new or edited quote and returns its expiry date
+func validateQuote(quote NewQuote, now time.Time) (string, error) {
+	if strings.TrimSpace(quote.Name) == "" {
+		return "", invalidf("a quote must have a name")
+	}
+	if len(quote.Items) == 0 {
+		return "", invalidf("a quote must have at least one item")
+	}
+	for _, item := range quote.Items {
+		if item.Quantity <= 0 {
+			return "", invalidf("quantity of %s must be positive", item.ProductName)
+		}
+		if item.Price < 0 {
+			return "", invalidf("price of %s cannot be negative", item.ProductName)
+		}
+	}
+
+	if quote.ExpiresOn == "" {
+		return now.Add(quoteValidity).Format(scheduleDateLayout), nil
+	}
+	if _, err := time.ParseInLocation(scheduleDateLayout, quote.ExpiresOn, time.Local); err != nil {
+		return "", invalidf("invalid expiry date %q, expected YYYY-MM-DD", quote.ExpiresOn)
+	}
+	return quote.ExpiresOn, nil
+}
+
+// quoteStatus reports a quote's stored status, or Expired once a quote that was not
+// accepted is past its last valid day
+func quoteStatus(status string, expiresOn string, now time.Time) string {
+	if (status == QuoteDraft || status == QuoteSent) && expiresOn < now.Format(scheduleDateLayout) {
+		return QuoteExpired
+	}
+	return status
+}
+
+// insertQuoteItems adds the lines of a quote within a transaction
+func insertQuoteItems(ctx context.Context, tx *sql.Tx, quoteID string, items []OrderItem) error {
+	for _, item := range items {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO quote_items (id, quote_id, product_id, variant_id, name, price, quantity, description, sku, list_price, override_reason)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, uuid.New().String(), quoteID, item.ProductID, nullIfEmpty(item.VariantID), item.ProductName, item.Price, item.Quantity,
+			nullIfEmpty(item.Description), nullIfEmpty(item.SKU), item.ListPrice, nullIfEmpty(item.OverrideReason),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert quote item: %v", err)
+		}
+	}
+	return nil
+}
+
+// CreateQuote saves a draft quote and returns its ID
+func (db *Database) CreateQuote(ctx context.Context, quote NewQuote, createdBy string) (id string, err error) {
+	expiresOn, err := validateQuote(quote, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if quote.Items, err = resolveOrderItems(ctx, tx, quote.Items); err != nil {
+		return "", err
+	}
+
+	// Quotes are numbered like orders so they can be referred to on the phone
+	var maxID int
+	if err = tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(CAST(id AS INTEGER)), 0) FROM quotes").Scan(&maxID); err != nil {
+		return "", fmt.Errorf("failed to get max quote ID: %v", err)
+	}
+	id = strconv.Itoa(maxID + 1)
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO quotes (id, date, name, description, total, expires_on, status, created_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, id, time.Now().Format(priceTimeLayout), quote.Name, quote.Description, orderTotal(quote.Items), expiresOn,
+		QuoteDraft, nullIfEmpty(createdBy))
+	if err != nil {
+		return "", fmt.Errorf("failed to insert quote: %v", err)
+	}
+	if err = insertQuoteItems(ctx, tx, id, quote.Items); err != nil {
+		return "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	db.log.Debug("Quote created", "id", id, "items", len(quote.Items))
+	return id, nil
+}
+
+// UpdateQuote replaces the name, description, lines and expiry date of a quote that has
+// not been accepted. Editing an expired quote makes it a draft again.
+func (db *Database) UpdateQuote(ctx context.Context, id string, quote NewQuote) (err error) {
+	expiresOn, err := validateQuote(quote, time.Now())
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	current, err := loadQuote(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if current.Status == QuoteAccepted {
+		err = invalidf("quote %s has been accepted and can no longer be edited", id)
+		return err
+	}
+	status := current.Status
+	if status == QuoteExpired {
+		status = QuoteDraft
+	}
+	if quote.Items, err = resolveEditedItems(ctx, tx, current.Items, quote.Items); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE quotes SET name = ?, description = ?, total = ?, expires_on = ?, status = ? WHERE id = ?",
+		quote.Name, quote.Description, orderTotal(quote.Items), expiresOn, status, id)
+	if err != nil {
+		return fmt.Errorf("failed to update quote: %v", err)
+	}
+	if _, err = tx.ExecContext(ctx, "DELETE FROM quote_items WHERE quote_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete quote items: %v", err)
+	}
+	if err = insertQuoteItems(ctx, tx, id, quote.Items); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// UpdateQuoteStatus marks a quote as draft, sent or expired. Quotes become accepted
+// only by converting them into an order.
+func (db *Database) UpdateQuoteStatus(ctx context.Context, id string, status string) (err error) {
+	switch status {
+	case QuoteDraft, QuoteSent, QuoteExpired:
+	case QuoteAccepted:
+		return invalidf("a quote is accepted by converting it into an order")
+	default:
+		return invalidf("unknown quote status %q", status)
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	quote, err := loadQuote(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if quote.Status == QuoteAccepted {
+		err = invalidf("quote %s has already been accepted", id)
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE quotes SET status = ? WHERE id = ?", status, id); err != nil {
+		return fmt.Errorf("failed to update quote status: %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// ConvertQuoteToOrder creates a pending order from a draft or sent quote at the quoted
+// prices, marks the quote accepted and returns the new order's ID
+func (db *Database) ConvertQuoteToOrder(ctx context.Context, id string, convertedBy string) (orderID string, err error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	quote, err := loadQuote(ctx, tx, id)
+	if err != nil {
+		return "", err
+	}
+	switch quote.Status {
+	case QuoteAccepted:
+		err = invalidf("quote %s was already converted into order %s", id, quote.OrderID)
+		return "", err
+	case QuoteExpired:
+		err = invalidf("quote %s expired on %s", id, quote.ExpiresOn)
+		return "", err
+	}
+	if err = validateNewOrder(quote.Name, quote.Items); err != nil {
+		return "", err
+	}
+
+	// Lines keep their quoted price, which is recorded as an override wherever the
+	// list price has moved on since the quote was made
+	for i := range quote.Items {
+		quote.Items[i].OverrideReason = firstNonEmpty(quote.Items[i].OverrideReason, "Quote")
+	}
+	items, err := resolveOrderItems(ctx, tx, quote.Items)
+	if err != nil {
+		return "", err
+	}
+	if orderID, err = db.insertOrder(ctx, tx, quote.Name, quote.Description, items); err != nil {
+		return "", err
+	}
+	change := fmt.Sprintf("Created from quote #%s", id)
+	if err = insertOrderChanges(ctx, tx, orderID, convertedBy, []string{change}); err != nil {
+		return "", err
+	}
+	if _, err = tx.ExecContext(ctx, "UPDATE quotes SET status = ?, order_id = ? WHERE id = ?", QuoteAccepted, orderID, id); err != nil {
+		return "", fmt.Errorf("failed to update quote: %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	db.log.Debug("Quote converted", "quote", id, "order", orderID)
+	db.publish(EntityOrder, ActionCreated, orderID)
+	db.publishReservations(orderID, nil)
+	return orderID, nil
+}
+
+// DeleteQuote removes a quote that has not been accepted
+func (db *Database) DeleteQuote(ctx context.Context, id string) (err error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	quote, err := loadQuote(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if quote.Status == QuoteAccepted {
+		err = invalidf("quote %s has been accepted and is kept with order %s", id, quote.OrderID)
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM quote_items WHERE quote_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete quote items: %v", err)
+	}
+	if _, err = tx.ExecContext(ctx, "DELETE FROM quotes WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete quote: %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// loadQuote reads a quote and its items within a transaction
+func loadQuote(ctx context.Context, tx *sql.Tx, id string) (Quote, error) {
+	quotes, err := queryQuotes(ctx, tx, "id = ?", id)
+	if err != nil {
+		return Quote{}, err
+	}
+	if len(quotes) == 0 {
+		return Quote{}, &NotFoundError{Kind: "quote", ID: id}
+	}
+	return quotes[0], nil
+}
+
+// queryQuotes reads the quotes matching a condition with their items, newest first
+func queryQuotes(ctx context.Context, q queryer, where string, args ...any) ([]Quote, error) {
+	// Read the items first so that only one query is open at a time
+	rows, err := q.QueryContext(ctx, `
+		SELECT quote_id, product_id, variant_id, name, price, quantity, description, sku, list_price, override_reason FROM quote_items
+		WHERE quote_id IN (SELECT id FROM quotes WHERE `+where+`)
+		ORDER BY rowid
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query quote items: %v", err)
+	}
+	items := map[string][]OrderItem{}
+	for rows.Next() {
+		var quoteID string
+		var item OrderItem
+		var variantID, description, sku, overrideReason sql.NullString
+		var listPrice sql.NullFloat64
+		if err := rows.Scan(&quoteID, &item.ProductID, &variantID, &item.ProductName, &item.Price, &item.Quantity,
+			&description, &sku, &listPrice, &overrideReason); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan quote item: %v", err)
+		}
+		item.VariantID = variantID.String
+		scanLineSnapshot(&item, description, sku, listPrice, overrideReason)
+		items[quoteID] = append(items[quoteID], item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating quote items: %v", err)
+	}
+
+	rows, err = q.QueryContext(ctx, `
+		SELECT id, date, name, description, total, expires_on, status, order_id, created_by FROM quotes
+		WHERE `+where+`
+		ORDER BY CAST(id AS INTEGER) DESC
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query quotes: %v", err)
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	quotes := []Quote{}
+	for rows.Next() {
+		var quote Quote
+		var description, orderID, createdBy sql.NullString
+		if err := rows.Scan(&quote.ID, &quote.Date, &quote.Name, &description, &quote.Total, &quote.ExpiresOn,
+			&quote.Status, &orderID, &createdBy); err != nil {
+			return nil, fmt.Errorf("failed to scan quote: %v", err)
+		}
+		quote.Description, quote.OrderID, quote.CreatedBy = description.String, orderID.String, createdBy.String
+		quote.Status = quoteStatus(quote.Status, quote.ExpiresOn, now)
+		quote.Items = items[quote.ID]
+		quotes = append(quotes, quote)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating quotes: %v", err)
+	}
+	return quotes, nil
+}
+
+// GetQuotes returns every quote, newest first
+func (db *Database) GetQuotes(ctx context.Context) ([]Quote, error) {
+	return queryQuotes(ctx, db.read, "1 = 1")
+}
+
+// GetQuote returns a single quote
+func (db *Database) GetQuote(ctx context.Context, id string) (Quote, error) {
+	quotes, err := queryQuotes(ctx, db.read, "id = ?", id)
+	if err != nil {
+		return Quote{}, err
+	}
+	if len(quotes) == 0 {
+		return Quote{}, &NotFoundError{Kind: "quote", ID: id}
+	}
+	return quotes[0], nil
+}
+
+// renderQuote lays out a quote on as many A4 pages as its lines need and returns the
+// PDF document. The text is drawn with embedded fonts, so Hebrew names and descriptions
+// print as written.
+func renderQuote(quote Quote) ([]byte, error) {
+	regular, bold, err := newDocumentFonts()
+	if err != nil {
+		return nil, err
+	}
+	fonts := map[string]*pdfFont{"F1": regular, "F2": bold}
+
+	left, right := quoteMargin, pageWidth-quoteMargin
+	top := pageHeight - quoteMargin
+
+	text := func(content *bytes.Buffer, font string, size float64, x float64, y float64, s string) {
+		fmt.Fprintf(content, "BT /%s %.0f Tf %.2f %.2f Td %s Tj ET\n", font, size, x, y, fonts[font].text(s))
+	}
+	// Amounts are right-aligned on x
+	amount := func(content *bytes.Buffer, font string, x float64, y float64, s string) {
+		text(content, font, 10, x-fonts[font].width(s, 10), y, s)
+	}
+	header := func(content *bytes.Buffer, y float64) {
+		text(content, "F2", 10, left, y, "Item")
+		amount(content, "F2", right-170, y, "Qty")
+		amount(content, "F2", right-85, y, "Price")
+		amount(content, "F2", right, y, "Amount")
+		fmt.Fprintf(content, "%.2f %.2f m %.2f %.2f l S\n", left, y-5, right, y-5)
+	}
+
+	var pages []string
+	var content bytes.Buffer
+	text(&content, "F2", 20, left, top-20, "Quote #"+quote.ID)
+	text(&content, "F1", 10, left, top-44, "Date: "+strings.SplitN(quote.Date, " ", 2)[0])
+	text(&content, "F1", 10, left, top-58, "Valid until: "+quote.ExpiresOn)
+	text(&content, "F2", 12, left, top-84, bold.fit(quote.Name, 12, right-left))
+	if quote.Description != "" {
+		text(&content, "F1", 10, left, top-100, regular.fit(quote.Description, 10, right-left))
+	}
+
+	y := top - 130
+	header(&content, y)
+	perPage := quoteLinesFirst
+	for i, item := range quote.Items {
+		if perPage == 0 {
+			pages = append(pages, content.String())
+			content.Reset()
+			y = top
+			header(&content, y)
+			perPage = quoteLinesNext
+		}
+		perPage--
+		y -= quoteLineHeight
+
+		text(&content, "F1", 10, left, y, regular.fit(item.ProductName, 10, right-left-220))
+		amount(&content, "F1", right-170, y, strconv.Itoa(item.Quantity))
+		amount(&content, "F1", right-85, y, formatAmount(item.Price))
+		amount(&content, "F1", right, y, formatAmount(item.Price*float64(item.Quantity)))
+		if i == len(quote.Items)-1 {
+			fmt.Fprintf(&content, "%.2f %.2f m %.2f %.2f l S\n", left, y-8, right, y-8)
+		}
+	}
+
+	amount(&content, "F2", right, y-26, "Total: "+formatAmount(quote.Total))
+	pages = append(pages, content.String())
+
+	// The fonts are written last, once the pages have drawn every glyph they need
+	var doc pdfDocument
+	var resources []string
+	for i, name := range []string{"F1", "F2"} {
+		font, err := fonts[name].addTo(&doc, "QUOTE"+string(rune('A'+i)))
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, fmt.Sprintf("/%s %d 0 R", name, font))
+	}
+	return doc.build(pages, strings.Join(resources, " ")), nil
+}
+
+// CreateQuote saves a draft quote and returns its ID, or "" on error
+func (a *App) CreateQuote(quote NewQuote) string {
+	ctx, logger, db, done := a.call("CreateQuote")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error creating quote", "err", err)
+		return ""
+	}
+
+	id, err := db.CreateQuote(ctx, quote, a.currentUser().Username)
+	if err != nil {
+		logger.Error("Error creating quote", "err", err)
+		return ""
+	}
+	logger.Info("Quote created", "id", id)
+	return id
+}
+
+// UpdateQuote changes a quote that has not been accepted
+func (a *App) UpdateQuote(id string, quote NewQuote) bool {
+	ctx, logger, db, done := a.call("UpdateQuote")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error updating quote", "err", err)
+		return false
+	}
+
+	if err := db.UpdateQuote(ctx, id, quote); err != nil {
+		logger.Error("Error updating quote", "id", id, "err", err)
+		return false
+	}
+	logger.Info("Quote updated", "id", id)
+	return true
+}
+
+// GetQuotes returns every quote, newest first
+func (a *App) GetQuotes() []Quote {
+	ctx, logger, db, done := a.call("GetQuotes")
+	defer done()
+
+	quotes, err := db.GetQuotes(ctx)
+	if err != nil {
+		logger.Error("Error getting quotes", "err", err)
+		return []Quote{}
+	}
+	return quotes
+}
+
+// UpdateQuoteStatus marks a quote as draft, sent or expired
+func (a *App) UpdateQuoteStatus(id string, status string) bool {
+	ctx, logger, db, done := a.call("UpdateQuoteStatus")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error updating quote status", "err", err)
+		return false
+	}
+
+	if err := db.UpdateQuoteStatus(ctx, id, status); err != nil {
+		logger.Error("Error updating quote status", "id", id, "status", status, "err", err)
+		return false
+	}
+	logger.Info("Quote status updated", "id", id, "status", status)
+	return true
+}
+
+// DeleteQuote removes a quote that has not been accepted
+func (a *App) DeleteQuote(id string) bool {
+	ctx, logger, db, done := a.call("DeleteQuote")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error deleting quote", "err", err)
+		return false
+	}
+
+	if err := db.DeleteQuote(ctx, id); err != nil {
+		logger.Error("Error deleting quote", "id", id, "err", err)
+		return false
+	}
+	logger.Info("Quote deleted", "id", id)
+	return true
+}
+
+// ConvertQuoteToOrder creates a pending order from a quote at the quoted prices and
+// returns the order's ID, or "" on error
+func (a *App) ConvertQuoteToOrder(id string) string {
+	ctx, logger, db, done := a.call("ConvertQuoteToOrder")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error converting quote", "err", err)
+		return ""
+	}
+
+	orderID, err := db.ConvertQuoteToOrder(ctx, id, a.currentUser().Username)
+	if err != nil {
+		logger.Error("Error converting quote", "id", id, "err", err)
+		return ""
+	}
+	logger.Info("Quote converted to order", "id", id, "order", orderID)
+	return orderID
+}
+
+// ExportQuotePDF asks where to save and writes a quote as a PDF document
+func (a *App) ExportQuotePDF(id string) bool {
+	ctx, logger, db, done := a.call("ExportQuotePDF")
+	defer done()
+
+	quote, err := db.GetQuote(ctx, id)
+	if err != nil {
+		logger.Error("Error exporting quote", "id", id, "err", err)
+		return false
+	}
+
+	filePath, err := wailsruntime.SaveFileDialog(a.ctx, wailsruntime.SaveDialogOptions{
+		Title:           "Save Quote",
+		DefaultFilename: fmt.Sprintf("quote-%s.pdf", quote.ID),
+		Filters: []wailsruntime.FileFilter{
+			{DisplayName: "PDF Documents (*.pdf)", Pattern: "*.pdf"},
+		},
+	})
+	if err != nil {
+		logger.Error("Error opening save dialog", "err", err)
+		return false
+	}
+	if filePath == "" {
+		// The user cancelled the dialog
+		return false
+	}
+
+	pdf, err := renderQuote(quote)
+	if err != nil {
+		logger.Error("Error rendering quote", "id", id, "err", err)
+		return false
+	}
+	if err := os.WriteFile(filePath, pdf, 0644); err != nil {
+		logger.Error("Error writing quote", "err", err)
+		return false
+	}
+
+	logger.Info("Quote exported", "id", id, "path", filePath)
+	return true
+}
@@ -0,0 +1,606 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Automatic backups are written at most once per backupInterval, keeping the newest maxDatabaseBackups
+const (
+	backupInterval     = 24 * time.Hour
+	maxDatabaseBackups = 7
+)
+
+// backupTimeLayout timestamps automatic backups and set-aside damaged files
+const backupTimeLayout = "20060102-150405"
+
+// Kinds of problems that keep the database from opening
+const (
+	ProblemLocked   = "locked"
+	ProblemDamaged  = "damaged"
+	ProblemReadOnly = "read-only"
+	ProblemOther    = "other"
+)
+
+// DatabaseState tells the frontend whether the database is open and, when it is not,
+// what went wrong and which ways back are available
+type DatabaseState struct {
+	Ready   bool   `json:"ready"`
+	Path    string `json:"path"`
+	Error   string `json:"error"`
+	Problem string `json:"problem"`
+	// LatestBackup is the newest automatic backup, "" if there is none
+	LatestBackup     string `json:"latestBackup"`
+	LatestBackupTime string `json:"latestBackupTime"`
+	// Message describes the outcome of the last recovery action
+	Message string `json:"message"`
+}
+
+// RecoveryReport summarizes what salvageDatabase copied out of a damaged file
+type RecoveryReport struct {
+	Tables int
+	Rows   int
+	// Damaged lists the tables that could not be read completely
+	Damaged []string
+}
+
+// databaseFiles are where the app keeps its database, its automatic backups and the
+// choice of a database file other than the default one
+type databaseFiles struct {
+	path         string
+	backupDir    string
+	settingsPath string
+}
+
+// defaultDatabaseFiles returns the locations in the application data folder
+func defaultDatabaseFiles() databaseFiles {
+	return databaseFiles{
+		path:         getDBPath(),
+		backupDir:    filepath.Join(getAppDataDir(), "backups"),
+		settingsPath: getDatabaseSettingsPath(),
+	}
+}
+
+// getDatabaseSettingsPath returns the file remembering the database file the user opened
+func getDatabaseSettingsPath() string {
+	return filepath.Join(getAppDataDir(), "database.json")
+}
+
+// databaseSettings is the content of database.json
+type databaseSettings struct {
+	Path string `json:"path"`
+}
+
+// loadDatabasePath returns the database file chosen in the settings file, or fallback
+// when none was chosen or the settings cannot be read
+func loadDatabasePath(settingsPath string, fallback string) string {
+	data, err := os.ReadFile(settingsPath)
+	if err != nil {
+		return fallback
+	}
+
+	var settings databaseSettings
+	if err := json.Unmarshal(data, &settings); err != nil || settings.Path == "" {
+		return fallback
+	}
+	return settings.Path
+}
+
+// saveDatabasePath remembers the database file to open on the next start
+func saveDatabasePath(settingsPath string, path string) error {
+	data, err := json.MarshalIndent(databaseSettings{Path: path}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(settingsPath), 0755); err != nil {
+		return fmt.Errorf("failed to create settings directory: %v", err)
+	}
+	if err := writeFileAtomic(settingsPath, data); err != nil {
+		return fmt.Errorf("failed to save database path: %v", err)
+	}
+	return nil
+}
+
+// classifyDatabaseError tells which kind of problem an error from opening the database is
+func classifyDatabaseError(err error) string {
+	message := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(message, "locked") || strings.Contains(message, "busy"):
+		return ProblemLocked
+	case strings.Contains(message, "malformed") || strings.Contains(message, "not a database") || strings.Contains(message, "corrupt"):
+		return ProblemDamaged
+	case strings.Contains(message, "readonly") || strings.Contains(message, "read-only") || strings.Contains(message, "permission denied"):
+		return ProblemReadOnly
+	default:
+		return ProblemOther
+	}
+}
+
+// quickCheck fails when SQLite finds the database file damaged
+func (db *Database) quickCheck() error {
+	var result string
+	if err := db.db.QueryRow("PRAGMA quick_check(1)").Scan(&result); err != nil {
+		return fmt.Errorf("failed to check database: %v", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("database disk image is malformed: %s", result)
+	}
+	return nil
+}
+
+// backupFiles returns the automatic backups in dir, newest first
+func backupFiles(dir string) []string {
+	matches, _ := filepath.Glob(filepath.Join(dir, "backup-*.sqlite"))
+	// The timestamp in the name sorts chronologically
+	sort.Sort(sort.Reverse(sort.StringSlice(matches)))
+	return matches
+}
+
+// backupIfDue writes an automatic backup to dir unless the newest one is younger than
+// interval, then deletes all but the newest keep backups. It returns the path of the
+// new backup, or "" if none was due.
+func (db *Database) backupIfDue(ctx context.Context, dir string, interval time.Duration, keep int) (string, error) {
+	if existing := backupFiles(dir); len(existing) > 0 {
+		if info, err := os.Stat(existing[0]); err == nil && time.Since(info.ModTime()) < interval {
+			return "", nil
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %v", err)
+	}
+	path := filepath.Join(dir, "backup-"+time.Now().Format(backupTimeLayout)+".sqlite")
+	if err := db.Backup(ctx, path); err != nil {
+		return "", err
+	}
+
+	for i, old := range backupFiles(dir) {
+		if i < keep {
+			continue
+		}
+		if err := os.Remove(old); err != nil {
+			db.log.Warn("Error removing old backup", "path", old, "err", err)
+		}
+	}
+	return path, nil
+}
+
+// setAside renames a database file and its journal files out of the way, so a
+// replacement can take its place without destroying what may still be recoverable.
+// It returns the new name of the database file, or "" if there was none.
+func setAside(path string) (string, error) {
+	target := path + ".broken-" + time.Now().Format(backupTimeLayout)
+	moved := ""
+	for _, suffix := range []string{"", "-wal", "-shm", "-journal"} {
+		if _, err := os.Stat(path + suffix); err != nil {
+			continue
+		}
+		if err := os.Rename(path+suffix, target+suffix); err != nil {
+			return "", fmt.Errorf("failed to move damaged database aside: %v", err)
+		}
+		if suffix == "" {
+			moved = target
+		}
+	}
+	return moved, nil
+}
+
+// replaceDatabase moves the database at path aside and puts the file at replacement in its place
+func replaceDatabase(path string, replacement string) (string, error) {
+	moved, err := setAside(path)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Rename(replacement, path); err != nil {
+		return moved, fmt.Errorf("failed to put the new database in place: %v", err)
+	}
+	return moved, nil
+}
+
+// copyFile copies src to a new file at dest
+func copyFile(src string, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dest)
+		return err
+	}
+	return out.Close()
+}
+
+// salvageDatabase copies every readable row of the database at src into a new database
+// at dest. Rows on damaged pages are skipped; the rest of their table is still copied.
+func salvageDatabase(src string, dest string) (RecoveryReport, error) {
+	var report RecoveryReport
+
+	from, err := sql.Open("sqlite3", "file:"+src+"?mode=ro")
+	if err != nil {
+		return report, fmt.Errorf("failed to open damaged database: %v", err)
+	}
+	defer from.Close()
+
+	type schemaEntry struct{ kind, name, sql string }
+	var schema []schemaEntry
+	rows, err := from.Query("SELECT type, name, sql FROM sqlite_master WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%'")
+	if err != nil {
+		return report, fmt.Errorf("failed to read the schema, nothing can be recovered: %v", err)
+	}
+	for rows.Next() {
+		var entry schemaEntry
+		if err := rows.Scan(&entry.kind, &entry.name, &entry.sql); err != nil {
+			rows.Close()
+			return report, fmt.Errorf("failed to read the schema, nothing can be recovered: %v", err)
+		}
+		schema = append(schema, entry)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return report, fmt.Errorf("failed to read the schema, nothing can be recovered: %v", err)
+	}
+
+	var userVersion int
+	from.QueryRow("PRAGMA user_version").Scan(&userVersion)
+
+	to, err := sql.Open("sqlite3", dest)
+	if err != nil {
+		return report, fmt.Errorf("failed to create recovered database: %v", err)
+	}
+	defer to.Close()
+
+	// Tables first, then their rows, then indexes and triggers so damaged data cannot
+	// trip a constraint halfway through the copy
+	for _, entry := range schema {
+		if entry.kind != "table" {
+			continue
+		}
+		if _, err := to.Exec(entry.sql); err != nil {
+			return report, fmt.Errorf("failed to create table %s: %v", entry.name, err)
+		}
+		copied, complete := salvageTable(from, to, entry.name)
+		report.Tables++
+		report.Rows += copied
+		if !complete {
+			report.Damaged = append(report.Damaged, entry.name)
+		}
+	}
+	for _, entry := range schema {
+		if entry.kind == "table" {
+			continue
+		}
+		// An index the salvaged rows violate is left out; the app recreates its own on open
+		to.Exec(entry.sql)
+	}
+
+	if _, err := to.Exec(fmt.Sprintf("PRAGMA user_version = %d", userVersion)); err != nil {
+		return report, fmt.Errorf("failed to set schema version: %v", err)
+	}
+	return report, nil
+}
+
+// salvageTable copies the readable rows of a table in rowid order. When a read fails it
+// skips ahead past the damage, doubling the jump each time it fails again. It reports
+// how many rows were copied and whether the whole table could be read.
+func salvageTable(from *sql.DB, to *sql.DB, table string) (int, bool) {
+	quoted := `"` + strings.ReplaceAll(table, `"`, `""`) + `"`
+	copied := 0
+	complete := true
+	after := int64(0)
+	skip := int64(1)
+
+	for attempt := 0; attempt < 64; attempt++ {
+		last, n, err := copyRowsAfter(from, to, quoted, after)
+		copied += n
+		if err == nil {
+			return copied, complete
+		}
+		complete = false
+		if n > 0 {
+			after = last
+			skip = 1
+		}
+		after += skip
+		skip *= 2
+	}
+	return copied, false
+}
+
+// copyRowsAfter copies the rows with a rowid above after, stopping at the first error.
+// It returns the last rowid copied and the number of rows.
+func copyRowsAfter(from *sql.DB, to *sql.DB, table string, after int64) (int64, int, error) {
+	rows, err := from.Query("SELECT rowid, * FROM "+table+" WHERE rowid > ? ORDER BY rowid", after)
+	if err != nil {
+		return after, 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return after, 0, err
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	insert := "INSERT INTO " + table + " (rowid, " + quotedColumns(columns[1:]) + ") VALUES (" + placeholders + ")"
+
+	tx, err := to.Begin()
+	if err != nil {
+		return after, 0, err
+	}
+	defer tx.Commit()
+
+	last, copied := after, 0
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return last, copied, err
+		}
+		last = values[0].(int64)
+		if _, err := tx.Exec(insert, values...); err != nil {
+			// A row that cannot be stored again is dropped
+			continue
+		}
+		copied++
+	}
+	return last, copied, rows.Err()
+}
+
+// quotedColumns joins column names as quoted SQL identifiers
+func quotedColumns(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = `"` + strings.ReplaceAll(column, `"`, `""`) + `"`
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// databaseFailed puts the App into recovery mode after the database at path could not
+// be opened. logger is used for the database once it is opened again.
+func (a *App) databaseFailed(path string, logger *slog.Logger, err error) {
+	a.dbMu.Lock()
+	defer a.dbMu.Unlock()
+
+	a.files.path = path
+	a.dbLog = logger
+	a.dbErr = err
+}
+
+// useDatabase makes db the App's database and starts the work that depends on it
+func (a *App) useDatabase(db *Database, message string) {
+	a.dbMu.Lock()
+	a.db = db
+	a.repos = db.Repositories()
+	a.files.path = db.path
+	a.dbErr = nil
+	a.recoveryMessage = message
+	background := a.background
+	a.dbMu.Unlock()
+
+	if background != nil {
+		a.databaseOpened(background, db)
+	}
+}
+
+// databaseOpened forwards the database's events, tidies up images and takes the
+// automatic backup once the database is open
+func (a *App) databaseOpened(ctx context.Context, db *Database) {
+	a.forwardEvents(ctx, db)
+
+	// Remove image files left behind by products deleted outside the app
+	if removed, err := a.pruneOrphanedImages(ctx, db); err != nil {
+		a.logger().Error("Error pruning orphaned images", "err", err)
+	} else if removed > 0 {
+		a.logger().Info("Removed orphaned product images", "count", removed)
+	}
+
+	go func() {
+		if path, err := db.backupIfDue(ctx, a.files.backupDir, backupInterval, maxDatabaseBackups); err != nil {
+			a.logger().Error("Error backing up database", "err", err)
+		} else if path != "" {
+			a.logger().Info("Database backed up", "path", path)
+		}
+	}()
+}
+
+// databaseState describes the database for the frontend
+func (a *App) databaseState() DatabaseState {
+	a.dbMu.RLock()
+	defer a.dbMu.RUnlock()
+
+	state := DatabaseState{Ready: a.db != nil, Path: a.files.path, Message: a.recoveryMessage}
+	if a.dbErr != nil {
+		state.Error = a.dbErr.Error()
+		state.Problem = classifyDatabaseError(a.dbErr)
+	}
+	if backups := backupFiles(a.files.backupDir); len(backups) > 0 {
+		state.LatestBackup = backups[0]
+		if info, err := os.Stat(backups[0]); err == nil {
+			state.LatestBackupTime = info.ModTime().Format(time.RFC3339)
+		}
+	}
+	return state
+}
+
+// runRecovery runs a recovery action while the App is in recovery mode. The action
+// returns the opened database and a message describing what it did.
+func (a *App) runRecovery(logger *slog.Logger, action func(path string, dbLog *slog.Logger) (*Database, string, error)) DatabaseState {
+	a.recoverMu.Lock()
+	defer a.recoverMu.Unlock()
+
+	a.dbMu.RLock()
+	open, path, dbLog := a.db != nil, a.files.path, a.dbLog
+	a.dbMu.RUnlock()
+
+	if open {
+		logger.Warn("Recovery requested while the database is open")
+		return a.databaseState()
+	}
+
+	db, message, err := action(path, dbLog)
+	if err != nil {
+		logger.Error("Database recovery failed", "err", err)
+		a.dbMu.Lock()
+		a.recoveryMessage = err.Error()
+		a.dbMu.Unlock()
+		return a.databaseState()
+	}
+
+	logger.Info("Database recovered", "path", db.path, "result", message)
+	a.useDatabase(db, message)
+	return a.databaseState()
+}
+
+// GetDatabaseState reports whether the database is open, and if not, why. The
+// frontend shows the recovery screen instead of the app while it is not ready.
+func (a *App) GetDatabaseState() DatabaseState {
+	return a.databaseState()
+}
+
+// RetryOpenDatabase tries to open the database again, for example once another
+// program has released its lock
+func (a *App) RetryOpenDatabase() DatabaseState {
+	logger, _ := a.begin("RetryOpenDatabase")
+
+	return a.runRecovery(logger, func(path string, dbLog *slog.Logger) (*Database, string, error) {
+		db, err := OpenDatabase(path, dbLog)
+		if err != nil {
+			a.setDatabaseError(err)
+			return nil, "", err
+		}
+		return db, "", nil
+	})
+}
+
+// OpenDatabaseFile lets the user pick another database file, opens it and remembers
+// it for the next start
+func (a *App) OpenDatabaseFile() DatabaseState {
+	logger, _ := a.begin("OpenDatabaseFile")
+
+	filePath, err := wailsruntime.OpenFileDialog(a.ctx, wailsruntime.OpenDialogOptions{
+		Title: "Open Database",
+		Filters: []wailsruntime.FileFilter{
+			{DisplayName: "SQLite Databases (*.sqlite;*.db)", Pattern: "*.sqlite;*.db"},
+		},
+	})
+	if err != nil {
+		logger.Error("Error opening file dialog", "err", err)
+		return a.databaseState()
+	}
+	if filePath == "" {
+		// The user cancelled the dialog
+		return a.databaseState()
+	}
+	return a.openDatabaseFile(logger, filePath)
+}
+
+// openDatabaseFile opens the database at filePath and remembers it for the next start
+func (a *App) openDatabaseFile(logger *slog.Logger, filePath string) DatabaseState {
+	return a.runRecovery(logger, func(_ string, dbLog *slog.Logger) (*Database, string, error) {
+		db, err := OpenDatabase(filePath, dbLog)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to open %s: %v", filePath, err)
+		}
+		if err := saveDatabasePath(a.files.settingsPath, filePath); err != nil {
+			logger.Warn("Error remembering database file", "err", err)
+		}
+		return db, "Opened " + filePath, nil
+	})
+}
+
+// RestoreLatestBackup replaces the database with the newest automatic backup. The
+// damaged file is kept next to it.
+func (a *App) RestoreLatestBackup() DatabaseState {
+	logger, _ := a.begin("RestoreLatestBackup")
+
+	return a.runRecovery(logger, func(path string, dbLog *slog.Logger) (*Database, string, error) {
+		backups := backupFiles(a.files.backupDir)
+		if len(backups) == 0 {
+			return nil, "", fmt.Errorf("no backup found in %s", a.files.backupDir)
+		}
+
+		restoring := path + ".restoring"
+		os.Remove(restoring)
+		if err := copyFile(backups[0], restoring); err != nil {
+			return nil, "", fmt.Errorf("failed to copy backup: %v", err)
+		}
+		moved, err := replaceDatabase(path, restoring)
+		if err != nil {
+			os.Remove(restoring)
+			return nil, "", err
+		}
+
+		db, err := OpenDatabase(path, dbLog)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to open restored backup: %v", err)
+		}
+		return db, fmt.Sprintf("Restored %s%s", filepath.Base(backups[0]), keptAs(moved)), nil
+	})
+}
+
+// RecoverDatabase copies everything still readable from the damaged database into a
+// new file and opens that instead. The damaged file is kept next to it.
+func (a *App) RecoverDatabase() DatabaseState {
+	logger, _ := a.begin("RecoverDatabase")
+
+	return a.runRecovery(logger, func(path string, dbLog *slog.Logger) (*Database, string, error) {
+		recovered := path + ".recovered"
+		os.Remove(recovered)
+		report, err := salvageDatabase(path, recovered)
+		if err != nil {
+			os.Remove(recovered)
+			return nil, "", err
+		}
+		moved, err := replaceDatabase(path, recovered)
+		if err != nil {
+			os.Remove(recovered)
+			return nil, "", err
+		}
+
+		db, err := OpenDatabase(path, dbLog)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to open recovered database: %v", err)
+		}
+
+		message := fmt.Sprintf("Recovered %d rows from %d tables", report.Rows, report.Tables)
+		if len(report.Damaged) > 0 {
+			message += "; some rows of " + strings.Join(report.Damaged, ", ") + " could not be read"
+		}
+		return db, message + keptAs(moved), nil
+	})
+}
+
+// keptAs describes where a damaged file was moved to
+func keptAs(moved string) string {
+	if moved == "" {
+		return ""
+	}
+	return "; the previous file was kept as " + filepath.Base(moved)
+}
+
+// setDatabaseError records why the database is still not open
+func (a *App) setDatabaseError(err error) {
+	a.dbMu.Lock()
+	defer a.dbMu.Unlock()
+	a.dbErr = err
+}
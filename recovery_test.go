@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newRecoveryApp creates an App in recovery mode for the database at path
+func newRecoveryApp(t *testing.T, path string, openErr error) *App {
+	t.Helper()
+
+	dir := t.TempDir()
+	app := newTestApp(t, nil)
+	app.files = databaseFiles{path: path, backupDir: filepath.Join(dir, "backups"), settingsPath: filepath.Join(dir, "database.json")}
+	app.databaseFailed(path, nil, openErr)
+	t.Cleanup(func() {
+		if _, db := app.stores(); db != nil {
+			db.Close()
+		}
+	})
+	return app
+}
+
+// createStockDatabase creates a database at path holding count stock items, with
+// descriptions long enough to spread them over many pages
+func createStockDatabase(t *testing.T, path string, count int) {
+	t.Helper()
+
+	db := openTestDatabaseAt(t, path)
+	defer db.Close()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < count; i++ {
+		if _, err := tx.Exec("INSERT INTO stock_items (id, name, description, quantity) VALUES (?, ?, ?, ?)",
+			fmt.Sprintf("item-%d", i), "Item", strings.Repeat("x", 500), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// damagePage overwrites one page in the middle of a database file with garbage
+func damagePage(t *testing.T, path string) {
+	t.Helper()
+
+	const pageSize = 4096
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	page := info.Size() / pageSize / 2
+	if _, err := file.WriteAt([]byte(strings.Repeat("\xff", pageSize)), page*pageSize); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClassifyDatabaseError(t *testing.T) {
+	cases := map[string]string{
+		"database is locked":                                      ProblemLocked,
+		"database disk image is malformed: page 7":                ProblemDamaged,
+		"failed to create products table: file is not a database": ProblemDamaged,
+		"attempt to write a readonly database":                    ProblemReadOnly,
+		"unable to open database file":                            ProblemOther,
+	}
+	for message, want := range cases {
+		if got := classifyDatabaseError(errors.New(message)); got != want {
+			t.Errorf("%q: got %s, want %s", message, got, want)
+		}
+	}
+}
+
+func TestSalvageDamagedDatabase(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "garden.sqlite")
+	createStockDatabase(t, path, 2000)
+	damagePage(t, path)
+
+	if db, err := OpenDatabase(path, nil); err == nil {
+		db.Close()
+		t.Fatal("expected the damaged database to be refused")
+	} else if classifyDatabaseError(err) != ProblemDamaged {
+		t.Fatalf("expected a damaged database error, got %v", err)
+	}
+
+	recovered := filepath.Join(dir, "recovered.sqlite")
+	report, err := salvageDatabase(path, recovered)
+	if err != nil {
+		t.Fatalf("salvage failed: %v", err)
+	}
+	if report.Tables == 0 || report.Rows == 0 {
+		t.Fatalf("expected rows to be recovered, got %+v", report)
+	}
+
+	db := openTestDatabaseAt(t, recovered)
+	items, err := db.GetStockItems(context.Background())
+	if err != nil || len(items) == 0 || len(items) > 2000 {
+		t.Fatalf("expected most stock items back, got %d, %v", len(items), err)
+	}
+}
+
+func TestBackupIfDue(t *testing.T) {
+	db := openTestDatabase(t)
+	dir := filepath.Join(t.TempDir(), "backups")
+
+	first, err := db.backupIfDue(context.Background(), dir, time.Hour, 3)
+	if err != nil || first == "" {
+		t.Fatalf("expected a first backup, got %q, %v", first, err)
+	}
+	if again, err := db.backupIfDue(context.Background(), dir, time.Hour, 3); err != nil || again != "" {
+		t.Fatalf("expected no backup within the interval, got %q, %v", again, err)
+	}
+
+	// Older backups beyond the limit are deleted
+	for _, name := range []string{"backup-20200101-000000.sqlite", "backup-20200102-000000.sqlite", "backup-20200103-000000.sqlite"} {
+		os.WriteFile(filepath.Join(dir, name), nil, 0644)
+	}
+	os.Chtimes(first, time.Now().Add(-2*time.Hour), time.Now().Add(-2*time.Hour))
+	time.Sleep(time.Second) // backups are named to the second
+	latest, err := db.backupIfDue(context.Background(), dir, time.Hour, 3)
+	if err != nil || latest == "" {
+		t.Fatalf("expected a due backup, got %q, %v", latest, err)
+	}
+
+	backups := backupFiles(dir)
+	if len(backups) != 3 || backups[0] != latest || backups[1] != first {
+		t.Fatalf("expected the newest three backups, got %v", backups)
+	}
+}
+
+func TestRecoveryMode(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "garden.sqlite")
+	os.WriteFile(path, []byte(strings.Repeat("not a database ", 400)), 0644)
+
+	_, openErr := OpenDatabase(path, nil)
+	if openErr == nil {
+		t.Fatal("expected the garbage file to be refused")
+	}
+	app := newRecoveryApp(t, path, openErr)
+
+	state := app.GetDatabaseState()
+	if state.Ready || state.Problem != ProblemDamaged || state.Path != path || state.LatestBackup != "" {
+		t.Fatalf("unexpected state %+v", state)
+	}
+	if status := app.DatabaseStatus(); status != "Database not initialized" {
+		t.Fatalf("unexpected status %q", status)
+	}
+
+	// Nothing can be salvaged from a file without a readable schema, and there is no backup yet
+	if state := app.RecoverDatabase(); state.Ready || state.Message == "" {
+		t.Fatalf("expected recovery to fail with a message, got %+v", state)
+	}
+	if state := app.RestoreLatestBackup(); state.Ready || !strings.Contains(state.Message, "no backup") {
+		t.Fatalf("expected restoring without a backup to fail, got %+v", state)
+	}
+
+	// Restore the newest automatic backup
+	source := openTestDatabase(t)
+	if _, err := source.AddStockItem(context.Background(), StockItem{Name: "Compost", Quantity: 12}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.backupIfDue(context.Background(), app.files.backupDir, time.Hour, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	state = app.RestoreLatestBackup()
+	if !state.Ready || state.Error != "" || !strings.Contains(state.Message, "kept as") {
+		t.Fatalf("expected the backup to be restored, got %+v", state)
+	}
+	if items := app.GetStockItems(); len(items) != 1 || items[0].Name != "Compost" {
+		t.Fatalf("expected the backed up stock, got %+v", items)
+	}
+	if broken, _ := filepath.Glob(path + ".broken-*"); len(broken) != 1 {
+		t.Fatalf("expected the damaged file to be kept, got %v", broken)
+	}
+
+	// Recovery actions do nothing once the database is open
+	if state := app.RecoverDatabase(); !state.Ready {
+		t.Fatalf("expected the open database to stay, got %+v", state)
+	}
+}
+
+func TestRecoverDatabaseBinding(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "garden.sqlite")
+	createStockDatabase(t, path, 2000)
+	damagePage(t, path)
+
+	_, openErr := OpenDatabase(path, nil)
+	app := newRecoveryApp(t, path, openErr)
+
+	state := app.RecoverDatabase()
+	if !state.Ready || !strings.HasPrefix(state.Message, "Recovered ") {
+		t.Fatalf("expected the database to be recovered, got %+v", state)
+	}
+	if items := app.GetStockItems(); len(items) == 0 {
+		t.Fatal("expected stock items in the recovered database")
+	}
+}
+
+func TestOpenDatabaseFile(t *testing.T) {
+	dir := t.TempDir()
+	app := newRecoveryApp(t, filepath.Join(dir, "missing", "garden.sqlite"), errors.New("unable to open database file"))
+
+	garbage := filepath.Join(dir, "garbage.sqlite")
+	os.WriteFile(garbage, []byte(strings.Repeat("garbage ", 1000)), 0644)
+	if state := app.openDatabaseFile(app.logger(), garbage); state.Ready || !strings.Contains(state.Message, garbage) {
+		t.Fatalf("expected opening a garbage file to fail, got %+v", state)
+	}
+
+	other := filepath.Join(dir, "other.sqlite")
+	createStockDatabase(t, other, 3)
+	state := app.openDatabaseFile(app.logger(), other)
+	if !state.Ready || state.Path != other {
+		t.Fatalf("expected the other file to open, got %+v", state)
+	}
+	if remembered := loadDatabasePath(app.files.settingsPath, ""); remembered != other {
+		t.Fatalf("expected the choice to be remembered, got %q", remembered)
+	}
+	if items := app.GetStockItems(); len(items) != 3 {
+		t.Fatalf("expected the other file's stock, got %d items", len(items))
+	}
+}
@@ -0,0 +1,665 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Frequencies at which a template can generate orders. A template without a frequency
+// only creates orders when asked to.
+const (
+	FrequencyWeekly  = "weekly"
+	FrequencyMonthly = "monthly"
+)
+
+// scheduleDateLayout is the layout of the days on which scheduled orders fall
+const scheduleDateLayout = "2006-01-02"
+
+// schedulerInterval is how often the app looks for scheduled orders that are due
+const schedulerInterval = 15 * time.Minute
+
+// maxCatchUpOrders is the most orders one schedule generates at once. When more days
+// were missed, only the latest are generated and the rest are skipped.
+const maxCatchUpOrders = 4
+
+// OrderTemplate is a saved order that can be created again on demand or on a schedule
+type OrderTemplate struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Items       []OrderItem `json:"items"`
+	Total       float64     `json:"total"`
+	// Frequency is "weekly", "monthly" or "" when the template is not scheduled
+	Frequency string `json:"frequency"`
+	// StartDate is the first day an order is due (YYYY-MM-DD). Monthly orders fall on
+	// its day of the month, or on the last day of shorter months.
+	StartDate string `json:"startDate"`
+	// NextRun is the day the next order will be generated and LastRun the day the last
+	// one was; both are kept by the scheduler
+	NextRun string `json:"nextRun"`
+	LastRun string `json:"lastRun"`
+	Paused  bool   `json:"paused"`
+}
+
+// ScheduledOrder is an order a template will generate on a coming day
+type ScheduledOrder struct {
+	TemplateID   string      `json:"templateId"`
+	TemplateName string      `json:"templateName"`
+	Date         string      `json:"date"`
+	Items        []OrderItem `json:"items"`
+	Total        float64     `json:"total"`
+}
+
+// initializeOrderTemplates creates the order_templates and order_template_items tables
+func (db *Database) initializeOrderTemplates() error {
+	_, err := db.db.Exec(`CREATE TABLE IF NOT EXISTS order_templates (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		description TEXT,
+		frequency TEXT,
+		start_date TEXT,
+		next_run TEXT,
+		last_run TEXT,
+		paused INTEGER NOT NULL DEFAULT 0,
+		created_by TEXT,
+		created_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create order_templates table: %v", err)
+	}
+
+	_, err = db.db.Exec(`CREATE TABLE IF NOT EXISTS order_template_items (
+		id TEXT PRIMARY KEY,
+		template_id TEXT NOT NULL,
+		product_id TEXT NOT NULL,
+		variant_id TEXT,
+		name TEXT NOT NULL,
+		price REAL NOT NULL,
+		quantity INTEGER NOT NULL,
+		FOREIGN KEY (template_id) REFERENCES order_templates(id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create order_template_items table: %v", err)
+	}
+
+	_, err = db.db.Exec("CREATE INDEX IF NOT EXISTS idx_order_template_items_template ON order_template_items(template_id)")
+	if err != nil {
+		return fmt.Errorf("failed to create order_template_items index: %v", err)
+	}
+	return nil
+}
+
+// validateOrderTemplate checks a template before it is saved
+func validateOrderTemplate(template OrderTemplate) error {
+	if strings.TrimSpace(template.Name) == "" {
+		return invalidf("a template must have a name")
+	}
+	if len(template.Items) == 0 {
+		return invalidf("a template must have at least one item")
+	}
+	for _, item := range template.Items {
+		if item.Quantity <= 0 {
+			return invalidf("quantity of %s must be positive", item.ProductName)
+		}
+		if item.Price < 0 {
+			return invalidf("price of %s cannot be negative", item.ProductName)
+		}
+	}
+
+	switch template.Frequency {
+	case "":
+		return nil
+	case FrequencyWeekly, FrequencyMonthly:
+	default:
+		return invalidf("unknown frequency %q, expected weekly or monthly", template.Frequency)
+	}
+	if _, err := time.ParseInLocation(scheduleDateLayout, template.StartDate, time.Local); err != nil {
+		return invalidf("invalid start date %q, expected YYYY-MM-DD", template.StartDate)
+	}
+	return nil
+}
+
+// scheduledDate returns the nth day, counting from zero, on which a schedule starting
+// on start falls. Monthly schedules keep the start's day of the month where they can.
+func scheduledDate(start time.Time, frequency string, n int) time.Time {
+	if frequency == FrequencyWeekly {
+		return start.AddDate(0, 0, 7*n)
+	}
+	first := time.Date(start.Year(), start.Month()+time.Month(n), 1, 0, 0, 0, 0, time.Local)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(start.Day(), lastDay)-1)
+}
+
+// firstScheduledDate returns the first day on or after from on which a schedule falls
+func firstScheduledDate(startDate string, frequency string, from time.Time) time.Time {
+	start, _ := time.ParseInLocation(scheduleDateLayout, startDate, time.Local)
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.Local)
+	for n := 0; ; n++ {
+		if date := scheduledDate(start, frequency, n); !date.Before(from) {
+			return date
+		}
+	}
+}
+
+// nextRun works out the day a template's next order is due, never earlier than today
+// or the day after its last order
+func nextRun(template OrderTemplate, today time.Time) string {
+	if template.Frequency == "" {
+		return ""
+	}
+	from := today
+	if last, err := time.ParseInLocation(scheduleDateLayout, template.LastRun, time.Local); err == nil && !last.Before(from) {
+		from = last.AddDate(0, 0, 1)
+	}
+	return firstScheduledDate(template.StartDate, template.Frequency, from).Format(scheduleDateLayout)
+}
+
+// SaveOrderTemplate creates a template, or replaces the one with the same ID, and
+// returns its ID. Saving a schedule works out when its next order is due.
+func (db *Database) SaveOrderTemplate(ctx context.Context, template OrderTemplate, savedBy string) (id string, err error) {
+	if err := validateOrderTemplate(template); err != nil {
+		return "", err
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Templates hold no price overrides; their orders are priced when they are created
+	if template.Items, err = resolveOrderItems(ctx, tx, atListPrice(template.Items)); err != nil {
+		return "", err
+	}
+
+	id = template.ID
+	if id == "" {
+		id = uuid.New().String()
+		_, err = tx.ExecContext(ctx, "INSERT INTO order_templates (id, name, created_by, created_at) VALUES (?, ?, ?, ?)",
+			id, template.Name, nullIfEmpty(savedBy), time.Now().Format(priceTimeLayout))
+		if err != nil {
+			return "", fmt.Errorf("failed to insert order template: %v", err)
+		}
+	} else {
+		var lastRun sql.NullString
+		err = tx.QueryRowContext(ctx, "SELECT last_run FROM order_templates WHERE id = ?", id).Scan(&lastRun)
+		if err == sql.ErrNoRows {
+			return "", &NotFoundError{Kind: "order template", ID: id}
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to query order template: %v", err)
+		}
+		template.LastRun = lastRun.String
+
+		if _, err = tx.ExecContext(ctx, "DELETE FROM order_template_items WHERE template_id = ?", id); err != nil {
+			return "", fmt.Errorf("failed to delete order template items: %v", err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE order_templates SET name = ?, description = ?, frequency = ?, start_date = ?, next_run = ?, paused = ?
+		WHERE id = ?
+	`, template.Name, template.Description, nullIfEmpty(template.Frequency), nullIfEmpty(template.StartDate),
+		nullIfEmpty(nextRun(template, time.Now())), template.Paused, id)
+	if err != nil {
+		return "", fmt.Errorf("failed to update order template: %v", err)
+	}
+
+	for _, item := range template.Items {
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO order_template_items (id, template_id, product_id, variant_id, name, price, quantity) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			uuid.New().String(), id, item.ProductID, nullIfEmpty(item.VariantID), item.ProductName, item.Price, item.Quantity,
+		)
+		if err != nil {
+			return "", fmt.Errorf("failed to insert order template item: %v", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	db.log.Debug("Order template saved", "id", id, "frequency", template.Frequency)
+	return id, nil
+}
+
+// queryer runs queries on the database or within a transaction
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// queryOrderTemplates reads the templates matching a condition, with their items
+func queryOrderTemplates(ctx context.Context, q queryer, where string, args ...any) ([]OrderTemplate, error) {
+	// Read the items first so that only one query is open at a time
+	rows, err := q.QueryContext(ctx, `
+		SELECT template_id, product_id, variant_id, name, price, quantity FROM order_template_items
+		WHERE template_id IN (SELECT id FROM order_templates WHERE `+where+`)
+		ORDER BY rowid
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order template items: %v", err)
+	}
+	items := map[string][]OrderItem{}
+	for rows.Next() {
+		var templateID string
+		var item OrderItem
+		var variantID sql.NullString
+		if err := rows.Scan(&templateID, &item.ProductID, &variantID, &item.ProductName, &item.Price, &item.Quantity); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan order template item: %v", err)
+		}
+		item.VariantID, item.ListPrice = variantID.String, item.Price
+		items[templateID] = append(items[templateID], item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating order template items: %v", err)
+	}
+
+	rows, err = q.QueryContext(ctx, `
+		SELECT id, name, description, frequency, start_date, next_run, last_run, paused FROM order_templates
+		WHERE `+where+`
+		ORDER BY name
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order templates: %v", err)
+	}
+	defer rows.Close()
+
+	templates := []OrderTemplate{}
+	for rows.Next() {
+		var template OrderTemplate
+		var description, frequency, startDate, next, last sql.NullString
+		if err := rows.Scan(&template.ID, &template.Name, &description, &frequency, &startDate, &next, &last, &template.Paused); err != nil {
+			return nil, fmt.Errorf("failed to scan order template: %v", err)
+		}
+		template.Description, template.Frequency, template.StartDate = description.String, frequency.String, startDate.String
+		template.NextRun, template.LastRun = next.String, last.String
+		template.Items = items[template.ID]
+		template.Total = orderTotal(template.Items)
+		templates = append(templates, template)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating order templates: %v", err)
+	}
+	return templates, nil
+}
+
+// GetOrderTemplates returns every order template by name
+func (db *Database) GetOrderTemplates(ctx context.Context) ([]OrderTemplate, error) {
+	return queryOrderTemplates(ctx, db.read, "1 = 1")
+}
+
+// GetOrderTemplate returns a single order template
+func (db *Database) GetOrderTemplate(ctx context.Context, id string) (OrderTemplate, error) {
+	templates, err := queryOrderTemplates(ctx, db.read, "id = ?", id)
+	if err != nil {
+		return OrderTemplate{}, err
+	}
+	if len(templates) == 0 {
+		return OrderTemplate{}, &NotFoundError{Kind: "order template", ID: id}
+	}
+	return templates[0], nil
+}
+
+// DeleteOrderTemplate removes a template and its schedule. Orders it already created
+// are kept.
+func (db *Database) DeleteOrderTemplate(ctx context.Context, id string) (err error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM order_template_items WHERE template_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete order template items: %v", err)
+	}
+	result, err := tx.ExecContext(ctx, "DELETE FROM order_templates WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete order template: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		err = &NotFoundError{Kind: "order template", ID: id}
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// CreateOrderFromTemplate creates a pending order with a template's items now and
+// returns the order's ID
+func (db *Database) CreateOrderFromTemplate(ctx context.Context, templateID string, createdBy string) (orderID string, err error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	templates, err := queryOrderTemplates(ctx, tx, "id = ?", templateID)
+	if err != nil {
+		return "", err
+	}
+	if len(templates) == 0 {
+		return "", &NotFoundError{Kind: "order template", ID: templateID}
+	}
+	template := templates[0]
+
+	items, err := resolveOrderItems(ctx, tx, template.Items)
+	if err != nil {
+		return "", err
+	}
+	if orderID, err = db.insertOrder(ctx, tx, template.Name, template.Description, items); err != nil {
+		return "", err
+	}
+	change := fmt.Sprintf("Created from template %q", template.Name)
+	if err = insertOrderChanges(ctx, tx, orderID, createdBy, []string{change}); err != nil {
+		return "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	db.publish(EntityOrder, ActionCreated, orderID)
+	db.publishReservations(orderID, nil)
+	return orderID, nil
+}
+
+// GenerateDueOrders creates the pending orders of every active schedule due on or
+// before now and returns their IDs. An order is created for each day that was missed
+// while the app was closed, up to maxCatchUpOrders, and each carries the day it was due
+// in its history. A schedule that fails does not hold up the others: its error is
+// logged and returned with theirs, and a schedule that can no longer create its order,
+// such as one with a deleted product, is paused.
+func (db *Database) GenerateDueOrders(ctx context.Context, now time.Time) ([]string, error) {
+	today := now.Format(scheduleDateLayout)
+	templates, err := queryOrderTemplates(ctx, db.read, "frequency IS NOT NULL AND paused = 0 AND next_run <= ?", today)
+	if err != nil {
+		return nil, err
+	}
+
+	var created []string
+	var failed []error
+	for _, template := range templates {
+		ids, err := db.generateTemplateOrders(ctx, template, now)
+		created = append(created, ids...)
+		if err == nil {
+			continue
+		}
+		db.log.Warn("Error generating scheduled orders", "template", template.ID, "err", err)
+		failed = append(failed, fmt.Errorf("template %s: %w", template.ID, err))
+
+		var invalid *ValidationError
+		var notFound *NotFoundError
+		if errors.As(err, &invalid) || errors.As(err, &notFound) {
+			if _, err := db.db.ExecContext(ctx, "UPDATE order_templates SET paused = 1 WHERE id = ?", template.ID); err != nil {
+				db.log.Warn("Error pausing order template", "template", template.ID, "err", err)
+			} else {
+				db.log.Warn("Order template paused", "template", template.ID)
+			}
+		}
+	}
+	return created, errors.Join(failed...)
+}
+
+// generateTemplateOrders creates the orders a template is due and moves its schedule on
+func (db *Database) generateTemplateOrders(ctx context.Context, template OrderTemplate, now time.Time) (created []string, err error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			created = nil
+		}
+	}()
+
+	items, err := resolveOrderItems(ctx, tx, template.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	today := now.Format(scheduleDateLayout)
+	var days []string
+	due := template.NextRun
+	for due <= today {
+		days = append(days, due)
+		date, _ := time.ParseInLocation(scheduleDateLayout, due, time.Local)
+		due = firstScheduledDate(template.StartDate, template.Frequency, date.AddDate(0, 0, 1)).Format(scheduleDateLayout)
+	}
+
+	// A schedule left for months catches up with its latest orders only
+	var skipped []string
+	if len(days) > maxCatchUpOrders {
+		skipped, days = days[:len(days)-maxCatchUpOrders], days[len(days)-maxCatchUpOrders:]
+		db.log.Warn("Skipped missed scheduled orders", "template", template.ID, "skipped", len(skipped), "from", skipped[0])
+	}
+
+	for i, day := range days {
+		var orderID string
+		if orderID, err = db.insertOrder(ctx, tx, template.Name, template.Description, items); err != nil {
+			return nil, err
+		}
+		changes := []string{fmt.Sprintf("Generated from template %q for %s", template.Name, day)}
+		if i == 0 && len(skipped) > 0 {
+			changes = append(changes, fmt.Sprintf("Skipped %d earlier orders missed from %s to %s", len(skipped), skipped[0], skipped[len(skipped)-1]))
+		}
+		if err = insertOrderChanges(ctx, tx, orderID, "", changes); err != nil {
+			return nil, err
+		}
+		created = append(created, orderID)
+		template.LastRun = day
+	}
+
+	// Only move the schedule on from where it was read, in case another process
+	// generated the same orders in the meantime
+	result, err := tx.ExecContext(ctx, "UPDATE order_templates SET next_run = ?, last_run = ? WHERE id = ? AND next_run = ?",
+		due, template.LastRun, template.ID, template.NextRun)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update order template schedule: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		err = tx.Rollback()
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	db.log.Info("Scheduled orders generated", "template", template.ID, "orders", len(created), "next_run", due)
+	for _, id := range created {
+		db.publish(EntityOrder, ActionCreated, id)
+		db.publishReservations(id, nil)
+	}
+	return created, nil
+}
+
+// PreviewScheduledOrders lists the orders active schedules will generate over the given
+// number of days from now, by date
+func (db *Database) PreviewScheduledOrders(ctx context.Context, now time.Time, days int) ([]ScheduledOrder, error) {
+	if days <= 0 {
+		return nil, invalidf("days must be positive")
+	}
+	templates, err := queryOrderTemplates(ctx, db.read, "frequency IS NOT NULL AND paused = 0")
+	if err != nil {
+		return nil, err
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	end := today.AddDate(0, 0, days)
+	upcoming := []ScheduledOrder{}
+	for _, template := range templates {
+		// Orders already due are generated on the scheduler's next run, so show them today,
+		// as many as it will catch up with
+		next, err := time.ParseInLocation(scheduleDateLayout, template.NextRun, time.Local)
+		if err != nil {
+			continue
+		}
+		var orders []ScheduledOrder
+		due := 0
+		for date := next; date.Before(end); {
+			shown := date
+			if !shown.After(today) {
+				shown = today
+				due++
+			}
+			orders = append(orders, ScheduledOrder{
+				TemplateID: template.ID, TemplateName: template.Name, Date: shown.Format(scheduleDateLayout),
+				Items: template.Items, Total: template.Total,
+			})
+			date = firstScheduledDate(template.StartDate, template.Frequency, date.AddDate(0, 0, 1))
+		}
+		upcoming = append(upcoming, orders[max(due-maxCatchUpOrders, 0):]...)
+	}
+
+	sort.SliceStable(upcoming, func(i, j int) bool {
+		if upcoming[i].Date != upcoming[j].Date {
+			return upcoming[i].Date < upcoming[j].Date
+		}
+		return upcoming[i].TemplateName < upcoming[j].TemplateName
+	})
+	return upcoming, nil
+}
+
+// runScheduler generates the orders of due schedules now and every schedulerInterval
+// until ctx is done
+func (a *App) runScheduler(ctx context.Context) {
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		a.generateDueOrders()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// generateDueOrders creates the orders that are due, unless the database is not open
+func (a *App) generateDueOrders() {
+	if _, db := a.stores(); db == nil {
+		return
+	}
+	ctx, logger, db, done := a.call("GenerateDueOrders")
+	defer done()
+
+	created, err := db.GenerateDueOrders(ctx, time.Now())
+	if err != nil {
+		logger.Error("Error generating scheduled orders", "err", err)
+	}
+	if len(created) > 0 {
+		logger.Info("Scheduled orders created", "orders", created)
+	}
+}
+
+// SaveOrderTemplate creates or replaces an order template and returns its ID, or "" on error
+func (a *App) SaveOrderTemplate(template OrderTemplate) string {
+	ctx, logger, db, done := a.call("SaveOrderTemplate")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error saving order template", "err", err)
+		return ""
+	}
+
+	id, err := db.SaveOrderTemplate(ctx, template, a.currentUser().Username)
+	if err != nil {
+		logger.Error("Error saving order template", "id", template.ID, "err", err)
+		return ""
+	}
+	logger.Info("Order template saved", "id", id)
+	return id
+}
+
+// GetOrderTemplates returns every order template by name
+func (a *App) GetOrderTemplates() []OrderTemplate {
+	ctx, logger, db, done := a.call("GetOrderTemplates")
+	defer done()
+
+	templates, err := db.GetOrderTemplates(ctx)
+	if err != nil {
+		logger.Error("Error getting order templates", "err", err)
+		return []OrderTemplate{}
+	}
+	return templates
+}
+
+// DeleteOrderTemplate removes an order template and its schedule
+func (a *App) DeleteOrderTemplate(id string) bool {
+	ctx, logger, db, done := a.call("DeleteOrderTemplate")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error deleting order template", "err", err)
+		return false
+	}
+
+	if err := db.DeleteOrderTemplate(ctx, id); err != nil {
+		logger.Error("Error deleting order template", "id", id, "err", err)
+		return false
+	}
+	logger.Info("Order template deleted", "id", id)
+	return true
+}
+
+// CreateOrderFromTemplate creates a pending order from a template now and returns its
+// ID, or "" on error
+func (a *App) CreateOrderFromTemplate(templateID string) string {
+	ctx, logger, db, done := a.call("CreateOrderFromTemplate")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error creating order from template", "err", err)
+		return ""
+	}
+
+	orderID, err := db.CreateOrderFromTemplate(ctx, templateID, a.currentUser().Username)
+	if err != nil {
+		logger.Error("Error creating order from template", "template", templateID, "err", err)
+		return ""
+	}
+	logger.Info("Order created from template", "template", templateID, "id", orderID)
+	return orderID
+}
+
+// PreviewScheduledOrders lists the orders schedules will generate over the coming days
+func (a *App) PreviewScheduledOrders(days int) []ScheduledOrder {
+	ctx, logger, db, done := a.call("PreviewScheduledOrders")
+	defer done()
+
+	upcoming, err := db.PreviewScheduledOrders(ctx, time.Now(), days)
+	if err != nil {
+		logger.Error("Error previewing scheduled orders", "err", err)
+		return []ScheduledOrder{}
+	}
+	return upcoming
+}
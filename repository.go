@@ -0,0 +1,71 @@
+package main
+
+import "context"
+
+// ProductRepository stores the product catalogue
+type ProductRepository interface {
+	GetProducts(ctx context.Context) ([]Product, error)
+	AddProduct(ctx context.Context, product Product) (string, error)
+	UpdateProduct(ctx context.Context, product Product) error
+	DeleteProduct(ctx context.Context, id string) error
+
+	// GetProductImage returns the name of a product's image, or "" if it has none
+	GetProductImage(ctx context.Context, productID string) (string, error)
+	// GetReferencedImages returns the names of all images used by a product
+	GetReferencedImages(ctx context.Context) (map[string]bool, error)
+}
+
+// OrderRepository stores customer orders
+type OrderRepository interface {
+	GetOrders(ctx context.Context) ([]Order, error)
+	CreateOrder(ctx context.Context, name string, description string, items []OrderItem) (string, error)
+	UpdateOrderStatus(ctx context.Context, orderID string, status string) error
+	DeleteOrder(ctx context.Context, id string) error
+
+	// UpdateOrder replaces the name, description and items of an editable order and
+	// records the changes under changedBy
+	UpdateOrder(ctx context.Context, id string, update NewOrder, changedBy string) error
+	// GetOrderHistory returns the changes made to an order, oldest first
+	GetOrderHistory(ctx context.Context, orderID string) ([]OrderChange, error)
+}
+
+// StockRepository stores the inventory. UpdateStockItem and DeleteStockItem report
+// false when no item has the ID.
+type StockRepository interface {
+	GetStockItems(ctx context.Context) ([]StockItem, error)
+	AddStockItem(ctx context.Context, item StockItem) (StockItem, error)
+	UpdateStockItem(ctx context.Context, item StockItem) (bool, error)
+	DeleteStockItem(ctx context.Context, id string) (bool, error)
+}
+
+// Repositories are the stores the App reads and writes products, orders and stock through
+type Repositories struct {
+	Products ProductRepository
+	Orders   OrderRepository
+	Stock    StockRepository
+}
+
+// Repositories returns the database as the App's product, order and stock stores
+func (db *Database) Repositories() Repositories {
+	return Repositories{Products: db, Orders: db, Stock: db}
+}
+
+// with returns the repositories with their log messages tagged with args. Only the
+// SQLite database logs, so other implementations are returned unchanged.
+func (r Repositories) with(args ...any) Repositories {
+	return Repositories{
+		Products: tagged(r.Products, args...),
+		Orders:   tagged(r.Orders, args...),
+		Stock:    tagged(r.Stock, args...),
+	}
+}
+
+// tagged returns repo with its log messages tagged with args if it is the SQLite database
+func tagged[T any](repo T, args ...any) T {
+	if db, ok := any(repo).(*Database); ok && db != nil {
+		if scoped, ok := any(db.with(args...)).(T); ok {
+			return scoped
+		}
+	}
+	return repo
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Open orders reserve the stock their lines will ship from. A line draws on the stock
+// item linked to its variant, and reserves what it still has to ship for as long as
+// its order can ship. Reservations follow the orders, so creating, editing, cancelling
+// or deleting an order changes them without anything else to keep in step.
+
+// queryReservations adds up the quantities reserved by open orders for the stock items
+// matching a condition on stock_items, keyed by stock item
+func queryReservations(ctx context.Context, q queryer, where string, args ...any) (map[string]float64, error) {
+	// Orders reserve stock for as long as they can ship
+	var statuses []any
+	for status := range shippableOrderStatuses {
+		statuses = append(statuses, status)
+	}
+	in := strings.TrimSuffix(strings.Repeat("?, ", len(statuses)), ", ")
+	args = append(statuses, args...)
+	rows, err := q.QueryContext(ctx, `
+		SELECT v.stock_item_id, SUM(oi.quantity - COALESCE((
+			SELECT SUM(si.quantity) FROM shipment_items si WHERE si.order_item_id = oi.id
+		), 0))
+		FROM order_items oi
+		JOIN orders o ON o.id = oi.order_id
+		JOIN product_variants v ON v.id = oi.variant_id
+		WHERE o.status IN (`+in+`)
+			AND v.stock_item_id IN (SELECT id FROM stock_items WHERE `+where+`)
+		GROUP BY v.stock_item_id
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reservations: %v", err)
+	}
+	defer rows.Close()
+
+	reserved := make(map[string]float64)
+	for rows.Next() {
+		var stockItemID string
+		var quantity float64
+		if err := rows.Scan(&stockItemID, &quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan reservation: %v", err)
+		}
+		reserved[stockItemID] = quantity
+	}
+	return reserved, rows.Err()
+}
+
+// setReservations fills in the reserved and available quantities of stock items
+func setReservations(items []StockItem, reserved map[string]float64) {
+	for i := range items {
+		items[i].Reserved = reserved[items[i].ID]
+		items[i].Available = items[i].Quantity - items[i].Reserved
+	}
+}
+
+// orderStockItems returns the stock items an order's lines reserve
+func orderStockItems(ctx context.Context, q queryer, orderID string) ([]string, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT DISTINCT v.stock_item_id FROM order_items oi JOIN product_variants v ON v.id = oi.variant_id
+		WHERE oi.order_id = ? AND v.stock_item_id IS NOT NULL
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order stock items: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan order stock item: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// publishReservations announces the stock items whose reservations an order changed:
+// those its lines reserved before the change, and those they reserve now. A failed
+// lookup only costs the events, so it is logged rather than returned.
+func (db *Database) publishReservations(orderID string, before []string) {
+	if !db.events.HasSubscribers() {
+		return
+	}
+	// The change is committed, so the lookup runs even if the caller's context ends
+	after, err := orderStockItems(context.Background(), db.read, orderID)
+	if err != nil {
+		db.log.Warn("Error finding reserved stock", "order", orderID, "err", err)
+	}
+	seen := make(map[string]bool)
+	for _, id := range append(before, after...) {
+		if !seen[id] {
+			seen[id] = true
+			db.publish(EntityStock, ActionUpdated, id)
+		}
+	}
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// returnConditions are the states a returned item can come back in
+var returnConditions = map[string]bool{"Resellable": true, "Opened": true, "Damaged": true}
+
+// ReturnLine is a quantity of one shipped order item that came back
+type ReturnLine struct {
+	OrderItemID string `json:"orderItemId"`
+	Quantity    int    `json:"quantity"`
+	Condition   string `json:"condition"`
+	// RestockItemID names the stock item the quantity goes back into, or "" to leave
+	// stock unchanged
+	RestockItemID string `json:"restockItemId"`
+}
+
+// NewReturn is the request body for recording a return against an order
+type NewReturn struct {
+	Items  []ReturnLine `json:"items"`
+	Reason string       `json:"reason"`
+	Refund float64      `json:"refund"`
+	// LocationID is where restocked items go back to, or "" for the default location
+	LocationID string `json:"locationId"`
+}
+
+// ReturnItem is a returned quantity of an order item
+type ReturnItem struct {
+	OrderItemID   string `json:"orderItemId"`
+	ProductName   string `json:"productName"`
+	Quantity      int    `json:"quantity"`
+	Condition     string `json:"condition"`
+	RestockItemID string `json:"restockItemId"`
+}
+
+// Return records items of an order that came back and the amount refunded for them
+type Return struct {
+	ID         string  `json:"id"`
+	OrderID    string  `json:"orderId"`
+	ReturnedAt string  `json:"returnedAt"`
+	ReturnedBy string  `json:"returnedBy"`
+	Reason     string  `json:"reason"`
+	Refund     float64 `json:"refund"`
+	// PaidOut is the part of Refund paid back to the customer. The rest came off what
+	// they still owed on the order.
+	PaidOut float64      `json:"paidOut"`
+	Items   []ReturnItem `json:"items"`
+}
+
+// SalesSummary totals sales and refunds over a date range
+type SalesSummary struct {
+	OrderCount    int     `json:"orderCount"`
+	GrossSales    float64 `json:"grossSales"`
+	ReturnCount   int     `json:"returnCount"`
+	ReturnedUnits int     `json:"returnedUnits"`
+	Refunds       float64 `json:"refunds"`
+	NetSales      float64 `json:"netSales"`
+}
+
+// initializeReturns creates the returns and return_items tables
+func (db *Database) initializeReturns() error {
+	_, err := db.db.Exec(`CREATE TABLE IF NOT EXISTS returns (
+		id TEXT PRIMARY KEY,
+		order_id TEXT NOT NULL,
+		returned_at TEXT NOT NULL,
+		returned_by TEXT,
+		reason TEXT NOT NULL,
+		refund REAL NOT NULL DEFAULT 0,
+		FOREIGN KEY (order_id) REFERENCES orders(id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create returns table: %v", err)
+	}
+
+	_, err = db.db.Exec(`CREATE TABLE IF NOT EXISTS return_items (
+		id TEXT PRIMARY KEY,
+		return_id TEXT NOT NULL,
+		order_item_id TEXT NOT NULL,
+		quantity INTEGER NOT NULL,
+		condition TEXT NOT NULL,
+		stock_item_id TEXT,
+		FOREIGN KEY (return_id) REFERENCES returns(id),
+		FOREIGN KEY (order_item_id) REFERENCES order_items(id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create return_items table: %v", err)
+	}
+
+	_, err = db.db.Exec("CREATE INDEX IF NOT EXISTS idx_return_items_order_item ON return_items(order_item_id)")
+	if err != nil {
+		return fmt.Errorf("failed to create return_items index: %v", err)
+	}
+	return nil
+}
+
+// initializeRefundPayouts adds the paid_out column to returns. Refunds given before it
+// existed were all paid back, which is how balances were worked out until then.
+func (db *Database) initializeRefundPayouts() error {
+	exists, err := db.columnExists("returns", "paid_out")
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	if _, err := db.db.Exec("ALTER TABLE returns ADD COLUMN paid_out REAL NOT NULL DEFAULT 0"); err != nil {
+		return fmt.Errorf("failed to add paid_out column to returns table: %v", err)
+	}
+	if _, err := db.db.Exec("UPDATE returns SET paid_out = refund"); err != nil {
+		return fmt.Errorf("failed to set paid_out of existing returns: %v", err)
+	}
+	return nil
+}
+
+// toCents rounds an amount to whole cents, so sums of prices compare reliably
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// validateReturn checks that a return names shipped items of the order that have not
+// come back yet, and refunds no more than the order has left to refund. Only what the
+// customer has paid can be paid back, which paidOutRefund sees to.
+func validateReturn(order Order, ret NewReturn) error {
+	if len(ret.Items) == 0 {
+		return invalidf("choose at least one item to return")
+	}
+	if strings.TrimSpace(ret.Reason) == "" {
+		return invalidf("a return needs a reason")
+	}
+
+	seen := make(map[string]bool)
+	for _, line := range ret.Items {
+		item, ok := findOrderItem(order, line.OrderItemID)
+		if !ok {
+			return invalidf("order %s has no item %s", order.ID, line.OrderItemID)
+		}
+		if seen[line.OrderItemID] {
+			return invalidf("%s is listed more than once", item.ProductName)
+		}
+		seen[line.OrderItemID] = true
+
+		if line.Quantity <= 0 {
+			return invalidf("quantity of %s must be positive", item.ProductName)
+		}
+		if returnable := item.Shipped - item.Returned; line.Quantity > returnable {
+			return invalidf("only %d × %s can be returned", returnable, item.ProductName)
+		}
+		if !returnConditions[line.Condition] {
+			return invalidf("unknown condition %q for %s", line.Condition, item.ProductName)
+		}
+		if line.Condition == "Damaged" && line.RestockItemID != "" {
+			return invalidf("damaged %s cannot be restocked", item.ProductName)
+		}
+	}
+
+	if ret.Refund < 0 {
+		return invalidf("a refund cannot be negative")
+	}
+	if left := toCents(order.Total) - toCents(order.Refunded); toCents(ret.Refund) > left {
+		return invalidf("only %s of order %s is left to refund", formatAmount(float64(left)/100), order.ID)
+	}
+	return nil
+}
+
+// paidOutRefund returns how much of a refund is paid back to the customer. The refund
+// first comes off what they still owe on the order, and only the rest is paid back.
+func paidOutRefund(order Order, refund float64) float64 {
+	owing := max(toCents(order.BalanceDue), 0)
+	return float64(max(toCents(refund)-owing, 0)) / 100
+}
+
+// CreateReturn records items that came back from an order with the amount refunded,
+// and puts the restocked quantities back into stock
+func (db *Database) CreateReturn(ctx context.Context, orderID string, ret NewReturn, returnedBy string) (id string, err error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	order, err := loadOrder(ctx, tx, orderID)
+	if err != nil {
+		return "", err
+	}
+	if err = validateReturn(order, ret); err != nil {
+		return "", err
+	}
+
+	id = uuid.New().String()
+	paidOut := paidOutRefund(order, ret.Refund)
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO returns (id, order_id, returned_at, returned_by, reason, refund, paid_out) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		id, orderID, time.Now().Format(priceTimeLayout), nullIfEmpty(returnedBy), ret.Reason, ret.Refund, paidOut,
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to insert return: %v", err)
+	}
+
+	var changes, restocked []string
+	for _, line := range ret.Items {
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO return_items (id, return_id, order_item_id, quantity, condition, stock_item_id) VALUES (?, ?, ?, ?, ?, ?)",
+			uuid.New().String(), id, line.OrderItemID, line.Quantity, line.Condition, nullIfEmpty(line.RestockItemID),
+		)
+		if err != nil {
+			return "", fmt.Errorf("failed to insert return item: %v", err)
+		}
+
+		item, _ := findOrderItem(order, line.OrderItemID)
+		change := fmt.Sprintf("Returned %d × %s (%s)", line.Quantity, item.ProductName, line.Condition)
+
+		if line.RestockItemID != "" {
+			_, err = moveStock(ctx, tx, StockMovement{
+				StockItemID: line.RestockItemID, Kind: MovementReturn, ToLocationID: firstNonEmpty(ret.LocationID, DefaultLocationID),
+				Quantity: float64(line.Quantity), Reference: "Order #" + orderID, MovedBy: returnedBy,
+			})
+			if err != nil {
+				return "", err
+			}
+			restocked = append(restocked, line.RestockItemID)
+			change += ", restocked"
+		}
+		changes = append(changes, change)
+	}
+	switch {
+	case ret.Refund <= 0:
+	case toCents(paidOut) == toCents(ret.Refund):
+		changes = append(changes, "Refunded "+formatAmount(ret.Refund))
+	default:
+		changes = append(changes, fmt.Sprintf("Refunded %s: %s paid back, %s taken off the balance due",
+			formatAmount(ret.Refund), formatAmount(paidOut), formatAmount(ret.Refund-paidOut)))
+	}
+
+	if err = insertOrderChanges(ctx, tx, orderID, returnedBy, changes); err != nil {
+		return "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	db.log.Debug("Return recorded", "order", orderID, "return", id, "lines", len(ret.Items), "refund", ret.Refund)
+	db.publish(EntityOrder, ActionUpdated, orderID)
+	for _, stockID := range restocked {
+		db.publish(EntityStock, ActionUpdated, stockID)
+	}
+	return id, nil
+}
+
+// GetReturns returns the returns recorded against an order, oldest first
+func (db *Database) GetReturns(ctx context.Context, orderID string) ([]Return, error) {
+	// Items are read first, because the read pool may not have a second connection
+	// free while the return rows are open
+	itemRows, err := db.read.QueryContext(ctx, `
+		SELECT ri.return_id, ri.order_item_id, oi.name, ri.quantity, ri.condition, ri.stock_item_id
+		FROM return_items ri
+		JOIN returns r ON r.id = ri.return_id
+		JOIN order_items oi ON oi.id = ri.order_item_id
+		WHERE r.order_id = ?
+		ORDER BY ri.rowid
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query return items: %v", err)
+	}
+	defer itemRows.Close()
+
+	itemsByReturn := make(map[string][]ReturnItem)
+	for itemRows.Next() {
+		var returnID string
+		var item ReturnItem
+		var stockItemID sql.NullString
+		if err := itemRows.Scan(&returnID, &item.OrderItemID, &item.ProductName, &item.Quantity, &item.Condition, &stockItemID); err != nil {
+			return nil, fmt.Errorf("failed to scan return item: %v", err)
+		}
+		item.RestockItemID = stockItemID.String
+		itemsByReturn[returnID] = append(itemsByReturn[returnID], item)
+	}
+	if err := itemRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating return items: %v", err)
+	}
+	itemRows.Close()
+
+	rows, err := db.read.QueryContext(ctx, `
+		SELECT id, order_id, returned_at, returned_by, reason, refund, paid_out FROM returns
+		WHERE order_id = ?
+		ORDER BY returned_at, rowid
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query returns: %v", err)
+	}
+	defer rows.Close()
+
+	returns := []Return{}
+	for rows.Next() {
+		var ret Return
+		var returnedBy sql.NullString
+		if err := rows.Scan(&ret.ID, &ret.OrderID, &ret.ReturnedAt, &returnedBy, &ret.Reason, &ret.Refund, &ret.PaidOut); err != nil {
+			return nil, fmt.Errorf("failed to scan return: %v", err)
+		}
+		ret.ReturnedBy = returnedBy.String
+		ret.Items = itemsByReturn[ret.ID]
+		returns = append(returns, ret)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating returns: %v", err)
+	}
+
+	return returns, nil
+}
+
+// GetSalesSummary totals order sales and the refunds given against them. Sales count by
+// order date and refunds by return date; cancelled orders are excluded, and empty dates
+// leave the range open.
+func (db *Database) GetSalesSummary(ctx context.Context, fromDate string, toDate string) (SalesSummary, error) {
+	var summary SalesSummary
+
+	// Dates are stored with a time part, so include the whole final day
+	dateRange := func(column string) (string, []interface{}) {
+		var where string
+		var args []interface{}
+		if fromDate != "" {
+			where += " AND " + column + " >= ?"
+			args = append(args, fromDate)
+		}
+		if toDate != "" {
+			where += " AND " + column + " < date(?, '+1 day')"
+			args = append(args, toDate)
+		}
+		return where, args
+	}
+
+	where, args := dateRange("date")
+	err := db.read.QueryRowContext(ctx,
+		"SELECT COUNT(*), COALESCE(SUM(total), 0) FROM orders WHERE status != 'Cancelled'"+where, args...,
+	).Scan(&summary.OrderCount, &summary.GrossSales)
+	if err != nil {
+		return summary, fmt.Errorf("failed to query sales: %v", err)
+	}
+
+	where, args = dateRange("r.returned_at")
+	err = db.read.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(r.refund), 0),
+			COALESCE(SUM((SELECT SUM(quantity) FROM return_items WHERE return_id = r.id)), 0)
+		FROM returns r
+		JOIN orders o ON o.id = r.order_id
+		WHERE o.status != 'Cancelled'`+where, args...,
+	).Scan(&summary.ReturnCount, &summary.Refunds, &summary.ReturnedUnits)
+	if err != nil {
+		return summary, fmt.Errorf("failed to query returns: %v", err)
+	}
+
+	summary.NetSales = float64(toCents(summary.GrossSales)-toCents(summary.Refunds)) / 100
+	return summary, nil
+}
+
+// ReturnItems records items that came back from an order, with the refund given
+func (a *App) ReturnItems(orderID string, ret NewReturn) bool {
+	ctx, logger, db, done := a.call("ReturnItems")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error recording return", "err", err)
+		return false
+	}
+
+	id, err := db.CreateReturn(ctx, orderID, ret, a.currentUser().Username)
+	if err != nil {
+		logger.Error("Error recording return", "order", orderID, "err", err)
+		return false
+	}
+	logger.Info("Return recorded", "order", orderID, "return", id, "refund", ret.Refund)
+	return true
+}
+
+// GetReturns returns the returns recorded against an order, oldest first
+func (a *App) GetReturns(orderID string) []Return {
+	ctx, logger, db, done := a.call("GetReturns")
+	defer done()
+
+	returns, err := db.GetReturns(ctx, orderID)
+	if err != nil {
+		logger.Error("Error getting returns", "err", err)
+		return []Return{}
+	}
+	return returns
+}
+
+// GetSalesSummary returns sales, refunds and net sales between two dates (YYYY-MM-DD,
+// inclusive)
+func (a *App) GetSalesSummary(fromDate string, toDate string) SalesSummary {
+	ctx, logger, db, done := a.call("GetSalesSummary")
+	defer done()
+
+	summary, err := db.GetSalesSummary(ctx, fromDate, toDate)
+	if err != nil {
+		logger.Error("Error getting sales summary", "err", err)
+		return SalesSummary{}
+	}
+	return summary
+}
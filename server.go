@@ -0,0 +1,1323 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// maxRequestBodySize limits the size of JSON request bodies accepted by the API
+const maxRequestBodySize = 1 << 20
+
+// NewOrder is the request body used to create an order
+type NewOrder struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Items       []OrderItem `json:"items"`
+}
+
+// OrderStatusUpdate is the request body used to change the status of an order
+type OrderStatusUpdate struct {
+	Status string `json:"status"`
+}
+
+// APIError is the body of every error response
+type APIError struct {
+	Error string `json:"error"`
+}
+
+// apiParam describes a query parameter of an API route
+type apiParam struct {
+	Name        string
+	Type        string
+	Description string
+	Required    bool
+}
+
+// apiRoute describes one endpoint of the HTTP API. The same table registers the
+// handlers and generates the OpenAPI document, so the two cannot drift apart.
+type apiRoute struct {
+	Method   string
+	Path     string
+	Summary  string
+	Tag      string
+	Params   []apiParam
+	Body     interface{} // zero value of the request body type, nil if none
+	Response interface{} // zero value of the response type, nil for 204 No Content
+	Status   int
+	Public   bool   // reachable without a session token
+	Role     string // minimum role required, see requiredRole
+	Handle   func(r *http.Request) (interface{}, error)
+}
+
+// requiredRole returns the minimum role for a route. Routes that do not name one
+// are readable by every user and writable only by admins.
+func (route apiRoute) requiredRole() string {
+	if route.Role != "" {
+		return route.Role
+	}
+	if route.Method == http.MethodGet {
+		return RoleReadOnly
+	}
+	return RoleAdmin
+}
+
+// LoginRequest is the request body used to log in or create the initial admin
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// LoginResponse holds the session token to send as "Authorization: Bearer <token>"
+type LoginResponse struct {
+	Token     string `json:"token"`
+	ExpiresAt string `json:"expiresAt"`
+	User      User   `json:"user"`
+}
+
+// NewUser is the request body used to create an account
+type NewUser struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+// UserUpdate is the request body used to change an account; an empty password keeps the current one
+type UserUpdate struct {
+	Role     string `json:"role"`
+	Password string `json:"password"`
+}
+
+// userContextKey is the request context key holding the authenticated User
+type userContextKey struct{}
+
+// requestUser returns the user that made an authenticated request
+func requestUser(r *http.Request) User {
+	user, _ := r.Context().Value(userContextKey{}).(User)
+	return user
+}
+
+// bearerToken returns the session token from the Authorization header
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) > len("Bearer ") && strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		return strings.TrimSpace(header[len("Bearer "):])
+	}
+	return ""
+}
+
+// APIServer exposes the Database over an HTTP JSON API for clients other than the
+// desktop window, such as the till tablet or the website
+type APIServer struct {
+	db         *Database
+	images     *ImageStore
+	corsOrigin string
+	log        *slog.Logger
+	routes     []apiRoute
+	mux        *http.ServeMux
+}
+
+// NewAPIServer creates the HTTP API over the given database. corsOrigin, when set,
+// is the origin allowed to call the API from a browser ("*" for any). A nil logger
+// logs to the default logger.
+func NewAPIServer(db *Database, images *ImageStore, corsOrigin string, logger *slog.Logger) *APIServer {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	s := &APIServer{db: db, images: images, corsOrigin: corsOrigin, log: logger, mux: http.NewServeMux()}
+	s.routes = s.buildRoutes()
+
+	for _, route := range s.routes {
+		route := route
+		s.mux.HandleFunc(route.Method+" "+route.Path, func(w http.ResponseWriter, r *http.Request) {
+			s.serveRoute(w, r, route)
+		})
+	}
+
+	s.mux.HandleFunc("GET /api/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, buildOpenAPIDocument(s.routes))
+	})
+
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *APIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.corsOrigin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", s.corsOrigin)
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+
+	// Tag every log message of the request with a correlation ID, reusing the
+	// client's ID so both sides of a call can be matched up
+	id := r.Header.Get("X-Request-ID")
+	if !validRequestID(id) {
+		id = newCorrelationID()
+	}
+	w.Header().Set("X-Request-ID", id)
+
+	logger := s.log.With("request_id", id)
+	scope := requestScope{log: logger, db: s.db.with("request_id", id)}
+	r = r.WithContext(context.WithValue(r.Context(), requestScopeKey{}, scope))
+
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	started := time.Now()
+	s.mux.ServeHTTP(recorder, r)
+	logger.Info("API request", "method", r.Method, "path", r.URL.Path, "status", recorder.status,
+		"duration", time.Since(started))
+}
+
+// requestScopeKey is the request context key holding the requestScope
+type requestScopeKey struct{}
+
+// requestScope holds the logger and database handle of one API request, both tagged
+// with the request's correlation ID
+type requestScope struct {
+	log *slog.Logger
+	db  *Database
+}
+
+// logger returns the logger of a request
+func (s *APIServer) logger(r *http.Request) *slog.Logger {
+	if scope, ok := r.Context().Value(requestScopeKey{}).(requestScope); ok {
+		return scope.log
+	}
+	return s.log
+}
+
+// database returns the database handle of a request
+func (s *APIServer) database(r *http.Request) *Database {
+	if scope, ok := r.Context().Value(requestScopeKey{}).(requestScope); ok {
+		return scope.db
+	}
+	return s.db
+}
+
+// validRequestID reports whether a client-supplied X-Request-ID is safe to log and echo
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c == '-' || c == '_' || c == '.' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// statusRecorder remembers the status code written to a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// authenticate resolves the session token of a request and checks the route's role
+func (s *APIServer) authenticate(r *http.Request, route apiRoute) (*http.Request, error) {
+	token := bearerToken(r)
+	if token == "" {
+		return r, ErrNotAuthenticated
+	}
+
+	user, err := s.database(r).GetSessionUser(r.Context(), token)
+	if err != nil {
+		return r, err
+	}
+	if err := requireRole(user, route.requiredRole()); err != nil {
+		return r, err
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), userContextKey{}, user)), nil
+}
+
+// serveRoute runs a route handler and writes its result or error as JSON
+func (s *APIServer) serveRoute(w http.ResponseWriter, r *http.Request, route apiRoute) {
+	var err error
+	if !route.Public {
+		r, err = s.authenticate(r, route)
+	}
+
+	var result interface{}
+	if err == nil {
+		result, err = route.Handle(r)
+	}
+	if err != nil {
+		status := errorStatus(err)
+		if status == http.StatusInternalServerError {
+			s.logger(r).Error("API request failed", "err", err)
+		}
+		if status == http.StatusUnauthorized {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		}
+		writeJSON(w, status, APIError{Error: err.Error()})
+		return
+	}
+
+	if route.Response == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, route.Status, result)
+}
+
+// errorStatus maps an error to the HTTP status code reported to the client
+func errorStatus(err error) int {
+	var notFound *NotFoundError
+	var invalid *ValidationError
+	var forbidden *PermissionError
+	switch {
+	case errors.Is(err, ErrNotAuthenticated), errors.Is(err, ErrInvalidCredentials):
+		return http.StatusUnauthorized
+	case errors.As(err, &forbidden):
+		return http.StatusForbidden
+	case errors.As(err, &notFound):
+		return http.StatusNotFound
+	case errors.As(err, &invalid):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeJSON writes a value as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		slog.Error("Error encoding API response", "err", err)
+	}
+}
+
+// decodeBody decodes a JSON request body into target
+func decodeBody(r *http.Request, target interface{}) error {
+	decoder := json.NewDecoder(io.LimitReader(r.Body, maxRequestBodySize))
+	if err := decoder.Decode(target); err != nil {
+		return invalidf("invalid JSON body: %v", err)
+	}
+	return nil
+}
+
+// queryBool parses an optional boolean query parameter
+func queryBool(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, invalidf("query parameter %s must be true or false", name)
+	}
+	return parsed, nil
+}
+
+// buildRoutes lists every endpoint of the API
+func (s *APIServer) buildRoutes() []apiRoute {
+	return []apiRoute{
+		{
+			Method: "POST", Path: "/api/auth/login", Tag: "Auth", Status: http.StatusOK, Public: true,
+			Summary: "Log in and receive a session token", Body: LoginRequest{}, Response: LoginResponse{},
+			Handle: s.login,
+		},
+		{
+			Method: "POST", Path: "/api/auth/setup", Tag: "Auth", Status: http.StatusCreated, Public: true,
+			Summary: "Create the initial admin account; only allowed while no accounts exist",
+			Body:    LoginRequest{}, Response: LoginResponse{},
+			Handle: s.setup,
+		},
+		{
+			Method: "POST", Path: "/api/auth/logout", Tag: "Auth", Role: RoleReadOnly,
+			Summary: "End the current session",
+			Handle: func(r *http.Request) (interface{}, error) {
+				return nil, s.database(r).DeleteSession(r.Context(), bearerToken(r))
+			},
+		},
+		{
+			Method: "GET", Path: "/api/auth/me", Tag: "Auth", Status: http.StatusOK,
+			Summary: "Get the logged-in user", Response: User{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				return requestUser(r), nil
+			},
+		},
+		{
+			Method: "GET", Path: "/api/users", Tag: "Users", Status: http.StatusOK, Role: RoleAdmin,
+			Summary: "List accounts", Response: []User{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				return s.database(r).GetUsers(r.Context())
+			},
+		},
+		{
+			Method: "POST", Path: "/api/users", Tag: "Users", Status: http.StatusCreated, Role: RoleAdmin,
+			Summary: "Create an account", Body: NewUser{}, Response: User{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				var user NewUser
+				if err := decodeBody(r, &user); err != nil {
+					return nil, err
+				}
+				return s.database(r).AddUser(r.Context(), user.Username, user.Password, user.Role)
+			},
+		},
+		{
+			Method: "PUT", Path: "/api/users/{id}", Tag: "Users", Status: http.StatusOK, Role: RoleAdmin,
+			Summary: "Change the role or password of an account", Body: UserUpdate{}, Response: User{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				var update UserUpdate
+				if err := decodeBody(r, &update); err != nil {
+					return nil, err
+				}
+				if err := s.database(r).UpdateUser(r.Context(), r.PathValue("id"), update.Role, update.Password); err != nil {
+					return nil, err
+				}
+				return s.database(r).GetUser(r.Context(), r.PathValue("id"))
+			},
+		},
+		{
+			Method: "DELETE", Path: "/api/users/{id}", Tag: "Users", Role: RoleAdmin,
+			Summary: "Delete an account",
+			Handle: func(r *http.Request) (interface{}, error) {
+				if requestUser(r).ID == r.PathValue("id") {
+					return nil, invalidf("cannot delete the account you are logged in with")
+				}
+				return nil, s.database(r).DeleteUser(r.Context(), r.PathValue("id"))
+			},
+		},
+		{
+			Method: "GET", Path: "/api/products", Tag: "Products", Status: http.StatusOK,
+			Summary: "List products, optionally filtered by category, tags or search text",
+			Params: []apiParam{
+				{Name: "categoryId", Type: "string", Description: "Only products in this category"},
+				{Name: "includeSubcategories", Type: "boolean", Description: "Include products in subcategories of categoryId"},
+				{Name: "uncategorized", Type: "boolean", Description: "Only products without a category"},
+				{Name: "tag", Type: "string", Description: "Only products with this tag; may be repeated"},
+				{Name: "search", Type: "string", Description: "Match against name and description"},
+			},
+			Response: []Product{},
+			Handle:   s.listProducts,
+		},
+		{
+			Method: "POST", Path: "/api/products", Tag: "Products", Status: http.StatusCreated, Role: RoleStaff,
+			Summary: "Create a product", Body: Product{}, Response: Product{},
+			Handle: s.createProduct,
+		},
+		{
+			Method: "GET", Path: "/api/products/{id}", Tag: "Products", Status: http.StatusOK,
+			Summary: "Get a product", Response: Product{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				return s.database(r).getProduct(r.Context(), r.PathValue("id"))
+			},
+		},
+		{
+			Method: "PUT", Path: "/api/products/{id}", Tag: "Products", Status: http.StatusOK, Role: RoleStaff,
+			Summary: "Update a product", Body: Product{}, Response: Product{},
+			Handle: s.updateProduct,
+		},
+		{
+			Method: "DELETE", Path: "/api/products/{id}", Tag: "Products", Role: RoleAdmin,
+			Summary: "Delete a product",
+			Handle:  s.deleteProduct,
+		},
+		{
+			Method: "GET", Path: "/api/products/{id}/variants", Tag: "Products", Status: http.StatusOK,
+			Summary: "List the variants of a product", Response: []ProductVariant{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				if _, err := s.database(r).getProduct(r.Context(), r.PathValue("id")); err != nil {
+					return nil, err
+				}
+				variants, err := s.database(r).GetProductVariants(r.Context(), r.PathValue("id"))
+				if variants == nil {
+					variants = []ProductVariant{}
+				}
+				return variants, err
+			},
+		},
+		{
+			Method: "POST", Path: "/api/products/{id}/variants", Tag: "Products", Status: http.StatusCreated, Role: RoleStaff,
+			Summary: "Add a variant to a product", Body: ProductVariant{}, Response: ProductVariant{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				var variant ProductVariant
+				if err := decodeBody(r, &variant); err != nil {
+					return nil, err
+				}
+				if _, err := s.database(r).getProduct(r.Context(), r.PathValue("id")); err != nil {
+					return nil, err
+				}
+				variant.ProductID = r.PathValue("id")
+				return s.database(r).AddProductVariant(r.Context(), variant)
+			},
+		},
+		{
+			Method: "GET", Path: "/api/products/{id}/prices", Tag: "Products", Status: http.StatusOK,
+			Summary: "Get the price history of a product", Response: []ProductPrice{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				if _, err := s.database(r).getProduct(r.Context(), r.PathValue("id")); err != nil {
+					return nil, err
+				}
+				return s.database(r).GetPriceHistory(r.Context(), r.PathValue("id"))
+			},
+		},
+		{
+			Method: "POST", Path: "/api/products/{id}/prices", Tag: "Products", Status: http.StatusCreated, Role: RoleStaff,
+			Summary: "Schedule a price change for a product", Body: ProductPrice{}, Response: ProductPrice{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				var entry ProductPrice
+				if err := decodeBody(r, &entry); err != nil {
+					return nil, err
+				}
+				entry.ProductID = r.PathValue("id")
+				return s.database(r).SchedulePriceChange(r.Context(), entry)
+			},
+		},
+		{
+			Method: "GET", Path: "/api/categories", Tag: "Products", Status: http.StatusOK,
+			Summary: "List product categories", Response: []Category{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				return s.database(r).GetCategories(r.Context())
+			},
+		},
+		{
+			Method: "GET", Path: "/api/lookup", Tag: "Products", Status: http.StatusOK,
+			Summary:  "Resolve a barcode, SKU or product ID",
+			Params:   []apiParam{{Name: "code", Type: "string", Description: "Scanned barcode, SKU or product ID", Required: true}},
+			Response: BarcodeLookup{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				code := r.URL.Query().Get("code")
+				if code == "" {
+					return nil, invalidf("query parameter code is required")
+				}
+				return s.database(r).LookupByBarcode(r.Context(), code)
+			},
+		},
+		{
+			Method: "GET", Path: "/api/orders", Tag: "Orders", Status: http.StatusOK,
+			Summary: "List orders with their items", Response: []Order{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				orders, err := s.database(r).GetOrders(r.Context())
+				if orders == nil {
+					orders = []Order{}
+				}
+				return orders, err
+			},
+		},
+		{
+			Method: "POST", Path: "/api/orders", Tag: "Orders", Status: http.StatusCreated, Role: RoleStaff,
+			Summary: "Create an order", Body: NewOrder{}, Response: Order{},
+			Handle: s.createOrder,
+		},
+		{
+			Method: "GET", Path: "/api/orders/outstanding", Tag: "Orders", Status: http.StatusOK,
+			Summary: "List orders with a balance left to pay, oldest first", Response: []Order{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				return s.database(r).GetOutstandingOrders(r.Context())
+			},
+		},
+		{
+			Method: "GET", Path: "/api/orders/{id}", Tag: "Orders", Status: http.StatusOK,
+			Summary: "Get an order", Response: Order{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				return s.database(r).GetOrder(r.Context(), r.PathValue("id"))
+			},
+		},
+		{
+			Method: "PUT", Path: "/api/orders/{id}/status", Tag: "Orders", Status: http.StatusOK, Role: RoleStaff,
+			Summary: "Change the status of an order", Body: OrderStatusUpdate{}, Response: Order{},
+			Handle: s.updateOrderStatus,
+		},
+		{
+			Method: "PUT", Path: "/api/orders/{id}", Tag: "Orders", Status: http.StatusOK, Role: RoleStaff,
+			Summary: "Edit a pending or processing order", Body: NewOrder{}, Response: Order{},
+			Handle: s.updateOrder,
+		},
+		{
+			Method: "POST", Path: "/api/orders/{id}/shipments", Tag: "Orders", Status: http.StatusCreated, Role: RoleStaff,
+			Summary: "Ship some of an order's items", Body: NewShipment{}, Response: Order{},
+			Handle: s.createShipment,
+		},
+		{
+			Method: "GET", Path: "/api/orders/{id}/shipments", Tag: "Orders", Status: http.StatusOK,
+			Summary: "List the shipments of an order", Response: []Shipment{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				return s.database(r).GetShipments(r.Context(), r.PathValue("id"))
+			},
+		},
+		{
+			Method: "POST", Path: "/api/orders/{id}/split", Tag: "Orders", Status: http.StatusCreated, Role: RoleStaff,
+			Summary: "Move unshipped items to a new order", Body: OrderSplit{}, Response: Order{},
+			Handle: s.splitOrder,
+		},
+		{
+			Method: "POST", Path: "/api/orders/{id}/returns", Tag: "Orders", Status: http.StatusCreated, Role: RoleStaff,
+			Summary: "Record items returned from an order and the refund given", Body: NewReturn{}, Response: Order{},
+			Handle: s.createReturn,
+		},
+		{
+			Method: "GET", Path: "/api/orders/{id}/returns", Tag: "Orders", Status: http.StatusOK,
+			Summary: "List the returns of an order", Response: []Return{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				return s.database(r).GetReturns(r.Context(), r.PathValue("id"))
+			},
+		},
+		{
+			Method: "POST", Path: "/api/orders/{id}/payments", Tag: "Orders", Status: http.StatusCreated, Role: RoleStaff,
+			Summary: "Record a payment or deposit for an order", Body: NewPayment{}, Response: Order{},
+			Handle: s.addPayment,
+		},
+		{
+			Method: "GET", Path: "/api/orders/{id}/payments", Tag: "Orders", Status: http.StatusOK,
+			Summary: "List the payments of an order", Response: []Payment{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				return s.database(r).GetPayments(r.Context(), r.PathValue("id"))
+			},
+		},
+		{
+			Method: "DELETE", Path: "/api/payments/{id}", Tag: "Orders", Role: RoleAdmin,
+			Summary: "Delete a payment recorded by mistake",
+			Handle: func(r *http.Request) (interface{}, error) {
+				return nil, s.database(r).DeletePayment(r.Context(), r.PathValue("id"), requestUser(r).Username)
+			},
+		},
+		{
+			Method: "GET", Path: "/api/orders/{id}/history", Tag: "Orders", Status: http.StatusOK,
+			Summary: "List the changes made to an order", Response: []OrderChange{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				return s.database(r).GetOrderHistory(r.Context(), r.PathValue("id"))
+			},
+		},
+		{
+			Method: "DELETE", Path: "/api/orders/{id}", Tag: "Orders", Role: RoleAdmin,
+			Summary: "Delete an order",
+			Handle: func(r *http.Request) (interface{}, error) {
+				return nil, s.database(r).DeleteOrder(r.Context(), r.PathValue("id"))
+			},
+		},
+		{
+			Method: "GET", Path: "/api/order-templates", Tag: "Orders", Status: http.StatusOK,
+			Summary: "List saved and recurring order templates", Response: []OrderTemplate{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				return s.database(r).GetOrderTemplates(r.Context())
+			},
+		},
+		{
+			Method: "POST", Path: "/api/order-templates", Tag: "Orders", Status: http.StatusCreated, Role: RoleStaff,
+			Summary: "Save an order template, optionally with a weekly or monthly schedule", Body: OrderTemplate{}, Response: OrderTemplate{},
+			Handle: s.saveOrderTemplate,
+		},
+		{
+			Method: "GET", Path: "/api/order-templates/preview", Tag: "Orders", Status: http.StatusOK,
+			Summary:  "List the orders schedules will generate over the coming days",
+			Params:   []apiParam{{Name: "days", Type: "integer", Description: "How many days ahead to look (default 30)"}},
+			Response: []ScheduledOrder{},
+			Handle:   s.previewScheduledOrders,
+		},
+		{
+			Method: "PUT", Path: "/api/order-templates/{id}", Tag: "Orders", Status: http.StatusOK, Role: RoleStaff,
+			Summary: "Replace an order template and its schedule", Body: OrderTemplate{}, Response: OrderTemplate{},
+			Handle: s.saveOrderTemplate,
+		},
+		{
+			Method: "DELETE", Path: "/api/order-templates/{id}", Tag: "Orders", Role: RoleStaff,
+			Summary: "Delete an order template and its schedule",
+			Handle: func(r *http.Request) (interface{}, error) {
+				return nil, s.database(r).DeleteOrderTemplate(r.Context(), r.PathValue("id"))
+			},
+		},
+		{
+			Method: "POST", Path: "/api/order-templates/{id}/orders", Tag: "Orders", Status: http.StatusCreated, Role: RoleStaff,
+			Summary: "Create a pending order from a template now", Response: Order{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				id, err := s.database(r).CreateOrderFromTemplate(r.Context(), r.PathValue("id"), requestUser(r).Username)
+				if err != nil {
+					return nil, err
+				}
+				return s.database(r).GetOrder(r.Context(), id)
+			},
+		},
+		{
+			Method: "GET", Path: "/api/quotes", Tag: "Quotes", Status: http.StatusOK,
+			Summary: "List quotes, newest first", Response: []Quote{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				return s.database(r).GetQuotes(r.Context())
+			},
+		},
+		{
+			Method: "POST", Path: "/api/quotes", Tag: "Quotes", Status: http.StatusCreated, Role: RoleStaff,
+			Summary: "Create a draft quote", Body: NewQuote{}, Response: Quote{},
+			Handle: s.createQuote,
+		},
+		{
+			Method: "GET", Path: "/api/quotes/{id}", Tag: "Quotes", Status: http.StatusOK,
+			Summary: "Get a quote", Response: Quote{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				return s.database(r).GetQuote(r.Context(), r.PathValue("id"))
+			},
+		},
+		{
+			Method: "PUT", Path: "/api/quotes/{id}", Tag: "Quotes", Status: http.StatusOK, Role: RoleStaff,
+			Summary: "Edit a quote that has not been accepted", Body: NewQuote{}, Response: Quote{},
+			Handle: s.updateQuote,
+		},
+		{
+			Method: "PUT", Path: "/api/quotes/{id}/status", Tag: "Quotes", Status: http.StatusOK, Role: RoleStaff,
+			Summary: "Mark a quote as Draft, Sent or Expired", Body: QuoteStatusUpdate{}, Response: Quote{},
+			Handle: s.updateQuoteStatus,
+		},
+		{
+			Method: "POST", Path: "/api/quotes/{id}/order", Tag: "Quotes", Status: http.StatusCreated, Role: RoleStaff,
+			Summary: "Convert a quote into a pending order at the quoted prices", Response: Order{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				orderID, err := s.database(r).ConvertQuoteToOrder(r.Context(), r.PathValue("id"), requestUser(r).Username)
+				if err != nil {
+					return nil, err
+				}
+				return s.database(r).GetOrder(r.Context(), orderID)
+			},
+		},
+		{
+			Method: "DELETE", Path: "/api/quotes/{id}", Tag: "Quotes", Role: RoleStaff,
+			Summary: "Delete a quote that has not been accepted",
+			Handle: func(r *http.Request) (interface{}, error) {
+				return nil, s.database(r).DeleteQuote(r.Context(), r.PathValue("id"))
+			},
+		},
+		{
+			Method: "GET", Path: "/api/stock", Tag: "Stock", Status: http.StatusOK,
+			Summary: "List stock items", Response: []StockItem{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				items, err := s.database(r).GetStockItems(r.Context())
+				if items == nil {
+					items = []StockItem{}
+				}
+				return items, err
+			},
+		},
+		{
+			Method: "POST", Path: "/api/stock", Tag: "Stock", Status: http.StatusCreated, Role: RoleStaff,
+			Summary: "Create a stock item", Body: StockItem{}, Response: StockItem{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				var item StockItem
+				if err := decodeBody(r, &item); err != nil {
+					return nil, err
+				}
+				return s.database(r).AddStockItem(r.Context(), item)
+			},
+		},
+		{
+			Method: "PUT", Path: "/api/stock/{id}", Tag: "Stock", Status: http.StatusOK, Role: RoleStaff,
+			Summary: "Update a stock item", Body: StockItem{}, Response: StockItem{},
+			Handle: s.updateStockItem,
+		},
+		{
+			Method: "DELETE", Path: "/api/stock/{id}", Tag: "Stock", Role: RoleAdmin,
+			Summary: "Delete a stock item with no stock history besides its opening stock",
+			Handle: func(r *http.Request) (interface{}, error) {
+				deleted, err := s.database(r).DeleteStockItem(r.Context(), r.PathValue("id"))
+				if err == nil && !deleted {
+					err = &NotFoundError{Kind: "stock item", ID: r.PathValue("id")}
+				}
+				return nil, err
+			},
+		},
+		{
+			Method: "POST", Path: "/api/stock/{id}/adjustments", Tag: "Stock", Status: http.StatusOK, Role: RoleStaff,
+			Summary: "Add stock to, or remove it from, one location", Body: StockAdjustment{}, Response: StockItem{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				var adjustment StockAdjustment
+				if err := decodeBody(r, &adjustment); err != nil {
+					return nil, err
+				}
+				return s.database(r).AdjustStockQuantity(r.Context(), r.PathValue("id"), adjustment, requestUser(r).Username)
+			},
+		},
+		{
+			Method: "POST", Path: "/api/stock/transfers", Tag: "Stock", Status: http.StatusOK, Role: RoleStaff,
+			Summary: "Move stock between two locations", Body: StockTransfer{}, Response: StockItem{},
+			Handle: s.transferStock,
+		},
+		{
+			Method: "GET", Path: "/api/stock/movements", Tag: "Stock", Status: http.StatusOK,
+			Summary:  "List stock movements, newest first",
+			Params:   []apiParam{{Name: "item", Type: "string", Description: "Only movements of this stock item"}},
+			Response: []StockMovement{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				return s.database(r).GetStockMovements(r.Context(), r.URL.Query().Get("item"), stockMovementLimit)
+			},
+		},
+		{
+			Method: "POST", Path: "/api/stock/{id}/receipts", Tag: "Stock", Status: http.StatusOK, Role: RoleStaff,
+			Summary: "Receive a lot of a stock item with its expiry date", Body: StockReceipt{}, Response: StockItem{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				var receipt StockReceipt
+				if err := decodeBody(r, &receipt); err != nil {
+					return nil, err
+				}
+				return s.database(r).ReceiveStock(r.Context(), r.PathValue("id"), receipt, requestUser(r).Username)
+			},
+		},
+		{
+			Method: "GET", Path: "/api/stock/{id}/lots", Tag: "Stock", Status: http.StatusOK,
+			Summary: "List the lots of a stock item, expiring first first", Response: []Lot{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				return s.database(r).GetLots(r.Context(), r.PathValue("id"))
+			},
+		},
+		{
+			Method: "GET", Path: "/api/lots/expiring", Tag: "Stock", Status: http.StatusOK,
+			Summary:  "List lots with stock left that have expired or expire soon",
+			Params:   []apiParam{{Name: "days", Type: "integer", Description: "How many days ahead to look (default 30)"}},
+			Response: []Lot{},
+			Handle:   s.expiringLots,
+		},
+		{
+			Method: "GET", Path: "/api/lots/{id}/shipments", Tag: "Stock", Status: http.StatusOK,
+			Summary: "List the order lines a lot was shipped with", Response: []LotShipment{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				return s.database(r).GetLotShipments(r.Context(), r.PathValue("id"))
+			},
+		},
+		{
+			Method: "GET", Path: "/api/stock-counts", Tag: "Stock", Status: http.StatusOK,
+			Summary: "List stock counts, newest first", Response: []StockCount{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				return s.database(r).GetStockCounts(r.Context())
+			},
+		},
+		{
+			Method: "POST", Path: "/api/stock-counts", Tag: "Stock", Status: http.StatusCreated, Role: RoleStaff,
+			Summary: "Start a stock count, keeping the quantities held now as expected", Body: NewStockCount{}, Response: StockCount{},
+			Handle: s.createStockCount,
+		},
+		{
+			Method: "GET", Path: "/api/stock-counts/{id}", Tag: "Stock", Status: http.StatusOK,
+			Summary: "Get a stock count with its variances", Response: StockCount{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				return s.database(r).GetStockCount(r.Context(), r.PathValue("id"))
+			},
+		},
+		{
+			Method: "PUT", Path: "/api/stock-counts/{id}/lines", Tag: "Stock", Status: http.StatusOK, Role: RoleStaff,
+			Summary: "Record counted quantities on an open stock count", Body: []StockCountEntry{}, Response: StockCount{},
+			Handle: s.recordStockCounts,
+		},
+		{
+			Method: "POST", Path: "/api/stock-counts/{id}/post", Tag: "Stock", Status: http.StatusOK, Role: RoleAdmin,
+			Summary: "Apply the variances of a stock count to stock", Response: StockCount{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				id := r.PathValue("id")
+				if err := s.database(r).PostStockCount(r.Context(), id, requestUser(r).Username); err != nil {
+					return nil, err
+				}
+				return s.database(r).GetStockCount(r.Context(), id)
+			},
+		},
+		{
+			Method: "DELETE", Path: "/api/stock-counts/{id}", Tag: "Stock", Role: RoleStaff,
+			Summary: "Abandon an open stock count",
+			Handle: func(r *http.Request) (interface{}, error) {
+				return nil, s.database(r).DeleteStockCount(r.Context(), r.PathValue("id"))
+			},
+		},
+		{
+			Method: "GET", Path: "/api/locations", Tag: "Stock", Status: http.StatusOK,
+			Summary: "List stock locations", Response: []Location{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				return s.database(r).GetLocations(r.Context())
+			},
+		},
+		{
+			Method: "POST", Path: "/api/locations", Tag: "Stock", Status: http.StatusCreated, Role: RoleStaff,
+			Summary: "Create a stock location", Body: Location{}, Response: Location{},
+			Handle: s.saveLocation,
+		},
+		{
+			Method: "PUT", Path: "/api/locations/{id}", Tag: "Stock", Status: http.StatusOK, Role: RoleStaff,
+			Summary: "Rename a stock location", Body: Location{}, Response: Location{},
+			Handle: s.saveLocation,
+		},
+		{
+			Method: "DELETE", Path: "/api/locations/{id}", Tag: "Stock", Role: RoleAdmin,
+			Summary: "Delete a stock location that holds no stock",
+			Handle: func(r *http.Request) (interface{}, error) {
+				return nil, s.database(r).DeleteLocation(r.Context(), r.PathValue("id"))
+			},
+		},
+		{
+			Method: "GET", Path: "/api/analytics/category-sales", Tag: "Analytics", Status: http.StatusOK,
+			Summary: "Sales totals per product category",
+			Params: []apiParam{
+				{Name: "from", Type: "string", Description: "First day to include (YYYY-MM-DD)"},
+				{Name: "to", Type: "string", Description: "Last day to include (YYYY-MM-DD)"},
+			},
+			Response: []CategorySales{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				return s.database(r).GetCategorySales(r.Context(), r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+			},
+		},
+		{
+			Method: "GET", Path: "/api/analytics/sales", Tag: "Analytics", Status: http.StatusOK,
+			Summary: "Gross sales, refunds and net sales",
+			Params: []apiParam{
+				{Name: "from", Type: "string", Description: "First day to include (YYYY-MM-DD)"},
+				{Name: "to", Type: "string", Description: "Last day to include (YYYY-MM-DD)"},
+			},
+			Response: SalesSummary{},
+			Handle: func(r *http.Request) (interface{}, error) {
+				return s.database(r).GetSalesSummary(r.Context(), r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+			},
+		},
+	}
+}
+
+// login handles POST /api/auth/login
+func (s *APIServer) login(r *http.Request) (interface{}, error) {
+	var credentials LoginRequest
+	if err := decodeBody(r, &credentials); err != nil {
+		return nil, err
+	}
+
+	user, err := s.database(r).Authenticate(r.Context(), credentials.Username, credentials.Password)
+	if err != nil {
+		s.logger(r).Warn("API login failed", "username", credentials.Username, "remote", r.RemoteAddr)
+		return nil, err
+	}
+	return s.startSession(r, user)
+}
+
+// setup handles POST /api/auth/setup
+func (s *APIServer) setup(r *http.Request) (interface{}, error) {
+	var credentials LoginRequest
+	if err := decodeBody(r, &credentials); err != nil {
+		return nil, err
+	}
+
+	user, err := s.database(r).AddFirstAdmin(r.Context(), credentials.Username, credentials.Password)
+	if err != nil {
+		return nil, err
+	}
+	s.logger(r).Info("Initial admin created", "username", user.Username, "remote", r.RemoteAddr)
+	return s.startSession(r, user)
+}
+
+// startSession creates a session token for a user who has just logged in
+func (s *APIServer) startSession(r *http.Request, user User) (LoginResponse, error) {
+	token, expiresAt, err := s.database(r).CreateSession(r.Context(), user.ID)
+	if err != nil {
+		return LoginResponse{}, err
+	}
+	return LoginResponse{Token: token, ExpiresAt: expiresAt, User: user}, nil
+}
+
+// listProducts handles GET /api/products
+func (s *APIServer) listProducts(r *http.Request) (interface{}, error) {
+	query := ProductQuery{
+		CategoryID: r.URL.Query().Get("categoryId"),
+		Tags:       r.URL.Query()["tag"],
+		Search:     r.URL.Query().Get("search"),
+	}
+
+	var err error
+	if query.IncludeSubcategories, err = queryBool(r, "includeSubcategories"); err != nil {
+		return nil, err
+	}
+	if query.Uncategorized, err = queryBool(r, "uncategorized"); err != nil {
+		return nil, err
+	}
+
+	products, err := s.database(r).QueryProducts(r.Context(), query)
+	if products == nil {
+		products = []Product{}
+	}
+	return products, err
+}
+
+// createProduct handles POST /api/products
+func (s *APIServer) createProduct(r *http.Request) (interface{}, error) {
+	var product Product
+	if err := decodeBody(r, &product); err != nil {
+		return nil, err
+	}
+	if product.Name == "" {
+		return nil, invalidf("product name is required")
+	}
+	if product.Status == "" {
+		product.Status = "In Stock"
+	}
+
+	id, err := s.database(r).AddProduct(r.Context(), product)
+	if err != nil {
+		return nil, err
+	}
+	return s.database(r).getProduct(r.Context(), id)
+}
+
+// updateProduct handles PUT /api/products/{id}
+func (s *APIServer) updateProduct(r *http.Request) (interface{}, error) {
+	var product Product
+	if err := decodeBody(r, &product); err != nil {
+		return nil, err
+	}
+	product.ID = r.PathValue("id")
+
+	if err := s.database(r).UpdateProduct(r.Context(), product); err != nil {
+		return nil, err
+	}
+	return s.database(r).getProduct(r.Context(), product.ID)
+}
+
+// deleteProduct handles DELETE /api/products/{id}
+func (s *APIServer) deleteProduct(r *http.Request) (interface{}, error) {
+	id := r.PathValue("id")
+	image, err := s.database(r).GetProductImage(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.database(r).DeleteProduct(r.Context(), id); err != nil {
+		return nil, err
+	}
+
+	if image != "" && s.images != nil {
+		if err := releaseImage(r.Context(), s.database(r), s.images, image); err != nil {
+			s.logger(r).Error("Error removing orphaned image", "image", image, "err", err)
+		}
+	}
+	return nil, nil
+}
+
+// createOrder handles POST /api/orders
+func (s *APIServer) createOrder(r *http.Request) (interface{}, error) {
+	var order NewOrder
+	if err := decodeBody(r, &order); err != nil {
+		return nil, err
+	}
+	if err := validateNewOrder(order.Name, order.Items); err != nil {
+		return nil, err
+	}
+
+	id, err := s.database(r).CreateOrder(r.Context(), order.Name, order.Description, order.Items)
+	if err != nil {
+		return nil, err
+	}
+	return s.database(r).GetOrder(r.Context(), id)
+}
+
+// updateOrderStatus handles PUT /api/orders/{id}/status
+func (s *APIServer) updateOrderStatus(r *http.Request) (interface{}, error) {
+	var update OrderStatusUpdate
+	if err := decodeBody(r, &update); err != nil {
+		return nil, err
+	}
+	if update.Status == "" {
+		return nil, invalidf("status is required")
+	}
+
+	if err := s.database(r).UpdateOrderStatus(r.Context(), r.PathValue("id"), update.Status); err != nil {
+		return nil, err
+	}
+	return s.database(r).GetOrder(r.Context(), r.PathValue("id"))
+}
+
+// updateOrder handles PUT /api/orders/{id}
+func (s *APIServer) updateOrder(r *http.Request) (interface{}, error) {
+	var order NewOrder
+	if err := decodeBody(r, &order); err != nil {
+		return nil, err
+	}
+
+	id := r.PathValue("id")
+	if err := s.database(r).UpdateOrder(r.Context(), id, order, requestUser(r).Username); err != nil {
+		return nil, err
+	}
+	return s.database(r).GetOrder(r.Context(), id)
+}
+
+// createShipment handles POST /api/orders/{id}/shipments and returns the updated order
+func (s *APIServer) createShipment(r *http.Request) (interface{}, error) {
+	var shipment NewShipment
+	if err := decodeBody(r, &shipment); err != nil {
+		return nil, err
+	}
+
+	id := r.PathValue("id")
+	if _, err := s.database(r).CreateShipment(r.Context(), id, shipment, requestUser(r).Username); err != nil {
+		return nil, err
+	}
+	return s.database(r).GetOrder(r.Context(), id)
+}
+
+// splitOrder handles POST /api/orders/{id}/split and returns the new order
+func (s *APIServer) splitOrder(r *http.Request) (interface{}, error) {
+	var split OrderSplit
+	if err := decodeBody(r, &split); err != nil {
+		return nil, err
+	}
+
+	newID, err := s.database(r).SplitOrder(r.Context(), r.PathValue("id"), split, requestUser(r).Username)
+	if err != nil {
+		return nil, err
+	}
+	return s.database(r).GetOrder(r.Context(), newID)
+}
+
+// createReturn handles POST /api/orders/{id}/returns and returns the updated order
+func (s *APIServer) createReturn(r *http.Request) (interface{}, error) {
+	var ret NewReturn
+	if err := decodeBody(r, &ret); err != nil {
+		return nil, err
+	}
+
+	id := r.PathValue("id")
+	if _, err := s.database(r).CreateReturn(r.Context(), id, ret, requestUser(r).Username); err != nil {
+		return nil, err
+	}
+	return s.database(r).GetOrder(r.Context(), id)
+}
+
+// addPayment handles POST /api/orders/{id}/payments and returns the updated order
+func (s *APIServer) addPayment(r *http.Request) (interface{}, error) {
+	var payment NewPayment
+	if err := decodeBody(r, &payment); err != nil {
+		return nil, err
+	}
+
+	id := r.PathValue("id")
+	if _, err := s.database(r).AddPayment(r.Context(), id, payment, requestUser(r).Username); err != nil {
+		return nil, err
+	}
+	return s.database(r).GetOrder(r.Context(), id)
+}
+
+// saveOrderTemplate handles POST /api/order-templates and PUT /api/order-templates/{id}
+func (s *APIServer) saveOrderTemplate(r *http.Request) (interface{}, error) {
+	var template OrderTemplate
+	if err := decodeBody(r, &template); err != nil {
+		return nil, err
+	}
+	template.ID = r.PathValue("id")
+
+	id, err := s.database(r).SaveOrderTemplate(r.Context(), template, requestUser(r).Username)
+	if err != nil {
+		return nil, err
+	}
+	return s.database(r).GetOrderTemplate(r.Context(), id)
+}
+
+// previewScheduledOrders handles GET /api/order-templates/preview
+func (s *APIServer) previewScheduledOrders(r *http.Request) (interface{}, error) {
+	days := 30
+	if value := r.URL.Query().Get("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, invalidf("invalid days %q", value)
+		}
+		days = parsed
+	}
+	return s.database(r).PreviewScheduledOrders(r.Context(), time.Now(), days)
+}
+
+// createQuote handles POST /api/quotes
+func (s *APIServer) createQuote(r *http.Request) (interface{}, error) {
+	var quote NewQuote
+	if err := decodeBody(r, &quote); err != nil {
+		return nil, err
+	}
+
+	id, err := s.database(r).CreateQuote(r.Context(), quote, requestUser(r).Username)
+	if err != nil {
+		return nil, err
+	}
+	return s.database(r).GetQuote(r.Context(), id)
+}
+
+// updateQuote handles PUT /api/quotes/{id}
+func (s *APIServer) updateQuote(r *http.Request) (interface{}, error) {
+	var quote NewQuote
+	if err := decodeBody(r, &quote); err != nil {
+		return nil, err
+	}
+
+	id := r.PathValue("id")
+	if err := s.database(r).UpdateQuote(r.Context(), id, quote); err != nil {
+		return nil, err
+	}
+	return s.database(r).GetQuote(r.Context(), id)
+}
+
+// updateQuoteStatus handles PUT /api/quotes/{id}/status
+func (s *APIServer) updateQuoteStatus(r *http.Request) (interface{}, error) {
+	var update QuoteStatusUpdate
+	if err := decodeBody(r, &update); err != nil {
+		return nil, err
+	}
+
+	id := r.PathValue("id")
+	if err := s.database(r).UpdateQuoteStatus(r.Context(), id, update.Status); err != nil {
+		return nil, err
+	}
+	return s.database(r).GetQuote(r.Context(), id)
+}
+
+// updateStockItem handles PUT /api/stock/{id}
+func (s *APIServer) updateStockItem(r *http.Request) (interface{}, error) {
+	var item StockItem
+	if err := decodeBody(r, &item); err != nil {
+		return nil, err
+	}
+	item.ID = r.PathValue("id")
+
+	updated, err := s.database(r).UpdateStockItem(r.Context(), item)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, &NotFoundError{Kind: "stock item", ID: item.ID}
+	}
+	return item, nil
+}
+
+// transferStock handles POST /api/stock/transfers
+func (s *APIServer) transferStock(r *http.Request) (interface{}, error) {
+	var transfer StockTransfer
+	if err := decodeBody(r, &transfer); err != nil {
+		return nil, err
+	}
+
+	if err := s.database(r).TransferStock(r.Context(), transfer, requestUser(r).Username); err != nil {
+		return nil, err
+	}
+	return s.database(r).GetStockItem(r.Context(), transfer.StockItemID)
+}
+
+// expiringLots handles GET /api/lots/expiring
+func (s *APIServer) expiringLots(r *http.Request) (interface{}, error) {
+	days := expiringLotDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, invalidf("invalid days %q", value)
+		}
+		days = parsed
+	}
+	return s.database(r).GetExpiringLots(r.Context(), days)
+}
+
+// createStockCount handles POST /api/stock-counts
+func (s *APIServer) createStockCount(r *http.Request) (interface{}, error) {
+	var count NewStockCount
+	if err := decodeBody(r, &count); err != nil {
+		return nil, err
+	}
+
+	id, err := s.database(r).CreateStockCount(r.Context(), count, requestUser(r).Username)
+	if err != nil {
+		return nil, err
+	}
+	return s.database(r).GetStockCount(r.Context(), id)
+}
+
+// recordStockCounts handles PUT /api/stock-counts/{id}/lines
+func (s *APIServer) recordStockCounts(r *http.Request) (interface{}, error) {
+	var entries []StockCountEntry
+	if err := decodeBody(r, &entries); err != nil {
+		return nil, err
+	}
+
+	id := r.PathValue("id")
+	if err := s.database(r).RecordStockCounts(r.Context(), id, entries, requestUser(r).Username); err != nil {
+		return nil, err
+	}
+	return s.database(r).GetStockCount(r.Context(), id)
+}
+
+// saveLocation handles POST /api/locations and PUT /api/locations/{id}
+func (s *APIServer) saveLocation(r *http.Request) (interface{}, error) {
+	var location Location
+	if err := decodeBody(r, &location); err != nil {
+		return nil, err
+	}
+	location.ID = r.PathValue("id")
+	return s.database(r).SaveLocation(r.Context(), location)
+}
+
+// runServe starts the headless HTTP API and blocks until interrupted.
+// It returns the process exit code.
+func runServe(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := flags.String("addr", "127.0.0.1:8080", "address to listen on")
+	dbPath := flags.String("db", "", "path to the SQLite database (defaults to the desktop app's database)")
+	corsOrigin := flags.String("cors-origin", "", "origin allowed to call the API from a browser, or * for any")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	// Server logs go to the console rather than the desktop log file
+	config, configErr := loadLogConfig(getLogConfigPath())
+	logging := NewLogging(os.Stderr, config)
+	logger := logging.Logger("api")
+	slog.SetDefault(logging.Logger("app"))
+	if configErr != nil {
+		logger.Warn("Invalid logging configuration, using defaults", "err", configErr)
+	}
+
+	var db *Database
+	var err error
+	if *dbPath != "" {
+		db, err = OpenDatabase(*dbPath, logging.Logger("db"))
+	} else {
+		db, err = NewDatabase(logging.Logger("db"))
+	}
+	if err != nil {
+		logger.Error("Failed to initialize database", "err", err)
+		return exitFailure
+	}
+	defer db.Close()
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           NewAPIServer(db, NewImageStore(filepath.Join(getAppDataDir(), "images")), *corsOrigin, logger),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	errs := make(chan error, 1)
+	go func() {
+		logger.Info("Serving API", "url", "http://"+*addr+"/api", "openapi", "/api/openapi.json")
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		logger.Error("API server stopped", "err", err)
+		return exitFailure
+	case <-stop:
+	}
+
+	logger.Info("Shutting down API server")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Error("Error shutting down API server", "err", err)
+		return exitFailure
+	}
+	return exitOK
+}
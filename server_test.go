@@ -0,0 +1,606 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testAPI is an API server over a fresh database, with a session token to call it with
+type testAPI struct {
+	server *httptest.Server
+	db     *Database
+	token  string
+}
+
+// newTestAPI starts the API over a fresh database in a temporary directory and
+// logs in as the initial admin
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+
+	db := openTestDatabase(t)
+	server := httptest.NewServer(NewAPIServer(db, NewImageStore(filepath.Join(t.TempDir(), "images")), "*", nil))
+	t.Cleanup(server.Close)
+
+	api := &testAPI{server: server, db: db}
+	var session LoginResponse
+	if status := api.do(t, "POST", "/api/auth/setup", LoginRequest{Username: "admin", Password: "admin-password"}, &session); status != http.StatusCreated {
+		t.Fatalf("setup: got status %d", status)
+	}
+	api.token = session.Token
+	return api
+}
+
+// loginAs creates an account with the given role and returns a client logged in with it
+func (api *testAPI) loginAs(t *testing.T, role string) *testAPI {
+	t.Helper()
+
+	if _, err := api.db.AddUser(context.Background(), role+"-user", "password-"+role, role); err != nil {
+		t.Fatalf("failed to add %s user: %v", role, err)
+	}
+
+	var session LoginResponse
+	if status := api.do(t, "POST", "/api/auth/login", LoginRequest{Username: role + "-user", Password: "password-" + role}, &session); status != http.StatusOK {
+		t.Fatalf("login as %s: got status %d", role, status)
+	}
+	return &testAPI{server: api.server, db: api.db, token: session.Token}
+}
+
+// do sends a request with an optional JSON body and decodes the JSON response into out
+func (api *testAPI) do(t *testing.T, method, path string, body interface{}, out interface{}) int {
+	t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("failed to encode request: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, api.server.URL+path, reader)
+	if err != nil {
+		t.Fatalf("failed to build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if api.token != "" {
+		req.Header.Set("Authorization", "Bearer "+api.token)
+	}
+
+	resp, err := api.server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("failed to decode %s %s response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestProductLifecycle(t *testing.T) {
+	api := newTestAPI(t)
+
+	var products []Product
+	if status := api.do(t, "GET", "/api/products", nil, &products); status != http.StatusOK {
+		t.Fatalf("list products: got status %d", status)
+	}
+	seeded := len(products)
+
+	var created Product
+	status := api.do(t, "POST", "/api/products", Product{
+		Name: "Olive tree", Price: 120, Description: "Large", Tags: []string{"Outdoor"},
+	}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("create product: got status %d", status)
+	}
+	if created.ID == "" || created.Name != "Olive tree" || created.Status != "In Stock" {
+		t.Fatalf("unexpected created product: %+v", created)
+	}
+
+	created.Price = 99
+	var updated Product
+	if status := api.do(t, "PUT", "/api/products/"+created.ID, created, &updated); status != http.StatusOK {
+		t.Fatalf("update product: got status %d", status)
+	}
+	if updated.Price != 99 {
+		t.Fatalf("expected updated price 99, got %v", updated.Price)
+	}
+
+	var history []ProductPrice
+	if status := api.do(t, "GET", "/api/products/"+created.ID+"/prices", nil, &history); status != http.StatusOK {
+		t.Fatalf("price history: got status %d", status)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 price history entries, got %d", len(history))
+	}
+
+	var filtered []Product
+	api.do(t, "GET", "/api/products?tag=outdoor", nil, &filtered)
+	if len(filtered) != 1 {
+		t.Fatalf("expected tag filter to match 1 product, got %d", len(filtered))
+	}
+
+	api.do(t, "GET", "/api/products", nil, &products)
+	if len(products) != seeded+1 {
+		t.Fatalf("expected %d products, got %d", seeded+1, len(products))
+	}
+
+	if status := api.do(t, "DELETE", "/api/products/"+created.ID, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("delete product: got status %d", status)
+	}
+
+	var apiErr APIError
+	if status := api.do(t, "GET", "/api/products/"+created.ID, nil, &apiErr); status != http.StatusNotFound {
+		t.Fatalf("get deleted product: got status %d", status)
+	}
+	if apiErr.Error == "" {
+		t.Fatal("expected an error message for a missing product")
+	}
+}
+
+func TestOrderLifecycle(t *testing.T) {
+	api := newTestAPI(t)
+
+	var product Product
+	api.do(t, "POST", "/api/products", Product{Name: "Fern", Price: 25}, &product)
+
+	var order Order
+	status := api.do(t, "POST", "/api/orders", NewOrder{
+		Name:  "Dana",
+		Items: []OrderItem{{ProductID: product.ID, ProductName: product.Name, Quantity: 2, Price: 25}},
+	}, &order)
+	if status != http.StatusCreated {
+		t.Fatalf("create order: got status %d", status)
+	}
+	if order.ID == "" || len(order.Items) != 1 || order.Total != 50 {
+		t.Fatalf("unexpected created order: %+v", order)
+	}
+
+	var edited Order
+	status = api.do(t, "PUT", "/api/orders/"+order.ID, NewOrder{
+		Name:  "Dana",
+		Items: []OrderItem{{ProductID: product.ID, ProductName: product.Name, Quantity: 3, Price: 25}},
+	}, &edited)
+	if status != http.StatusOK || edited.Total != 75 {
+		t.Fatalf("edit order: got status %d, order %+v", status, edited)
+	}
+	var history []OrderChange
+	if api.do(t, "GET", "/api/orders/"+order.ID+"/history", nil, &history); len(history) != 1 {
+		t.Fatalf("unexpected order history %+v", history)
+	}
+
+	var shipped Order
+	status = api.do(t, "POST", "/api/orders/"+order.ID+"/shipments", NewShipment{
+		Items: []ShipmentLine{{OrderItemID: edited.Items[0].ID, Quantity: 1}},
+	}, &shipped)
+	if status != http.StatusCreated || shipped.Status != "Partially Shipped" || shipped.Items[0].Remaining != 2 {
+		t.Fatalf("ship order: got status %d, order %+v", status, shipped)
+	}
+	var split Order
+	status = api.do(t, "POST", "/api/orders/"+order.ID+"/split", OrderSplit{
+		Items: []ShipmentLine{{OrderItemID: edited.Items[0].ID, Quantity: 2}},
+	}, &split)
+	if status != http.StatusCreated || split.Total != 50 {
+		t.Fatalf("split order: got status %d, order %+v", status, split)
+	}
+	var shipments []Shipment
+	if api.do(t, "GET", "/api/orders/"+order.ID+"/shipments", nil, &shipments); len(shipments) != 1 {
+		t.Fatalf("unexpected shipments %+v", shipments)
+	}
+
+	var returned Order
+	status = api.do(t, "POST", "/api/orders/"+order.ID+"/returns", NewReturn{
+		Items:  []ReturnLine{{OrderItemID: edited.Items[0].ID, Quantity: 1, Condition: "Opened"}},
+		Reason: "Wrong size",
+		Refund: 10,
+	}, &returned)
+	if status != http.StatusCreated || returned.Refunded != 10 || returned.BalanceDue != 15 || returned.Items[0].Returned != 1 {
+		t.Fatalf("return items: got status %d, order %+v", status, returned)
+	}
+	var sales SalesSummary
+	if api.do(t, "GET", "/api/analytics/sales", nil, &sales); sales.NetSales != sales.GrossSales-10 {
+		t.Fatalf("unexpected sales summary %+v", sales)
+	}
+
+	var paid Order
+	status = api.do(t, "POST", "/api/orders/"+order.ID+"/payments", NewPayment{Method: "Card", Amount: 10}, &paid)
+	if status != http.StatusCreated || paid.Paid != 10 || paid.PaymentStatus != PaymentPartiallyPaid {
+		t.Fatalf("add payment: got status %d, order %+v", status, paid)
+	}
+	var outstanding []Order
+	if api.do(t, "GET", "/api/orders/outstanding", nil, &outstanding); len(outstanding) != 2 {
+		t.Fatalf("expected the order and its split to be outstanding, got %+v", outstanding)
+	}
+
+	var changed Order
+	status = api.do(t, "PUT", "/api/orders/"+order.ID+"/status", OrderStatusUpdate{Status: "Completed"}, &changed)
+	if status != http.StatusOK || changed.Status != "Completed" {
+		t.Fatalf("update status: got status %d, order %+v", status, changed)
+	}
+
+	if status := api.do(t, "PUT", "/api/orders/"+order.ID+"/status", OrderStatusUpdate{Status: "Shipped"}, &APIError{}); status != http.StatusBadRequest {
+		t.Fatalf("set a shipped status by hand: got status %d", status)
+	}
+
+	if status := api.do(t, "PUT", "/api/orders/missing/status", OrderStatusUpdate{Status: "Completed"}, &APIError{}); status != http.StatusNotFound {
+		t.Fatalf("update missing order: got status %d", status)
+	}
+
+	if status := api.do(t, "DELETE", "/api/orders/"+order.ID, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("delete order: got status %d", status)
+	}
+	if status := api.do(t, "GET", "/api/orders/"+order.ID, nil, &APIError{}); status != http.StatusNotFound {
+		t.Fatalf("get deleted order: got status %d", status)
+	}
+}
+
+func TestOrderTemplates(t *testing.T) {
+	api := newTestAPI(t)
+	productID := addTestProduct(t, api.db, "Potting Soil 50L", 40)
+	items := []OrderItem{{ProductID: productID, Quantity: 10}}
+
+	var template OrderTemplate
+	status := api.do(t, "POST", "/api/order-templates", OrderTemplate{
+		Name: "Green Yards", Items: items, Frequency: FrequencyWeekly, StartDate: time.Now().Format("2006-01-02"),
+	}, &template)
+	if status != http.StatusCreated || template.ID == "" || template.Total != 400 || template.NextRun == "" {
+		t.Fatalf("save template: got status %d, template %+v", status, template)
+	}
+
+	template.Frequency = "yearly"
+	if status := api.do(t, "PUT", "/api/order-templates/"+template.ID, template, &APIError{}); status != http.StatusBadRequest {
+		t.Fatalf("save template with an unknown frequency: got status %d", status)
+	}
+
+	var preview []ScheduledOrder
+	if api.do(t, "GET", "/api/order-templates/preview?days=14", nil, &preview); len(preview) != 2 {
+		t.Fatalf("expected two weekly orders in the preview, got %+v", preview)
+	}
+
+	var order Order
+	status = api.do(t, "POST", "/api/order-templates/"+template.ID+"/orders", nil, &order)
+	if status != http.StatusCreated || order.Name != "Green Yards" || order.Total != 400 {
+		t.Fatalf("create order from template: got status %d, order %+v", status, order)
+	}
+
+	if status := api.do(t, "DELETE", "/api/order-templates/"+template.ID, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("delete template: got status %d", status)
+	}
+	if status := api.do(t, "POST", "/api/order-templates/"+template.ID+"/orders", nil, &APIError{}); status != http.StatusNotFound {
+		t.Fatalf("create order from deleted template: got status %d", status)
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	api := newTestAPI(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+	}{
+		{"order without items", "POST", "/api/orders", NewOrder{Name: "Dana"}},
+		{"order without name", "POST", "/api/orders", NewOrder{Items: []OrderItem{{ProductName: "x", Quantity: 1}}}},
+		{"product without name", "POST", "/api/products", Product{Price: 5}},
+		{"invalid barcode", "POST", "/api/products", Product{Name: "Pot", Barcode: "1234"}},
+		{"malformed JSON", "POST", "/api/stock", "not an object"},
+		{"invalid boolean", "GET", "/api/products?uncategorized=maybe", nil},
+		{"lookup without code", "GET", "/api/lookup", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var apiErr APIError
+			if status := api.do(t, tt.method, tt.path, tt.body, &apiErr); status != http.StatusBadRequest {
+				t.Fatalf("expected status 400, got %d", status)
+			}
+			if apiErr.Error == "" {
+				t.Fatal("expected an error message")
+			}
+		})
+	}
+}
+
+func TestStockCRUD(t *testing.T) {
+	api := newTestAPI(t)
+
+	var item StockItem
+	status := api.do(t, "POST", "/api/stock", StockItem{Name: "Soil", Quantity: 10}, &item)
+	if status != http.StatusCreated || item.ID == "" {
+		t.Fatalf("create stock item: got status %d, item %+v", status, item)
+	}
+
+	item.Quantity = 7
+	var updated StockItem
+	if status := api.do(t, "PUT", "/api/stock/"+item.ID, item, &updated); status != http.StatusOK || updated.Quantity != 7 {
+		t.Fatalf("update stock item: got status %d, item %+v", status, updated)
+	}
+
+	if status := api.do(t, "PUT", "/api/stock/missing", item, &APIError{}); status != http.StatusNotFound {
+		t.Fatalf("update missing stock item: got status %d", status)
+	}
+
+	var items []StockItem
+	api.do(t, "GET", "/api/stock", nil, &items)
+	if len(items) != 1 || items[0].Quantity != 7 {
+		t.Fatalf("unexpected stock items: %+v", items)
+	}
+
+	if status := api.do(t, "DELETE", "/api/stock/"+item.ID, nil, &APIError{}); status != http.StatusBadRequest {
+		t.Fatalf("delete stock item with movements: got status %d", status)
+	}
+
+	var opened StockItem
+	api.do(t, "POST", "/api/stock", StockItem{Name: "Bark", Quantity: 5}, &opened)
+	if status := api.do(t, "DELETE", "/api/stock/"+opened.ID, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("delete stock item with only opening stock: got status %d", status)
+	}
+	if status := api.do(t, "DELETE", "/api/stock/"+opened.ID, nil, &APIError{}); status != http.StatusNotFound {
+		t.Fatalf("delete missing stock item: got status %d", status)
+	}
+}
+
+func TestStockLocations(t *testing.T) {
+	api := newTestAPI(t)
+
+	var item StockItem
+	api.do(t, "POST", "/api/stock", StockItem{Name: "Soil", Quantity: 10}, &item)
+	var shed Location
+	if status := api.do(t, "POST", "/api/locations", Location{Name: "Shed"}, &shed); status != http.StatusCreated || shed.ID == "" {
+		t.Fatalf("create location: got status %d, location %+v", status, shed)
+	}
+
+	var moved StockItem
+	status := api.do(t, "POST", "/api/stock/transfers", StockTransfer{
+		StockItemID: item.ID, FromLocationID: DefaultLocationID, ToLocationID: shed.ID, Quantity: 4,
+	}, &moved)
+	if status != http.StatusOK || moved.Quantity != 10 || len(moved.Locations) != 2 {
+		t.Fatalf("transfer stock: got status %d, item %+v", status, moved)
+	}
+
+	adjustment := StockAdjustment{LocationID: shed.ID, Delta: -5}
+	if status := api.do(t, "POST", "/api/stock/"+item.ID+"/adjustments", adjustment, &APIError{}); status != http.StatusBadRequest {
+		t.Fatalf("remove more than a location holds: got status %d", status)
+	}
+
+	var movements []StockMovement
+	api.do(t, "GET", "/api/stock/movements?item="+item.ID, nil, &movements)
+	if len(movements) != 2 || movements[0].Kind != MovementTransfer || movements[0].MovedBy != "admin" {
+		t.Fatalf("unexpected movements %+v", movements)
+	}
+
+	if status := api.do(t, "DELETE", "/api/locations/"+shed.ID, nil, &APIError{}); status != http.StatusBadRequest {
+		t.Fatalf("delete a location holding stock: got status %d", status)
+	}
+}
+
+func TestStockLots(t *testing.T) {
+	api := newTestAPI(t)
+
+	var item StockItem
+	api.do(t, "POST", "/api/stock", StockItem{Name: "Fertilizer"}, &item)
+	expires := time.Now().AddDate(0, 0, 7).Format(scheduleDateLayout)
+
+	var received StockItem
+	status := api.do(t, "POST", "/api/stock/"+item.ID+"/receipts", StockReceipt{LotNumber: "F-1", ExpiresOn: expires, Quantity: 6}, &received)
+	if status != http.StatusOK || received.Quantity != 6 {
+		t.Fatalf("receive stock: got status %d, item %+v", status, received)
+	}
+	for _, receipt := range []StockReceipt{{Quantity: 1}, {LotNumber: "F-2", ExpiresOn: "next week", Quantity: 1}} {
+		if status := api.do(t, "POST", "/api/stock/"+item.ID+"/receipts", receipt, &APIError{}); status != http.StatusBadRequest {
+			t.Fatalf("receipt %+v: got status %d", receipt, status)
+		}
+	}
+
+	var lots []Lot
+	if api.do(t, "GET", "/api/stock/"+item.ID+"/lots", nil, &lots); len(lots) != 1 || lots[0].ExpiresOn != expires {
+		t.Fatalf("unexpected lots %+v", lots)
+	}
+	var expiring []Lot
+	if api.do(t, "GET", "/api/lots/expiring?days=14", nil, &expiring); len(expiring) != 1 || expiring[0].StockItemName != "Fertilizer" {
+		t.Fatalf("unexpected expiring lots %+v", expiring)
+	}
+	if api.do(t, "GET", "/api/lots/expiring?days=3", nil, &expiring); len(expiring) != 0 {
+		t.Fatalf("expected no lots expiring within 3 days, got %+v", expiring)
+	}
+
+	var movements []StockMovement
+	api.do(t, "GET", "/api/stock/movements?item="+item.ID, nil, &movements)
+	if len(movements) != 1 || movements[0].Kind != MovementReceipt || movements[0].LotNumber != "F-1" {
+		t.Fatalf("unexpected movements %+v", movements)
+	}
+	var shipments []LotShipment
+	if status := api.do(t, "GET", "/api/lots/"+lots[0].ID+"/shipments", nil, &shipments); status != http.StatusOK || len(shipments) != 0 {
+		t.Fatalf("lot shipments: got status %d, shipments %+v", status, shipments)
+	}
+}
+
+func TestStockCounts(t *testing.T) {
+	api := newTestAPI(t)
+
+	var item StockItem
+	api.do(t, "POST", "/api/stock", StockItem{Name: "Seed trays", Quantity: 20}, &item)
+
+	var count StockCount
+	status := api.do(t, "POST", "/api/stock-counts", NewStockCount{Name: "Trays", StockItemIDs: []string{item.ID}}, &count)
+	if status != http.StatusCreated || count.ID == "" || len(count.Lines) != 1 || count.Lines[0].Expected != 20 || count.Lines[0].Counted != nil {
+		t.Fatalf("create stock count: got status %d, count %+v", status, count)
+	}
+
+	status = api.do(t, "PUT", "/api/stock-counts/"+count.ID+"/lines", []StockCountEntry{{StockItemID: item.ID, Counted: 17}}, &count)
+	if status != http.StatusOK || count.Variances != 1 || count.UnitsShort != 3 || count.Lines[0].CountedBy != "admin" {
+		t.Fatalf("record counts: got status %d, count %+v", status, count)
+	}
+
+	if status := api.do(t, "POST", "/api/stock-counts/"+count.ID+"/post", nil, &count); status != http.StatusOK || count.Status != StockCountPosted {
+		t.Fatalf("post stock count: got status %d, count %+v", status, count)
+	}
+	var stock []StockItem
+	if api.do(t, "GET", "/api/stock", nil, &stock); len(stock) != 1 || stock[0].Quantity != 17 {
+		t.Fatalf("expected 17 after posting, got %+v", stock)
+	}
+	if status := api.do(t, "DELETE", "/api/stock-counts/"+count.ID, nil, &APIError{}); status != http.StatusBadRequest {
+		t.Fatalf("delete a posted count: got status %d", status)
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	api := newTestAPI(t)
+
+	var doc struct {
+		OpenAPI    string                            `json:"openapi"`
+		Paths      map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}
+	if status := api.do(t, "GET", "/api/openapi.json", nil, &doc); status != http.StatusOK {
+		t.Fatalf("openapi document: got status %d", status)
+	}
+
+	if doc.OpenAPI == "" {
+		t.Fatal("missing openapi version")
+	}
+	for _, path := range []string{"/api/products", "/api/products/{id}", "/api/orders", "/api/stock/{id}"} {
+		if _, ok := doc.Paths[path]; !ok {
+			t.Errorf("missing path %s", path)
+		}
+	}
+	if _, ok := doc.Paths["/api/orders/{id}/status"]["put"]; !ok {
+		t.Error("missing PUT /api/orders/{id}/status")
+	}
+	for _, schema := range []string{"Product", "ProductVariant", "Order", "OrderItem", "StockItem", "APIError"} {
+		if _, ok := doc.Components.Schemas[schema]; !ok {
+			t.Errorf("missing schema %s", schema)
+		}
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	api := newTestAPI(t)
+
+	req, _ := http.NewRequest(http.MethodOptions, api.server.URL+"/api/products", nil)
+	req.Header.Set("Origin", "https://shop.example")
+	resp, err := api.server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("preflight failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Fatalf("expected CORS origin *, got %q", got)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	api := newTestAPI(t)
+	anonymous := &testAPI{server: api.server, db: api.db}
+
+	if status := anonymous.do(t, "GET", "/api/products", nil, &APIError{}); status != http.StatusUnauthorized {
+		t.Fatalf("request without token: got status %d", status)
+	}
+	if status := anonymous.do(t, "POST", "/api/auth/setup", LoginRequest{Username: "intruder", Password: "intruder-password"}, &APIError{}); status != http.StatusBadRequest {
+		t.Fatalf("second setup: got status %d", status)
+	}
+	if status := anonymous.do(t, "POST", "/api/auth/login", LoginRequest{Username: "admin", Password: "wrong-password"}, &APIError{}); status != http.StatusUnauthorized {
+		t.Fatalf("login with wrong password: got status %d", status)
+	}
+
+	var me User
+	if status := api.do(t, "GET", "/api/auth/me", nil, &me); status != http.StatusOK || me.Role != RoleAdmin {
+		t.Fatalf("me: got status %d, user %+v", status, me)
+	}
+
+	if status := api.do(t, "POST", "/api/auth/logout", nil, nil); status != http.StatusNoContent {
+		t.Fatalf("logout: got status %d", status)
+	}
+	if status := api.do(t, "GET", "/api/auth/me", nil, &APIError{}); status != http.StatusUnauthorized {
+		t.Fatalf("request after logout: got status %d", status)
+	}
+}
+
+func TestRolePermissions(t *testing.T) {
+	api := newTestAPI(t)
+	staff := api.loginAs(t, RoleStaff)
+	readOnly := api.loginAs(t, RoleReadOnly)
+
+	var product Product
+	api.do(t, "POST", "/api/products", Product{Name: "Rose", Price: 12}, &product)
+
+	if status := readOnly.do(t, "GET", "/api/products", nil, &[]Product{}); status != http.StatusOK {
+		t.Fatalf("read-only list: got status %d", status)
+	}
+	if status := readOnly.do(t, "POST", "/api/products", Product{Name: "Tulip"}, &APIError{}); status != http.StatusForbidden {
+		t.Fatalf("read-only create: got status %d", status)
+	}
+
+	product.Price = 14
+	if status := staff.do(t, "PUT", "/api/products/"+product.ID, product, &Product{}); status != http.StatusOK {
+		t.Fatalf("staff update: got status %d", status)
+	}
+	if status := staff.do(t, "DELETE", "/api/products/"+product.ID, nil, &APIError{}); status != http.StatusForbidden {
+		t.Fatalf("staff delete: got status %d", status)
+	}
+	if status := staff.do(t, "GET", "/api/users", nil, &APIError{}); status != http.StatusForbidden {
+		t.Fatalf("staff list users: got status %d", status)
+	}
+	if status := api.do(t, "DELETE", "/api/products/"+product.ID, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("admin delete: got status %d", status)
+	}
+}
+
+func TestUserManagement(t *testing.T) {
+	api := newTestAPI(t)
+
+	var me User
+	api.do(t, "GET", "/api/auth/me", nil, &me)
+	if status := api.do(t, "PUT", "/api/users/"+me.ID, UserUpdate{Role: RoleStaff}, &APIError{}); status != http.StatusBadRequest {
+		t.Fatalf("demoting the last admin: got status %d", status)
+	}
+
+	var user User
+	if status := api.do(t, "POST", "/api/users", NewUser{Username: "dana", Password: "short", Role: RoleStaff}, &APIError{}); status != http.StatusBadRequest {
+		t.Fatalf("short password: got status %d", status)
+	}
+	if status := api.do(t, "POST", "/api/users", NewUser{Username: "dana", Password: "long-enough", Role: RoleStaff}, &user); status != http.StatusCreated {
+		t.Fatalf("create user: got status %d", status)
+	}
+	if status := api.do(t, "POST", "/api/users", NewUser{Username: "DANA", Password: "long-enough", Role: RoleStaff}, &APIError{}); status != http.StatusBadRequest {
+		t.Fatalf("duplicate username: got status %d", status)
+	}
+
+	var session LoginResponse
+	api.do(t, "POST", "/api/auth/login", LoginRequest{Username: "dana", Password: "long-enough"}, &session)
+	dana := &testAPI{server: api.server, db: api.db, token: session.Token}
+
+	// Changing the password ends existing sessions
+	if status := api.do(t, "PUT", "/api/users/"+user.ID, UserUpdate{Role: RoleAdmin, Password: "another-password"}, &user); status != http.StatusOK || user.Role != RoleAdmin {
+		t.Fatalf("update user: got status %d, user %+v", status, user)
+	}
+	if status := dana.do(t, "GET", "/api/auth/me", nil, &APIError{}); status != http.StatusUnauthorized {
+		t.Fatalf("session after password change: got status %d", status)
+	}
+
+	if status := api.do(t, "DELETE", "/api/users/"+me.ID, nil, &APIError{}); status != http.StatusBadRequest {
+		t.Fatalf("deleting own account: got status %d", status)
+	}
+	if status := api.do(t, "DELETE", "/api/users/"+user.ID, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("delete user: got status %d", status)
+	}
+}
@@ -0,0 +1,489 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// shippableOrderStatuses are the statuses in which an order can still ship or be split
+var shippableOrderStatuses = map[string]bool{"Pending": true, "Processing": true, "Partially Shipped": true}
+
+// shippedOrderStatuses are set from an order's shipments and cannot be set by hand
+var shippedOrderStatuses = map[string]bool{"Partially Shipped": true, "Shipped": true}
+
+// ShipmentLine is a quantity of one order item, to ship or to move to another order
+type ShipmentLine struct {
+	OrderItemID string `json:"orderItemId"`
+	Quantity    int    `json:"quantity"`
+	// StockItemID names the stock item a shipped line is taken from. When it is "", the
+	// stock item linked to the line's variant is used, if any.
+	StockItemID string `json:"stockItemId"`
+}
+
+// NewShipment is the request body for shipping part of an order
+type NewShipment struct {
+	Items []ShipmentLine `json:"items"`
+	Note  string         `json:"note"`
+	// LocationID is the location the shipped stock leaves from, or "" to leave stock
+	// unchanged
+	LocationID string `json:"locationId"`
+}
+
+// OrderSplit is the request body for moving items to a new order
+type OrderSplit struct {
+	Items []ShipmentLine `json:"items"`
+}
+
+// ShipmentItem is a quantity of an order item that left in a shipment
+type ShipmentItem struct {
+	OrderItemID string `json:"orderItemId"`
+	ProductName string `json:"productName"`
+	Quantity    int    `json:"quantity"`
+	// Lots lists the lots the shipped stock was taken from, if it was tracked in lots
+	Lots []LotQuantity `json:"lots"`
+}
+
+// Shipment records items of an order that were sent together
+type Shipment struct {
+	ID        string         `json:"id"`
+	OrderID   string         `json:"orderId"`
+	ShippedAt string         `json:"shippedAt"`
+	ShippedBy string         `json:"shippedBy"`
+	Note      string         `json:"note"`
+	Items     []ShipmentItem `json:"items"`
+	// LocationID and Location name where the stock was taken from, if anywhere
+	LocationID string `json:"locationId"`
+	Location   string `json:"location"`
+}
+
+// initializeShipments creates the shipments and shipment_items tables
+func (db *Database) initializeShipments() error {
+	_, err := db.db.Exec(`CREATE TABLE IF NOT EXISTS shipments (
+		id TEXT PRIMARY KEY,
+		order_id TEXT NOT NULL,
+		shipped_at TEXT NOT NULL,
+		shipped_by TEXT,
+		note TEXT,
+		FOREIGN KEY (order_id) REFERENCES orders(id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create shipments table: %v", err)
+	}
+
+	_, err = db.db.Exec(`CREATE TABLE IF NOT EXISTS shipment_items (
+		id TEXT PRIMARY KEY,
+		shipment_id TEXT NOT NULL,
+		order_item_id TEXT NOT NULL,
+		quantity INTEGER NOT NULL,
+		FOREIGN KEY (shipment_id) REFERENCES shipments(id),
+		FOREIGN KEY (order_item_id) REFERENCES order_items(id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create shipment_items table: %v", err)
+	}
+
+	_, err = db.db.Exec("CREATE INDEX IF NOT EXISTS idx_shipment_items_order_item ON shipment_items(order_item_id)")
+	if err != nil {
+		return fmt.Errorf("failed to create shipment_items index: %v", err)
+	}
+	return nil
+}
+
+// shippedStatus derives an order's status from how much of it has shipped. It returns
+// "" when nothing has shipped, so the status set by hand is kept.
+func shippedStatus(items []OrderItem) string {
+	shipped, remaining := 0, 0
+	for _, item := range items {
+		shipped += item.Shipped
+		remaining += item.Remaining
+	}
+	switch {
+	case shipped == 0:
+		return ""
+	case remaining == 0:
+		return "Shipped"
+	default:
+		return "Partially Shipped"
+	}
+}
+
+// validateShipmentLines checks that each line names an item of the order once and asks
+// for no more than is left to ship
+func validateShipmentLines(order Order, lines []ShipmentLine) error {
+	if !shippableOrderStatuses[order.Status] {
+		return invalidf("an order that is %s cannot ship or be split", order.Status)
+	}
+	if len(lines) == 0 {
+		return invalidf("choose at least one item")
+	}
+
+	seen := make(map[string]bool)
+	for _, line := range lines {
+		item, ok := findOrderItem(order, line.OrderItemID)
+		if !ok {
+			return invalidf("order %s has no item %s", order.ID, line.OrderItemID)
+		}
+		if seen[line.OrderItemID] {
+			return invalidf("%s is listed more than once", item.ProductName)
+		}
+		seen[line.OrderItemID] = true
+
+		if line.Quantity <= 0 {
+			return invalidf("quantity of %s must be positive", item.ProductName)
+		}
+		if line.Quantity > item.Remaining {
+			return invalidf("only %d × %s are left to ship", item.Remaining, item.ProductName)
+		}
+	}
+	return nil
+}
+
+// findOrderItem returns the item of an order with the given ID
+func findOrderItem(order Order, itemID string) (OrderItem, bool) {
+	for _, item := range order.Items {
+		if item.ID == itemID {
+			return item, true
+		}
+	}
+	return OrderItem{}, false
+}
+
+// updateShippedStatus sets an order's status from its shipments, after they changed
+// within a transaction
+func updateShippedStatus(ctx context.Context, tx *sql.Tx, orderID string) error {
+	order, err := loadOrder(ctx, tx, orderID)
+	if err != nil {
+		return err
+	}
+
+	status := shippedStatus(order.Items)
+	if status == "" || status == order.Status {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE orders SET status = ? WHERE id = ?", status, orderID); err != nil {
+		return fmt.Errorf("failed to update order status: %v", err)
+	}
+	return nil
+}
+
+// CreateShipment records that some of an order's items have left, and sets the order to
+// Partially Shipped or Shipped depending on what is left
+func (db *Database) CreateShipment(ctx context.Context, orderID string, shipment NewShipment, shippedBy string) (id string, err error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	order, err := loadOrder(ctx, tx, orderID)
+	if err != nil {
+		return "", err
+	}
+	if err = validateShipmentLines(order, shipment.Items); err != nil {
+		return "", err
+	}
+
+	var from string
+	if shipment.LocationID != "" {
+		if from, err = locationName(ctx, tx, shipment.LocationID); err != nil {
+			return "", err
+		}
+	}
+
+	id = uuid.New().String()
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO shipments (id, order_id, shipped_at, shipped_by, note, location_id) VALUES (?, ?, ?, ?, ?, ?)",
+		id, orderID, time.Now().Format(priceTimeLayout), nullIfEmpty(shippedBy), nullIfEmpty(shipment.Note),
+		nullIfEmpty(shipment.LocationID),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to insert shipment: %v", err)
+	}
+
+	var changes, taken []string
+	for _, line := range shipment.Items {
+		shipmentItemID := uuid.New().String()
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO shipment_items (id, shipment_id, order_item_id, quantity) VALUES (?, ?, ?, ?)",
+			shipmentItemID, id, line.OrderItemID, line.Quantity,
+		)
+		if err != nil {
+			return "", fmt.Errorf("failed to insert shipment item: %v", err)
+		}
+
+		item, _ := findOrderItem(order, line.OrderItemID)
+		change := fmt.Sprintf("Shipped %d × %s", line.Quantity, item.ProductName)
+
+		if shipment.LocationID != "" {
+			var stockItemID string
+			if stockItemID, err = shipmentStockItem(ctx, tx, line, item); err != nil {
+				return "", err
+			}
+			if stockItemID != "" {
+				var lots []LotQuantity
+				lots, err = moveStock(ctx, tx, StockMovement{
+					StockItemID: stockItemID, Kind: MovementShipment, FromLocationID: shipment.LocationID,
+					Quantity: float64(line.Quantity), Reference: "Order #" + orderID, MovedBy: shippedBy,
+				})
+				if err != nil {
+					return "", err
+				}
+				if err = insertShipmentLots(ctx, tx, shipmentItemID, lots); err != nil {
+					return "", err
+				}
+				taken = append(taken, stockItemID)
+				change += " from " + from
+				if len(lots) > 0 {
+					change += " (lot " + lotNumbers(lots) + ")"
+				}
+			}
+		}
+		changes = append(changes, change)
+	}
+
+	if err = updateShippedStatus(ctx, tx, orderID); err != nil {
+		return "", err
+	}
+	if err = insertOrderChanges(ctx, tx, orderID, shippedBy, changes); err != nil {
+		return "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	db.log.Debug("Shipment recorded", "order", orderID, "shipment", id, "lines", len(shipment.Items), "location", shipment.LocationID)
+	db.publish(EntityOrder, ActionUpdated, orderID)
+	// Stock was taken, and what the order still reserves went down
+	db.publishReservations(orderID, taken)
+	return id, nil
+}
+
+// GetShipments returns the shipments of an order, oldest first
+func (db *Database) GetShipments(ctx context.Context, orderID string) ([]Shipment, error) {
+	// Items are read first, because the read pool may not have a second connection
+	// free while the shipment rows are open
+	lotsByItem, err := queryShipmentLots(ctx, db.read, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	itemRows, err := db.read.QueryContext(ctx, `
+		SELECT si.id, si.shipment_id, si.order_item_id, oi.name, si.quantity
+		FROM shipment_items si
+		JOIN shipments s ON s.id = si.shipment_id
+		JOIN order_items oi ON oi.id = si.order_item_id
+		WHERE s.order_id = ?
+		ORDER BY si.rowid
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shipment items: %v", err)
+	}
+	defer itemRows.Close()
+
+	itemsByShipment := make(map[string][]ShipmentItem)
+	for itemRows.Next() {
+		var id, shipmentID string
+		var item ShipmentItem
+		if err := itemRows.Scan(&id, &shipmentID, &item.OrderItemID, &item.ProductName, &item.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan shipment item: %v", err)
+		}
+		item.Lots = lotsByItem[id]
+		itemsByShipment[shipmentID] = append(itemsByShipment[shipmentID], item)
+	}
+	if err := itemRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating shipment items: %v", err)
+	}
+	itemRows.Close()
+
+	rows, err := db.read.QueryContext(ctx, `
+		SELECT s.id, s.order_id, s.shipped_at, s.shipped_by, s.note, s.location_id, l.name
+		FROM shipments s LEFT JOIN locations l ON l.id = s.location_id
+		WHERE s.order_id = ?
+		ORDER BY s.shipped_at, s.rowid
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shipments: %v", err)
+	}
+	defer rows.Close()
+
+	shipments := []Shipment{}
+	for rows.Next() {
+		var shipment Shipment
+		var shippedBy, note, locationID, location sql.NullString
+		if err := rows.Scan(&shipment.ID, &shipment.OrderID, &shipment.ShippedAt, &shippedBy, &note, &locationID, &location); err != nil {
+			return nil, fmt.Errorf("failed to scan shipment: %v", err)
+		}
+		shipment.ShippedBy, shipment.Note = shippedBy.String, note.String
+		shipment.LocationID, shipment.Location = locationID.String, location.String
+		shipment.Items = itemsByShipment[shipment.ID]
+		shipments = append(shipments, shipment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating shipments: %v", err)
+	}
+
+	return shipments, nil
+}
+
+// SplitOrder moves unshipped quantities of an order's items to a new pending order and
+// returns its ID. A line moved completely is removed unless part of it has shipped, and
+// the original order keeps at least one line.
+func (db *Database) SplitOrder(ctx context.Context, orderID string, split OrderSplit, splitBy string) (newID string, err error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	order, err := loadOrder(ctx, tx, orderID)
+	if err != nil {
+		return "", err
+	}
+	if err = validateShipmentLines(order, split.Items); err != nil {
+		return "", err
+	}
+
+	moving := make(map[string]int)
+	for _, line := range split.Items {
+		moving[line.OrderItemID] = line.Quantity
+	}
+
+	var kept, moved []OrderItem
+	for _, item := range order.Items {
+		quantity, ok := moving[item.ID]
+		if !ok {
+			kept = append(kept, item)
+			continue
+		}
+		// Moved lines keep the product data and price they were sold with
+		moved = append(moved, OrderItem{
+			ProductID: item.ProductID, VariantID: item.VariantID, ProductName: item.ProductName,
+			Price: item.Price, Quantity: quantity, Description: item.Description, SKU: item.SKU,
+			ListPrice: item.ListPrice, OverrideReason: item.OverrideReason,
+		})
+		if item.Quantity-quantity > 0 {
+			item.Quantity -= quantity
+			kept = append(kept, item)
+		}
+	}
+	if len(kept) == 0 {
+		return "", invalidf("leave at least one item in order %s", orderID)
+	}
+	paid := toCents(order.Paid) - toCents(order.RefundsPaidOut)
+	if toCents(orderTotal(kept)) < paid {
+		return "", invalidf("order %s has %s paid, more than would be left in it", orderID, formatAmount(float64(paid)/100))
+	}
+
+	name := fmt.Sprintf("%s (split from #%s)", order.Name, orderID)
+	newID, err = db.insertOrder(ctx, tx, name, order.Description, moved)
+	if err != nil {
+		return "", err
+	}
+
+	var changes []string
+	for _, item := range order.Items {
+		quantity, ok := moving[item.ID]
+		if !ok {
+			continue
+		}
+		if quantity == item.Quantity {
+			_, err = tx.ExecContext(ctx, "DELETE FROM order_items WHERE id = ?", item.ID)
+		} else {
+			_, err = tx.ExecContext(ctx, "UPDATE order_items SET quantity = quantity - ? WHERE id = ?", quantity, item.ID)
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to move order item: %v", err)
+		}
+		changes = append(changes, fmt.Sprintf("Moved %d × %s to order #%s", quantity, item.ProductName, newID))
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE orders SET total = ? WHERE id = ?", orderTotal(kept), orderID); err != nil {
+		return "", fmt.Errorf("failed to update order total: %v", err)
+	}
+	if err = updateShippedStatus(ctx, tx, orderID); err != nil {
+		return "", err
+	}
+	if err = insertOrderChanges(ctx, tx, orderID, splitBy, changes); err != nil {
+		return "", err
+	}
+	if err = insertOrderChanges(ctx, tx, newID, splitBy, []string{"Split from order #" + orderID}); err != nil {
+		return "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	db.log.Debug("Order split", "order", orderID, "new", newID, "lines", len(moved))
+	db.publish(EntityOrder, ActionUpdated, orderID)
+	db.publish(EntityOrder, ActionCreated, newID)
+	db.publishReservations(orderID, nil)
+	db.publishReservations(newID, nil)
+	return newID, nil
+}
+
+// ShipOrder records a shipment of some of an order's items
+func (a *App) ShipOrder(orderID string, shipment NewShipment) bool {
+	ctx, logger, db, done := a.call("ShipOrder")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error shipping order", "err", err)
+		return false
+	}
+
+	id, err := db.CreateShipment(ctx, orderID, shipment, a.currentUser().Username)
+	if err != nil {
+		logger.Error("Error shipping order", "order", orderID, "err", err)
+		return false
+	}
+	logger.Info("Order shipped", "order", orderID, "shipment", id)
+	return true
+}
+
+// GetShipments returns the shipments of an order, oldest first
+func (a *App) GetShipments(orderID string) []Shipment {
+	ctx, logger, db, done := a.call("GetShipments")
+	defer done()
+
+	shipments, err := db.GetShipments(ctx, orderID)
+	if err != nil {
+		logger.Error("Error getting shipments", "err", err)
+		return []Shipment{}
+	}
+	return shipments
+}
+
+// SplitOrder moves the given quantities of an order's items to a new order and returns
+// its ID, or "" if the split failed
+func (a *App) SplitOrder(orderID string, split OrderSplit) string {
+	ctx, logger, db, done := a.call("SplitOrder")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error splitting order", "err", err)
+		return ""
+	}
+
+	newID, err := db.SplitOrder(ctx, orderID, split, a.currentUser().Username)
+	if err != nil {
+		logger.Error("Error splitting order", "order", orderID, "err", err)
+		return ""
+	}
+	logger.Info("Order split", "order", orderID, "new", newID)
+	return newID
+}
@@ -0,0 +1,560 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stock count statuses. An open count takes counted quantities; a posted count has
+// applied its variances to stock and can no longer change.
+const (
+	StockCountOpen   = "Open"
+	StockCountPosted = "Posted"
+)
+
+// NewStockCount is the request body for starting a stock count
+type NewStockCount struct {
+	Name string `json:"name"`
+	// LocationID is the location being counted, or "" for the default location
+	LocationID string `json:"locationId"`
+	// StockItemIDs are the items to count, or none to count every stock item
+	StockItemIDs []string `json:"stockItemIds"`
+}
+
+// StockCountEntry is a counted quantity of one stock item
+type StockCountEntry struct {
+	StockItemID string  `json:"stockItemId"`
+	Counted     float64 `json:"counted"`
+}
+
+// StockCountLine is one stock item of a stock count
+type StockCountLine struct {
+	StockItemID   string `json:"stockItemId"`
+	StockItemName string `json:"stockItemName"`
+	SKU           string `json:"sku"`
+	// Expected is the quantity at the location when the item was counted, or when the
+	// count started while it has not been counted
+	Expected float64 `json:"expected"`
+	// Counted is nil until the item has been counted
+	Counted   *float64 `json:"counted"`
+	CountedAt string   `json:"countedAt"`
+	CountedBy string   `json:"countedBy"`
+	// Variance is Counted less Expected, or 0 while the item has not been counted
+	Variance float64 `json:"variance"`
+}
+
+// StockCount is a stock-take of one location, counted over as many days as it takes
+// and posted as adjustments once its variances have been reviewed
+type StockCount struct {
+	ID         string           `json:"id"`
+	Name       string           `json:"name"`
+	LocationID string           `json:"locationId"`
+	Location   string           `json:"location"`
+	Status     string           `json:"status"`
+	StartedAt  string           `json:"startedAt"`
+	StartedBy  string           `json:"startedBy"`
+	PostedAt   string           `json:"postedAt"`
+	PostedBy   string           `json:"postedBy"`
+	Lines      []StockCountLine `json:"lines"`
+	// Counted is the number of lines counted so far, and Variances the number of those
+	// that differ from what was expected
+	Counted   int `json:"counted"`
+	Variances int `json:"variances"`
+	// UnitsOver and UnitsShort add up the positive and negative variances
+	UnitsOver  float64 `json:"unitsOver"`
+	UnitsShort float64 `json:"unitsShort"`
+}
+
+// initializeStockCounts creates the stock_counts and stock_count_lines tables
+func (db *Database) initializeStockCounts() error {
+	_, err := db.db.Exec(`CREATE TABLE IF NOT EXISTS stock_counts (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		location_id TEXT NOT NULL,
+		status TEXT NOT NULL,
+		started_at TEXT NOT NULL,
+		started_by TEXT,
+		posted_at TEXT,
+		posted_by TEXT
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create stock_counts table: %v", err)
+	}
+
+	_, err = db.db.Exec(`CREATE TABLE IF NOT EXISTS stock_count_lines (
+		count_id TEXT NOT NULL,
+		stock_item_id TEXT NOT NULL,
+		expected REAL NOT NULL,
+		counted REAL,
+		counted_at TEXT,
+		counted_by TEXT,
+		PRIMARY KEY (count_id, stock_item_id),
+		FOREIGN KEY (count_id) REFERENCES stock_counts(id),
+		FOREIGN KEY (stock_item_id) REFERENCES stock_items(id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create stock_count_lines table: %v", err)
+	}
+	return nil
+}
+
+// CreateStockCount starts counting stock items at a location and returns the count's
+// ID. The quantities held there now are kept as the expected quantities. An item can
+// only be in one open count per location at a time.
+func (db *Database) CreateStockCount(ctx context.Context, count NewStockCount, startedBy string) (id string, err error) {
+	count.Name = strings.TrimSpace(count.Name)
+	if count.Name == "" {
+		return "", invalidf("a stock count must have a name")
+	}
+	count.LocationID = firstNonEmpty(count.LocationID, DefaultLocationID)
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = locationName(ctx, tx, count.LocationID); err != nil {
+		return "", err
+	}
+
+	itemIDs := count.StockItemIDs
+	if len(itemIDs) == 0 {
+		if itemIDs, err = stockItemIDs(ctx, tx); err != nil {
+			return "", err
+		}
+	}
+	if len(itemIDs) == 0 {
+		err = invalidf("there are no stock items to count")
+		return "", err
+	}
+
+	// Stock counts are numbered like orders so they can be referred to on the floor
+	var maxID int
+	if err = tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(CAST(id AS INTEGER)), 0) FROM stock_counts").Scan(&maxID); err != nil {
+		return "", fmt.Errorf("failed to get max stock count ID: %v", err)
+	}
+	id = strconv.Itoa(maxID + 1)
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO stock_counts (id, name, location_id, status, started_at, started_by) VALUES (?, ?, ?, ?, ?, ?)",
+		id, count.Name, count.LocationID, StockCountOpen, time.Now().Format(priceTimeLayout), nullIfEmpty(startedBy),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to insert stock count: %v", err)
+	}
+
+	for _, itemID := range itemIDs {
+		var name string
+		var openCount sql.NullString
+		err = tx.QueryRowContext(ctx, `
+			SELECT s.name, (
+				SELECT c.id FROM stock_count_lines cl JOIN stock_counts c ON c.id = cl.count_id
+				WHERE cl.stock_item_id = s.id AND c.location_id = ? AND c.status = ? AND c.id <> ?
+			)
+			FROM stock_items s WHERE s.id = ?
+		`, count.LocationID, StockCountOpen, id, itemID).Scan(&name, &openCount)
+		if err == sql.ErrNoRows {
+			err = &NotFoundError{Kind: "stock item", ID: itemID}
+			return "", err
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to query stock item: %v", err)
+		}
+		if openCount.Valid {
+			err = invalidf("%s is already being counted there in stock count %s", name, openCount.String)
+			return "", err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO stock_count_lines (count_id, stock_item_id, expected)
+			SELECT ?1, ?2, COALESCE((SELECT quantity FROM stock_levels WHERE stock_item_id = ?2 AND location_id = ?3), 0)
+		`, id, itemID, count.LocationID)
+		if err != nil {
+			if strings.Contains(err.Error(), "UNIQUE") {
+				err = invalidf("stock item %s is listed twice", itemID)
+				return "", err
+			}
+			return "", fmt.Errorf("failed to insert stock count line: %v", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	db.log.Debug("Stock count started", "id", id, "location", count.LocationID, "items", len(itemIDs))
+	return id, nil
+}
+
+// stockItemIDs returns the IDs of every stock item by name within a transaction
+func stockItemIDs(ctx context.Context, tx *sql.Tx) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM stock_items ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stock items: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan stock item: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating stock items: %v", err)
+	}
+	return ids, nil
+}
+
+// loadStockCount reads a stock count and its lines within a transaction
+func loadStockCount(ctx context.Context, tx *sql.Tx, id string) (StockCount, error) {
+	counts, err := queryStockCounts(ctx, tx, "c.id = ?", id)
+	if err != nil {
+		return StockCount{}, err
+	}
+	if len(counts) == 0 {
+		return StockCount{}, &NotFoundError{Kind: "stock count", ID: id}
+	}
+	return counts[0], nil
+}
+
+// RecordStockCounts saves counted quantities on an open stock count. Counting an item
+// again replaces its earlier count. Each item's expected quantity is taken again as it
+// is counted, so stock that moved since the count started is not counted twice.
+func (db *Database) RecordStockCounts(ctx context.Context, id string, entries []StockCountEntry, countedBy string) (err error) {
+	if len(entries) == 0 {
+		return invalidf("no counted quantities given")
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	count, err := loadStockCount(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if count.Status != StockCountOpen {
+		err = invalidf("stock count %s has been posted and can no longer change", id)
+		return err
+	}
+
+	countedAt := time.Now().Format(priceTimeLayout)
+	for _, entry := range entries {
+		if entry.Counted < 0 {
+			err = invalidf("counted quantity cannot be negative")
+			return err
+		}
+		result, err := tx.ExecContext(ctx, `
+			UPDATE stock_count_lines SET counted = ?, counted_at = ?, counted_by = ?, expected = COALESCE((
+				SELECT quantity FROM stock_levels WHERE stock_item_id = ?5 AND location_id = ?6
+			), 0)
+			WHERE count_id = ?4 AND stock_item_id = ?5
+		`, entry.Counted, countedAt, nullIfEmpty(countedBy), id, entry.StockItemID, count.LocationID)
+		if err != nil {
+			return fmt.Errorf("failed to update stock count line: %v", err)
+		}
+		if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+			return invalidf("stock item %s is not part of stock count %s", entry.StockItemID, id)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	db.log.Debug("Stock counted", "count", id, "items", len(entries))
+	return nil
+}
+
+// PostStockCount applies the variances of a stock count's counted lines to the stock
+// at its location, all at once, as Count movements. Lines not counted are left alone.
+// Stock that moved since an item was counted is kept: each item changes by its variance
+// rather than being set to the counted quantity.
+func (db *Database) PostStockCount(ctx context.Context, id string, postedBy string) (err error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	count, err := loadStockCount(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if count.Status != StockCountOpen {
+		err = invalidf("stock count %s has already been posted", id)
+		return err
+	}
+	if count.Counted == 0 {
+		err = invalidf("stock count %s has no counted items to post", id)
+		return err
+	}
+
+	var adjusted []string
+	for _, line := range count.Lines {
+		if line.Counted == nil || line.Variance == 0 {
+			continue
+		}
+		movement := adjustmentMovement(line.StockItemID, count.LocationID, line.Variance)
+		movement.Kind, movement.Reference, movement.MovedBy = MovementCount, "Stock count #"+id, postedBy
+		if _, err = moveStock(ctx, tx, movement); err != nil {
+			return err
+		}
+		adjusted = append(adjusted, line.StockItemID)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE stock_counts SET status = ?, posted_at = ?, posted_by = ? WHERE id = ?",
+		StockCountPosted, time.Now().Format(priceTimeLayout), nullIfEmpty(postedBy), id)
+	if err != nil {
+		return fmt.Errorf("failed to update stock count: %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	db.log.Debug("Stock count posted", "id", id, "adjusted", len(adjusted))
+	for _, stockItemID := range adjusted {
+		db.publish(EntityStock, ActionUpdated, stockItemID)
+	}
+	return nil
+}
+
+// DeleteStockCount abandons an open stock count. Posted counts are kept as the record
+// of the adjustments they made.
+func (db *Database) DeleteStockCount(ctx context.Context, id string) (err error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	count, err := loadStockCount(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if count.Status != StockCountOpen {
+		err = invalidf("stock count %s has been posted and is kept with its adjustments", id)
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM stock_count_lines WHERE count_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete stock count lines: %v", err)
+	}
+	if _, err = tx.ExecContext(ctx, "DELETE FROM stock_counts WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete stock count: %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// queryStockCounts reads the stock counts matching a condition on stock_counts c with
+// their lines and variances, newest first
+func queryStockCounts(ctx context.Context, q queryer, where string, args ...any) ([]StockCount, error) {
+	// Read the lines first so that only one query is open at a time
+	rows, err := q.QueryContext(ctx, `
+		SELECT cl.count_id, cl.stock_item_id, COALESCE(s.name, ''), COALESCE(s.sku, ''), cl.expected, cl.counted,
+			cl.counted_at, cl.counted_by
+		FROM stock_count_lines cl LEFT JOIN stock_items s ON s.id = cl.stock_item_id
+		WHERE cl.count_id IN (SELECT c.id FROM stock_counts c WHERE `+where+`)
+		ORDER BY s.name, cl.rowid
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stock count lines: %v", err)
+	}
+	lines := map[string][]StockCountLine{}
+	for rows.Next() {
+		var countID string
+		var line StockCountLine
+		var counted sql.NullFloat64
+		var countedAt, countedBy sql.NullString
+		if err := rows.Scan(&countID, &line.StockItemID, &line.StockItemName, &line.SKU, &line.Expected, &counted,
+			&countedAt, &countedBy); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan stock count line: %v", err)
+		}
+		if counted.Valid {
+			line.Counted = &counted.Float64
+			line.Variance = counted.Float64 - line.Expected
+		}
+		line.CountedAt, line.CountedBy = countedAt.String, countedBy.String
+		lines[countID] = append(lines[countID], line)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating stock count lines: %v", err)
+	}
+
+	rows, err = q.QueryContext(ctx, `
+		SELECT c.id, c.name, c.location_id, COALESCE(l.name, ''), c.status, c.started_at, c.started_by, c.posted_at, c.posted_by
+		FROM stock_counts c LEFT JOIN locations l ON l.id = c.location_id
+		WHERE `+where+`
+		ORDER BY CAST(c.id AS INTEGER) DESC
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stock counts: %v", err)
+	}
+	defer rows.Close()
+
+	counts := []StockCount{}
+	for rows.Next() {
+		var count StockCount
+		var startedBy, postedAt, postedBy sql.NullString
+		if err := rows.Scan(&count.ID, &count.Name, &count.LocationID, &count.Location, &count.Status, &count.StartedAt,
+			&startedBy, &postedAt, &postedBy); err != nil {
+			return nil, fmt.Errorf("failed to scan stock count: %v", err)
+		}
+		count.StartedBy, count.PostedAt, count.PostedBy = startedBy.String, postedAt.String, postedBy.String
+		count.Lines = lines[count.ID]
+		for _, line := range count.Lines {
+			if line.Counted == nil {
+				continue
+			}
+			count.Counted++
+			if line.Variance > 0 {
+				count.Variances++
+				count.UnitsOver += line.Variance
+			} else if line.Variance < 0 {
+				count.Variances++
+				count.UnitsShort -= line.Variance
+			}
+		}
+		counts = append(counts, count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating stock counts: %v", err)
+	}
+	return counts, nil
+}
+
+// GetStockCounts returns every stock count, newest first
+func (db *Database) GetStockCounts(ctx context.Context) ([]StockCount, error) {
+	return queryStockCounts(ctx, db.read, "1 = 1")
+}
+
+// GetStockCount returns a single stock count with its variances
+func (db *Database) GetStockCount(ctx context.Context, id string) (StockCount, error) {
+	counts, err := queryStockCounts(ctx, db.read, "c.id = ?", id)
+	if err != nil {
+		return StockCount{}, err
+	}
+	if len(counts) == 0 {
+		return StockCount{}, &NotFoundError{Kind: "stock count", ID: id}
+	}
+	return counts[0], nil
+}
+
+// GetStockCounts returns every stock count, newest first
+func (a *App) GetStockCounts() []StockCount {
+	ctx, logger, db, done := a.call("GetStockCounts")
+	defer done()
+
+	counts, err := db.GetStockCounts(ctx)
+	if err != nil {
+		logger.Error("Error getting stock counts", "err", err)
+		return []StockCount{}
+	}
+	return counts
+}
+
+// CreateStockCount starts a stock count and returns its ID, or "" on error
+func (a *App) CreateStockCount(count NewStockCount) string {
+	ctx, logger, db, done := a.call("CreateStockCount")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error creating stock count", "err", err)
+		return ""
+	}
+
+	id, err := db.CreateStockCount(ctx, count, a.currentUser().Username)
+	if err != nil {
+		logger.Error("Error creating stock count", "err", err)
+		return ""
+	}
+	logger.Info("Stock count started", "id", id, "location", count.LocationID)
+	return id
+}
+
+// RecordStockCounts saves counted quantities on an open stock count
+func (a *App) RecordStockCounts(id string, entries []StockCountEntry) bool {
+	ctx, logger, db, done := a.call("RecordStockCounts")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error recording stock counts", "err", err)
+		return false
+	}
+
+	if err := db.RecordStockCounts(ctx, id, entries, a.currentUser().Username); err != nil {
+		logger.Error("Error recording stock counts", "id", id, "err", err)
+		return false
+	}
+	logger.Info("Stock counted", "id", id, "items", len(entries))
+	return true
+}
+
+// PostStockCount applies the variances of a stock count to stock
+func (a *App) PostStockCount(id string) bool {
+	ctx, logger, db, done := a.call("PostStockCount")
+	defer done()
+
+	if err := a.authorize(RoleAdmin); err != nil {
+		logger.Error("Error posting stock count", "err", err)
+		return false
+	}
+
+	if err := db.PostStockCount(ctx, id, a.currentUser().Username); err != nil {
+		logger.Error("Error posting stock count", "id", id, "err", err)
+		return false
+	}
+	logger.Info("Stock count posted", "id", id)
+	return true
+}
+
+// DeleteStockCount abandons an open stock count
+func (a *App) DeleteStockCount(id string) bool {
+	ctx, logger, db, done := a.call("DeleteStockCount")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error deleting stock count", "err", err)
+		return false
+	}
+
+	if err := db.DeleteStockCount(ctx, id); err != nil {
+		logger.Error("Error deleting stock count", "id", id, "err", err)
+		return false
+	}
+	logger.Info("Stock count deleted", "id", id)
+	return true
+}
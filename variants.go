@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ProductVariant represents a sellable option of a product, such as a pot size or color
+type ProductVariant struct {
+	ID          string  `json:"id"`
+	ProductID   string  `json:"productId"`
+	SKU         string  `json:"sku"`
+	Barcode     string  `json:"barcode"`
+	Size        string  `json:"size"`
+	PotDiameter float64 `json:"potDiameter"`
+	Color       string  `json:"color"`
+	Price       float64 `json:"price"`
+	StockItemID string  `json:"stockItemId"`
+	Description string  `json:"description"`
+}
+
+// initializeVariants creates the product_variants table and the variant link on order items
+func (db *Database) initializeVariants() error {
+	_, err := db.db.Exec(`CREATE TABLE IF NOT EXISTS product_variants (
+		id TEXT PRIMARY KEY,
+		product_id TEXT NOT NULL,
+		sku TEXT UNIQUE,
+		size TEXT,
+		pot_diameter REAL,
+		color TEXT,
+		price REAL NOT NULL,
+		stock_item_id TEXT,
+		description TEXT,
+		FOREIGN KEY (product_id) REFERENCES products(id),
+		FOREIGN KEY (stock_item_id) REFERENCES stock_items(id)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create product_variants table: %v", err)
+	}
+
+	_, err = db.db.Exec("CREATE INDEX IF NOT EXISTS idx_product_variants_product ON product_variants(product_id)")
+	if err != nil {
+		return fmt.Errorf("failed to create product_variants index: %v", err)
+	}
+
+	// Order items may reference a specific variant of the product
+	hasVariantColumn, err := db.columnExists("order_items", "variant_id")
+	if err != nil {
+		return err
+	}
+	if !hasVariantColumn {
+		_, err = db.db.Exec("ALTER TABLE order_items ADD COLUMN variant_id TEXT")
+		if err != nil {
+			return fmt.Errorf("failed to add variant_id column to order_items table: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// nullIfEmpty converts an empty string to a NULL value for optional columns
+func nullIfEmpty(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+// scanVariants reads product variants from the given rows
+func scanVariants(rows *sql.Rows) ([]ProductVariant, error) {
+	var variants []ProductVariant
+	for rows.Next() {
+		var variant ProductVariant
+		var sku, barcode, size, color, stockItemID, description sql.NullString
+		var potDiameter sql.NullFloat64
+
+		if err := rows.Scan(&variant.ID, &variant.ProductID, &sku, &size, &potDiameter, &color,
+			&variant.Price, &stockItemID, &description, &barcode); err != nil {
+			return nil, fmt.Errorf("failed to scan product variant: %v", err)
+		}
+
+		variant.SKU = sku.String
+		variant.Barcode = barcode.String
+		variant.Size = size.String
+		variant.PotDiameter = potDiameter.Float64
+		variant.Color = color.String
+		variant.StockItemID = stockItemID.String
+		variant.Description = description.String
+
+		variants = append(variants, variant)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating product variants: %v", err)
+	}
+
+	return variants, nil
+}
+
+// variantTitle names a variant after its product and options, such as "Fern (Large, 12cm)"
+func variantTitle(productName string, variant ProductVariant) string {
+	var options []string
+	for _, option := range []string{variant.Size, variant.Color} {
+		if option != "" {
+			options = append(options, option)
+		}
+	}
+	if variant.PotDiameter > 0 {
+		options = append(options, fmt.Sprintf("%gcm", variant.PotDiameter))
+	}
+	if len(options) == 0 {
+		return productName
+	}
+	return productName + " (" + strings.Join(options, ", ") + ")"
+}
+
+// variantColumns lists the columns read by scanVariants
+const variantColumns = "id, product_id, sku, size, pot_diameter, color, price, stock_item_id, description, barcode"
+
+// GetProductVariants retrieves all variants of a product
+func (db *Database) GetProductVariants(ctx context.Context, productID string) ([]ProductVariant, error) {
+	rows, err := db.read.QueryContext(ctx,
+		"SELECT "+variantColumns+" FROM product_variants WHERE product_id = ? ORDER BY pot_diameter, size, color",
+		productID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query product variants: %v", err)
+	}
+	defer rows.Close()
+
+	return scanVariants(rows)
+}
+
+// getVariantsByProduct retrieves the variants of the products matching a WHERE clause on
+// products, grouped by their product ID
+func (db *Database) getVariantsByProduct(ctx context.Context, where string, args ...interface{}) (map[string][]ProductVariant, error) {
+	rows, err := db.read.QueryContext(ctx,
+		"SELECT "+variantColumns+" FROM product_variants WHERE product_id IN (SELECT id FROM products"+where+") "+
+			"ORDER BY pot_diameter, size, color",
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query product variants: %v", err)
+	}
+	defer rows.Close()
+
+	variants, err := scanVariants(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	byProduct := make(map[string][]ProductVariant)
+	for _, variant := range variants {
+		byProduct[variant.ProductID] = append(byProduct[variant.ProductID], variant)
+	}
+	return byProduct, nil
+}
+
+// AddProductVariant adds a new variant to an existing product
+func (db *Database) AddProductVariant(ctx context.Context, variant ProductVariant) (ProductVariant, error) {
+	if variant.ProductID == "" {
+		return ProductVariant{}, invalidf("variant must belong to a product")
+	}
+
+	// Generate a UUID if not provided
+	if variant.ID == "" {
+		variant.ID = uuid.New().String()
+	}
+
+	if err := db.validateIdentifiers(ctx, variant.ID, variant.SKU, variant.Barcode); err != nil {
+		return ProductVariant{}, err
+	}
+
+	_, err := db.db.ExecContext(ctx,
+		"INSERT INTO product_variants ("+variantColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		variant.ID, variant.ProductID, nullIfEmpty(variant.SKU), variant.Size, variant.PotDiameter,
+		variant.Color, variant.Price, nullIfEmpty(variant.StockItemID), variant.Description, nullIfEmpty(variant.Barcode),
+	)
+	if err != nil {
+		return ProductVariant{}, fmt.Errorf("failed to insert product variant: %v", err)
+	}
+
+	// Variants are listed with their product
+	db.publish(EntityProduct, ActionUpdated, variant.ProductID)
+	return variant, nil
+}
+
+// UpdateProductVariant updates an existing product variant
+func (db *Database) UpdateProductVariant(ctx context.Context, variant ProductVariant) error {
+	if err := db.validateIdentifiers(ctx, variant.ID, variant.SKU, variant.Barcode); err != nil {
+		return err
+	}
+
+	result, err := db.db.ExecContext(ctx, `
+		UPDATE product_variants
+		SET sku = ?, barcode = ?, size = ?, pot_diameter = ?, color = ?, price = ?, stock_item_id = ?, description = ?
+		WHERE id = ?
+	`, nullIfEmpty(variant.SKU), nullIfEmpty(variant.Barcode), variant.Size, variant.PotDiameter, variant.Color,
+		variant.Price, nullIfEmpty(variant.StockItemID), variant.Description, variant.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update product variant: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return &NotFoundError{Kind: "product variant", ID: variant.ID}
+	}
+
+	db.publishVariantProduct(variant.ID)
+	return nil
+}
+
+// DeleteProductVariant removes a product variant by ID
+func (db *Database) DeleteProductVariant(ctx context.Context, id string) error {
+	var productID string
+	err := db.read.QueryRowContext(ctx, "SELECT product_id FROM product_variants WHERE id = ?", id).Scan(&productID)
+	if err == sql.ErrNoRows {
+		return &NotFoundError{Kind: "product variant", ID: id}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query product variant: %v", err)
+	}
+
+	result, err := db.db.ExecContext(ctx, "DELETE FROM product_variants WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete product variant: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return &NotFoundError{Kind: "product variant", ID: id}
+	}
+
+	db.publish(EntityProduct, ActionUpdated, productID)
+	return nil
+}
+
+// publishVariantProduct reports a change to the product a variant belongs to
+func (db *Database) publishVariantProduct(variantID string) {
+	if !db.events.HasSubscribers() {
+		return
+	}
+
+	var productID string
+	if err := db.read.QueryRow("SELECT product_id FROM product_variants WHERE id = ?", variantID).Scan(&productID); err != nil {
+		db.log.Warn("Error finding product of variant", "variant", variantID, "err", err)
+		return
+	}
+	db.publish(EntityProduct, ActionUpdated, productID)
+}
+
+// GetProductVariants returns all variants of a product
+func (a *App) GetProductVariants(productID string) []ProductVariant {
+	ctx, logger, db, done := a.call("GetProductVariants")
+	defer done()
+
+	variants, err := db.GetProductVariants(ctx, productID)
+	if err != nil {
+		logger.Error("Error getting product variants", "err", err)
+		return []ProductVariant{}
+	}
+	return variants
+}
+
+// AddProductVariant adds a new variant to a product
+func (a *App) AddProductVariant(variant ProductVariant) ProductVariant {
+	ctx, logger, db, done := a.call("AddProductVariant")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error adding product variant", "err", err)
+		return ProductVariant{}
+	}
+
+	savedVariant, err := db.AddProductVariant(ctx, variant)
+	if err != nil {
+		logger.Error("Error adding product variant", "err", err)
+		return ProductVariant{}
+	}
+	return savedVariant
+}
+
+// UpdateProductVariant updates an existing product variant
+func (a *App) UpdateProductVariant(variant ProductVariant) bool {
+	ctx, logger, db, done := a.call("UpdateProductVariant")
+	defer done()
+
+	if err := a.authorize(RoleStaff); err != nil {
+		logger.Error("Error updating product variant", "err", err)
+		return false
+	}
+
+	err := db.UpdateProductVariant(ctx, variant)
+	if err != nil {
+		logger.Error("Error updating product variant", "err", err)
+		return false
+	}
+	return true
+}
+
+// DeleteProductVariant removes a product variant by ID
+func (a *App) DeleteProductVariant(id string) bool {
+	ctx, logger, db, done := a.call("DeleteProductVariant")
+	defer done()
+
+	if err := a.authorize(RoleAdmin); err != nil {
+		logger.Error("Error deleting product variant", "err", err)
+		return false
+	}
+
+	err := db.DeleteProductVariant(ctx, id)
+	if err != nil {
+		logger.Error("Error deleting product variant", "err", err)
+		return false
+	}
+	return true
+}